@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/iomallach/gchad/internal/client/cli"
+	"github.com/iomallach/gchad/internal/client/infrastructure"
+)
+
+// commandFlags are the flags shared by the commands that skip the TUI
+type commandFlags struct {
+	room      *string
+	server    *string
+	name      *string
+	token     *string
+	transport *string
+}
+
+func newCommandFlags(command string, usage string) (*flag.FlagSet, commandFlags) {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s %s\n", os.Args[0], usage)
+		flags.PrintDefaults()
+	}
+
+	return flags, commandFlags{
+		room:      flags.String("room", "general", "the room to talk to"),
+		server:    flags.String("server", defaultServer, "the host:port of the chat server"),
+		name:      flags.String("name", os.Getenv("USER"), "the name to appear under"),
+		token:     flags.String("moderator-token", os.Getenv(moderatorTokenEnv), moderatorTokenUsage),
+		transport: flags.String("transport", transportWebsocket, transportUsage),
+	}
+}
+
+// client connects to the room under the name given by the flags, the connection being logged
+// along with the TUI ones
+func (f commandFlags) client() (*infrastructure.ChatClient, func(), error) {
+	if *f.name == "" {
+		return nil, nil, errors.New("no name to appear under, see -name")
+	}
+	url, err := serverUrl(*f.server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logFile, logger := openLog()
+	dialer, err := newDialer(*f.transport, logger)
+	if err != nil {
+		logFile.Close()
+		return nil, nil, err
+	}
+	chatClient := newChatClient(dialer, logger, url.WithQueryParam("room", *f.room))
+	chatClient.SetName(*f.name)
+	chatClient.SetModeratorToken(*f.token)
+
+	return chatClient, func() { logFile.Close() }, nil
+}
+
+// runSend posts the arguments as a message, or every line of the standard input when there are none
+func runSend(args []string) int {
+	flags, common := newCommandFlags("send", "send [flags] [message...]")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	chatClient, closeLog, err := common.client()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "send: %v\n", err)
+		return 2
+	}
+	defer closeLog()
+
+	if flags.NArg() > 0 {
+		err = cli.Send(chatClient, strings.Join(flags.Args(), " "))
+	} else {
+		err = cli.SendLines(chatClient, os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "send: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runTail prints what is said in the room until interrupted
+func runTail(args []string) int {
+	flags, common := newCommandFlags("tail", "tail [flags]")
+	format := flags.String("format", string(cli.FormatText), "the output format, text or json lines")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	outputFormat, err := cli.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tail: %v\n", err)
+		return 2
+	}
+	chatClient, closeLog, err := common.client()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tail: %v\n", err)
+		return 2
+	}
+	defer closeLog()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cli.Tail(ctx, chatClient, os.Stdout, outputFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "tail: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -1,55 +1,193 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/gorilla/websocket"
-	"github.com/iomallach/gchad/internal/client/domain"
 	"github.com/iomallach/gchad/internal/client/infrastructure"
 	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/logging"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+const defaultServer = "localhost:8080"
+
 func main() {
-	// Redirect logs to a file to avoid breaking the TUI
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "send":
+			os.Exit(runSend(os.Args[2:]))
+		case "tail":
+			os.Exit(runTail(os.Args[2:]))
+		}
+	}
+
+	room := flag.String("room", "general", "the room to join on login")
+	server := flag.String("server", defaultServer, "the host:port of the chat server")
+	transport := flag.String("transport", transportWebsocket, transportUsage)
+	token := flag.String("moderator-token", os.Getenv(moderatorTokenEnv), moderatorTokenUsage)
+	flag.Parse()
+
+	logFile, logger := openLog()
+	defer logFile.Close()
+
+	configPath, err := infrastructure.DefaultConfigPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to locate the config: %v\n", err)
+		os.Exit(1)
+	}
+	config, err := infrastructure.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load the config: %v\n", err)
+		os.Exit(1)
+	}
+	keymaps, err := ui.DefaultKeymaps().Override(config.Keys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid key bindings in %s: %v\n", configPath, err)
+		os.Exit(1)
+	}
+	theme, err := resolveTheme(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid theme in %s: %v\n", configPath, err)
+		os.Exit(1)
+	}
+	ui.SetTheme(theme)
+
+	dialer, err := newDialer(*transport, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	url, err := serverUrl(*server)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	historyPath, err := infrastructure.DefaultHistoryPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to locate the input history: %v\n", err)
+		os.Exit(1)
+	}
+	history := ui.NewInputHistory(infrastructure.NewFileHistory(historyPath, 1000))
+	if err := history.Load(); err != nil {
+		logger.Error(fmt.Sprintf("failed to load input history: %s", err.Error()), map[string]any{"path": historyPath})
+	}
+	notifier := ui.NewTerminalNotifier(os.Stderr)
+
+	// every room gets a connection and a chat of its own
+	newChatClient := func(room string) *infrastructure.ChatClient {
+		chatClient := newChatClient(dialer, logger, url.WithQueryParam("room", room))
+		chatClient.SetModeratorToken(*token)
+		return chatClient
+	}
+	newChat := func(chatClient *infrastructure.ChatClient) ui.Chat {
+		var transcript ui.TranscriptStore
+		transcriptPath, err := infrastructure.DefaultTranscriptPath(chatClient.Server(), chatClient.Room())
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to locate the transcript, not keeping one: %s", err.Error()), map[string]any{"room": chatClient.Room()})
+		} else {
+			transcript = infrastructure.NewFileTranscript(transcriptPath)
+		}
+
+		return ui.InitialChatModel(
+			keymaps.Chat,
+			chatClient,
+			ui.NewMessageRingBuffer(100),
+			notifier,
+			history,
+			transcript,
+		)
+	}
+
+	chatClient := newChatClient(*room)
+	login := ui.InitialLoginModel("Who are you?", keymaps.Login, chatClient)
+	model := ui.InitialAppModel(
+		login,
+		newChat(chatClient),
+		func(room string) ui.Chat { return newChat(newChatClient(room)) },
+		keymaps.App,
+	)
+	program := tea.NewProgram(model, tea.WithReportFocus())
+	if _, err := program.Run(); err != nil {
+		fmt.Printf("there has been an error: %s", err.Error())
+	}
+}
+
+// openLog sends the logs to a file, keeping them from breaking the TUI or mixing with the output of
+// the commands
+func openLog() (*os.File, logging.Logger) {
 	logFile, err := os.OpenFile("client.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to open log file: %v\n", err)
 		os.Exit(1)
 	}
-	defer logFile.Close()
 
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: logFile})
 	zerolog.SetGlobalLevel(zerolog.DebugLevel)
 
-	logger := infrastructure.NewZeroLogLogger(log.Logger)
-	dialer := infrastructure.NewWebsocketDialer(websocket.DefaultDialer, logger)
-	url := infrastructure.NewUrl(
-		"ws",
-		"localhost",
-		"chat",
-		8080,
-		infrastructure.NewQueryParam("name", ""),
-	)
+	return logFile, infrastructure.NewZeroLogLogger(log.Logger)
+}
+
+// serverUrl points at the chat endpoint of a host:port
+func serverUrl(server string) (infrastructure.Url, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return infrastructure.Url{}, fmt.Errorf("invalid server %q: %w", server, err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return infrastructure.Url{}, fmt.Errorf("invalid server %q: bad port %s", server, port)
+	}
+
+	return infrastructure.NewUrl("ws", host, "chat", portNumber, infrastructure.NewQueryParam("name", "")), nil
+}
+
+const (
+	transportWebsocket = "websocket"
+	transportSSE       = "sse"
+	transportUsage     = "how to reach the server, websocket or sse for networks whose proxies break websockets"
+
+	moderatorTokenEnv   = "GCHAD_MODERATOR_TOKEN"
+	moderatorTokenUsage = "the token proving the name is a moderator's, defaults to $" + moderatorTokenEnv
+)
+
+// newDialer dials over the transport named by the flag
+func newDialer(transport string, logger logging.Logger) (gchad.Dialer, error) {
+	switch transport {
+	case transportWebsocket:
+		return gchad.NewDefaultDialer(logger), nil
+	case transportSSE:
+		return gchad.NewSSEDialer(nil, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q, expected %s or %s", transport, transportWebsocket, transportSSE)
+	}
+}
+
+func newChatClient(dialer gchad.Dialer, logger logging.Logger, url infrastructure.Url) *infrastructure.ChatClient {
 	communications := infrastructure.NewCommunications(
-		make(chan domain.Message, 256),
-		make(chan domain.ChatMessage, 256),
+		make(chan gchad.Message, 256),
 		make(chan error, 256),
 	)
-	chatClient := infrastructure.NewChatClient(
-		dialer,
-		communications,
-		logger,
-		url,
-	)
-	login := ui.InitialLoginModel("Who are you?", ui.DefaultLoginScreenKeymap, chatClient)
-	chat := ui.InitialChatModel(ui.DefaultChatScreenKeymap, chatClient, ui.NewMessageRingBuffer(100))
-	model := ui.InitialAppModel(login, chat, chatClient)
-	program := tea.NewProgram(model)
-	if _, err := program.Run(); err != nil {
-		fmt.Printf("there has been an error: %s", err.Error())
+
+	return infrastructure.NewChatClient(dialer, communications, logger, url)
+}
+
+// resolveTheme honours NO_COLOR unless the config asks for a theme
+func resolveTheme(config infrastructure.Config) (ui.Theme, error) {
+	if config.Theme == "" && os.Getenv("NO_COLOR") != "" {
+		return ui.NoColorTheme, nil
+	}
+
+	custom := make(map[string]ui.CustomTheme, len(config.Themes))
+	for name, theme := range config.Themes {
+		custom[name] = ui.CustomTheme{Base: theme.Base, Colors: theme.Colors, Nicknames: theme.Nicknames}
 	}
+
+	return ui.ResolveTheme(config.Theme, custom)
 }
@@ -0,0 +1,74 @@
+// examplebot echoes and reminds, showing how bots are written with the bot package
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/gchad/bot"
+)
+
+// maxReminder keeps reminders within a day, the bot forgets them when it stops anyway
+const maxReminder = 24 * time.Hour
+
+func main() {
+	server := flag.String("server", "ws://localhost:8080/chat", "the chat endpoint of the server")
+	name := flag.String("name", "helper", "the name of the bot")
+	rooms := flag.String("rooms", "", "comma separated rooms to join, the default one of the server if empty")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var roomIds []string
+	if *rooms != "" {
+		roomIds = strings.Split(*rooms, ",")
+	}
+	if err := newBot(*name).Run(ctx, *server, roomIds...); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newBot(name string) *bot.Bot {
+	b := bot.New(name)
+
+	b.Command("echo", func(ctx *bot.Context) error {
+		if ctx.Text == "" {
+			return ctx.Reply("usage: !echo <text>")
+		}
+		return ctx.Say(ctx.Text)
+	})
+
+	b.Command("remind", func(ctx *bot.Context) error {
+		if len(ctx.Args) < 2 {
+			return ctx.Reply("usage: !remind <duration, e.g. 10m> <what>")
+		}
+		after, err := time.ParseDuration(ctx.Args[0])
+		if err != nil || after <= 0 || after > maxReminder {
+			return ctx.Reply(fmt.Sprintf("%q is not a duration up to %s", ctx.Args[0], maxReminder))
+		}
+		what := strings.TrimSpace(strings.TrimPrefix(ctx.Text, ctx.Args[0]))
+
+		time.AfterFunc(after, func() {
+			// the bot may have stopped in the meantime, the reminder is lost then
+			if err := ctx.Reply("reminder: " + what); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(os.Stderr, "failed to remind %s: %v\n", ctx.Message.From, err)
+			}
+		})
+		return ctx.Reply(fmt.Sprintf("I will remind you in %s", after))
+	})
+
+	b.Mention(func(ctx *bot.Context) error {
+		return ctx.Reply("I know !echo <text> and !remind <duration> <what>")
+	})
+
+	return b
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -12,11 +15,21 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/iomallach/gchad/internal/server/application"
 	"github.com/iomallach/gchad/internal/server/infrastructure"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
+	moderators := flag.String("moderators", "", "comma separated name:token pairs, clients entering under the name with the token being granted the moderator role")
+	deadLetters := flag.String("dead-letters", "dead_letters.jsonl", "where to keep the room events that could not be delivered to subscribers")
+	roomNames := flag.String("rooms", "general,random", "comma separated rooms to host, the first one is the default")
+	compress := flag.Bool("compress", true, "compress the messages of clients supporting permessage-deflate")
+	compressionLevel := flag.Int("compression-level", network.DefaultCompression.Level, "the compress/flate level, from -2 for huffman only to 9 for the best compression")
+	compressionThreshold := flag.Int("compression-threshold", network.DefaultCompression.Threshold, "the size in bytes under which messages are not compressed")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -27,24 +40,78 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	room := application.NewChatRoom("1", "General", application.NewClientRegistry())
+	moderatorTokens, err := parseModerators(*moderators)
+	if err != nil {
+		logger.Error(err.Error(), map[string]any{})
+		os.Exit(1)
+	}
+	rooms := make([]*application.ChatRoom, 0)
+	for _, roomName := range splitList(*roomNames) {
+		room := application.NewChatRoom(roomName, roomName, application.NewClientRegistry())
+		for name, token := range moderatorTokens {
+			room.AddModerator(name, token)
+		}
+		rooms = append(rooms, room)
+	}
+	if len(rooms) == 0 {
+		logger.Error("at least one room is required", map[string]any{})
+		os.Exit(1)
+	}
+	compression := network.Compression{Level: *compressionLevel, Threshold: *compressionThreshold}
+	if err := compression.Validate(); err != nil {
+		logger.Error(err.Error(), map[string]any{})
+		os.Exit(1)
+	}
 	// TODO: maybe the notifier shouldn't be exposed here at all, and shall handle
 	// registration calls via chat service telling it to do so?
 	notifier := infrastructure.NewClientNotifier(logger, make(map[string]*infrastructure.Client))
-	chatService := application.NewChatService(room, notifier, func() time.Time { return time.Now() }, 256, 256, logger)
+	dispatcher := infrastructure.NewHTTPEventDispatcher(
+		infrastructure.NewCallbackClient(),
+		infrastructure.DeliveryConfiguration{
+			Workers:     4,
+			QueueSize:   1024,
+			Timeout:     10 * time.Second,
+			MaxAttempts: 6,
+			MinBackoff:  time.Second,
+			MaxBackoff:  time.Minute,
+		},
+		infrastructure.NewFileDeadLetterLog(*deadLetters),
+		logger,
+	)
+	presenceConfig := application.PresenceConfiguration{
+		AwayAfter:       5 * time.Minute,
+		IdleCheckPeriod: 30 * time.Second,
+	}
+	chatService := application.NewChatService(
+		application.NewRoomRepository(rooms...),
+		application.NewInMemoryMessageStore(10000),
+		application.NewWebhookRegistry(application.RandomToken),
+		application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, dispatcher),
+		notifier,
+		func() time.Time { return time.Now() },
+		presenceConfig,
+		256,
+		256,
+		logger,
+	)
 
 	var upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true },
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		Subprotocols:      protocol.Subprotocols(),
+		EnableCompression: *compress,
 	}
 	clientConfig := infrastructure.ClientConfiguration{
 		WriteWait:       10 * time.Second,
 		PongWait:        60 * time.Second,
 		PingPeriod:      (60 * 9 * time.Second) / 10,
 		RecieveChanWait: 10 * time.Second,
+		HandshakeWait:   time.Second,
+		MaxMessageSize:  32 << 10,
 		SendChannelSize: 256,
 		RecvChannelSize: 256,
+		Compression:     compression,
 	}
 	handler := infrastructure.NewHandler(
 		upgrader,
@@ -56,16 +123,22 @@ func main() {
 		ctx,
 	)
 
+	webhookHandler := infrastructure.NewWebhookHandler(chatService, logger)
+
+	dispatcher.Start(ctx)
 	chatService.Start(ctx)
 
 	http.HandleFunc("/chat", handler.ServeHTTP)
+	http.HandleFunc("GET /events", handler.ServeEvents)
+	http.HandleFunc("POST /messages", handler.ServeMessages)
+	http.Handle("POST /hooks/{token}", webhookHandler)
 
 	server := &http.Server{
 		Addr: ":8080",
 	}
 
 	go func() {
-		logger.Info("server starting, serving the chat at /chat", map[string]any{"port": "8080"})
+		logger.Info("server starting, serving the chat at /chat, or /events and /messages behind proxies breaking websockets, and webhooks at /hooks/{token}", map[string]any{"port": "8080"})
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("server failed", map[string]any{"error": err.Error()})
 		}
@@ -84,3 +157,32 @@ func main() {
 
 	logger.Info("server stopped", map[string]any{})
 }
+
+// splitList splits a comma separated flag value, skipping empty entries
+func splitList(value string) []string {
+	items := make([]string, 0)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseModerators reads the name:token pairs of the moderators flag, a moderator with no token
+// being one nobody could prove to be
+func parseModerators(value string) (map[string]string, error) {
+	moderators := make(map[string]string)
+
+	for _, pair := range splitList(value) {
+		name, token, _ := strings.Cut(pair, ":")
+		if name == "" || token == "" {
+			return nil, fmt.Errorf("moderator %q is to be given as name:token", name)
+		}
+		moderators[name] = token
+	}
+
+	return moderators, nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+var ErrEmptyMessage = errors.New("the message is empty")
+
+// ChatClient is the part of the connection to the server the commands need
+type ChatClient interface {
+	// Connect returns once the server has let the client into the room
+	Connect() error
+	Deliver(message string) error
+	Close() error
+	InboundMessages() <-chan gchad.Message
+	Errors() <-chan error
+}
+
+// Send posts a single message
+func Send(client ChatClient, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return ErrEmptyMessage
+	}
+
+	return deliver(client, func(post func(string) error) error {
+		return post(text)
+	})
+}
+
+// SendLines posts every line read as a message of its own, skipping blank ones, until the input ends
+func SendLines(client ChatClient, input io.Reader) error {
+	return deliver(client, func(post func(string) error) error {
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			if err := post(scanner.Text()); err != nil {
+				return err
+			}
+		}
+
+		return scanner.Err()
+	})
+}
+
+// deliver connects and hangs up once everything posted has been written
+func deliver(client ChatClient, messages func(post func(string) error) error) error {
+	if err := client.Connect(); err != nil {
+		return err
+	}
+
+	err := messages(client.Deliver)
+	if closeErr := client.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to send: %w", closeErr)
+	}
+
+	return err
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+type Format string
+
+const (
+	// FormatText prints a line per message, the lines of a multiline message after the first
+	// one starting with a tab
+	FormatText Format = "text"
+	// FormatJSON prints every message as a JSON object on a line of its own, shaped as the server sends it
+	FormatJSON Format = "json"
+)
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+// jsonCodec prints messages as the JSON codec sends them, whichever one the connection uses
+var jsonCodec = gchad.NewCodec(protocol.SubprotocolJSON)
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatText, FormatJSON:
+		return Format(format), nil
+	}
+
+	return "", fmt.Errorf("unknown format %q, expected text or json", format)
+}
+
+// Tail prints the messages of the room and who comes and goes until the context is done or the
+// connection is lost
+func Tail(ctx context.Context, client ChatClient, out io.Writer, format Format) error {
+	if err := client.Connect(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case msg := <-client.InboundMessages():
+			if err := printMessage(out, msg, format); err != nil {
+				client.Close()
+				return err
+			}
+		case err := <-client.Errors():
+			return fmt.Errorf("lost the connection: %w", err)
+		case <-ctx.Done():
+			return client.Close()
+		}
+	}
+}
+
+func printMessage(out io.Writer, msg gchad.Message, format Format) error {
+	switch msg.(type) {
+	case gchad.ChatMessage, gchad.UserJoinedMessage, gchad.UserLeftMessage, gchad.UserRenamedMessage:
+	default:
+		// the room state and the answers to requests are of no interest to a reader of the feed
+		return nil
+	}
+
+	if format == FormatJSON {
+		return printJSON(out, msg)
+	}
+
+	_, err := fmt.Fprintln(out, textLine(msg))
+	return err
+}
+
+func printJSON(out io.Writer, msg gchad.Message) error {
+	line, err := jsonCodec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, string(line))
+	return err
+}
+
+func textLine(msg gchad.Message) string {
+	switch msg := msg.(type) {
+	case gchad.ChatMessage:
+		text := strings.ReplaceAll(msg.Text, "\n", "\n\t")
+		for _, attachment := range msg.Attachments {
+			text += "\n\t> " + strings.Join(nonEmpty(attachment.Title, attachment.Url, attachment.Text), " ")
+		}
+		return fmt.Sprintf("%s %s: %s", msg.Timestamp.Format(timestampLayout), msg.From, text)
+	case gchad.UserJoinedMessage:
+		return fmt.Sprintf("%s * %s joined", msg.Timestamp.Format(timestampLayout), msg.Name)
+	case gchad.UserLeftMessage:
+		return fmt.Sprintf("%s * %s left", msg.Timestamp.Format(timestampLayout), msg.Name)
+	case gchad.UserRenamedMessage:
+		return fmt.Sprintf("%s * %s is now %s", msg.Timestamp.Format(timestampLayout), msg.OldName, msg.NewName)
+	}
+
+	return ""
+}
+
+func nonEmpty(values ...string) []string {
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, strings.ReplaceAll(value, "\n", " "))
+		}
+	}
+
+	return kept
+}
@@ -1,6 +1,10 @@
 package domain
 
+import "sync"
+
+// ChatStats is counted by the client as messages come and go, while the UI reads a snapshot of it
 type ChatStats struct {
+	mu               sync.Mutex
 	MessagesReceived int
 	MessagesSent     int
 	ClientsInTheRoom int
@@ -11,21 +15,31 @@ func NewChatStats() *ChatStats {
 }
 
 func (s *ChatStats) IncrementReceived() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.MessagesReceived++
 }
 
 func (s *ChatStats) IncrementSent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.MessagesSent++
 }
 
-func (s *ChatStats) IncrementClients() {
-	s.ClientsInTheRoom++
+func (s *ChatStats) ResetClients(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ClientsInTheRoom = n
 }
 
-func (s *ChatStats) DecrementClients() {
-	s.ClientsInTheRoom--
-}
+// Snapshot is a copy of the counts that is safe to read while they keep changing
+func (s *ChatStats) Snapshot() *ChatStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *ChatStats) ResetClients(n int) {
-	s.ClientsInTheRoom = n
+	return &ChatStats{
+		MessagesReceived: s.MessagesReceived,
+		MessagesSent:     s.MessagesSent,
+		ClientsInTheRoom: s.ClientsInTheRoom,
+	}
 }
@@ -1,60 +0,0 @@
-package domain
-
-import (
-	"encoding/json"
-	"time"
-)
-
-type Message interface {
-	MessageType() MessageType
-}
-
-type MessageType string
-
-const (
-	TypeChatMessage       MessageType = "chat"
-	TypeUserJoinedMessage MessageType = "user_joined"
-	TypeUserLeftMessage   MessageType = "user_left"
-	TypeStatsMessage      MessageType = "stats"
-)
-
-type Envelope struct {
-	Type    MessageType     `json:"type"`
-	Payload json.RawMessage `json:"payload"`
-}
-
-type ChatMessage struct {
-	From      string    `json:"from"`
-	Timestamp time.Time `json:"timestamp"`
-	Text      string    `json:"text"`
-}
-
-func (m ChatMessage) MessageType() MessageType {
-	return TypeChatMessage
-}
-
-type UserJoinedMessage struct {
-	Timestamp time.Time `json:"timestamp"`
-	Name      string    `json:"name"`
-}
-
-func (m UserJoinedMessage) MessageType() MessageType {
-	return TypeUserJoinedMessage
-}
-
-type UserLeftMessage struct {
-	Timestamp time.Time `json:"timestamp"`
-	Name      string    `json:"name"`
-}
-
-func (m UserLeftMessage) MessageType() MessageType {
-	return TypeUserLeftMessage
-}
-
-type StatsMessage struct {
-	ClientsOnline int `json:"clients_online"`
-}
-
-func (m StatsMessage) MessageType() MessageType {
-	return TypeStatsMessage
-}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const (
+	searchFromPrefix   = "from:"
+	searchBeforePrefix = "before:"
+)
+
+// searchDateLayouts are tried in order for before:, the ones without a zone meaning local time
+var searchDateLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+var ErrEmptySearch = errors.New("nothing to search for")
+
+// ParseSearch reads "<terms> [from:nick] [before:date]", the filters going anywhere among the terms
+func ParseSearch(input string) (gchad.SearchMessage, error) {
+	request := gchad.SearchMessage{}
+	terms := make([]string, 0)
+
+	for _, field := range strings.Fields(input) {
+		switch {
+		case strings.HasPrefix(field, searchFromPrefix):
+			request.From = strings.TrimPrefix(field, searchFromPrefix)
+
+		case strings.HasPrefix(field, searchBeforePrefix):
+			before, err := parseSearchDate(strings.TrimPrefix(field, searchBeforePrefix))
+			if err != nil {
+				return gchad.SearchMessage{}, err
+			}
+			request.Before = &before
+
+		default:
+			terms = append(terms, field)
+		}
+	}
+
+	request.Terms = strings.Join(terms, " ")
+	if !searchable(request.Terms) && request.From == "" && request.Before == nil {
+		return gchad.SearchMessage{}, ErrEmptySearch
+	}
+
+	return request, nil
+}
+
+// searchable tells whether the terms have a letter or digit, which is all the server indexes
+func searchable(terms string) bool {
+	return strings.IndexFunc(terms, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+func parseSearchDate(value string) (time.Time, error) {
+	for _, layout := range searchDateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown date %q, try 2006-01-02", value)
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// TranscriptEntry is a message as kept in the local transcript of a room
+type TranscriptEntry struct {
+	Id          string             `json:"id,omitempty"`
+	Timestamp   time.Time          `json:"timestamp"`
+	From        string             `json:"from,omitempty"`
+	Text        string             `json:"text"`
+	Mentions    []gchad.Mention    `json:"mentions,omitempty"`
+	System      bool               `json:"system,omitempty"`
+	Webhook     bool               `json:"webhook,omitempty"`
+	Attachments []gchad.Attachment `json:"attachments,omitempty"`
+}
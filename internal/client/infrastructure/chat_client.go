@@ -1,37 +1,23 @@
 package infrastructure
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	neturl "net/url"
+	"strings"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
 	"github.com/iomallach/gchad/pkg/logging"
-	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
 )
 
-type Dialer interface {
-	Dial(url string) (network.Connection, error)
-}
+// sendTimeout is how long the UI waits for room in the outbound queue before dropping a message
+const sendTimeout = 50 * time.Millisecond
 
-type WebsocketsDialer struct {
-	dialer *websocket.Dialer
-	logger logging.Logger
-}
-
-func NewWebsocketDialer(dialer *websocket.Dialer, logger logging.Logger) *WebsocketsDialer {
-	return &WebsocketsDialer{dialer, logger}
-}
-
-func (d *WebsocketsDialer) Dial(url string) (network.Connection, error) {
-	conn, _, err := d.dialer.Dial(url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return network.NewWebsocketsConnection(conn, d.logger), nil
-}
+var ErrNotConnected = errors.New("not connected")
 
 type QueryParam struct {
 	key   string
@@ -43,19 +29,55 @@ func NewQueryParam(key, value string) QueryParam {
 }
 
 func (qp *QueryParam) String() string {
-	return fmt.Sprintf("%s=%s", qp.key, qp.value)
+	return fmt.Sprintf("%s=%s", qp.key, neturl.QueryEscape(qp.value))
 }
 
 type Url struct {
-	schema     string
-	base       string
-	path       string
-	port       int
-	queryParam QueryParam
+	schema      string
+	base        string
+	path        string
+	port        int
+	queryParams []QueryParam
+}
+
+func NewUrl(schema, base, path string, port int, queryParams ...QueryParam) Url {
+	return Url{schema, base, path, port, queryParams}
+}
+
+// WithQueryParam returns a copy of the url with the parameter set, added if it was not there
+func (url Url) WithQueryParam(key, value string) Url {
+	params := make([]QueryParam, 0, len(url.queryParams)+1)
+	found := false
+
+	for _, param := range url.queryParams {
+		if param.key == key {
+			param.value = value
+			found = true
+		}
+		params = append(params, param)
+	}
+	if !found {
+		params = append(params, NewQueryParam(key, value))
+	}
+	url.queryParams = params
+
+	return url
 }
 
-func NewUrl(schema, base, path string, port int, queryParam QueryParam) Url {
-	return Url{schema, base, path, port, queryParam}
+// Server is the host and port the url points at
+func (url *Url) Server() string {
+	return fmt.Sprintf("%s:%d", url.base, url.port)
+}
+
+// QueryParam returns the value of the parameter, or an empty string if it is not set
+func (url *Url) QueryParam(key string) string {
+	for _, param := range url.queryParams {
+		if param.key == key {
+			return param.value
+		}
+	}
+
+	return ""
 }
 
 func (url *Url) String() string {
@@ -65,37 +87,48 @@ func (url *Url) String() string {
 		subUrl = fmt.Sprintf("%s/%s", subUrl, url.path)
 	}
 
-	return fmt.Sprintf("%s?%s", subUrl, url.queryParam.String())
+	if len(url.queryParams) == 0 {
+		return subUrl
+	}
+
+	params := make([]string, 0, len(url.queryParams))
+	for _, param := range url.queryParams {
+		params = append(params, param.String())
+	}
+
+	return fmt.Sprintf("%s?%s", subUrl, strings.Join(params, "&"))
 }
 
+// Communications are the channels the UI reads, outbound messages being queued by the gchad.Client
 type Communications struct {
-	recv   chan domain.Message     // inbound messages
-	send   chan domain.ChatMessage // outbound messages
+	recv   chan gchad.Message // inbound messages
 	errors chan error
 }
 
-func NewCommunications(recv chan domain.Message, send chan domain.ChatMessage, errors chan error) *Communications {
+func NewCommunications(recv chan gchad.Message, errors chan error) *Communications {
 	return &Communications{
 		recv,
-		send,
 		errors,
 	}
 }
 
+// ChatClient adapts a gchad.Client to the channels the UI reads. Unlike the library, it drops what
+// the UI is too busy to take rather than waiting, and does not reconnect.
 type ChatClient struct {
-	conn   network.Connection
-	dialer Dialer
+	client *gchad.Client
+	dialer gchad.Dialer
 	logger logging.Logger
 
 	communications *Communications
 
 	name      string
+	token     string
 	url       Url
 	chatStats *domain.ChatStats
 }
 
 func NewChatClient(
-	dialer Dialer,
+	dialer gchad.Dialer,
 	communications *Communications,
 	logger logging.Logger,
 	url Url,
@@ -109,45 +142,158 @@ func NewChatClient(
 	}
 }
 
+// Connect returns once the server has let the client into the room
 func (c *ChatClient) Connect() error {
-	conn, err := c.dialer.Dial(c.url.String())
+	events := gchad.NewSubscription()
+	client, err := gchad.Dial(
+		context.Background(),
+		c.url.String(),
+		c.name,
+		gchad.WithRoom(c.Room()),
+		gchad.AsModerator(c.token),
+		gchad.WithDialer(c.dialer),
+		gchad.WithLogger(c.logger),
+		gchad.WithReconnect(gchad.NeverReconnect),
+		gchad.WithSubscription(events),
+	)
 	if err != nil {
 		return err
 	}
 	c.logger.Info(fmt.Sprintf("Successfully connected to %s", c.url.String()), map[string]any{})
 
-	c.conn = conn
-	go c.ReadPump()
-	go c.WritePump()
+	c.client = client
+	go c.forward(events)
 
 	return nil
 }
 
+// Disconnect hangs up, giving up on whatever is still queued after a second
 func (c *ChatClient) Disconnect() error {
-	if err := c.conn.SetWriteDeadline(time.Now().Add(time.Second * 90)); err != nil {
-		return err
+	if c.client == nil {
+		return nil
 	}
-	if err := c.conn.WriteCloseMessage([]byte{}); err != nil {
-		return err
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return c.client.Close(ctx)
+}
+
+// Close hangs up once everything queued has been written. Nothing can be sent afterwards.
+func (c *ChatClient) Close() error {
+	return c.client.Close(context.Background())
+}
+
+// SendMessage posts to the room and waits for the server to acknowledge the message, or to turn it
+// down with a gchad.ErrorMessage, for as long as the context lasts. The message is dropped rather
+// than queued for more than sendTimeout.
+func (c *ChatClient) SendMessage(ctx context.Context, message string) (gchad.AckMessage, error) {
+	if c.client == nil {
+		c.logger.Error("failed to send message, not connected", map[string]any{"message_type": string(protocol.Chat)})
+		return gchad.AckMessage{}, ErrNotConnected
+	}
+
+	queueCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	delivery, err := c.client.Post(queueCtx, message)
+	cancel()
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("failed to send message: %s", err.Error()), map[string]any{"message_type": string(protocol.Chat)})
+		return gchad.AckMessage{}, err
 	}
+	c.chatStats.IncrementSent()
+	c.logger.Debug("message sent", map[string]any{"message_type": string(protocol.Chat), "request_id": delivery.RequestId()})
 
-	return c.conn.Close()
+	return delivery.Wait(ctx)
 }
 
-func (c *ChatClient) SendMessage(message string) {
-	select {
-	case c.communications.send <- domain.ChatMessage{
-		From:      c.name,
-		Timestamp: time.Now(),
-		Text:      message,
-	}:
-		c.logger.Debug("message sent", map[string]any{"message": message})
-	case <-time.After(50 * time.Millisecond):
-		c.logger.Error("failed to send message, channel is full", map[string]any{"message": message})
+// Deliver sends the message like SendMessage, but waits for room in the queue rather than dropping
+// the message when the connection falls behind
+func (c *ChatClient) Deliver(message string) error {
+	return c.client.Send(context.Background(), message)
+}
+
+func (c *ChatClient) SetPresence(presence gchad.Presence, statusText string) {
+	c.send(protocol.SetPresence, func(ctx context.Context) error { return c.client.SetPresence(ctx, presence, statusText) })
+}
+
+// ReportActivity lets the server know the user is around, which keeps them from going away
+func (c *ChatClient) ReportActivity() {
+	c.send(protocol.Activity, c.client.ReportActivity)
+}
+
+func (c *ChatClient) Rename(name string) {
+	c.send(protocol.Rename, func(ctx context.Context) error { return c.client.Rename(ctx, name) })
+}
+
+// ListRooms asks the server which rooms there are, the answer arrives as a gchad.RoomListMessage
+func (c *ChatClient) ListRooms() {
+	c.send(protocol.ListRooms, c.client.ListRooms)
+}
+
+// Search asks the server to look through the messages of the room, the answer arrives as a
+// gchad.SearchResultsMessage
+func (c *ChatClient) Search(request gchad.SearchMessage) {
+	c.send(protocol.Search, func(ctx context.Context) error { return c.client.Search(ctx, request) })
+}
+
+// CreateWebhook asks the server for a webhook of the room, the webhooks of the room arriving as
+// a gchad.WebhookListMessage, as they do for RevokeWebhook and ListWebhooks
+func (c *ChatClient) CreateWebhook(name string) {
+	c.send(protocol.CreateWebhook, func(ctx context.Context) error { return c.client.CreateWebhook(ctx, name) })
+}
+
+func (c *ChatClient) RevokeWebhook(token string) {
+	c.send(protocol.RevokeWebhook, func(ctx context.Context) error { return c.client.RevokeWebhook(ctx, token) })
+}
+
+func (c *ChatClient) ListWebhooks() {
+	c.send(protocol.ListWebhooks, c.client.ListWebhooks)
+}
+
+// SubscribeEvents asks the server to post events of the room, the subscriptions of the room arriving as
+// a gchad.EventSubscriptionListMessage, as they do for UnsubscribeEvents and ListEventSubscriptions
+func (c *ChatClient) SubscribeEvents(request gchad.SubscribeEventsMessage) {
+	c.send(protocol.SubscribeEvents, func(ctx context.Context) error { return c.client.SubscribeEvents(ctx, request) })
+}
+
+func (c *ChatClient) UnsubscribeEvents(id string) {
+	c.send(protocol.UnsubscribeEvents, func(ctx context.Context) error { return c.client.UnsubscribeEvents(ctx, id) })
+}
+
+func (c *ChatClient) ListEventSubscriptions() {
+	c.send(protocol.ListEventSubscriptions, c.client.ListEventSubscriptions)
+}
+
+// WebhookUrl is where to post to the webhook with the token, on the server the client talks to
+func (c *ChatClient) WebhookUrl(token string) string {
+	schema := "http"
+	if c.url.schema == "wss" {
+		schema = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/hooks/%s", schema, c.url.Server(), token)
+}
+
+// send gives up after sendTimeout, reporting whether the message has been queued
+func (c *ChatClient) send(messageType protocol.MessageType, send func(ctx context.Context) error) bool {
+	if c.client == nil {
+		c.logger.Error("failed to send message, not connected", map[string]any{"message_type": string(messageType)})
+		return false
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	if err := send(ctx); err != nil {
+		c.logger.Error(fmt.Sprintf("failed to send message: %s", err.Error()), map[string]any{"message_type": string(messageType)})
+		return false
+	}
+	c.logger.Debug("message sent", map[string]any{"message_type": string(messageType)})
+
+	return true
 }
 
-func (c *ChatClient) InboundMessages() <-chan domain.Message {
+func (c *ChatClient) InboundMessages() <-chan gchad.Message {
 	return c.communications.recv
 }
 
@@ -157,13 +303,28 @@ func (c *ChatClient) Errors() <-chan error {
 
 func (c *ChatClient) SetName(name string) {
 	c.name = name
-	c.url.queryParam.value = name
+	c.url = c.url.WithQueryParam("name", name)
+}
+
+// SetModeratorToken proves the client is a moderator of the room, the token staying out of the url
+// shown to the user
+func (c *ChatClient) SetModeratorToken(token string) {
+	c.token = token
+}
+
+// Room is the id of the room the client talks to, an empty one means the server's default
+func (c *ChatClient) Room() string {
+	return c.url.QueryParam("room")
 }
 
 func (c *ChatClient) Host() string {
 	return c.url.String()
 }
 
+func (c *ChatClient) Server() string {
+	return c.url.Server()
+}
+
 func (c *ChatClient) communicateError(err error) {
 	select {
 	case c.communications.errors <- err:
@@ -172,57 +333,17 @@ func (c *ChatClient) communicateError(err error) {
 	}
 }
 
-// TODO: at this point, perhaps, really consider abstracting the reads and error handling
-// away from this structure
-func (c *ChatClient) ReadPump() {
-	defer c.conn.Close()
-
-	for {
-		if err := c.conn.SetReadDeadline(time.Now().Add(time.Second * 90)); err != nil {
-			c.logger.Error(fmt.Sprintf("failed to set read deadline: %s", err.Error()), map[string]any{})
-			c.communicateError(err)
-			return
-		}
-		c.conn.SetPingHandler(func(string) error {
-			err := c.conn.SetReadDeadline(time.Now().Add(time.Second * 90))
-			if err != nil {
-				return err
-			}
-			if err := c.conn.SetWriteDeadline(time.Now().Add(time.Second * 90)); err != nil {
-				return err
-			}
-			return c.conn.WritePongMessage([]byte{})
-		})
-
-		_, msg, err := c.conn.ReadMessage() // the first value is the ws internal code
-		if err != nil {
-			c.logger.Error(fmt.Sprintf("failed to read a message: %s", err.Error()), map[string]any{})
-			c.communicateError(err)
-			return
-		}
-
-		message, err := UnmarshallMessage(msg)
-		if err != nil {
-			c.logger.Error(fmt.Sprintf("failed to unmarshall the message: %s, %s", msg, err.Error()), map[string]any{})
-		}
-
-		switch message.MessageType() {
-		case domain.TypeChatMessage:
-			c.chatStats.IncrementReceived()
-		case domain.TypeUserJoinedMessage:
-			c.chatStats.IncrementClients()
-			c.chatStats.IncrementReceived()
-		case domain.TypeUserLeftMessage:
-			c.chatStats.DecrementClients()
-			c.chatStats.IncrementReceived()
-		case domain.TypeStatsMessage:
-			msg := message.(domain.StatsMessage)
-			c.chatStats.ResetClients(msg.ClientsOnline)
-
-			// Don't expose the stats message to ui, read the next message
-			// The ui would know the client count through the reference to stats
+// forward hands the events of the client over to the UI until the client is done
+func (c *ChatClient) forward(events *gchad.Subscription) {
+	for message := range events.Events() {
+		switch message := message.(type) {
+		case gchad.Disconnected:
+			c.communicateError(message.Err)
 			continue
+		case gchad.ChatMessage, gchad.UserJoinedMessage, gchad.UserLeftMessage, gchad.UserRenamedMessage:
+			c.chatStats.IncrementReceived()
 		}
+		c.chatStats.ResetClients(len(c.client.Members()))
 
 		select {
 		case c.communications.recv <- message:
@@ -233,46 +354,16 @@ func (c *ChatClient) ReadPump() {
 	}
 }
 
-func (c *ChatClient) WritePump() {
-	defer c.conn.Close()
-
-	for msg := range c.communications.send {
-		data, err := json.Marshal(msg)
-		if err != nil {
-			c.logger.Error(fmt.Sprintf("failed to marshall the message: %s with %s", data, err.Error()), map[string]any{})
-			continue
-		}
-
-		envelope := domain.Envelope{
-			Type:    domain.TypeChatMessage,
-			Payload: data,
-		}
-		message, err := json.Marshal(envelope)
-		if err != nil {
-			c.logger.Error(fmt.Sprintf("failed to marshall the message: %s with %s", message, err.Error()), map[string]any{})
-			continue
-		}
-
-		if err := c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
-			c.logger.Error(fmt.Sprintf("failed to set write deadline: %s", err.Error()), map[string]any{})
-			c.communicateError(err)
-			return
-		}
-		if err := c.conn.WriteTextMessage(message); err != nil {
-			c.logger.Error(fmt.Sprintf("failed to write message: %s", err.Error()), map[string]any{})
-			c.communicateError(err)
-			return
-		}
-		if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
-			c.logger.Error(fmt.Sprintf("failed to clear write deadline: %s", err.Error()), map[string]any{})
-			c.communicateError(err)
-			return
-		}
+// Stats are the counts as they are now, the forwarding goroutine going on counting
+func (c *ChatClient) Stats() *domain.ChatStats {
+	return c.chatStats.Snapshot()
+}
 
-		c.chatStats.IncrementSent()
+// Members returns everyone in the room as last synced with the server
+func (c *ChatClient) Members() []gchad.Member {
+	if c.client == nil {
+		return nil
 	}
-}
 
-func (c *ChatClient) Stats() *domain.ChatStats {
-	return c.chatStats
+	return c.client.Members()
 }
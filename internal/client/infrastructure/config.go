@@ -0,0 +1,62 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is the client configuration file. Everything in it is optional.
+type Config struct {
+	// Keys rebinds keys by screen and binding name, e.g. {"chat": {"find": ["ctrl+f"]}}
+	Keys map[string]map[string][]string `json:"keys,omitempty"`
+	// Theme names a built-in theme or one of Themes
+	Theme  string                 `json:"theme,omitempty"`
+	Themes map[string]ThemeConfig `json:"themes,omitempty"`
+}
+
+// ThemeConfig defines a theme on top of another one, changing some of its colors,
+// e.g. {"base": "latte", "colors": {"mauve": "#8839ef"}}
+type ThemeConfig struct {
+	Base      string            `json:"base,omitempty"`
+	Colors    map[string]string `json:"colors,omitempty"`
+	Nicknames []string          `json:"nicknames,omitempty"`
+}
+
+// DefaultConfigPath follows the XDG base directory spec, falling back to ~/.config
+func DefaultConfigPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(configHome, "gchad", "config.json"), nil
+}
+
+// LoadConfig reads the configuration file, a missing one being an empty configuration.
+// Unknown fields are rejected so that typos do not go unnoticed.
+func LoadConfig(path string) (Config, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	var config Config
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return config, nil
+}
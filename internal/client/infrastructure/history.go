@@ -0,0 +1,118 @@
+package infrastructure
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// FileHistory persists sent messages, one JSON encoded string per line,
+// so that entries spanning multiple lines survive the round trip
+type FileHistory struct {
+	path       string
+	maxEntries int
+}
+
+func NewFileHistory(path string, maxEntries int) *FileHistory {
+	return &FileHistory{path, maxEntries}
+}
+
+// DefaultHistoryPath follows the XDG base directory spec, falling back to ~/.local/state
+func DefaultHistoryPath() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(stateHome, "gchad", "history"), nil
+}
+
+// Load returns the most recent entries, oldest first. A missing file is an empty history.
+func (h *FileHistory) Load() ([]string, error) {
+	file, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// skip lines mangled by hand or by a crash halfway through a write
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entries) > h.maxEntries {
+		entries = entries[len(entries)-h.maxEntries:]
+		if err := h.rewrite(entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func (h *FileHistory) Append(entry string) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// rewrite truncates the file down to the given entries
+func (h *FileHistory) rewrite(entries []string) error {
+	tmpPath := h.path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		_, _ = writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, h.path)
+}
@@ -0,0 +1,99 @@
+package infrastructure
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iomallach/gchad/internal/client/domain"
+)
+
+// FileTranscript keeps every message of a room, one JSON object per line
+type FileTranscript struct {
+	path string
+}
+
+func NewFileTranscript(path string) *FileTranscript {
+	return &FileTranscript{path}
+}
+
+// DefaultTranscriptPath follows the XDG base directory spec, falling back to ~/.local/share.
+// Every server gets a directory with a file per room.
+func DefaultTranscriptPath(server string, room string) (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if room == "" {
+		room = "default"
+	}
+
+	return filepath.Join(dataHome, "gchad", "transcripts", pathSafe(server), pathSafe(room)+".jsonl"), nil
+}
+
+// pathSafe keeps a name from escaping its directory or upsetting file systems
+func pathSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimLeft(name, "."))
+}
+
+// Load returns every entry, oldest first. A missing file is an empty transcript.
+func (t *FileTranscript) Load() ([]domain.TranscriptEntry, error) {
+	file, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []domain.TranscriptEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]domain.TranscriptEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry domain.TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// skip lines mangled by hand or by a crash halfway through a write
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (t *FileTranscript) Append(entry domain.TranscriptEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
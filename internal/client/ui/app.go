@@ -1,26 +1,82 @@
 package ui
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
 )
 
 type ChatClient interface {
 	Connect() error
 	Disconnect() error
-	SendMessage(message string)
-	InboundMessages() <-chan domain.Message
+	// SendMessage returns once the server acknowledges the message or turns it down
+	SendMessage(ctx context.Context, message string) (gchad.AckMessage, error)
+	SetPresence(presence gchad.Presence, statusText string)
+	ReportActivity()
+	Rename(name string)
+	ListRooms()
+	Search(request gchad.SearchMessage)
+	CreateWebhook(name string)
+	RevokeWebhook(token string)
+	ListWebhooks()
+	WebhookUrl(token string) string
+	SubscribeEvents(request gchad.SubscribeEventsMessage)
+	UnsubscribeEvents(id string)
+	ListEventSubscriptions()
+	InboundMessages() <-chan gchad.Message
 	Errors() <-chan error
 	SetName(name string)
 	Host() string
+	Room() string
 	Stats() *domain.ChatStats
+	Members() []gchad.Member
 }
 
+// RoomOpener creates a chat for another room, with a client of its own that is not connected yet
+type RoomOpener func(room string) Chat
+
 type switchToChat struct {
 	name string
 }
 
-type disconnected struct{}
+// disconnected tells the app the chat of the room has lost its connection or was left
+type disconnected struct {
+	room string
+	err  error // nil when the room was left
+}
+
+func disconnectedCmd(room string, err error) tea.Cmd {
+	return func() tea.Msg {
+		return disconnected{room, err}
+	}
+}
+
+type roomOpened struct {
+	chat Chat
+}
+
+type failedToOpenRoom struct {
+	room string
+	err  error
+}
+
+func openRoomCmd(chat Chat, name string) tea.Cmd {
+	return func() tea.Msg {
+		chat.chatClient.SetName(name)
+		if err := chat.chatClient.Connect(); err != nil {
+			return failedToOpenRoom{chat.chatClient.Room(), err}
+		}
+
+		return roomOpened{chat}
+	}
+}
 
 type activeSession int
 
@@ -34,21 +90,62 @@ type screenSize struct {
 	width  int
 }
 
+type AppKeymap struct {
+	Tabs     []key.Binding
+	NextTab  key.Binding
+	PrevTab  key.Binding
+	OpenRoom key.Binding
+}
+
+var DefaultAppKeymap = AppKeymap{
+	Tabs:     tabBindings(),
+	NextTab:  key.NewBinding(key.WithKeys("ctrl+n"), key.WithHelp("ctrl+n", "next room")),
+	PrevTab:  key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "previous room")),
+	OpenRoom: key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "open a room")),
+}
+
+// tabBindings binds alt+1 to alt+9 to the tabs in order
+func tabBindings() []key.Binding {
+	bindings := make([]key.Binding, 0, 9)
+	for i := 1; i <= 9; i++ {
+		keys := fmt.Sprintf("alt+%d", i)
+		bindings = append(bindings, key.NewBinding(key.WithKeys(keys), key.WithHelp(keys, fmt.Sprintf("room %d", i))))
+	}
+
+	return bindings
+}
+
+// tabBarHeight is taken off the screen height given to the chats
+const tabBarHeight = 1
+
 type App struct {
 	loginScreen   Login
-	chatScreen    Chat
+	chatScreen    Chat // the chat of the room joined on login
+	tabs          []Chat
+	activeTab     int
+	picker        RoomPicker
+	showPicker    bool
+	openRoom      RoomOpener
+	name          string
+	bindings      AppKeymap
+	help          help.Model
 	screenSize    screenSize
 	activeSession activeSession
 }
 
-func InitialAppModel(loginScreen Login, chatScreen Chat, chatClient ChatClient) App {
+func InitialAppModel(loginScreen Login, chatScreen Chat, openRoom RoomOpener, bindings AppKeymap) App {
 	return App{
 		loginScreen:   loginScreen,
 		chatScreen:    chatScreen,
+		openRoom:      openRoom,
+		bindings:      bindings,
+		picker:        NewRoomPicker(),
+		help:          newHelp(),
 		activeSession: loginSession,
 		screenSize:    screenSize{},
 	}
 }
+
 func (a App) Init() tea.Cmd {
 	return nil
 }
@@ -59,20 +156,70 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		a.screenSize.width = msg.Width
 		a.screenSize.height = msg.Height
+		// room for the border and padding of the help box
+		a.help.Width = msg.Width - 4
 		updatedLoginScreen, loginUpdCmd := a.loginScreen.Update(msg)
-		updatedChatScreen, chatUpdCmd := a.chatScreen.Update(msg)
 		a.loginScreen = updatedLoginScreen.(Login)
-		a.chatScreen = updatedChatScreen.(Chat)
 
-		return a, tea.Batch(loginUpdCmd, chatUpdCmd)
+		cmds := []tea.Cmd{loginUpdCmd}
+		a.chatScreen, _ = a.updateChat(a.chatScreen, a.chatSize())
+		for i := range a.tabs {
+			var cmd tea.Cmd
+			a.tabs[i], cmd = a.updateChat(a.tabs[i], a.chatSize())
+			cmds = append(cmds, cmd)
+		}
+
+		return a, tea.Batch(cmds...)
 
 	case switchToChat:
-		// this activates the switch below and delivers the message over to the chat screen
 		a.activeSession = chatSession
+		a.name = msg.name
+		a.tabs = []Chat{a.chatScreen}
+		a.activeTab = 0
+		a.tabs[0].activate()
+
+		var cmd tea.Cmd
+		a.tabs[0], cmd = a.updateChat(a.tabs[0], msg)
+		return a, cmd
 
 	case disconnected:
-		a.activeSession = loginSession
+		return a.closeTab(msg.room, msg.err)
+
+	case bannerExpired:
+		return a.updateTab(msg.room, msg)
+
+	case messageSent:
+		return a.updateTab(msg.room, msg)
 
+	case newMessageReceived:
+		if roomList, ok := msg.msg.(gchad.RoomListMessage); ok {
+			a.picker.setRooms(roomList.Rooms)
+		}
+		a.followOwnRename(msg.room, msg.msg)
+		return a.updateTab(msg.room, msg)
+
+	case newErrorReceived:
+		return a.updateTab(msg.room, msg)
+
+	case roomPicked:
+		return a.pickRoom(msg.room)
+
+	case roomOpened:
+		return a.addTab(msg.chat)
+
+	case failedToOpenRoom:
+		if len(a.tabs) > 0 {
+			a.tabs[a.activeTab].addSystemNote(fmt.Sprintf("failed to open room %s: %s", msg.room, msg.err.Error()))
+			a.tabs[a.activeTab].refreshViewport()
+		}
+		return a, nil
+
+	case tea.KeyMsg:
+		if a.activeSession == chatSession {
+			if updated, cmd, handled := a.handleTabKeys(msg); handled {
+				return updated, cmd
+			}
+		}
 	}
 
 	switch a.activeSession {
@@ -81,20 +228,218 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.loginScreen = updatedLoginScreen.(Login)
 		return a, cmd
 	case chatSession:
-		updatedChatScreen, cmd := a.chatScreen.Update(msg)
-		a.chatScreen = updatedChatScreen.(Chat)
+		var cmd tea.Cmd
+		a.tabs[a.activeTab], cmd = a.updateChat(a.tabs[a.activeTab], msg)
 		return a, cmd
 	}
 
 	return a, nil
 }
 
+func (a App) updateChat(chat Chat, msg tea.Msg) (Chat, tea.Cmd) {
+	updated, cmd := chat.Update(msg)
+	return updated.(Chat), cmd
+}
+
+// chatSize is the screen size left to the chats below the tab bar
+func (a App) chatSize() tea.WindowSizeMsg {
+	return tea.WindowSizeMsg{Width: a.screenSize.width, Height: a.screenSize.height - tabBarHeight}
+}
+
+func (a App) handleTabKeys(msg tea.KeyMsg) (App, tea.Cmd, bool) {
+	if a.showPicker {
+		var cmd tea.Cmd
+		var done bool
+		a.picker, cmd, done = a.picker.Update(msg, a.tabs[a.activeTab].bindings)
+		a.showPicker = !done
+		return a, cmd, true
+	}
+
+	for i, binding := range a.bindings.Tabs {
+		if key.Matches(msg, binding) {
+			if i < len(a.tabs) {
+				a.switchTab(i)
+			}
+			return a, nil, true
+		}
+	}
+
+	switch {
+	case key.Matches(msg, a.bindings.NextTab):
+		a.switchTab((a.activeTab + 1) % len(a.tabs))
+		return a, nil, true
+
+	case key.Matches(msg, a.bindings.PrevTab):
+		a.switchTab((a.activeTab - 1 + len(a.tabs)) % len(a.tabs))
+		return a, nil, true
+
+	case key.Matches(msg, a.bindings.OpenRoom):
+		joined := make(map[string]bool, len(a.tabs))
+		for _, tab := range a.tabs {
+			joined[tab.chatClient.Room()] = true
+		}
+		a.picker.open(joined)
+		a.showPicker = true
+		return a, listRoomsCmd(a.tabs[a.activeTab].chatClient), true
+	}
+
+	return a, nil, false
+}
+
+func (a *App) switchTab(index int) {
+	a.tabs[a.activeTab].deactivate()
+	a.activeTab = index
+	a.tabs[a.activeTab].activate()
+}
+
+// followOwnRename has the rooms opened from now on joined under the name the user renamed to
+func (a *App) followOwnRename(room string, msg gchad.Message) {
+	renamed, ok := msg.(gchad.UserRenamedMessage)
+	if !ok {
+		return
+	}
+
+	index := a.tabIndex(room)
+	if index >= 0 && a.tabs[index].statusLine.connectedAs == renamed.OldName {
+		a.name = renamed.NewName
+	}
+}
+
+func (a App) tabIndex(room string) int {
+	for i, tab := range a.tabs {
+		if tab.chatClient.Room() == room {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// updateTab delivers a message to the tab of the room. Messages for tabs closed since are dropped,
+// which also stops polling their client.
+func (a App) updateTab(room string, msg tea.Msg) (tea.Model, tea.Cmd) {
+	index := a.tabIndex(room)
+	if index < 0 {
+		return a, nil
+	}
+
+	var cmd tea.Cmd
+	a.tabs[index], cmd = a.updateChat(a.tabs[index], msg)
+	return a, cmd
+}
+
+func (a App) pickRoom(room string) (tea.Model, tea.Cmd) {
+	if index := a.tabIndex(room); index >= 0 {
+		a.switchTab(index)
+		return a, nil
+	}
+
+	return a, openRoomCmd(a.openRoom(room), a.name)
+}
+
+func (a App) addTab(chat Chat) (tea.Model, tea.Cmd) {
+	chat, sizeCmd := a.updateChat(chat, a.chatSize())
+	chat, pollCmd := a.updateChat(chat, switchToChat{a.name})
+
+	a.tabs = append(a.tabs, chat)
+	a.switchTab(len(a.tabs) - 1)
+
+	return a, tea.Batch(sizeCmd, pollCmd)
+}
+
+// closeTab drops the tab of the room, going back to the login screen once the last one is gone,
+// with a fresh chat to log into the room of the first one. A lost connection is reported where
+// the user ends up.
+func (a App) closeTab(room string, err error) (tea.Model, tea.Cmd) {
+	index := a.tabIndex(room)
+	if index < 0 {
+		return a, nil
+	}
+
+	a.tabs = append(a.tabs[:index:index], a.tabs[index+1:]...)
+	if len(a.tabs) == 0 {
+		a.activeSession = loginSession
+		a.showPicker = false
+		a.chatScreen, _ = a.updateChat(a.openRoom(a.chatScreen.chatClient.Room()), a.chatSize())
+		a.loginScreen.chatClient = a.chatScreen.chatClient
+		if err != nil {
+			a.loginScreen.textAboveInput = fmt.Sprintf("lost the connection: %s", err.Error())
+		}
+		return a, nil
+	}
+
+	if a.activeTab >= index && a.activeTab > 0 {
+		a.activeTab--
+	}
+	a.tabs[a.activeTab].activate()
+	if err != nil {
+		return a, a.tabs[a.activeTab].showBanner(fmt.Sprintf("lost the connection to %s: %s", room, err.Error()))
+	}
+
+	return a, nil
+}
+
+func (a App) tabBarView() string {
+	tabs := make([]string, 0, len(a.tabs))
+
+	for i, tab := range a.tabs {
+		label := fmt.Sprintf("%d %s", i+1, tab.chatClient.Room())
+		style := tabStyle
+		if i == a.activeTab {
+			style = activeTabStyle
+		} else {
+			if tab.unread > 0 {
+				label += " " + unreadStyle.Render(fmt.Sprintf("(%d)", tab.unread))
+			}
+			if mentions := len(tab.unseenMentions); mentions > 0 {
+				label += " " + mentionsStyle.Render(fmt.Sprintf("@%d", mentions))
+			}
+		}
+		tabs = append(tabs, style.Render(label))
+	}
+
+	return truncate(strings.Join(tabs, " "), a.screenSize.width)
+}
+
+func newHelp() help.Model {
+	model := help.New()
+	model.FullSeparator = "   "
+
+	return model
+}
+
+// helpView lists the bindings of the chat and of the app, generated from their help text
+func (a App) helpView() string {
+	groups := append(a.tabs[a.activeTab].bindings.FullHelp(), a.bindings.FullHelp()...)
+	hint := roomPickerHintStyle.Render("press any key to close")
+
+	return helpBoxStyle.Render(a.help.FullHelpView(groups) + "\n\n" + hint)
+}
+
+// overlay centers the box in place of the chat
+func (a App) overlay(box string) string {
+	return lipgloss.Place(
+		a.screenSize.width,
+		a.screenSize.height-tabBarHeight,
+		lipgloss.Center,
+		lipgloss.Center,
+		box,
+	)
+}
+
 func (a App) View() string {
 	switch a.activeSession {
 	case loginSession:
 		return a.loginScreen.View()
 	case chatSession:
-		return a.chatScreen.View()
+		chat := a.tabs[a.activeTab].View()
+		switch {
+		case a.tabs[a.activeTab].showHelp:
+			chat = a.overlay(a.helpView())
+		case a.showPicker:
+			chat = a.overlay(a.picker.View())
+		}
+		return a.tabBarView() + "\n" + chat
 	default:
 		panic("unknown active screen")
 	}
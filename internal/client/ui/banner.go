@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// defaultBannerDuration is how long a banner stays above the composer unless told otherwise
+const defaultBannerDuration = 5 * time.Second
+
+// banner is a line shown above the composer for a while, e.g. why the server turned a message down
+type banner struct {
+	text string
+	// shown counts the banners, telling the one expiring from the one that replaced it
+	shown int
+}
+
+type bannerExpired struct {
+	room  string
+	shown int
+}
+
+// showBanner replaces whatever banner is up, returning the command taking it down
+func (c *Chat) showBanner(text string) tea.Cmd {
+	c.banner.text = text
+	c.banner.shown++
+	room, shown := c.chatClient.Room(), c.banner.shown
+
+	return tea.Tick(c.bannerDuration, func(time.Time) tea.Msg {
+		return bannerExpired{room, shown}
+	})
+}
+
+// WithBannerDuration has the banners stay up for d, e.g. for tests not to wait for them
+func (c Chat) WithBannerDuration(d time.Duration) Chat {
+	c.bannerDuration = d
+	return c
+}
+
+func (c *Chat) expireBanner(msg bannerExpired) {
+	if msg.shown == c.banner.shown {
+		c.banner.text = ""
+	}
+}
+
+// rejectionText tells the user what the server turned down and why
+func rejectionText(msg gchad.ErrorMessage) string {
+	if msg.Request == "" {
+		return "the server turned down a message: " + msg.Message
+	}
+
+	return "the server turned down " + string(msg.Request) + ": " + msg.Message
+}
+
+// bannerView takes the place of the new messages line while a banner is up
+func (c *Chat) bannerView() string {
+	if c.banner.text == "" {
+		return c.newMessagesView()
+	}
+
+	return errorBannerStyle.Width(c.chatViewPort.Width).MaxHeight(1).Render(" " + c.banner.text)
+}
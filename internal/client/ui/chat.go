@@ -3,32 +3,42 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/iomallach/gchad/internal/client/domain"
-)
-
-var (
-	timestampStyle = lipgloss.NewStyle().Foreground(CatppuccinMocha.Lavender)
-	nameStyle      = lipgloss.NewStyle().Foreground(CatppuccinMocha.Blue).Bold(true)
-	textStyle      = lipgloss.NewStyle().Foreground(CatppuccinMocha.Text)
-	systemStyle    = lipgloss.NewStyle().Foreground(CatppuccinMocha.Yellow).Italic(true)
-	headerStyle    = lipgloss.NewStyle().
-			Foreground(CatppuccinMocha.Yellow).
-			Bold(true).
-			Border(lipgloss.RoundedBorder()).
-			Align(lipgloss.Center)
+	"github.com/iomallach/gchad/pkg/gchad"
 )
 
 type ChatScreenKeymap struct {
-	CtrlC key.Binding
-	Enter key.Binding
-	Esc   key.Binding
-	CtrlD key.Binding
+	CtrlC     key.Binding
+	Enter     key.Binding
+	Newline   key.Binding
+	Esc       key.Binding
+	CtrlD     key.Binding
+	CtrlT     key.Binding
+	CtrlR     key.Binding
+	Bottom    key.Binding
+	Retry     key.Binding
+	Find      key.Binding
+	NextMatch key.Binding
+	PrevMatch key.Binding
+	Tab       key.Binding
+	Up        key.Binding
+	Down      key.Binding
+	// moving around the messages in viewport mode
+	ScrollUp     key.Binding
+	ScrollDown   key.Binding
+	PageUp       key.Binding
+	PageDown     key.Binding
+	HalfPageUp   key.Binding
+	HalfPageDown key.Binding
+	Top          key.Binding
+	End          key.Binding
+	Help         key.Binding
 }
 
 var DefaultChatScreenKeymap = ChatScreenKeymap{
@@ -40,51 +50,145 @@ var DefaultChatScreenKeymap = ChatScreenKeymap{
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "submit"),
 	),
+	Newline: key.NewBinding(
+		key.WithKeys("alt+enter", "ctrl+j"),
+		key.WithHelp("alt+enter/ctrl+j", "new line"),
+	),
 	Esc: key.NewBinding(
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "toggle viewport/input"),
 	),
 	CtrlD: key.NewBinding(
 		key.WithKeys("ctrl+d"),
-		key.WithHelp("ctrl+d", "disconnect"),
+		key.WithHelp("ctrl+d", "leave the room"),
+	),
+	CtrlT: key.NewBinding(
+		key.WithKeys("ctrl+t"),
+		key.WithHelp("ctrl+t", "toggle member list"),
+	),
+	CtrlR: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "search history"),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "jump to the latest message"),
+	),
+	Retry: key.NewBinding(
+		key.WithKeys("ctrl+y"),
+		key.WithHelp("ctrl+y", "send a failed message again"),
+	),
+	Find: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search the messages"),
+	),
+	NextMatch: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "older match"),
+	),
+	PrevMatch: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "newer match"),
+	),
+	Tab: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "complete"),
+	),
+	Up: key.NewBinding(
+		key.WithKeys("up"),
+		key.WithHelp("↑", "previous message"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down"),
+		key.WithHelp("↓", "next message"),
+	),
+	ScrollUp: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "scroll up"),
+	),
+	ScrollDown: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "scroll down"),
+	),
+	PageUp: key.NewBinding(
+		key.WithKeys("pgup", "ctrl+b"),
+		key.WithHelp("pgup/ctrl+b", "page up"),
+	),
+	PageDown: key.NewBinding(
+		key.WithKeys("pgdown", "ctrl+f"),
+		key.WithHelp("pgdn/ctrl+f", "page down"),
+	),
+	HalfPageUp: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "half a page up"),
+	),
+	HalfPageDown: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "half a page down"),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("g", "home"),
+		key.WithHelp("g/home", "oldest message"),
+	),
+	End: key.NewBinding(
+		key.WithKeys("G", "end"),
+		key.WithHelp("G/end", "latest message"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "show the keys"),
 	),
 }
 
+// maxInputHeight is how many lines the composer grows to before it starts scrolling
+const maxInputHeight = 5
+
+// activityReportInterval throttles how often typing is reported to the server
+const activityReportInterval = 30 * time.Second
+
+// newMessageReceived and newErrorReceived name the room they came from, so that they reach the right tab
 type newMessageReceived struct {
-	msg domain.Message
+	room string
+	msg  gchad.Message
 }
 
 type newErrorReceived struct {
-	err error
+	room string
+	err  error
 }
 
 func pollForChatMessageCmd(chatClient ChatClient) tea.Cmd {
+	room := chatClient.Room()
+
 	return func() tea.Msg {
 		select {
 		case msg := <-chatClient.InboundMessages():
-			return newMessageReceived{msg}
+			return newMessageReceived{room, msg}
 		case err := <-chatClient.Errors():
-			return newErrorReceived{err}
+			return newErrorReceived{room, err}
 		}
 	}
 }
 
 // the simplest possible implementation due to low scale
 type MessageRingBuffer struct {
-	buffer  []string
+	buffer  []Entry
 	maxSize int
 	size    int
 	start   int
+	added   int
 }
 
 func NewMessageRingBuffer(maxSize int) *MessageRingBuffer {
 	return &MessageRingBuffer{
-		buffer:  make([]string, maxSize),
+		buffer:  make([]Entry, maxSize),
 		maxSize: maxSize,
 	}
 }
 
-func (b *MessageRingBuffer) Add(elem string) {
+func (b *MessageRingBuffer) Add(elem Entry) {
+	b.added++
+
 	if b.size < b.maxSize {
 		b.buffer[b.size] = elem
 		b.size++
@@ -94,8 +198,27 @@ func (b *MessageRingBuffer) Add(elem string) {
 	}
 }
 
-func (b *MessageRingBuffer) Elements() []string {
-	elements := make([]string, b.size)
+// Added returns how many elements have ever been added, including the ones pushed out since
+func (b *MessageRingBuffer) Added() int {
+	return b.added
+}
+
+// markSent marks the chat message with the id as acknowledged by the server
+func (b *MessageRingBuffer) markSent(id string) {
+	for i := range b.buffer[:b.size] {
+		if b.buffer[i].kind == chatEntry && b.buffer[i].id == id {
+			b.buffer[i].sent = true
+			return
+		}
+	}
+}
+
+func (b *MessageRingBuffer) Len() int {
+	return b.size
+}
+
+func (b *MessageRingBuffer) Elements() []Entry {
+	elements := make([]Entry, b.size)
 
 	for i := 0; i < b.size; i++ {
 		elements[i] = b.buffer[(b.start+i)%b.size]
@@ -105,17 +228,58 @@ func (b *MessageRingBuffer) Elements() []string {
 }
 
 type Chat struct {
-	input        textinput.Model
-	chatViewPort viewport.Model
-	statusLine   StatusLine
-	bindings     ChatScreenKeymap
-	chatClient   ChatClient
-	messages     *MessageRingBuffer
-	ready        bool
+	input              textarea.Model
+	chatViewPort       viewport.Model
+	statusLine         StatusLine
+	memberList         MemberList
+	showMembers        bool
+	screenSize         screenSize
+	bindings           ChatScreenKeymap
+	chatClient         ChatClient
+	messages           *MessageRingBuffer
+	notifier           DesktopNotifier
+	history            *InputHistory
+	completion         completion
+	search             historySearch
+	unseenMentions     []int // positions of the messages mentioning the user, see MessageRingBuffer.Added
+	entryHeights       []int // how many viewport lines each message took when last rendered
+	active             bool  // whether this is the tab on screen
+	unread             int   // chat messages received while in the background
+	scroll             scrollState
+	transcript         TranscriptStore
+	transcriptFailed   bool
+	scrollback         []Entry // older messages brought back from the transcript while searching
+	find               transcriptSearch
+	results            searchResults
+	showHelp           bool
+	banner             banner
+	bannerDuration     time.Duration
+	outbox             outbox
+	lastActivityReport time.Time
+	ready              bool
 }
 
-func InitialChatModel(bindings ChatScreenKeymap, chatClient ChatClient, messages *MessageRingBuffer) Chat {
-	return Chat{bindings: bindings, chatClient: chatClient, messages: messages}
+func InitialChatModel(
+	bindings ChatScreenKeymap,
+	chatClient ChatClient,
+	messages *MessageRingBuffer,
+	notifier DesktopNotifier,
+	history *InputHistory,
+	transcript TranscriptStore,
+) Chat {
+	return Chat{
+		bindings:       bindings,
+		chatClient:     chatClient,
+		messages:       messages,
+		notifier:       notifier,
+		history:        history,
+		memberList:     NewMemberList(),
+		showMembers:    true,
+		scroll:         newScrollState(),
+		transcript:     transcript,
+		find:           transcriptSearch{current: -1},
+		bannerDuration: defaultBannerDuration,
+	}
 }
 
 func (c Chat) Init() tea.Cmd {
@@ -131,12 +295,47 @@ func (c Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		c, cmd = c.updateOnWindowSizeChange(msg)
 
 	case tea.KeyMsg:
+		if c.showHelp {
+			// any key closes the help, quitting stays available
+			c.showHelp = false
+			if key.Matches(msg, c.bindings.CtrlC) {
+				return c, tea.Quit
+			}
+			return c, nil
+		}
 		if key.Matches(msg, c.bindings.CtrlD) {
 			_ = c.chatClient.Disconnect() // swallow the error?
 			c.chatViewPort.SetContent("")
-			return c, func() tea.Msg {
-				return disconnected{}
-			}
+			return c, disconnectedCmd(c.chatClient.Room(), nil)
+		}
+		if key.Matches(msg, c.bindings.CtrlT) {
+			c.showMembers = !c.showMembers
+			c.layout()
+			c.refreshViewport()
+			return c, nil
+		}
+		if key.Matches(msg, c.bindings.Bottom) {
+			c.jumpToBottom()
+			return c, nil
+		}
+		if key.Matches(msg, c.bindings.Retry) {
+			cmd = c.retry()
+			c.refreshViewport()
+			return c, cmd
+		}
+		if c.results.open {
+			return c, c.updateResults(msg)
+		}
+		if !c.input.Focused() && c.find.typing {
+			c.updateFind(msg)
+			return c, nil
+		}
+		if c.input.Focused() && c.search.active {
+			c.updateSearch(msg)
+			return c, nil
+		}
+		if !key.Matches(msg, c.bindings.Tab) {
+			c.completion.reset()
 		}
 		if c.input.Focused() {
 			switch {
@@ -145,49 +344,140 @@ func (c Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return c, tea.Quit
 
 			case key.Matches(msg, c.bindings.Enter):
-				if c.input.Value() != "" {
-					go c.chatClient.SendMessage(c.input.Value())
-					c.input.Reset()
+				value := c.input.Value()
+				c.input.Reset()
+				c.layout()
+				if value != "" {
+					c.remember(value)
+					// speaking up means the user has caught up
+					c.scroll.hasDivider = false
+					c.jumpToBottom()
+				}
+
+				switch {
+				case value == "":
+					return c, nil
+				case isCommand(value):
+					cmd = c.runCommand(value)
+					c.refreshViewport()
+					return c, cmd
+				default:
+					cmd = c.send(value)
+					c.refreshViewport()
+					return c, cmd
 				}
-				return c, nil
 
 			case key.Matches(msg, c.bindings.Esc):
 				c.input.Blur()
 
+			case key.Matches(msg, c.bindings.Tab):
+				c.complete()
+
+			// in a multiline draft the arrows move between lines, history is only reached from its edges
+			case key.Matches(msg, c.bindings.Up) && c.input.Line() == 0:
+				if entry, ok := c.history.Previous(c.input.Value()); ok {
+					c.setInput(entry)
+				}
+
+			case key.Matches(msg, c.bindings.Down) && c.input.Line() == c.input.LineCount()-1:
+				if entry, ok := c.history.Next(); ok {
+					c.setInput(entry)
+				}
+
+			case key.Matches(msg, c.bindings.CtrlR):
+				c.startSearch()
+
 			default:
 				c.input, cmd = c.input.Update(msg)
+				c.layout()
+				cmd = tea.Batch(cmd, c.reportActivity())
 			}
 		} else {
 			switch {
+			case key.Matches(msg, c.bindings.Esc) && c.find.active():
+				c.closeFind()
 			case key.Matches(msg, c.bindings.Esc):
 				c.input.Focus()
 			case key.Matches(msg, c.bindings.CtrlC):
 				return c, tea.Quit
+			case key.Matches(msg, c.bindings.Find):
+				c.startFind()
+			case key.Matches(msg, c.bindings.NextMatch) && c.find.active():
+				c.moveMatch(-1)
+			case key.Matches(msg, c.bindings.PrevMatch) && c.find.active():
+				c.moveMatch(1)
+			case key.Matches(msg, c.bindings.Help):
+				c.showHelp = true
+			case key.Matches(msg, c.bindings.Top):
+				c.chatViewPort.GotoTop()
+				c.scrolled()
+			case key.Matches(msg, c.bindings.End):
+				c.jumpToBottom()
+			default:
+				c.chatViewPort, cmd = c.chatViewPort.Update(msg)
+				c.scrolled()
 			}
 		}
 
 	case newMessageReceived:
+		if rejection, ok := msg.msg.(gchad.ErrorMessage); ok {
+			if rejection.RequestId != "" {
+				// the message it turns down says so in the outbox
+				return c, pollForChatMessageCmd(c.chatClient)
+			}
+			return c, tea.Batch(c.showBanner(rejectionText(rejection)), pollForChatMessageCmd(c.chatClient))
+		}
+		if results, ok := msg.msg.(gchad.SearchResultsMessage); ok {
+			c.showResults(results)
+			return c, pollForChatMessageCmd(c.chatClient)
+		}
+		c.followOwnRename(msg.msg)
+		c.memberList.members = c.chatClient.Members()
+		added := c.messages.Added()
 		c.updateMessages(msg.msg)
-		c.chatViewPort.SetContent(strings.Join(c.messages.Elements(), "\n"))
-		c.chatViewPort.GotoBottom()
+		if c.messages.Added() > added && !c.scroll.following {
+			c.scroll.newBelow++
+		}
+		c.followMatches()
+		if _, ok := msg.msg.(gchad.ChatMessage); ok && !c.active {
+			c.unread++
+		}
+		notifyCmd := c.trackMention(msg.msg)
+		c.refreshViewport()
 
 		stats := c.chatClient.Stats()
 		updatedStatusLine, cmd := c.statusLine.Update(stats)
 		c.statusLine = updatedStatusLine.(StatusLine)
 
-		return c, tea.Batch(cmd, pollForChatMessageCmd(c.chatClient))
+		return c, tea.Batch(cmd, notifyCmd, pollForChatMessageCmd(c.chatClient))
 
 	case newErrorReceived:
-		// TODO: display the error somehow
+		// the connection is gone for good, the app tells the user why
 		c.input.Reset()
 		c.chatViewPort.SetContent("")
 
-		return c, func() tea.Msg {
-			return disconnected{}
-		}
+		return c, disconnectedCmd(c.chatClient.Room(), msg.err)
+
+	case bannerExpired:
+		c.expireBanner(msg)
+
+	case messageSent:
+		c.settle(msg)
+		c.refreshViewport()
+
+	case tea.BlurMsg:
+		c.lookAway()
+
+	case tea.FocusMsg:
+		c.lookBack()
 
 	case switchToChat:
 		c.statusLine.connectedAs = msg.name
+		c.memberList.members = nil
+		c.statusLine.room = c.chatClient.Room()
+		c.unseenMentions = nil
+		c.statusLine.mentions = 0
+		c.unread = 0
 		return c, pollForChatMessageCmd(c.chatClient)
 	}
 
@@ -198,43 +488,298 @@ func (c Chat) updateOnWindowSizeChange(msg tea.WindowSizeMsg) (Chat, tea.Cmd) {
 	updatedStatusLine, cmd := c.statusLine.Update(msg)
 	c.statusLine = updatedStatusLine.(StatusLine)
 
+	c.screenSize = screenSize{height: msg.Height, width: msg.Width}
+
 	if !c.ready {
-		c.input = textinput.New()
-		c.input.Width = msg.Width - 2
+		c.input = newComposer(c.bindings)
 		c.input.Focus()
-		c.chatViewPort = viewport.New(msg.Width-2, msg.Height-9)
+		c.chatViewPort = viewport.New(0, 0)
+		c.chatViewPort.KeyMap = viewportKeyMap(c.bindings)
 
 		c.ready = true
-	} else {
-		c.chatViewPort.Width = msg.Width - 2
-		c.chatViewPort.Height = msg.Height - 9
-		c.input.Width = msg.Width - 2
 	}
+	c.layout()
+	c.refreshViewport()
 
 	return c, cmd
 }
 
-func (c *Chat) updateMessages(msg domain.Message) {
+func (c *Chat) sidebarWidth() int {
+	if !c.showMembers {
+		return 0
+	}
+
+	return memberListWidth
+}
+
+// viewportKeyMap hands the scrolling bindings over to the viewport, which has no horizontal scrolling to do
+func viewportKeyMap(bindings ChatScreenKeymap) viewport.KeyMap {
+	return viewport.KeyMap{
+		PageDown:     bindings.PageDown,
+		PageUp:       bindings.PageUp,
+		HalfPageUp:   bindings.HalfPageUp,
+		HalfPageDown: bindings.HalfPageDown,
+		Up:           bindings.ScrollUp,
+		Down:         bindings.ScrollDown,
+		Left:         key.NewBinding(key.WithDisabled()),
+		Right:        key.NewBinding(key.WithDisabled()),
+	}
+}
+
+// newComposer creates the multiline input, enter is left to submit the message
+func newComposer(bindings ChatScreenKeymap) textarea.Model {
+	input := textarea.New()
+	input.Prompt = "> "
+	input.Placeholder = ""
+	input.ShowLineNumbers = false
+	input.CharLimit = 0
+	input.MaxHeight = 0
+	input.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	input.KeyMap.InsertNewline = bindings.Newline
+	input.SetHeight(1)
+
+	return input
+}
+
+// inputHeight is the composer height fitting the current draft
+func (c *Chat) inputHeight() int {
+	return max(1, min(c.input.LineCount(), maxInputHeight))
+}
+
+// layout sizes the components to the last known screen size
+func (c *Chat) layout() {
+	inputHeight := c.inputHeight()
+
+	c.chatViewPort.Width = c.screenSize.width - 2 - c.sidebarWidth()
+	c.chatViewPort.Height = c.screenSize.height - 8 - inputHeight
+	c.input.SetWidth(c.screenSize.width - 2)
+	c.input.SetHeight(inputHeight)
+	c.memberList.height = c.chatViewPort.Height
+	if c.scroll.following {
+		c.chatViewPort.GotoBottom()
+	}
+}
+
+func (c *Chat) setInput(value string) {
+	c.input.SetValue(value)
+	c.input.CursorEnd()
+	c.layout()
+}
+
+func (c *Chat) remember(entry string) {
+	if err := c.history.Add(entry); err != nil {
+		c.addSystemNote(fmt.Sprintf("failed to save input history: %s", err.Error()))
+	}
+}
+
+// complete replaces the word before the cursor with the next completion candidate
+func (c *Chat) complete() {
+	if !c.completion.active {
+		members := make([]string, 0, len(c.memberList.members))
+		for _, member := range c.memberList.members {
+			members = append(members, member.Name)
+		}
+
+		start, candidates := completionCandidates(c.input.Value(), members, commandNames())
+		if len(candidates) == 0 {
+			return
+		}
+		c.completion = completion{active: true, start: start, candidates: candidates, index: -1}
+	}
+
+	c.setInput(c.completion.next(c.input.Value()))
+}
+
+// refreshViewport renders the messages again, following the latest one unless the user scrolled up
+func (c *Chat) refreshViewport() {
+	evicted := c.evictedLines()
+	content, heights := renderEntries(
+		c.displayedEntries(),
+		c.chatViewPort.Width,
+		c.dividerIndex(),
+		c.find.highlight(),
+		c.find.currentEntry(),
+	)
+	// what the user sent and the server has yet to acknowledge goes below the messages
+	if outbox := c.outboxView(c.chatViewPort.Width); outbox != "" {
+		content = strings.TrimPrefix(content+"\n"+outbox, "\n")
+	}
+	c.entryHeights = heights
+	c.scroll.renderedFrom = c.messages.Added() - c.messages.Len()
+	c.scroll.renderedScrollback = len(c.scrollback)
+	c.chatViewPort.SetContent(content)
+
+	if c.scroll.following {
+		c.chatViewPort.GotoBottom()
+	} else {
+		// messages pushed out of the buffer would otherwise scroll the text under the reader
+		c.chatViewPort.SetYOffset(c.chatViewPort.YOffset - evicted)
+	}
+	c.markMentionsSeen()
+}
+
+func (c *Chat) mentionsMe(msg gchad.Message) bool {
+	chatMsg, ok := msg.(gchad.ChatMessage)
+	return ok && chatMsg.From != c.statusLine.connectedAs && chatMsg.MentionsName(c.statusLine.connectedAs)
+}
+
+// trackMention remembers a message mentioning the user until it is scrolled into view,
+// and notifies the user about it unless they asked not to be disturbed
+func (c *Chat) trackMention(msg gchad.Message) tea.Cmd {
+	if !c.mentionsMe(msg) {
+		return nil
+	}
+
+	c.unseenMentions = append(c.unseenMentions, c.messages.Added()-1)
+	c.statusLine.mentions = len(c.unseenMentions)
+
+	for _, member := range c.memberList.members {
+		if member.Name == c.statusLine.connectedAs && member.Presence == gchad.PresenceDoNotDisturb {
+			return nil
+		}
+	}
+
+	chatMsg := msg.(gchad.ChatMessage)
+	notifier := c.notifier
+	return func() tea.Msg {
+		notifier.Notify("gchad", chatMsg.From+": "+chatMsg.Text)
+		return nil
+	}
+}
+
+// activate brings the tab on screen, which counts as having read it
+func (c *Chat) activate() {
+	c.active = true
+	c.unread = 0
+	c.lookBack()
+}
+
+func (c *Chat) deactivate() {
+	c.active = false
+	c.lookAway()
+}
+
+// markMentionsSeen forgets the mentions currently visible in the viewport
+func (c *Chat) markMentionsSeen() {
+	if !c.active {
+		return
+	}
+
+	firstPosition := c.messages.Added() - c.messages.Len()
+	mentioned := make(map[int]bool, len(c.unseenMentions))
+	for _, position := range c.unseenMentions {
+		mentioned[position] = true
+	}
+
+	unseen := make([]int, 0, len(c.unseenMentions))
+	top, bottom := c.chatViewPort.YOffset, c.chatViewPort.YOffset+c.chatViewPort.Height
+	line := 0
+	for _, height := range c.entryHeights[:c.scroll.renderedScrollback] {
+		line += height
+	}
+
+	// messages may span several lines, so walk them to find where each one lands in the viewport
+	for i, height := range c.entryHeights[c.scroll.renderedScrollback:] {
+		position := firstPosition + i
+		visible := line < bottom && line+height > top
+		if mentioned[position] && !visible {
+			unseen = append(unseen, position)
+		}
+		line += height
+	}
+
+	c.unseenMentions = unseen
+	c.statusLine.mentions = len(unseen)
+}
+
+// reportActivity tells the server the user is typing, at most once per activityReportInterval
+func (c *Chat) reportActivity() tea.Cmd {
+	if time.Since(c.lastActivityReport) < activityReportInterval {
+		return nil
+	}
+
+	c.lastActivityReport = time.Now()
+	return reportActivityCmd(c.chatClient)
+}
+
+func (c *Chat) addSystemNote(note string) {
+	c.messages.Add(NewSystemEntry(note, time.Now()))
+}
+
+func (c *Chat) followOwnRename(msg gchad.Message) {
+	renamed, ok := msg.(gchad.UserRenamedMessage)
+	if !ok || renamed.OldName != c.statusLine.connectedAs {
+		return
+	}
+
+	c.statusLine.connectedAs = renamed.NewName
+	c.chatClient.SetName(renamed.NewName)
+}
+
+func (c *Chat) updateMessages(msg gchad.Message) {
 	switch msg := msg.(type) {
-	case domain.ChatMessage:
-		time := timestampStyle.Render(msg.Timestamp.Format("15:04:05"))
-		name := nameStyle.Render(msg.From + ":")
-		text := textStyle.Render(msg.Text)
-		c.messages.Add(fmt.Sprintf("%s %s %s", time, name, text))
+	case gchad.ChatMessage:
+		entry := NewChatEntry(msg, c.mentionsMe(msg))
+		entry.sent = c.arrived(msg)
+		c.addEntry(entry)
+
+	case gchad.UserJoinedMessage:
+		if msg.Bot {
+			c.addEntry(NewSystemEntry(msg.Name+" (bot) joined!", msg.Timestamp))
+		} else {
+			c.addEntry(NewSystemEntry(msg.Name+" joined!", msg.Timestamp))
+		}
+
+	case gchad.UserLeftMessage:
+		c.addEntry(NewSystemEntry(msg.Name+" left!", msg.Timestamp))
 
-	case domain.UserJoinedMessage:
-		time := timestampStyle.Render(msg.Timestamp.Format("15:04:05"))
-		text := systemStyle.Render(msg.Name + " joined!")
-		c.messages.Add(fmt.Sprintf("%s %s", time, text))
+	case gchad.UserRenamedMessage:
+		c.addEntry(NewSystemEntry(msg.OldName+" is now known as "+msg.NewName, msg.Timestamp))
 
-	case domain.UserLeftMessage:
-		time := timestampStyle.Render(msg.Timestamp.Format("15:04:05"))
-		text := systemStyle.Render(msg.Name + " left!")
-		c.messages.Add(fmt.Sprintf("%s %s", time, text))
+	case gchad.WebhookListMessage:
+		// the urls are as good as passwords, they are kept out of the transcript
+		if len(msg.Webhooks) == 0 {
+			c.addSystemNote("the room has no webhooks")
+		}
+		for _, webhook := range msg.Webhooks {
+			c.addSystemNote(fmt.Sprintf("webhook %s by %s: %s", webhook.Name, webhook.CreatedBy, c.chatClient.WebhookUrl(webhook.Token)))
+		}
+
+	case gchad.EventSubscriptionListMessage:
+		// so are the secrets
+		if len(msg.Subscriptions) == 0 {
+			c.addSystemNote("the room has no event subscriptions")
+		}
+		for _, subscription := range msg.Subscriptions {
+			events := make([]string, 0, len(subscription.Events))
+			for _, event := range subscription.Events {
+				events = append(events, string(event))
+			}
+			note := fmt.Sprintf("events %s by %s: %s to %s", subscription.Id, subscription.CreatedBy, strings.Join(events, ","), subscription.Url)
+			if len(subscription.Keywords) > 0 {
+				note += fmt.Sprintf(" on %s", strings.Join(subscription.Keywords, ","))
+			}
+			c.addSystemNote(note + ", secret " + subscription.Secret)
+		}
 	}
 }
 
 func (c Chat) View() string {
-	styledHeader := headerStyle.Width(c.chatViewPort.Width).Render(c.chatClient.Host())
-	return fmt.Sprintf("\n%s\n%s\n\n%s\n%s", styledHeader, c.chatViewPort.View(), c.input.View(), c.statusLine.View())
+	styledHeader := headerStyle.Width(c.chatViewPort.Width + c.sidebarWidth()).Render(c.chatClient.Host())
+	body := c.chatViewPort.View()
+	if c.results.open {
+		body = c.results.View(c.chatViewPort.Width, c.chatViewPort.Height)
+	}
+	if c.showMembers {
+		body = lipgloss.JoinHorizontal(lipgloss.Top, body, c.memberList.View())
+	}
+	input := c.input.View()
+	switch {
+	case c.search.active:
+		input = c.searchView()
+	case c.find.active():
+		input = c.findView()
+	}
+
+	return fmt.Sprintf("\n%s\n%s\n%s\n%s\n%s", styledHeader, body, c.bannerView(), input, c.statusLine.View())
 }
@@ -2,8 +2,9 @@ package ui
 
 import "github.com/charmbracelet/lipgloss"
 
-// https://github.com/catppuccin/catppuccin
-var CatppuccinMocha = struct {
+// Palette names the colors of a theme after the Catppuccin ones, which every built-in theme
+// fills in, from the accents down to the background shades
+type Palette struct {
 	Rosewater lipgloss.Color
 	Flamingo  lipgloss.Color
 	Pink      lipgloss.Color
@@ -30,7 +31,97 @@ var CatppuccinMocha = struct {
 	Base      lipgloss.Color
 	Mantle    lipgloss.Color
 	Crust     lipgloss.Color
-}{
+}
+
+// https://github.com/catppuccin/catppuccin
+var CatppuccinLatte = Palette{
+	Rosewater: lipgloss.Color("#dc8a78"),
+	Flamingo:  lipgloss.Color("#dd7878"),
+	Pink:      lipgloss.Color("#ea76cb"),
+	Mauve:     lipgloss.Color("#8839ef"),
+	Red:       lipgloss.Color("#d20f39"),
+	Maroon:    lipgloss.Color("#e64553"),
+	Peach:     lipgloss.Color("#fe640b"),
+	Yellow:    lipgloss.Color("#df8e1d"),
+	Green:     lipgloss.Color("#40a02b"),
+	Teal:      lipgloss.Color("#179299"),
+	Sky:       lipgloss.Color("#04a5e5"),
+	Sapphire:  lipgloss.Color("#209fb5"),
+	Blue:      lipgloss.Color("#1e66f5"),
+	Lavender:  lipgloss.Color("#7287fd"),
+	Text:      lipgloss.Color("#4c4f69"),
+	Subtext1:  lipgloss.Color("#5c5f77"),
+	Subtext0:  lipgloss.Color("#6c6f85"),
+	Overlay2:  lipgloss.Color("#7c7f93"),
+	Overlay1:  lipgloss.Color("#8c8fa1"),
+	Overlay0:  lipgloss.Color("#9ca0b0"),
+	Surface2:  lipgloss.Color("#acb0be"),
+	Surface1:  lipgloss.Color("#bcc0cc"),
+	Surface0:  lipgloss.Color("#ccd0da"),
+	Base:      lipgloss.Color("#eff1f5"),
+	Mantle:    lipgloss.Color("#e6e9ef"),
+	Crust:     lipgloss.Color("#dce0e8"),
+}
+
+var CatppuccinFrappe = Palette{
+	Rosewater: lipgloss.Color("#f2d5cf"),
+	Flamingo:  lipgloss.Color("#eebebe"),
+	Pink:      lipgloss.Color("#f4b8e4"),
+	Mauve:     lipgloss.Color("#ca9ee6"),
+	Red:       lipgloss.Color("#e78284"),
+	Maroon:    lipgloss.Color("#ea999c"),
+	Peach:     lipgloss.Color("#ef9f76"),
+	Yellow:    lipgloss.Color("#e5c890"),
+	Green:     lipgloss.Color("#a6d189"),
+	Teal:      lipgloss.Color("#81c8be"),
+	Sky:       lipgloss.Color("#99d1db"),
+	Sapphire:  lipgloss.Color("#85c1dc"),
+	Blue:      lipgloss.Color("#8caaee"),
+	Lavender:  lipgloss.Color("#babbf1"),
+	Text:      lipgloss.Color("#c6d0f5"),
+	Subtext1:  lipgloss.Color("#b5bfe2"),
+	Subtext0:  lipgloss.Color("#a5adce"),
+	Overlay2:  lipgloss.Color("#949cbb"),
+	Overlay1:  lipgloss.Color("#838ba7"),
+	Overlay0:  lipgloss.Color("#737994"),
+	Surface2:  lipgloss.Color("#626880"),
+	Surface1:  lipgloss.Color("#51576d"),
+	Surface0:  lipgloss.Color("#414559"),
+	Base:      lipgloss.Color("#303446"),
+	Mantle:    lipgloss.Color("#292c3c"),
+	Crust:     lipgloss.Color("#232634"),
+}
+
+var CatppuccinMacchiato = Palette{
+	Rosewater: lipgloss.Color("#f4dbd6"),
+	Flamingo:  lipgloss.Color("#f0c6c6"),
+	Pink:      lipgloss.Color("#f5bde6"),
+	Mauve:     lipgloss.Color("#c6a0f6"),
+	Red:       lipgloss.Color("#ed8796"),
+	Maroon:    lipgloss.Color("#ee99a0"),
+	Peach:     lipgloss.Color("#f5a97f"),
+	Yellow:    lipgloss.Color("#eed49f"),
+	Green:     lipgloss.Color("#a6da95"),
+	Teal:      lipgloss.Color("#8bd5ca"),
+	Sky:       lipgloss.Color("#91d7e3"),
+	Sapphire:  lipgloss.Color("#7dc4e4"),
+	Blue:      lipgloss.Color("#8aadf4"),
+	Lavender:  lipgloss.Color("#b7bdf8"),
+	Text:      lipgloss.Color("#cad3f5"),
+	Subtext1:  lipgloss.Color("#b8c0e0"),
+	Subtext0:  lipgloss.Color("#a5adcb"),
+	Overlay2:  lipgloss.Color("#939ab7"),
+	Overlay1:  lipgloss.Color("#8087a2"),
+	Overlay0:  lipgloss.Color("#6e738d"),
+	Surface2:  lipgloss.Color("#5b6078"),
+	Surface1:  lipgloss.Color("#494d64"),
+	Surface0:  lipgloss.Color("#363a4f"),
+	Base:      lipgloss.Color("#24273a"),
+	Mantle:    lipgloss.Color("#1e2030"),
+	Crust:     lipgloss.Color("#181926"),
+}
+
+var CatppuccinMocha = Palette{
 	Rosewater: lipgloss.Color("#f5e0dc"),
 	Flamingo:  lipgloss.Color("#f2cdcd"),
 	Pink:      lipgloss.Color("#f5c2e7"),
@@ -58,3 +149,33 @@ var CatppuccinMocha = struct {
 	Mantle:    lipgloss.Color("#181825"),
 	Crust:     lipgloss.Color("#11111b"),
 }
+
+// HighContrast sticks to the 16 ANSI colors, which the terminal renders at full strength
+var HighContrast = Palette{
+	Rosewater: lipgloss.Color("15"),
+	Flamingo:  lipgloss.Color("13"),
+	Pink:      lipgloss.Color("13"),
+	Mauve:     lipgloss.Color("13"),
+	Red:       lipgloss.Color("9"),
+	Maroon:    lipgloss.Color("9"),
+	Peach:     lipgloss.Color("11"),
+	Yellow:    lipgloss.Color("11"),
+	Green:     lipgloss.Color("10"),
+	Teal:      lipgloss.Color("14"),
+	Sky:       lipgloss.Color("14"),
+	Sapphire:  lipgloss.Color("12"),
+	Blue:      lipgloss.Color("12"),
+	Lavender:  lipgloss.Color("12"),
+	Text:      lipgloss.Color("15"),
+	Subtext1:  lipgloss.Color("15"),
+	Subtext0:  lipgloss.Color("15"),
+	Overlay2:  lipgloss.Color("7"),
+	Overlay1:  lipgloss.Color("7"),
+	Overlay0:  lipgloss.Color("7"),
+	Surface2:  lipgloss.Color("8"),
+	Surface1:  lipgloss.Color("8"),
+	Surface0:  lipgloss.Color("0"),
+	Base:      lipgloss.Color("0"),
+	Mantle:    lipgloss.Color("0"),
+	Crust:     lipgloss.Color("0"),
+}
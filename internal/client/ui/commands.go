@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const commandPrefix = "/"
+
+type chatCommand struct {
+	name  string
+	usage string
+	help  string
+	run   func(c *Chat, args string) tea.Cmd
+}
+
+var chatCommands = []chatCommand{
+	{
+		name:  "away",
+		usage: "/away [status]",
+		help:  "mark yourself away",
+		run: func(c *Chat, args string) tea.Cmd {
+			return setPresenceCmd(c.chatClient, gchad.PresenceAway, args)
+		},
+	},
+	{
+		name:  "dnd",
+		usage: "/dnd [status]",
+		help:  "do not disturb",
+		run: func(c *Chat, args string) tea.Cmd {
+			return setPresenceCmd(c.chatClient, gchad.PresenceDoNotDisturb, args)
+		},
+	},
+	{
+		name:  "back",
+		usage: "/back",
+		help:  "mark yourself online",
+		run: func(c *Chat, args string) tea.Cmd {
+			return setPresenceCmd(c.chatClient, gchad.PresenceOnline, "")
+		},
+	},
+	{
+		name:  "status",
+		usage: "/status [text]",
+		help:  "set a status text, keeping the presence",
+		run: func(c *Chat, args string) tea.Cmd {
+			presence := gchad.PresenceOnline
+			for _, member := range c.memberList.members {
+				if member.Name == c.statusLine.connectedAs {
+					presence = member.Presence
+				}
+			}
+			return setPresenceCmd(c.chatClient, presence, args)
+		},
+	},
+	{
+		name:  "nick",
+		usage: "/nick <name>",
+		help:  "change your name",
+		run: func(c *Chat, args string) tea.Cmd {
+			if args == "" || strings.ContainsAny(args, " \t") {
+				c.addSystemNote("usage: /nick <name>")
+				return nil
+			}
+			return renameCmd(c.chatClient, args)
+		},
+	},
+	{
+		name:  "search",
+		usage: "/search <terms> [from:nick] [before:date]",
+		help:  "search the messages the server kept for the room",
+		run: func(c *Chat, args string) tea.Cmd {
+			return c.startServerSearch(args)
+		},
+	},
+	{
+		name:  "join",
+		usage: "/join <room>",
+		help:  "open a room in a new tab",
+		run: func(c *Chat, args string) tea.Cmd {
+			if args == "" || strings.ContainsAny(args, " \t") {
+				c.addSystemNote("usage: /join <room>")
+				return nil
+			}
+			return func() tea.Msg {
+				return roomPicked{args}
+			}
+		},
+	},
+	{
+		name:  "webhook",
+		usage: "/webhook create <name> | revoke <token> | list",
+		help:  "manage the webhooks posting into the room, for moderators",
+		run: func(c *Chat, args string) tea.Cmd {
+			action, arg, _ := strings.Cut(args, " ")
+			arg = strings.TrimSpace(arg)
+			chatClient := c.chatClient
+
+			switch {
+			case action == "create" && arg != "" && !strings.ContainsAny(arg, " \t"):
+				return func() tea.Msg {
+					chatClient.CreateWebhook(arg)
+					return nil
+				}
+			case action == "revoke" && arg != "":
+				return func() tea.Msg {
+					chatClient.RevokeWebhook(arg)
+					return nil
+				}
+			case action == "list" && arg == "":
+				return func() tea.Msg {
+					chatClient.ListWebhooks()
+					return nil
+				}
+			}
+
+			c.addSystemNote("usage: /webhook create <name> | revoke <token> | list")
+			return nil
+		},
+	},
+	{
+		name:  "events",
+		usage: "/events subscribe <url> <event,...> [keyword,...] | unsubscribe <id> | list",
+		help:  "have the server post events of the room to a url, for moderators",
+		run: func(c *Chat, args string) tea.Cmd {
+			action, rest, _ := strings.Cut(args, " ")
+			fields := strings.Fields(rest)
+			chatClient := c.chatClient
+
+			switch {
+			case action == "subscribe" && (len(fields) == 2 || len(fields) == 3):
+				request := gchad.SubscribeEventsMessage{Url: fields[0]}
+				for _, event := range strings.Split(fields[1], ",") {
+					request.Events = append(request.Events, gchad.EventKind(event))
+				}
+				if len(fields) == 3 {
+					request.Keywords = strings.Split(fields[2], ",")
+				}
+				return func() tea.Msg {
+					chatClient.SubscribeEvents(request)
+					return nil
+				}
+			case action == "unsubscribe" && len(fields) == 1:
+				return func() tea.Msg {
+					chatClient.UnsubscribeEvents(fields[0])
+					return nil
+				}
+			case action == "list" && len(fields) == 0:
+				return func() tea.Msg {
+					chatClient.ListEventSubscriptions()
+					return nil
+				}
+			}
+
+			c.addSystemNote("usage: /events subscribe <url> <event,...> [keyword,...] | unsubscribe <id> | list")
+			c.addSystemNote("events: message_posted, user_joined, user_left, keyword_mentioned")
+			return nil
+		},
+	},
+}
+
+func renameCmd(chatClient ChatClient, name string) tea.Cmd {
+	return func() tea.Msg {
+		chatClient.Rename(name)
+		return nil
+	}
+}
+
+func setPresenceCmd(chatClient ChatClient, presence gchad.Presence, statusText string) tea.Cmd {
+	return func() tea.Msg {
+		chatClient.SetPresence(presence, statusText)
+		return nil
+	}
+}
+
+func listRoomsCmd(chatClient ChatClient) tea.Cmd {
+	return func() tea.Msg {
+		chatClient.ListRooms()
+		return nil
+	}
+}
+
+func reportActivityCmd(chatClient ChatClient) tea.Cmd {
+	return func() tea.Msg {
+		chatClient.ReportActivity()
+		return nil
+	}
+}
+
+func commandNames() []string {
+	names := []string{"help"}
+	for _, command := range chatCommands {
+		names = append(names, command.name)
+	}
+
+	return names
+}
+
+func isCommand(input string) bool {
+	return strings.HasPrefix(input, commandPrefix)
+}
+
+// runCommand executes a slash command typed into the chat input
+func (c *Chat) runCommand(input string) tea.Cmd {
+	name, args, _ := strings.Cut(strings.TrimPrefix(input, commandPrefix), " ")
+	args = strings.TrimSpace(args)
+
+	if name == "help" {
+		for _, command := range chatCommands {
+			c.addSystemNote(fmt.Sprintf("%s - %s", command.usage, command.help))
+		}
+		return nil
+	}
+
+	for _, command := range chatCommands {
+		if command.name == name {
+			return command.run(c, args)
+		}
+	}
+
+	c.addSystemNote(fmt.Sprintf("unknown command %s%s", commandPrefix, name))
+
+	return nil
+}
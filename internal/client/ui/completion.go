@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+)
+
+// completion cycles through the candidates for the word being completed on repeated tabs
+type completion struct {
+	active     bool
+	start      int // byte offset of the completed word in the input
+	candidates []string
+	index      int
+}
+
+func (c *completion) reset() {
+	*c = completion{}
+}
+
+// next returns the input with the word replaced by the next candidate
+func (c *completion) next(input string) string {
+	c.index = (c.index + 1) % len(c.candidates)
+
+	return input[:c.start] + c.candidates[c.index]
+}
+
+// completionCandidates works out what the last word of the input could be completed to.
+// The first word starting with the command prefix completes to commands, anything else to
+// member names, keeping an @ the user typed in front of the name.
+func completionCandidates(input string, members []string, commands []string) (int, []string) {
+	start := strings.LastIndexAny(input, " \t\n") + 1
+	word := input[start:]
+	candidates := make([]string, 0)
+
+	switch {
+	case start == 0 && strings.HasPrefix(word, commandPrefix):
+		for _, command := range commands {
+			if strings.HasPrefix(command, strings.TrimPrefix(word, commandPrefix)) {
+				candidates = append(candidates, commandPrefix+command+" ")
+			}
+		}
+
+	case word != "":
+		mention := strings.HasPrefix(word, "@")
+		prefix := strings.ToLower(strings.TrimPrefix(word, "@"))
+
+		for _, member := range members {
+			if !strings.HasPrefix(strings.ToLower(member), prefix) {
+				continue
+			}
+
+			candidate := member
+			if mention {
+				candidate = "@" + candidate
+			}
+			// address the member when the name is the only thing typed, IRC style
+			if start == 0 {
+				candidate += ":"
+			}
+			candidates = append(candidates, candidate+" ")
+		}
+	}
+
+	sort.Strings(candidates)
+
+	return start, candidates
+}
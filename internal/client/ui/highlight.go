@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// keywords is a small cross-language keyword set; good enough to make code blocks readable
+// without pulling in a full lexer
+var keywords = map[string]struct{}{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		break case catch class const continue def default defer do elif else enum export extends
+		false fn for from func function go if impl import in interface let match mut nil none null
+		package pub raise return select self static struct switch this throw true try type use var
+		while with yield async await lambda None True False`) {
+		keywords[k] = struct{}{}
+	}
+}
+
+// lineComment returns the line comment marker used by the language, defaulting to //
+func lineComment(language string) string {
+	switch strings.ToLower(language) {
+	case "python", "py", "sh", "bash", "shell", "zsh", "ruby", "rb", "yaml", "yml", "toml":
+		return "#"
+	case "sql", "lua", "haskell", "hs":
+		return "--"
+	default:
+		return "//"
+	}
+}
+
+// highlightCode colours a single line of code
+func highlightCode(line, language string) string {
+	var out strings.Builder
+	comment := lineComment(language)
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		r := rune(line[i])
+
+		switch {
+		case strings.HasPrefix(rest, comment):
+			out.WriteString(codeCommentStyle.Render(rest))
+			return out.String()
+
+		case r == '"' || r == '\'' || r == '`':
+			end := closingQuote(rest, line[i])
+			out.WriteString(codeStringStyle.Render(rest[:end]))
+			i += end
+
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(line) && (isWordByte(line[end]) || line[end] == '.') {
+				end++
+			}
+			out.WriteString(codeNumberStyle.Render(line[i:end]))
+			i = end
+
+		case isWordByte(line[i]):
+			end := i
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			if _, ok := keywords[word]; ok {
+				out.WriteString(codeKeywordStyle.Render(word))
+			} else {
+				out.WriteString(codePlainStyle.Render(word))
+			}
+			i = end
+
+		default:
+			end := i + 1
+			for end < len(line) && !isWordByte(line[end]) && !strings.ContainsRune("\"'`", rune(line[end])) &&
+				!strings.HasPrefix(line[end:], comment) {
+				end++
+			}
+			out.WriteString(codePlainStyle.Render(line[i:end]))
+			i = end
+		}
+	}
+
+	return out.String()
+}
+
+// closingQuote returns the index just past the closing quote, or the end of the line
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= 0x80 || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
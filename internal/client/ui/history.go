@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type HistoryStore interface {
+	Load() ([]string, error)
+	Append(entry string) error
+}
+
+// InputHistory lets the user walk through previously sent messages, shell style
+type InputHistory struct {
+	store   HistoryStore
+	entries []string
+	// cursor points at the entry being shown, len(entries) meaning the draft being typed
+	cursor int
+	draft  string
+}
+
+func NewInputHistory(store HistoryStore) *InputHistory {
+	return &InputHistory{store: store}
+}
+
+// Load reads the persisted history. The in-memory history keeps working if it fails.
+func (h *InputHistory) Load() error {
+	entries, err := h.store.Load()
+	if err != nil {
+		return err
+	}
+
+	h.entries = entries
+	h.cursor = len(h.entries)
+
+	return nil
+}
+
+func (h *InputHistory) Add(entry string) error {
+	h.cursor = len(h.entries)
+	h.draft = ""
+
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return nil
+	}
+
+	h.entries = append(h.entries, entry)
+	h.cursor = len(h.entries)
+
+	return h.store.Append(entry)
+}
+
+// Previous moves one entry back, remembering what was typed so far when leaving the draft
+func (h *InputHistory) Previous(current string) (string, bool) {
+	if h.cursor == 0 {
+		return "", false
+	}
+	if h.cursor == len(h.entries) {
+		h.draft = current
+	}
+
+	h.cursor--
+	return h.entries[h.cursor], true
+}
+
+// Next moves one entry forward, ending up back at the draft
+func (h *InputHistory) Next() (string, bool) {
+	if h.cursor >= len(h.entries) {
+		return "", false
+	}
+
+	h.cursor++
+	if h.cursor == len(h.entries) {
+		return h.draft, true
+	}
+
+	return h.entries[h.cursor], true
+}
+
+// Search looks for the most recent entry containing query, older than the entry at before.
+// It returns the position of the match so that searching again continues from there.
+func (h *InputHistory) Search(query string, before int) (string, int, bool) {
+	if before > len(h.entries) {
+		before = len(h.entries)
+	}
+
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return h.entries[i], i, true
+		}
+	}
+
+	return "", 0, false
+}
+
+func (h *InputHistory) Len() int {
+	return len(h.entries)
+}
+
+// Reset puts the cursor back on the draft
+func (h *InputHistory) Reset() {
+	h.cursor = len(h.entries)
+	h.draft = ""
+}
+
+// historySearch is the state of a ctrl+r reverse search through the input history
+type historySearch struct {
+	active   bool
+	query    string
+	match    string
+	position int
+	found    bool
+	original string // the input before searching, restored when the search is cancelled
+}
+
+func (c *Chat) startSearch() {
+	c.search = historySearch{
+		active:   true,
+		position: c.history.Len(),
+		original: c.input.Value(),
+	}
+}
+
+func (c *Chat) updateSearch(msg tea.KeyMsg) {
+	switch {
+	case key.Matches(msg, c.bindings.CtrlR):
+		// look further back for the same query
+		c.runSearch(c.search.position)
+
+	case key.Matches(msg, c.bindings.Enter):
+		if c.search.found {
+			c.setInput(c.search.match)
+		}
+		c.search = historySearch{}
+
+	case key.Matches(msg, c.bindings.Esc):
+		c.setInput(c.search.original)
+		c.search = historySearch{}
+
+	case msg.Type == tea.KeyBackspace:
+		if query := []rune(c.search.query); len(query) > 0 {
+			c.search.query = string(query[:len(query)-1])
+		}
+		c.runSearch(c.history.Len())
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		c.search.query += string(msg.Runes)
+		c.runSearch(c.history.Len())
+	}
+}
+
+func (c *Chat) runSearch(before int) {
+	if c.search.query == "" {
+		c.search.found = false
+		return
+	}
+
+	match, position, found := c.history.Search(c.search.query, before)
+	if found {
+		c.search.match = match
+		c.search.position = position
+	}
+	c.search.found = found
+}
+
+func (c Chat) searchView() string {
+	state := "reverse-i-search"
+	if !c.search.found && c.search.query != "" {
+		state = "failed reverse-i-search"
+	}
+
+	return searchPromptStyle.Render(fmt.Sprintf("(%s)`%s': ", state, c.search.query)) + c.search.match
+}
@@ -0,0 +1,209 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Keymaps are the bindings of every screen, the app ones being active alongside the chat ones
+type Keymaps struct {
+	Login LoginScreenKeymap
+	Chat  ChatScreenKeymap
+	App   AppKeymap
+}
+
+func DefaultKeymaps() Keymaps {
+	return Keymaps{
+		Login: DefaultLoginScreenKeymap,
+		Chat:  DefaultChatScreenKeymap,
+		App:   DefaultAppKeymap,
+	}
+}
+
+// KeymapOverrides rebinds the bindings of each screen by name to other keys, e.g.
+// {"chat": {"find": ["ctrl+f"]}}. An empty list of keys unbinds the binding.
+type KeymapOverrides map[string]map[string][]string
+
+// namedBinding is a binding along with the name it is configured by
+type namedBinding struct {
+	name    string
+	binding *key.Binding
+}
+
+func (km *LoginScreenKeymap) named() []namedBinding {
+	return []namedBinding{
+		{"quit", &km.CtrlC},
+		{"submit", &km.Enter},
+	}
+}
+
+// named lists the bindings active while typing a message, the ones active in viewport mode
+// and the ones active in both
+func (km *ChatScreenKeymap) named() (input []namedBinding, viewport []namedBinding, both []namedBinding) {
+	input = []namedBinding{
+		{"submit", &km.Enter},
+		{"newline", &km.Newline},
+		{"complete", &km.Tab},
+		{"history_previous", &km.Up},
+		{"history_next", &km.Down},
+		{"history_search", &km.CtrlR},
+	}
+	viewport = []namedBinding{
+		{"find", &km.Find},
+		{"next_match", &km.NextMatch},
+		{"previous_match", &km.PrevMatch},
+		{"scroll_up", &km.ScrollUp},
+		{"scroll_down", &km.ScrollDown},
+		{"page_up", &km.PageUp},
+		{"page_down", &km.PageDown},
+		{"half_page_up", &km.HalfPageUp},
+		{"half_page_down", &km.HalfPageDown},
+		{"top", &km.Top},
+		{"end", &km.End},
+		{"help", &km.Help},
+	}
+	both = []namedBinding{
+		{"quit", &km.CtrlC},
+		{"toggle_focus", &km.Esc},
+		{"leave", &km.CtrlD},
+		{"toggle_members", &km.CtrlT},
+		{"bottom", &km.Bottom},
+		{"retry", &km.Retry},
+	}
+
+	return input, viewport, both
+}
+
+func (km *AppKeymap) named() []namedBinding {
+	named := []namedBinding{
+		{"next_room", &km.NextTab},
+		{"previous_room", &km.PrevTab},
+		{"open_room", &km.OpenRoom},
+	}
+	for i := range km.Tabs {
+		named = append(named, namedBinding{fmt.Sprintf("room_%d", i+1), &km.Tabs[i]})
+	}
+
+	return named
+}
+
+// Override returns the keymaps with the overrides applied, failing on names it does not know
+// and on keys bound twice among the bindings active at the same time
+func (k Keymaps) Override(overrides KeymapOverrides) (Keymaps, error) {
+	// the bindings are values, copy the slice so that the defaults are left alone
+	k.App.Tabs = append([]key.Binding(nil), k.App.Tabs...)
+
+	input, viewport, both := k.Chat.named()
+	chat := append(append(append([]namedBinding{}, input...), viewport...), both...)
+	screens := map[string][]namedBinding{
+		"login": k.Login.named(),
+		"chat":  chat,
+		"app":   k.App.named(),
+	}
+
+	for _, screen := range sortedKeys(overrides) {
+		bindings, ok := screens[screen]
+		if !ok {
+			return Keymaps{}, fmt.Errorf("unknown screen %q in the key bindings", screen)
+		}
+		for _, name := range sortedKeys(overrides[screen]) {
+			if err := rebind(bindings, name, overrides[screen][name]); err != nil {
+				return Keymaps{}, fmt.Errorf("%s: %w", screen, err)
+			}
+		}
+	}
+
+	app := k.App.named()
+	active := [][]namedBinding{
+		k.Login.named(),
+		append(append(append([]namedBinding{}, input...), both...), app...),
+		append(append(append([]namedBinding{}, viewport...), both...), app...),
+	}
+	for _, bindings := range active {
+		if err := checkConflicts(bindings); err != nil {
+			return Keymaps{}, err
+		}
+	}
+
+	return k, nil
+}
+
+func rebind(bindings []namedBinding, name string, keys []string) error {
+	for _, named := range bindings {
+		if named.name != name {
+			continue
+		}
+
+		if len(keys) == 0 {
+			named.binding.SetEnabled(false)
+			return nil
+		}
+		named.binding.SetKeys(keys...)
+		named.binding.SetHelp(strings.Join(keys, "/"), named.binding.Help().Desc)
+		named.binding.SetEnabled(true)
+		return nil
+	}
+
+	return fmt.Errorf("unknown key binding %q", name)
+}
+
+// checkConflicts fails if a key is bound to more than one of the bindings
+func checkConflicts(bindings []namedBinding) error {
+	boundTo := make(map[string]string)
+
+	for _, named := range bindings {
+		if !named.binding.Enabled() {
+			continue
+		}
+		for _, k := range named.binding.Keys() {
+			if other, ok := boundTo[k]; ok && other != named.name {
+				return fmt.Errorf("%s is bound to both %s and %s", k, other, named.name)
+			}
+			boundTo[k] = named.name
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (km ChatScreenKeymap) ShortHelp() []key.Binding {
+	return []key.Binding{km.Help, km.Esc, km.Find, km.CtrlD}
+}
+
+// FullHelp groups the bindings into columns: writing, reading, searching and the rest
+func (km ChatScreenKeymap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{km.Enter, km.Newline, km.Tab, km.Up, km.Down, km.CtrlR, km.Retry},
+		{km.ScrollUp, km.ScrollDown, km.PageUp, km.PageDown, km.HalfPageUp, km.HalfPageDown, km.Top, km.End},
+		{km.Find, km.NextMatch, km.PrevMatch, km.Bottom},
+		{km.Esc, km.CtrlT, km.CtrlD, km.Help, km.CtrlC},
+	}
+}
+
+func (km AppKeymap) ShortHelp() []key.Binding {
+	return []key.Binding{km.NextTab, km.PrevTab, km.OpenRoom}
+}
+
+// FullHelp folds the tab bindings into a single entry, they only differ by the room number
+func (km AppKeymap) FullHelp() [][]key.Binding {
+	rooms := []key.Binding{km.NextTab, km.PrevTab, km.OpenRoom}
+	if len(km.Tabs) > 0 {
+		first, last := km.Tabs[0].Help().Key, km.Tabs[len(km.Tabs)-1].Help().Key
+		rooms = append(rooms, key.NewBinding(key.WithKeys(first), key.WithHelp(first+"…"+last, "room by number")))
+	}
+
+	return [][]key.Binding{rooms}
+}
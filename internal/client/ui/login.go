@@ -4,27 +4,24 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-var appLogo = textAboveStyle.Render("\n" +
+const appLogo = "\n" +
 	"            _               _\n" +
 	"  __ _  ___| |__   __ _  __| |\n" +
 	" / _` |/ __| '_ \\ / _` |/ _` |\n" +
 	"| (_| | (__| | | | (_| | (_| |\n" +
 	" \\__, |\\___|_| |_|\\__,_|\\__,_|\n" +
-	" |___/\n")
+	" |___/\n"
 
 var rootStyle = lipgloss.NewStyle().
 	Padding(2)
 
-var textAboveStyle = lipgloss.NewStyle().
-	Foreground(lipgloss.Color("#FF5F87")).
-	Bold(true)
-
 type failedToConnectToChat struct {
 	err error
 }
@@ -45,6 +42,10 @@ type LoginScreenKeymap struct {
 	Enter key.Binding
 }
 
+func (km *LoginScreenKeymap) ShortHelp() []key.Binding {
+	return []key.Binding{km.Enter, km.CtrlC}
+}
+
 func (km *LoginScreenKeymap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{km.CtrlC, km.Enter},
@@ -67,6 +68,7 @@ type Login struct {
 	textAboveInput string
 	input          textinput.Model
 	bindings       LoginScreenKeymap
+	help           help.Model
 	width          int
 	height         int
 }
@@ -88,6 +90,7 @@ func InitialLoginModel(textAboveInput string, bindings LoginScreenKeymap, chatCl
 		textAboveInput: textAboveInput,
 		input:          input,
 		bindings:       bindings,
+		help:           help.New(),
 		chatClient:     chatClient,
 	}
 }
@@ -147,7 +150,7 @@ func (l Login) View() string {
 	logo := textAboveStyle.Render(appLogo)
 
 	styledText := textAboveStyle.Render(l.textAboveInput)
-	inputSection := fmt.Sprintf("%s\n\n%s", styledText, l.input.View())
+	inputSection := fmt.Sprintf("%s\n\n%s\n\n%s", styledText, l.input.View(), l.help.View(&l.bindings))
 
 	content := lipgloss.JoinHorizontal(lipgloss.Center, logo, "    ", inputSection)
 	box := rootStyle.Render(content)
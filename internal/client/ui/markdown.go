@@ -0,0 +1,243 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const codeFence = "```"
+
+// block is either a run of prose or a fenced code block
+type block struct {
+	code     bool
+	language string
+	start    int // byte offset of the first line in the text
+	lines    []string
+}
+
+// splitBlocks separates fenced code blocks from the prose around them.
+// An unterminated fence runs to the end of the text.
+func splitBlocks(text string) []block {
+	blocks := make([]block, 0)
+	current := block{}
+	offset := 0
+
+	for _, line := range strings.Split(text, "\n") {
+		lineStart := offset
+		offset += len(line) + 1
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, codeFence) {
+			if len(current.lines) > 0 || current.code {
+				blocks = append(blocks, current)
+			}
+			if current.code {
+				current = block{start: offset}
+			} else {
+				language := strings.TrimSpace(strings.TrimPrefix(trimmed, codeFence))
+				current = block{code: true, language: language, start: offset}
+			}
+			continue
+		}
+
+		if len(current.lines) == 0 {
+			current.start = lineStart
+		}
+		current.lines = append(current.lines, line)
+	}
+
+	if len(current.lines) > 0 || current.code {
+		blocks = append(blocks, current)
+	}
+
+	return blocks
+}
+
+// renderMarkdown renders the markdown subset we support: **bold**, *italics* or _italics_,
+// `inline code` and fenced code blocks. Code blocks always start on a new line
+// and are wrapped to width, the caller is expected to wrap the prose.
+func renderMarkdown(text string, mentions []gchad.Mention, width int, highlight string) string {
+	var rendered strings.Builder
+
+	for i, b := range splitBlocks(text) {
+		if i > 0 || b.code {
+			rendered.WriteString("\n")
+		}
+
+		if b.code {
+			rendered.WriteString(renderCodeBlock(b, width))
+			continue
+		}
+
+		prose := strings.Join(b.lines, "\n")
+		rendered.WriteString(renderProse(prose, shiftMentions(mentions, b.start, len(prose)), highlight))
+	}
+
+	return rendered.String()
+}
+
+// shiftMentions returns the mentions falling into text[start:start+length], relative to start
+func shiftMentions(mentions []gchad.Mention, start, length int) []gchad.Mention {
+	shifted := make([]gchad.Mention, 0, len(mentions))
+
+	for _, mention := range mentions {
+		if mention.Start >= start && mention.End <= start+length {
+			shifted = append(shifted, gchad.Mention{
+				Name:  mention.Name,
+				Start: mention.Start - start,
+				End:   mention.End - start,
+			})
+		}
+	}
+
+	return shifted
+}
+
+// renderProse styles the text, highlighting the mentioned names and the occurrences of highlight.
+// Mentions in code spans are left as code.
+func renderProse(text string, mentions []gchad.Mention, highlight string) string {
+	var rendered strings.Builder
+	position := 0
+	spans := codeSpans(text)
+
+	for _, mention := range mentions {
+		if mention.Start < position || mention.End > len(text) || mention.Start >= mention.End {
+			continue
+		}
+		if slices.ContainsFunc(spans, func(span [2]int) bool { return mention.Start < span[1] && mention.End > span[0] }) {
+			continue
+		}
+
+		rendered.WriteString(renderInline(text[position:mention.Start], highlight))
+		rendered.WriteString(mentionStyle.Render(text[mention.Start:mention.End]))
+		position = mention.End
+	}
+	rendered.WriteString(renderInline(text[position:], highlight))
+
+	return rendered.String()
+}
+
+// renderInline applies the inline markdown styles to a single piece of prose
+func renderInline(text string, highlight string) string {
+	var out strings.Builder
+	var plain strings.Builder
+
+	flush := func() {
+		if plain.Len() > 0 {
+			out.WriteString(highlightMatches(plain.String(), highlight, textStyle))
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		// spans never cross lines
+		line := rest
+		if n := strings.IndexByte(rest, '\n'); n >= 0 {
+			line = rest[:n]
+		}
+
+		switch {
+		case rest[0] == '\n':
+			// styles are applied per line, lipgloss would pad a multiline block
+			flush()
+			out.WriteByte('\n')
+			i++
+			continue
+
+		case strings.HasPrefix(rest, "`"):
+			if end := codeSpanEnd(line); end > 0 {
+				flush()
+				out.WriteString(highlightMatches(rest[1:end-1], highlight, inlineCodeStyle))
+				i += end
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**"):
+			if end := strings.Index(line[2:], "**"); end > 0 {
+				flush()
+				out.WriteString(highlightMatches(rest[2:end+2], highlight, boldStyle))
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(rest, "*") || strings.HasPrefix(rest, "_"):
+			marker := rest[:1]
+			end := strings.Index(line[1:], marker)
+			// snake_case and a * b are not emphasis
+			if end > 0 && opensEmphasis(text, i) && !unicode.IsSpace(rune(rest[1])) {
+				flush()
+				out.WriteString(highlightMatches(rest[1:end+1], highlight, italicStyle))
+				i += end + 2
+				continue
+			}
+		}
+
+		plain.WriteByte(text[i])
+		i++
+	}
+	flush()
+
+	return out.String()
+}
+
+// codeSpanEnd is the length of the code span line starts with, backticks included, or 0 if it
+// does not start with one
+func codeSpanEnd(line string) int {
+	if !strings.HasPrefix(line, "`") {
+		return 0
+	}
+	if end := strings.Index(line[1:], "`"); end > 0 {
+		return end + 2
+	}
+
+	return 0
+}
+
+// codeSpans are the byte ranges of the code spans of the text, as renderInline finds them
+func codeSpans(text string) [][2]int {
+	spans := make([][2]int, 0)
+
+	for i := 0; i < len(text); i++ {
+		line := text[i:]
+		if n := strings.IndexByte(line, '\n'); n >= 0 {
+			line = line[:n]
+		}
+		if end := codeSpanEnd(line); end > 0 {
+			spans = append(spans, [2]int{i, i + end})
+			i += end - 1
+		}
+	}
+
+	return spans
+}
+
+func opensEmphasis(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	previous := rune(text[i-1])
+	return unicode.IsSpace(previous) || unicode.IsPunct(previous)
+}
+
+func renderCodeBlock(b block, width int) string {
+	lines := make([]string, 0, len(b.lines))
+	// the padding takes two columns
+	codeWidth := width - 2
+
+	for _, line := range b.lines {
+		line = highlightCode(strings.ReplaceAll(line, "\t", "    "), b.language)
+		// long lines carry on below rather than being cut, code being no use with a part missing
+		if codeWidth > 0 && ansi.StringWidth(line) > codeWidth {
+			line = ansi.Hardwrap(line, codeWidth, true)
+		}
+		lines = append(lines, line)
+	}
+
+	return codeBlockStyle.Render(strings.Join(lines, "\n"))
+}
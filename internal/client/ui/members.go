@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const memberListWidth = 24
+
+func roleBadge(role gchad.Role) string {
+	switch role {
+	case gchad.RoleModerator:
+		return " " + moderatorBadgeStyle.Render("★")
+	default:
+		return ""
+	}
+}
+
+func botBadge(bot bool) string {
+	if !bot {
+		return ""
+	}
+
+	return " " + botBadgeStyle.Render("bot")
+}
+
+func presenceIndicator(presence gchad.Presence) string {
+	switch presence {
+	case gchad.PresenceAway:
+		return presenceAwayStyle.Render("◐")
+	case gchad.PresenceDoNotDisturb:
+		return presenceDndStyle.Render("⊘")
+	default:
+		return presenceOnlineStyle.Render("●")
+	}
+}
+
+type MemberList struct {
+	members []gchad.Member
+	height  int
+}
+
+func NewMemberList() MemberList {
+	return MemberList{}
+}
+
+func (m MemberList) View() string {
+	// the border and the padding take two columns
+	contentWidth := memberListWidth - 2
+	lines := make([]string, 0, len(m.members))
+
+	for _, member := range m.members {
+		badge := roleBadge(member.Role) + botBadge(member.Bot)
+		name := truncate(member.Name, contentWidth-2-lipgloss.Width(badge))
+		lines = append(lines, presenceIndicator(member.Presence)+" "+nicknameStyle(member.Name).UnsetBold().Render(name)+badge)
+
+		if member.StatusText != "" {
+			lines = append(lines, "  "+memberStatusTextStyle.Render(truncate(member.StatusText, contentWidth-2)))
+		}
+	}
+
+	return memberListStyle.
+		Width(memberListWidth - 1).
+		Height(m.height).
+		MaxHeight(m.height).
+		Render(strings.Join(lines, "\n"))
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	if ansi.StringWidth(s) <= width {
+		return s
+	}
+
+	// measured in cells rather than runes, wide characters take two
+	return ansi.Truncate(s, width, "…")
+}
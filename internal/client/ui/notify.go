@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// DesktopNotifier draws the user's attention to something happening in the chat
+type DesktopNotifier interface {
+	Notify(title string, body string)
+}
+
+// TerminalNotifier rings the terminal bell and emits an OSC 9 notification,
+// which terminals supporting it turn into a desktop notification
+type TerminalNotifier struct {
+	out io.Writer
+}
+
+func NewTerminalNotifier(out io.Writer) *TerminalNotifier {
+	return &TerminalNotifier{out}
+}
+
+func (n *TerminalNotifier) Notify(title string, body string) {
+	_, _ = fmt.Fprintf(n.out, "\a\x1b]9;%s: %s\a", stripControl(title), stripControl(body))
+}
+
+// stripControl keeps text received from others from smuggling escape sequences into the terminal
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
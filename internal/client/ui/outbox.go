@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// ackTimeout is how long a message waits for the server to acknowledge it before it is taken for failed
+const ackTimeout = 30 * time.Second
+
+// outgoing is a message of the user the server has not acknowledged, failed meaning it turned the
+// message down or never answered
+type outgoing struct {
+	key       int
+	text      string
+	timestamp time.Time
+	failed    bool
+	reason    string
+}
+
+// outbox holds the messages of the user until the server acknowledges them. The message arriving
+// in the room and its ack may come either way round, the later of the two marking it as sent.
+type outbox struct {
+	messages []outgoing
+	// acked are the ids of the acknowledged messages which have yet to arrive in the room
+	acked map[string]bool
+	// echoed are the ids of the messages of the user which arrived ahead of their ack
+	echoed map[string]bool
+	keys   int
+}
+
+// messageSent tells the chat of the room how an outgoing message went
+type messageSent struct {
+	room string
+	key  int
+	ack  gchad.AckMessage
+	err  error
+}
+
+func sendMessageCmd(chatClient ChatClient, key int, text string) tea.Cmd {
+	room := chatClient.Room()
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+		defer cancel()
+
+		ack, err := chatClient.SendMessage(ctx, text)
+		return messageSent{room, key, ack, err}
+	}
+}
+
+// send puts the message in the outbox, returning the command sending it
+func (c *Chat) send(text string) tea.Cmd {
+	c.outbox.keys++
+	c.outbox.messages = append(c.outbox.messages, outgoing{key: c.outbox.keys, text: text, timestamp: time.Now()})
+
+	return sendMessageCmd(c.chatClient, c.outbox.keys, text)
+}
+
+// retry sends the oldest failed message again
+func (c *Chat) retry() tea.Cmd {
+	for i, msg := range c.outbox.messages {
+		if msg.failed {
+			c.outbox.messages[i].failed = false
+			c.outbox.messages[i].reason = ""
+			return sendMessageCmd(c.chatClient, msg.key, msg.text)
+		}
+	}
+
+	return nil
+}
+
+// settle takes an acknowledged message out of the outbox and marks a failed one as such
+func (c *Chat) settle(msg messageSent) {
+	i := slices.IndexFunc(c.outbox.messages, func(o outgoing) bool { return o.key == msg.key })
+	if i < 0 {
+		return
+	}
+
+	if msg.err != nil {
+		c.outbox.messages[i].failed = true
+		c.outbox.messages[i].reason = failureText(msg.err)
+	} else {
+		c.outbox.messages = slices.Delete(c.outbox.messages, i, i+1)
+		c.acknowledged(msg.ack.Id)
+	}
+	// the ack of whatever arrived ahead of it is not coming anymore
+	if !c.outbox.sending() {
+		c.outbox.echoed = nil
+	}
+}
+
+func (c *Chat) acknowledged(id string) {
+	// servers that predate acks give no id, their messages arrive unmarked
+	if id == "" {
+		return
+	}
+
+	if c.outbox.echoed[id] {
+		delete(c.outbox.echoed, id)
+		c.messages.markSent(id)
+		return
+	}
+	if c.outbox.acked == nil {
+		c.outbox.acked = make(map[string]bool)
+	}
+	c.outbox.acked[id] = true
+}
+
+// arrived tells whether the chat message is one the server acknowledged, remembering a message of
+// the user that overtook its ack so that the ack marks it once it comes
+func (c *Chat) arrived(msg gchad.ChatMessage) bool {
+	if c.outbox.acked[msg.Id] {
+		delete(c.outbox.acked, msg.Id)
+		return true
+	}
+
+	if msg.Id != "" && msg.From == c.statusLine.connectedAs && c.outbox.sending() {
+		if c.outbox.echoed == nil {
+			c.outbox.echoed = make(map[string]bool)
+		}
+		c.outbox.echoed[msg.Id] = true
+	}
+
+	return false
+}
+
+// sending tells whether any message is waiting for its ack
+func (o *outbox) sending() bool {
+	return slices.ContainsFunc(o.messages, func(msg outgoing) bool { return !msg.failed })
+}
+
+func failureText(err error) string {
+	var rejection gchad.ErrorMessage
+	switch {
+	case errors.As(err, &rejection):
+		return rejection.Message
+	case errors.Is(err, context.DeadlineExceeded):
+		return "no answer from the server"
+	default:
+		return err.Error()
+	}
+}
+
+// outboxView renders the outgoing messages as they will look in the room, each with how it is going
+func (c *Chat) outboxView(width int) string {
+	rendered := make([]string, 0, len(c.outbox.messages))
+	for _, msg := range c.outbox.messages {
+		entry := Entry{kind: chatEntry, timestamp: msg.timestamp, from: c.statusLine.connectedAs, text: msg.text}
+		status := sendingStyle.Render("sending…")
+		if msg.failed {
+			status = sendFailedStyle.Render("not sent: " + msg.reason + ", " + c.bindings.Retry.Help().Key + " to try again")
+		}
+		rendered = append(rendered, entry.render(width, "", false)+"\n"+hangingIndent(strings.Repeat(" ", gutterWidth), status, gutterWidth, width))
+	}
+
+	return strings.Join(rendered, "\n")
+}
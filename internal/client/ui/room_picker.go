@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const roomPickerWidth = 36
+
+// roomPicked asks the app to open the room in a tab, or to switch to it if it is already open
+type roomPicked struct {
+	room string
+}
+
+// RoomPicker lists the rooms the server hosts, as told by the last gchad.RoomListMessage
+type RoomPicker struct {
+	rooms    []gchad.RoomSummary
+	joined   map[string]bool
+	selected int
+	loading  bool
+}
+
+func NewRoomPicker() RoomPicker {
+	return RoomPicker{}
+}
+
+// open shows the picker while the room list is being fetched
+func (p *RoomPicker) open(joined map[string]bool) {
+	p.joined = joined
+	p.loading = true
+}
+
+func (p *RoomPicker) setRooms(rooms []gchad.RoomSummary) {
+	p.rooms = rooms
+	p.loading = false
+	p.selected = min(p.selected, max(0, len(rooms)-1))
+}
+
+// Update returns done once the picker should be closed, along with a command opening the chosen room if any
+func (p RoomPicker) Update(msg tea.KeyMsg, bindings ChatScreenKeymap) (RoomPicker, tea.Cmd, bool) {
+	switch {
+	case key.Matches(msg, bindings.Esc):
+		return p, nil, true
+
+	case key.Matches(msg, bindings.Up):
+		p.selected = max(0, p.selected-1)
+
+	case key.Matches(msg, bindings.Down):
+		p.selected = min(max(0, len(p.rooms)-1), p.selected+1)
+
+	case key.Matches(msg, bindings.Enter):
+		if len(p.rooms) == 0 {
+			return p, nil, false
+		}
+		room := p.rooms[p.selected].Id
+		return p, func() tea.Msg { return roomPicked{room} }, true
+	}
+
+	return p, nil, false
+}
+
+func (p RoomPicker) View() string {
+	lines := []string{roomPickerTitleStyle.Render("Rooms"), ""}
+
+	switch {
+	case p.loading && len(p.rooms) == 0:
+		lines = append(lines, roomPickerHintStyle.Render("loading..."))
+	case len(p.rooms) == 0:
+		lines = append(lines, roomPickerHintStyle.Render("no rooms"))
+	}
+
+	for i, room := range p.rooms {
+		marker := "  "
+		if p.joined[room.Id] {
+			marker = "• "
+		}
+		count := fmt.Sprintf("%d", room.Members)
+		name := truncate(marker+room.Name, roomPickerWidth-2-len(count)-1)
+		line := name + strings.Repeat(" ", max(1, roomPickerWidth-2-lipgloss.Width(name)-len(count))) + count
+
+		if i == p.selected {
+			lines = append(lines, roomPickerSelectedStyle.Render(line))
+		} else {
+			lines = append(lines, roomPickerItemStyle.Render(line))
+		}
+	}
+	lines = append(lines, "", roomPickerHintStyle.Render("enter join · esc close"))
+
+	return roomPickerStyle.Width(roomPickerWidth).Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scrollState keeps the viewport where the user left it while they read back
+type scrollState struct {
+	following    bool // the viewport sticks to the latest message
+	newBelow     int  // messages added below the viewport since the user scrolled up
+	renderedFrom int  // position of the first message at the last render, see MessageRingBuffer.Added
+	// how many scrollback entries came before the buffered ones at the last render
+	renderedScrollback int
+	away               bool
+	leftAt             int // how many messages there were when the user last looked away
+	hasDivider         bool
+	divider            int // position of the first message received while the user was away
+}
+
+func newScrollState() scrollState {
+	return scrollState{following: true}
+}
+
+// scrolled updates the state after the user moved the viewport
+func (c *Chat) scrolled() {
+	c.scroll.following = c.chatViewPort.AtBottom()
+	if c.scroll.following {
+		c.scroll.newBelow = 0
+	}
+	c.markMentionsSeen()
+}
+
+func (c *Chat) jumpToBottom() {
+	c.chatViewPort.GotoBottom()
+	c.scrolled()
+}
+
+// lookAway remembers what the user has seen before switching to another tab or window
+func (c *Chat) lookAway() {
+	c.scroll.away = true
+	c.scroll.leftAt = c.messages.Added()
+	c.scroll.hasDivider = false
+}
+
+// lookBack puts a divider above whatever arrived while the user was away
+func (c *Chat) lookBack() {
+	if c.scroll.away && c.messages.Added() > c.scroll.leftAt {
+		c.scroll.divider = c.scroll.leftAt
+		c.scroll.hasDivider = true
+	}
+	c.scroll.away = false
+	c.refreshViewport()
+}
+
+// dividerIndex returns where the divider goes among the messages in the buffer, -1 if nowhere
+func (c *Chat) dividerIndex() int {
+	if !c.scroll.hasDivider {
+		return -1
+	}
+
+	index := c.scroll.divider - (c.messages.Added() - c.messages.Len())
+	if index < 0 || index >= c.messages.Len() {
+		return -1
+	}
+
+	return len(c.scrollback) + index
+}
+
+// evictedLines counts the lines of messages pushed out of the buffer since the last render
+func (c *Chat) evictedLines() int {
+	evicted := (c.messages.Added() - c.messages.Len()) - c.scroll.renderedFrom
+	lines := 0
+
+	buffered := c.entryHeights[c.scroll.renderedScrollback:]
+	for i := 0; i < evicted && i < len(buffered); i++ {
+		lines += buffered[i]
+	}
+
+	return lines
+}
+
+func (c *Chat) newMessagesView() string {
+	if c.scroll.newBelow == 0 {
+		return ""
+	}
+
+	text := fmt.Sprintf(" %d new messages ↓ ", c.scroll.newBelow)
+	if c.scroll.newBelow == 1 {
+		text = " 1 new message ↓ "
+	}
+
+	return lipgloss.PlaceHorizontal(c.chatViewPort.Width, lipgloss.Right, newMessagesStyle.Render(text))
+}
+
+func renderDivider(width int) string {
+	label := " new since you left "
+	side := max(0, (width-lipgloss.Width(label))/2)
+	line := ""
+	for i := 0; i < side; i++ {
+		line += "─"
+	}
+
+	return dividerStyle.Render(line + label + line)
+}
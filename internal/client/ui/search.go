@@ -0,0 +1,276 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/iomallach/gchad/internal/client/domain"
+)
+
+// maxScrollback caps how many messages are brought back from the transcript while searching
+const maxScrollback = 1000
+
+// TranscriptStore keeps the messages of a room beyond what fits in the MessageRingBuffer
+type TranscriptStore interface {
+	Load() ([]domain.TranscriptEntry, error)
+	Append(entry domain.TranscriptEntry) error
+}
+
+// transcriptSearch is the state of a / search through the messages, on screen and in the transcript.
+// It also marks the message jumped to from the /search results, found by its id rather than a query.
+type transcriptSearch struct {
+	typing  bool
+	query   string
+	message string
+	matches []int // indexes into the displayed entries, oldest first
+	current int   // index into matches, -1 if there is none
+}
+
+func (s transcriptSearch) active() bool {
+	return s.typing || s.query != "" || s.message != ""
+}
+
+// highlight is the query to pick out in the messages, if any
+func (s transcriptSearch) highlight() string {
+	if !s.active() {
+		return ""
+	}
+
+	return s.query
+}
+
+// currentEntry is the index of the entry the search is on, -1 if none
+func (s transcriptSearch) currentEntry() int {
+	if s.current < 0 || s.current >= len(s.matches) {
+		return -1
+	}
+
+	return s.matches[s.current]
+}
+
+// highlightMatches renders text in base, picking out the case insensitive occurrences of query
+func highlightMatches(text string, query string, base lipgloss.Style) string {
+	if query == "" {
+		return base.Render(text)
+	}
+
+	haystack, needle := strings.ToLower(text), strings.ToLower(query)
+	if len(haystack) != len(text) {
+		// lower casing changed the byte offsets, fall back to an exact match
+		haystack, needle = text, query
+	}
+
+	var rendered strings.Builder
+	position := 0
+	for {
+		index := strings.Index(haystack[position:], needle)
+		if index < 0 {
+			break
+		}
+		start := position + index
+		end := start + len(needle)
+
+		if start > position {
+			rendered.WriteString(base.Render(text[position:start]))
+		}
+		rendered.WriteString(searchMatchStyle.Render(text[start:end]))
+		position = end
+	}
+	if position < len(text) {
+		rendered.WriteString(base.Render(text[position:]))
+	}
+
+	return rendered.String()
+}
+
+// addEntry shows a message from the server and keeps it in the transcript
+func (c *Chat) addEntry(entry Entry) {
+	c.messages.Add(entry)
+
+	if c.transcript == nil {
+		return
+	}
+	if err := c.transcript.Append(entry.Record()); err != nil && !c.transcriptFailed {
+		// once is enough, the disk is unlikely to recover by the next message
+		c.transcriptFailed = true
+		c.addSystemNote(fmt.Sprintf("failed to save the transcript: %s", err.Error()))
+	}
+}
+
+// displayedEntries are the messages in the viewport: whatever the transcript brought back, then the buffer
+func (c *Chat) displayedEntries() []Entry {
+	return append(append(make([]Entry, 0, len(c.scrollback)+c.messages.Len()), c.scrollback...), c.messages.Elements()...)
+}
+
+// loadScrollback brings back the transcript older than the oldest message in the buffer
+func (c *Chat) loadScrollback() {
+	c.scrollback = nil
+	if c.transcript == nil {
+		return
+	}
+
+	records, err := c.transcript.Load()
+	if err != nil {
+		c.addSystemNote(fmt.Sprintf("failed to load the transcript: %s", err.Error()))
+		return
+	}
+
+	buffered := c.messages.Elements()
+	older := make([]Entry, 0)
+	for _, record := range records {
+		if len(buffered) > 0 && !record.Timestamp.Before(buffered[0].timestamp) {
+			break
+		}
+		older = append(older, NewEntryFromRecord(record))
+	}
+	if len(older) > maxScrollback {
+		older = older[len(older)-maxScrollback:]
+	}
+
+	c.scrollback = older
+}
+
+func (c *Chat) startFind() {
+	c.find = transcriptSearch{typing: true, current: -1}
+	c.loadScrollback()
+	c.refreshViewport()
+}
+
+// closeFind drops the search along with the scrollback it brought in
+func (c *Chat) closeFind() {
+	c.find = transcriptSearch{current: -1}
+	c.scrollback = nil
+	c.refreshViewport()
+}
+
+// updateFind handles the keys while the query is being typed
+func (c *Chat) updateFind(msg tea.KeyMsg) {
+	switch {
+	case key.Matches(msg, c.bindings.Esc):
+		c.closeFind()
+		return
+
+	case key.Matches(msg, c.bindings.Enter):
+		c.find.typing = false
+		if c.find.query == "" {
+			c.closeFind()
+		}
+		return
+
+	case msg.Type == tea.KeyBackspace:
+		query := []rune(c.find.query)
+		if len(query) == 0 {
+			return
+		}
+		c.find.query = string(query[:len(query)-1])
+
+	case msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace:
+		c.find.query += string(msg.Runes)
+
+	default:
+		return
+	}
+
+	c.runFind()
+}
+
+// runFind looks for the query in every displayed message, starting from the newest match
+func (c *Chat) runFind() {
+	c.find.matches = nil
+	c.find.current = -1
+	if c.find.query != "" {
+		c.find.matches = c.matchingEntries()
+		c.find.current = len(c.find.matches) - 1
+	}
+
+	c.refreshViewport()
+	c.scrollToMatch()
+}
+
+// moveMatch steps through the matches, a negative step going to older messages
+func (c *Chat) moveMatch(step int) {
+	if len(c.find.matches) == 0 {
+		return
+	}
+
+	c.find.current = (c.find.current + step + len(c.find.matches)) % len(c.find.matches)
+	c.refreshViewport()
+	c.scrollToMatch()
+}
+
+// scrollToMatch brings the current match into view, a third of the way down the viewport
+func (c *Chat) scrollToMatch() {
+	current := c.find.currentEntry()
+	if current < 0 {
+		return
+	}
+
+	line := 0
+	for i := 0; i < current && i < len(c.entryHeights); i++ {
+		line += c.entryHeights[i]
+	}
+
+	c.chatViewPort.SetYOffset(max(0, line-c.chatViewPort.Height/3))
+	c.scrolled()
+}
+
+func (c Chat) findView() string {
+	if c.find.message != "" {
+		return searchPromptStyle.Render("search result  esc close")
+	}
+
+	status := ""
+	switch {
+	case c.find.query == "":
+	case len(c.find.matches) == 0:
+		status = "  no matches"
+	default:
+		status = fmt.Sprintf("  %d/%d", c.find.current+1, len(c.find.matches))
+		if !c.find.typing {
+			status += "  n older · N newer · esc close"
+		}
+	}
+
+	return searchPromptStyle.Render("/") + c.find.query + searchPromptStyle.Render(status)
+}
+
+// followMatches finds the matches again after messages came in, staying on the same match
+// even if the buffer pushed older messages out
+func (c *Chat) followMatches() {
+	if c.find.query == "" && c.find.message == "" {
+		return
+	}
+
+	current := c.find.currentEntry()
+	if current >= len(c.scrollback) {
+		current -= (c.messages.Added() - c.messages.Len()) - c.scroll.renderedFrom
+	}
+
+	c.find.matches = c.matchingEntries()
+	c.find.current = -1
+	for i, match := range c.find.matches {
+		if match <= current {
+			c.find.current = i
+		}
+	}
+}
+
+// matchingEntries returns the indexes of the displayed entries containing the query, case insensitively,
+// or of the message searched for by id
+func (c *Chat) matchingEntries() []int {
+	needle := strings.ToLower(c.find.query)
+	matches := make([]int, 0)
+
+	for i, entry := range c.displayedEntries() {
+		if c.find.message != "" && entry.id == c.find.message {
+			matches = append(matches, i)
+		} else if c.find.message == "" && strings.Contains(strings.ToLower(entry.text), needle) {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches
+}
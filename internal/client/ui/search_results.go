@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// searchPageSize is how many results are asked for at a time
+const searchPageSize = 10
+
+// searchResults is a page of what the server found for /search, listed in place of the messages
+type searchResults struct {
+	open     bool
+	loading  bool
+	request  gchad.SearchMessage
+	page     gchad.SearchResultsMessage
+	selected int
+}
+
+func searchCmd(chatClient ChatClient, request gchad.SearchMessage) tea.Cmd {
+	return func() tea.Msg {
+		chatClient.Search(request)
+		return nil
+	}
+}
+
+// startServerSearch sends the /search arguments to the server and shows the results once they arrive
+func (c *Chat) startServerSearch(args string) tea.Cmd {
+	request, err := domain.ParseSearch(args)
+	if err != nil {
+		c.addSystemNote(fmt.Sprintf("usage: /search <terms> [from:nick] [before:date], %s", err.Error()))
+		return nil
+	}
+
+	c.input.Blur()
+	return c.requestResults(request)
+}
+
+func (c *Chat) requestResults(request gchad.SearchMessage) tea.Cmd {
+	request.Limit = searchPageSize
+	c.results.open = true
+	c.results.loading = true
+	c.results.request = request
+
+	return searchCmd(c.chatClient, request)
+}
+
+// showResults takes in a page of results, unless the search has been closed while waiting for it
+func (c *Chat) showResults(page gchad.SearchResultsMessage) {
+	if !c.results.open {
+		return
+	}
+
+	c.results.page = page
+	c.results.loading = false
+	c.results.selected = 0
+}
+
+func (c *Chat) closeResults() {
+	c.results = searchResults{}
+	c.input.Focus()
+}
+
+// updateResults handles the keys while the results are listed
+func (c *Chat) updateResults(msg tea.KeyMsg) tea.Cmd {
+	page := c.results.page
+
+	switch {
+	case key.Matches(msg, c.bindings.CtrlC):
+		return tea.Quit
+
+	case key.Matches(msg, c.bindings.Esc):
+		c.closeResults()
+
+	case key.Matches(msg, c.bindings.Up):
+		c.results.selected = max(0, c.results.selected-1)
+
+	case key.Matches(msg, c.bindings.Down):
+		c.results.selected = min(max(0, len(page.Results)-1), c.results.selected+1)
+
+	case key.Matches(msg, c.bindings.Enter):
+		if c.results.loading || len(page.Results) == 0 {
+			return nil
+		}
+		id := page.Results[c.results.selected].Id
+		c.results = searchResults{}
+		c.jumpToMessage(id)
+
+	case key.Matches(msg, c.bindings.NextMatch):
+		if !c.results.loading && page.Offset+len(page.Results) < page.Total {
+			return c.requestResults(page.Request(page.Offset + len(page.Results)))
+		}
+
+	case key.Matches(msg, c.bindings.PrevMatch):
+		if !c.results.loading && page.Offset > 0 {
+			return c.requestResults(page.Request(page.Offset - searchPageSize))
+		}
+	}
+
+	return nil
+}
+
+// jumpToMessage scrolls to the message with the id, bringing back the transcript if it is no longer
+// in the buffer, and marks it the way a / search marks its current match
+func (c *Chat) jumpToMessage(id string) {
+	c.find = transcriptSearch{message: id, current: -1}
+	c.loadScrollback()
+
+	c.find.matches = c.matchingEntries()
+	if len(c.find.matches) == 0 {
+		c.closeFind()
+		c.addSystemNote("that message is not in the local transcript")
+		c.refreshViewport()
+		return
+	}
+
+	c.find.current = 0
+	c.refreshViewport()
+	c.scrollToMatch()
+}
+
+// View lists the results in a box of the given size, one line per message
+func (r searchResults) View(width int, height int) string {
+	lines := []string{resultsTitleStyle.Render(truncate(r.title(), width))}
+
+	switch {
+	case r.loading:
+		lines = append(lines, resultsHintStyle.Render("searching..."))
+	case len(r.page.Results) == 0:
+		lines = append(lines, resultsHintStyle.Render("no messages found"))
+	}
+
+	if !r.loading {
+		for i, msg := range r.page.Results {
+			text := strings.Join(strings.Fields(msg.Text), " ")
+			line := truncate(fmt.Sprintf("%s %s: %s", msg.Timestamp.Format("2006-01-02 15:04"), msg.From, text), width)
+			if i == r.selected {
+				lines = append(lines, resultsSelectedStyle.Render(line))
+			} else {
+				lines = append(lines, resultsItemStyle.Render(line))
+			}
+		}
+	}
+	lines = append(lines, "", resultsHintStyle.Render(truncate("enter jump · n older · N newer · esc close", width)))
+
+	return lipgloss.NewStyle().Width(width).Height(height).MaxHeight(height).Render(strings.Join(lines, "\n"))
+}
+
+func (r searchResults) title() string {
+	query := strings.TrimSpace(strings.Join([]string{r.request.Terms, r.filters()}, " "))
+	if r.loading || len(r.page.Results) == 0 {
+		return fmt.Sprintf("search: %s", query)
+	}
+
+	first := r.page.Offset + 1
+	last := r.page.Offset + len(r.page.Results)
+	return fmt.Sprintf("search: %s  %d-%d of %d", query, first, last, r.page.Total)
+}
+
+func (r searchResults) filters() string {
+	filters := make([]string, 0, 2)
+	if r.request.From != "" {
+		filters = append(filters, "from:"+r.request.From)
+	}
+	if r.request.Before != nil {
+		filters = append(filters, "before:"+r.request.Before.Format("2006-01-02 15:04"))
+	}
+
+	return strings.Join(filters, " ")
+}
@@ -8,45 +8,13 @@ import (
 	"github.com/iomallach/gchad/internal/client/domain"
 )
 
-var (
-	statusLeftStyle = lipgloss.NewStyle().
-			Background(CatppuccinMocha.Green).
-			Foreground(CatppuccinMocha.Base).
-			Bold(true).
-			Padding(0, 1)
-
-	statusMiddleStyle = lipgloss.NewStyle().
-				Foreground(CatppuccinMocha.Text).
-				Background(CatppuccinMocha.Crust)
-
-	statusRightStyle = lipgloss.NewStyle().
-				Background(CatppuccinMocha.Green).
-				Foreground(CatppuccinMocha.Base).
-				Bold(true).
-				Padding(0, 1)
-
-	statusRootStyle = lipgloss.NewStyle().
-			Background(CatppuccinMocha.Surface0)
-
-	leftSectionRightSeparatorStyle = lipgloss.NewStyle().
-					Foreground(CatppuccinMocha.Green).
-					Background(CatppuccinMocha.Surface0)
-
-	middleSectionSeparatorStyle = lipgloss.NewStyle().
-					Foreground(CatppuccinMocha.Crust).
-					Background(CatppuccinMocha.Surface0)
-
-	rightSectionLeftSeparatorStyle = lipgloss.NewStyle().
-					Foreground(CatppuccinMocha.Green).
-					Background(CatppuccinMocha.Surface0)
-)
-
 type StatusLine struct {
 	room             string
 	connectedAs      string
 	messagesReceived int
 	messagesSent     int
 	clientsInTheRoom int
+	mentions         int
 	width            int
 }
 
@@ -71,10 +39,18 @@ func (s StatusLine) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (s StatusLine) View() string {
-	leftSection := statusLeftStyle.Render(fmt.Sprintf(" %s", s.connectedAs))
+	leftText := fmt.Sprintf(" %s", s.connectedAs)
+	if s.room != "" {
+		leftText += fmt.Sprintf(" #%s", s.room)
+	}
+	leftSection := statusLeftStyle.Render(leftText)
 	leftSectionRightSeparator := leftSectionRightSeparatorStyle.Render("") // U+E0B0: right-pointing triangle
 
-	middleSection := statusMiddleStyle.Render(fmt.Sprintf(" In: %d Out: %d Online: %d ", s.messagesReceived, s.messagesSent, s.clientsInTheRoom))
+	middleText := fmt.Sprintf(" In: %d Out: %d Online: %d ", s.messagesReceived, s.messagesSent, s.clientsInTheRoom)
+	if s.mentions > 0 {
+		middleText += fmt.Sprintf("@%d ", s.mentions)
+	}
+	middleSection := statusMiddleStyle.Render(middleText)
 	middleSectionLeftSeparator := middleSectionSeparatorStyle.Render("")
 	middleSectionRightSeparator := middleSectionSeparatorStyle.Render("")
 
@@ -0,0 +1,353 @@
+package ui
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme colors the whole client. The styles are package wide and rebuilt by SetTheme,
+// which is expected to be called before the program starts.
+type Theme struct {
+	Name    string
+	Palette Palette
+	// Nicknames are the colors senders are told apart by, each name always getting the same one
+	Nicknames []lipgloss.Color
+	// NoColor themes leave the colors to the terminal and mark what is selected in reverse video instead
+	NoColor bool
+}
+
+func catppuccinTheme(name string, palette Palette) Theme {
+	return Theme{
+		Name:    name,
+		Palette: palette,
+		Nicknames: []lipgloss.Color{
+			palette.Rosewater, palette.Flamingo, palette.Pink, palette.Mauve, palette.Maroon, palette.Peach,
+			palette.Green, palette.Teal, palette.Sky, palette.Sapphire, palette.Blue, palette.Lavender,
+		},
+	}
+}
+
+var (
+	LatteTheme     = catppuccinTheme("latte", CatppuccinLatte)
+	FrappeTheme    = catppuccinTheme("frappe", CatppuccinFrappe)
+	MacchiatoTheme = catppuccinTheme("macchiato", CatppuccinMacchiato)
+	MochaTheme     = catppuccinTheme("mocha", CatppuccinMocha)
+	// HighContrastTheme leaves the dim shades out of the nickname colors
+	HighContrastTheme = Theme{
+		Name:      "high-contrast",
+		Palette:   HighContrast,
+		Nicknames: []lipgloss.Color{"9", "10", "11", "12", "13", "14"},
+	}
+	// NoColorTheme is what the NO_COLOR convention asks for, see https://no-color.org
+	NoColorTheme = Theme{Name: "no-color", NoColor: true}
+)
+
+// DefaultTheme is used unless the config picks another one
+var DefaultTheme = MochaTheme
+
+// BuiltinThemes are the themes available by name without defining any
+var BuiltinThemes = []Theme{LatteTheme, FrappeTheme, MacchiatoTheme, MochaTheme, HighContrastTheme, NoColorTheme}
+
+// CustomTheme is a user defined theme: a theme to start from, by name, and the colors changed in it
+// by their lower cased Palette name, e.g. {"mauve": "#ff79c6"}
+type CustomTheme struct {
+	Base      string
+	Colors    map[string]string
+	Nicknames []string
+}
+
+// colorPattern accepts what lipgloss does: hex colors and ANSI color numbers
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|#[0-9a-fA-F]{3}|[0-9]{1,3})$`)
+
+// ResolveTheme picks the theme by name among the custom and the built-in ones, custom ones shadowing
+// the built-in ones of the same name. An empty name is the DefaultTheme.
+func ResolveTheme(name string, custom map[string]CustomTheme) (Theme, error) {
+	return resolveTheme(name, custom, make(map[string]bool))
+}
+
+func resolveTheme(name string, custom map[string]CustomTheme, seen map[string]bool) (Theme, error) {
+	if name == "" {
+		return DefaultTheme, nil
+	}
+
+	definition, ok := custom[name]
+	if !ok || seen[name] {
+		for _, theme := range BuiltinThemes {
+			if theme.Name == name {
+				return theme, nil
+			}
+		}
+		return Theme{}, fmt.Errorf("unknown theme %q, pick one of %s", name, strings.Join(themeNames(custom), ", "))
+	}
+	seen[name] = true
+
+	theme, err := resolveTheme(definition.Base, custom, seen)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	theme.Name = name
+
+	colors := theme.Palette.named()
+	for _, slot := range sortedKeys(definition.Colors) {
+		color, ok := colors[slot]
+		if !ok {
+			return Theme{}, fmt.Errorf("theme %s: unknown color %q", name, slot)
+		}
+		value, err := parseColor(definition.Colors[slot])
+		if err != nil {
+			return Theme{}, fmt.Errorf("theme %s: %s: %w", name, slot, err)
+		}
+		*color = value
+	}
+
+	if len(definition.Nicknames) > 0 {
+		theme.Nicknames = make([]lipgloss.Color, 0, len(definition.Nicknames))
+		for _, value := range definition.Nicknames {
+			color, err := parseColor(value)
+			if err != nil {
+				return Theme{}, fmt.Errorf("theme %s: nicknames: %w", name, err)
+			}
+			theme.Nicknames = append(theme.Nicknames, color)
+		}
+	}
+	// colors set on top of a no-color theme are meant to be shown
+	theme.NoColor = theme.NoColor && len(definition.Colors) == 0
+
+	return theme, nil
+}
+
+func parseColor(value string) (lipgloss.Color, error) {
+	if !colorPattern.MatchString(value) {
+		return "", fmt.Errorf("invalid color %q, expected #rrggbb or an ANSI color number", value)
+	}
+	if number, err := strconv.Atoi(value); err == nil && number > 255 {
+		return "", fmt.Errorf("invalid color %q, ANSI colors go up to 255", value)
+	}
+
+	return lipgloss.Color(value), nil
+}
+
+func themeNames(custom map[string]CustomTheme) []string {
+	names := make([]string, 0, len(BuiltinThemes)+len(custom))
+	for _, theme := range BuiltinThemes {
+		names = append(names, theme.Name)
+	}
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// named points at the colors of the palette by their lower cased names
+func (p *Palette) named() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"rosewater": &p.Rosewater, "flamingo": &p.Flamingo, "pink": &p.Pink, "mauve": &p.Mauve,
+		"red": &p.Red, "maroon": &p.Maroon, "peach": &p.Peach, "yellow": &p.Yellow,
+		"green": &p.Green, "teal": &p.Teal, "sky": &p.Sky, "sapphire": &p.Sapphire,
+		"blue": &p.Blue, "lavender": &p.Lavender, "text": &p.Text, "subtext1": &p.Subtext1,
+		"subtext0": &p.Subtext0, "overlay2": &p.Overlay2, "overlay1": &p.Overlay1, "overlay0": &p.Overlay0,
+		"surface2": &p.Surface2, "surface1": &p.Surface1, "surface0": &p.Surface0, "base": &p.Base,
+		"mantle": &p.Mantle, "crust": &p.Crust,
+	}
+}
+
+// highlighted is text set on a colored background, or in reverse video when there are no colors
+func (t Theme) highlighted(foreground lipgloss.Color, background lipgloss.Color) lipgloss.Style {
+	if t.NoColor {
+		return lipgloss.NewStyle().Reverse(true)
+	}
+
+	return lipgloss.NewStyle().Foreground(foreground).Background(background)
+}
+
+var theme Theme
+
+var (
+	// messages
+	timestampStyle lipgloss.Style
+	textStyle      lipgloss.Style
+	systemStyle    lipgloss.Style
+	mentionStyle   lipgloss.Style
+	mentionMarker  string
+	sentMarker     string
+	headerStyle    lipgloss.Style
+
+	// messages on their way to the room
+	sendingStyle    lipgloss.Style
+	sendFailedStyle lipgloss.Style
+
+	// webhook posts
+	webhookBadgeStyle    lipgloss.Style
+	attachmentTitleStyle lipgloss.Style
+	attachmentUrlStyle   lipgloss.Style
+	attachmentTextStyle  lipgloss.Style
+
+	// markdown
+	boldStyle        lipgloss.Style
+	italicStyle      lipgloss.Style
+	inlineCodeStyle  lipgloss.Style
+	codeBlockStyle   lipgloss.Style
+	codeKeywordStyle lipgloss.Style
+	codeStringStyle  lipgloss.Style
+	codeNumberStyle  lipgloss.Style
+	codeCommentStyle lipgloss.Style
+	codePlainStyle   lipgloss.Style
+
+	// searching and scrolling
+	searchMatchStyle     lipgloss.Style
+	searchMarker         string
+	searchPromptStyle    lipgloss.Style
+	resultsTitleStyle    lipgloss.Style
+	resultsItemStyle     lipgloss.Style
+	resultsSelectedStyle lipgloss.Style
+	resultsHintStyle     lipgloss.Style
+	newMessagesStyle     lipgloss.Style
+	errorBannerStyle     lipgloss.Style
+	dividerStyle         lipgloss.Style
+
+	// tabs, room picker and help
+	tabStyle                lipgloss.Style
+	activeTabStyle          lipgloss.Style
+	unreadStyle             lipgloss.Style
+	mentionsStyle           lipgloss.Style
+	helpBoxStyle            lipgloss.Style
+	roomPickerStyle         lipgloss.Style
+	roomPickerTitleStyle    lipgloss.Style
+	roomPickerItemStyle     lipgloss.Style
+	roomPickerSelectedStyle lipgloss.Style
+	roomPickerHintStyle     lipgloss.Style
+
+	// member list
+	memberListStyle       lipgloss.Style
+	memberStatusTextStyle lipgloss.Style
+	presenceOnlineStyle   lipgloss.Style
+	presenceAwayStyle     lipgloss.Style
+	presenceDndStyle      lipgloss.Style
+	moderatorBadgeStyle   lipgloss.Style
+	botBadgeStyle         lipgloss.Style
+
+	// status line
+	statusLeftStyle                lipgloss.Style
+	statusMiddleStyle              lipgloss.Style
+	statusRightStyle               lipgloss.Style
+	statusRootStyle                lipgloss.Style
+	leftSectionRightSeparatorStyle lipgloss.Style
+	middleSectionSeparatorStyle    lipgloss.Style
+	rightSectionLeftSeparatorStyle lipgloss.Style
+
+	// login
+	textAboveStyle lipgloss.Style
+)
+
+func init() {
+	SetTheme(DefaultTheme)
+}
+
+// SetTheme rebuilds every style from the theme
+func SetTheme(t Theme) {
+	theme = t
+	p := t.Palette
+
+	timestampStyle = lipgloss.NewStyle().Foreground(p.Lavender)
+	textStyle = lipgloss.NewStyle().Foreground(p.Text)
+	systemStyle = lipgloss.NewStyle().Foreground(p.Yellow).Italic(true)
+	mentionStyle = lipgloss.NewStyle().Foreground(p.Peach).Bold(true)
+	mentionMarker = lipgloss.NewStyle().Foreground(p.Peach).Render("▌")
+	sentMarker = lipgloss.NewStyle().Foreground(p.Green).Render("✓")
+	headerStyle = lipgloss.NewStyle().
+		Foreground(p.Yellow).
+		Bold(true).
+		Border(lipgloss.RoundedBorder()).
+		Align(lipgloss.Center)
+
+	sendingStyle = lipgloss.NewStyle().Foreground(p.Overlay1).Italic(true)
+	sendFailedStyle = lipgloss.NewStyle().Foreground(p.Red).Italic(true)
+
+	webhookBadgeStyle = lipgloss.NewStyle().Foreground(p.Teal).Italic(true)
+	attachmentTitleStyle = lipgloss.NewStyle().Foreground(p.Sky).Bold(true)
+	attachmentUrlStyle = lipgloss.NewStyle().Foreground(p.Blue).Underline(true)
+	attachmentTextStyle = lipgloss.NewStyle().Foreground(p.Subtext0)
+
+	boldStyle = textStyle.Bold(true)
+	italicStyle = textStyle.Italic(true)
+	inlineCodeStyle = lipgloss.NewStyle().Foreground(p.Pink).Background(p.Surface0)
+	codeBlockStyle = lipgloss.NewStyle().
+		Background(p.Mantle).
+		Foreground(p.Text).
+		Padding(0, 1)
+	codeKeywordStyle = lipgloss.NewStyle().Foreground(p.Mauve).Background(p.Mantle)
+	codeStringStyle = lipgloss.NewStyle().Foreground(p.Green).Background(p.Mantle)
+	codeNumberStyle = lipgloss.NewStyle().Foreground(p.Peach).Background(p.Mantle)
+	codeCommentStyle = lipgloss.NewStyle().Foreground(p.Overlay1).Background(p.Mantle).Italic(true)
+	codePlainStyle = lipgloss.NewStyle().Foreground(p.Text).Background(p.Mantle)
+
+	searchMatchStyle = t.highlighted(p.Base, p.Yellow)
+	searchMarker = lipgloss.NewStyle().Foreground(p.Yellow).Render("▌")
+	searchPromptStyle = lipgloss.NewStyle().Foreground(p.Subtext0).Italic(true)
+	resultsTitleStyle = lipgloss.NewStyle().Foreground(p.Mauve).Bold(true)
+	resultsItemStyle = lipgloss.NewStyle().Foreground(p.Text)
+	resultsSelectedStyle = t.highlighted(p.Base, p.Mauve)
+	resultsHintStyle = lipgloss.NewStyle().Foreground(p.Overlay1).Italic(true)
+	newMessagesStyle = t.highlighted(p.Base, p.Sky).Bold(true)
+	errorBannerStyle = t.highlighted(p.Base, p.Red).Bold(true)
+	dividerStyle = lipgloss.NewStyle().Foreground(p.Red)
+
+	tabStyle = lipgloss.NewStyle().Foreground(p.Subtext0).Padding(0, 1)
+	activeTabStyle = t.highlighted(p.Base, p.Lavender).Bold(true).Padding(0, 1)
+	unreadStyle = lipgloss.NewStyle().Foreground(p.Sky)
+	mentionsStyle = lipgloss.NewStyle().Foreground(p.Peach).Bold(true)
+	helpBoxStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(p.Mauve).
+		Padding(0, 1)
+	roomPickerStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(p.Mauve).
+		Padding(0, 1)
+	roomPickerTitleStyle = lipgloss.NewStyle().Foreground(p.Mauve).Bold(true)
+	roomPickerItemStyle = lipgloss.NewStyle().Foreground(p.Text)
+	roomPickerSelectedStyle = t.highlighted(p.Base, p.Mauve)
+	roomPickerHintStyle = lipgloss.NewStyle().Foreground(p.Overlay1).Italic(true)
+
+	memberListStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder(), false, false, false, true).
+		BorderForeground(p.Surface1).
+		PaddingLeft(1)
+	memberStatusTextStyle = lipgloss.NewStyle().Foreground(p.Overlay1).Italic(true)
+	presenceOnlineStyle = lipgloss.NewStyle().Foreground(p.Green)
+	presenceAwayStyle = lipgloss.NewStyle().Foreground(p.Yellow)
+	presenceDndStyle = lipgloss.NewStyle().Foreground(p.Red)
+	moderatorBadgeStyle = lipgloss.NewStyle().Foreground(p.Mauve).Bold(true)
+	botBadgeStyle = lipgloss.NewStyle().Foreground(p.Teal).Italic(true)
+
+	statusLeftStyle = t.highlighted(p.Base, p.Green).Bold(true).Padding(0, 1)
+	statusMiddleStyle = lipgloss.NewStyle().Foreground(p.Text).Background(p.Crust)
+	statusRightStyle = t.highlighted(p.Base, p.Green).Bold(true).Padding(0, 1)
+	statusRootStyle = lipgloss.NewStyle().Background(p.Surface0)
+	leftSectionRightSeparatorStyle = lipgloss.NewStyle().Foreground(p.Green).Background(p.Surface0)
+	middleSectionSeparatorStyle = lipgloss.NewStyle().Foreground(p.Crust).Background(p.Surface0)
+	rightSectionLeftSeparatorStyle = lipgloss.NewStyle().Foreground(p.Green).Background(p.Surface0)
+
+	textAboveStyle = lipgloss.NewStyle().Foreground(p.Red).Bold(true)
+}
+
+// nicknameStyle colors a sender's name, the same name always getting the same color
+func nicknameStyle(name string) lipgloss.Style {
+	style := lipgloss.NewStyle().Bold(true)
+	if len(theme.Nicknames) == 0 {
+		return style
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+
+	return style.Foreground(theme.Nicknames[hash.Sum32()%uint32(len(theme.Nicknames))])
+}
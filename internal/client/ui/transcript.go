@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+const (
+	gutterWidth     = 1 // room for the mention marker
+	timestampWidth  = 8
+	nameColumnWidth = 12
+	// below this the body no longer gets its own column and wraps under the name instead
+	minBodyWidth = 20
+)
+
+type entryKind int
+
+const (
+	chatEntry entryKind = iota
+	systemEntry
+)
+
+// Entry is a message kept in the chat history. It is rendered on demand,
+// so that it can be reflowed whenever the viewport width changes.
+type Entry struct {
+	kind       entryKind
+	id         string // given by the server to chat messages, see gchad.ChatMessage
+	timestamp  time.Time
+	from       string
+	text       string
+	mentions   []gchad.Mention
+	mentionsMe bool
+	// webhook posts are marked as such and may come with attachments
+	webhook     bool
+	attachments []gchad.Attachment
+	// sent marks the messages of the user the server acknowledged this session
+	sent bool
+}
+
+func NewChatEntry(msg gchad.ChatMessage, mentionsMe bool) Entry {
+	return Entry{
+		kind:        chatEntry,
+		id:          msg.Id,
+		timestamp:   msg.Timestamp,
+		from:        msg.From,
+		text:        msg.Text,
+		mentions:    msg.Mentions,
+		mentionsMe:  mentionsMe,
+		webhook:     msg.Webhook,
+		attachments: msg.Attachments,
+	}
+}
+
+func NewSystemEntry(text string, timestamp time.Time) Entry {
+	return Entry{kind: systemEntry, timestamp: timestamp, text: text}
+}
+
+// Record is the entry as kept in the transcript
+func (e Entry) Record() domain.TranscriptEntry {
+	return domain.TranscriptEntry{
+		Id:          e.id,
+		Timestamp:   e.timestamp,
+		From:        e.from,
+		Text:        e.text,
+		Mentions:    e.mentions,
+		System:      e.kind == systemEntry,
+		Webhook:     e.webhook,
+		Attachments: e.attachments,
+	}
+}
+
+func NewEntryFromRecord(record domain.TranscriptEntry) Entry {
+	if record.System {
+		return NewSystemEntry(record.Text, record.Timestamp)
+	}
+
+	return Entry{
+		kind:        chatEntry,
+		id:          record.Id,
+		timestamp:   record.Timestamp,
+		from:        record.From,
+		text:        record.Text,
+		mentions:    record.Mentions,
+		webhook:     record.Webhook,
+		attachments: record.Attachments,
+	}
+}
+
+// Render lays the entry out to width: a timestamp, a fixed width nickname column and
+// the message body, wrapped with a hanging indent so continuation lines stay in the body column
+func (e Entry) Render(width int) string {
+	return e.render(width, "", false)
+}
+
+// render picks out the occurrences of highlight, marking the entry if it is the current search match
+func (e Entry) render(width int, highlight string, current bool) string {
+	gutter := " "
+	switch {
+	case current:
+		gutter = searchMarker
+	case e.mentionsMe:
+		gutter = mentionMarker
+	case e.sent:
+		gutter = sentMarker
+	}
+	prefix := gutter + timestampStyle.Render(e.timestamp.Format("15:04:05")) + " "
+	indent := gutterWidth + timestampWidth + 1
+
+	if e.kind == systemEntry {
+		return hangingIndent(prefix, highlightMatches(e.text, highlight, systemStyle), indent, width)
+	}
+
+	prefix += nameColumn(e.from) + " "
+	indent += nameColumnWidth + 1
+
+	if width-indent < minBodyWidth {
+		body := strings.TrimPrefix(e.renderBody(width-gutterWidth, highlight), "\n")
+		return prefix + "\n" + hangingIndent(gutter, body, gutterWidth, width)
+	}
+
+	return hangingIndent(prefix, e.renderBody(width-indent, highlight), indent, width)
+}
+
+// renderBody renders the message, badging webhook posts and listing their attachments below it
+func (e Entry) renderBody(width int, highlight string) string {
+	body := renderMarkdown(e.text, e.mentions, width, highlight)
+	if !e.webhook {
+		return body
+	}
+
+	if e.text == "" {
+		body = webhookBadgeStyle.Render("hook")
+	} else {
+		body = webhookBadgeStyle.Render("hook") + " " + body
+	}
+	for _, attachment := range e.attachments {
+		body += "\n" + renderAttachment(attachment, highlight)
+	}
+
+	return body
+}
+
+// renderAttachment puts the title and the link on a line, the text of the attachment under them
+func renderAttachment(attachment gchad.Attachment, highlight string) string {
+	heading := make([]string, 0, 2)
+	if attachment.Title != "" {
+		heading = append(heading, highlightMatches(attachment.Title, highlight, attachmentTitleStyle))
+	}
+	if attachment.Url != "" {
+		heading = append(heading, attachmentUrlStyle.Render(attachment.Url))
+	}
+
+	lines := make([]string, 0, 2)
+	if len(heading) > 0 {
+		lines = append(lines, "▸ "+strings.Join(heading, " "))
+	}
+	if attachment.Text != "" {
+		lines = append(lines, "  "+highlightMatches(attachment.Text, highlight, attachmentTextStyle))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// nameColumn right aligns the name in a column of nameColumnWidth cells, truncating it if it does not fit
+func nameColumn(name string) string {
+	style := nicknameStyle(name)
+	name = truncate(name, nameColumnWidth-1) + ":"
+	padding := strings.Repeat(" ", max(0, nameColumnWidth-ansi.StringWidth(name)))
+
+	return padding + style.Render(name)
+}
+
+// hangingIndent puts body after prefix, wrapping it to the space left after indent cells
+func hangingIndent(prefix, body string, indent, width int) string {
+	bodyWidth := width - indent
+	if bodyWidth <= 0 {
+		return prefix + body
+	}
+
+	lines := carryStyles(strings.Split(ansi.Wrap(body, bodyWidth, ""), "\n"))
+	padding := strings.Repeat(" ", indent)
+	for i := 1; i < len(lines); i++ {
+		lines[i] = padding + lines[i]
+	}
+
+	return prefix + strings.Join(lines, "\n")
+}
+
+var sgrSequence = regexp.MustCompile("\x1b\\[[0-9;:]*m")
+
+// carryStyles closes the styles left open at the end of each line and reopens them on the next one,
+// otherwise the styles of a wrapped span would leak into the indentation
+func carryStyles(lines []string) []string {
+	open := ""
+
+	for i, line := range lines {
+		line = open + line
+		for _, sequence := range sgrSequence.FindAllString(line, -1) {
+			if sequence == "\x1b[m" || sequence == "\x1b[0m" {
+				open = ""
+			} else {
+				open += sequence
+			}
+		}
+		if open != "" {
+			line += "\x1b[m"
+		}
+		lines[i] = line
+	}
+
+	return lines
+}
+
+// renderEntries renders the entries to width, returning how many lines each one takes.
+// The divider, if any, goes above the entry at that index and counts towards its height.
+// Occurrences of highlight are picked out, and the entry at current is marked as the search match.
+func renderEntries(entries []Entry, width int, divider int, highlight string, current int) (string, []int) {
+	rendered := make([]string, 0, len(entries))
+	heights := make([]int, 0, len(entries))
+
+	for i, entry := range entries {
+		text := entry.render(width, highlight, i == current)
+		if i == divider {
+			text = renderDivider(width) + "\n" + text
+		}
+		rendered = append(rendered, text)
+		heights = append(heights, lipgloss.Height(text))
+	}
+
+	return strings.Join(rendered, "\n"), heights
+}
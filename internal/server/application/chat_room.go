@@ -1,21 +1,47 @@
 package application
 
 import (
+	"crypto/subtle"
+	"sort"
+	"time"
+
 	"github.com/iomallach/gchad/internal/server/domain"
 )
 
+// ChatRoom owns the room state. Every change to it bumps the version, so the methods
+// changing the state are expected to be called from a single goroutine, the one handling
+// chat service events, which keeps versions in the order clients are told about them.
 type ChatRoom struct {
-	id      string
-	name    string
-	clients *ClientRegistry
+	id         string
+	name       string
+	clients    *ClientRegistry
+	moderators map[string]string
+	version    uint64
 }
 
 func NewChatRoom(id string, name string, clients *ClientRegistry) *ChatRoom {
 	return &ChatRoom{
-		id:      id,
-		name:    name,
-		clients: clients,
+		id:         id,
+		name:       name,
+		clients:    clients,
+		moderators: make(map[string]string),
+	}
+}
+
+// AddModerator grants the moderator role to clients entering the room under the given name, which
+// prove it is theirs with the token
+func (cr *ChatRoom) AddModerator(name string, token string) {
+	cr.moderators[name] = token
+}
+
+// IsModerator tells whether the token proves the name is a moderator's
+func (cr *ChatRoom) IsModerator(name string, token string) bool {
+	want, ok := cr.moderators[name]
+	if !ok || want == "" {
+		return false
 	}
+
+	return subtle.ConstantTimeCompare([]byte(want), []byte(token)) == 1
 }
 
 func (cr *ChatRoom) Id() string {
@@ -28,16 +54,104 @@ func (cr *ChatRoom) Name() string {
 
 func (cr *ChatRoom) LetClientIn(client *domain.Client) *domain.UserJoinedRoom {
 	cr.clients.AddClient(client)
+	cr.version++
 
-	return domain.NewUserJoinedRoomEvent(client.Name())
+	return domain.NewUserJoinedRoomEvent(client.Id(), client.Name(), client.Role(), client.IsBot(), cr.version)
 }
 
+// LetClientOut returns nil if the client is not in the room
 func (cr *ChatRoom) LetClientOut(clientId string) *domain.UserLeftRoom {
-	// TODO: null pointer exception danger
 	client := cr.clients.GetClient(clientId)
+	if client == nil {
+		return nil
+	}
 	cr.clients.RemoveClient(clientId)
+	cr.version++
+
+	return domain.NewUserLeftRoomEvent(client.Name(), cr.version)
+}
+
+// RenameClient swaps the client for a renamed copy. It returns nil if the client is not in the room
+// or already goes by that name.
+func (cr *ChatRoom) RenameClient(clientId string, newName string) *domain.UserRenamed {
+	client := cr.clients.GetClient(clientId)
+	if client == nil || client.Name() == newName {
+		return nil
+	}
+	cr.clients.ReplaceClient(client.Renamed(newName))
+	cr.version++
+
+	return domain.NewUserRenamedEvent(client.Name(), newName, cr.version)
+}
+
+// SetPresence applies the presence chosen by the user. It returns nil if nothing changed.
+func (cr *ChatRoom) SetPresence(clientId string, presence domain.Presence, statusText string, at time.Time) *domain.UserPresenceChanged {
+	return cr.updatePresence(clientId, func(client *domain.Client) bool {
+		return client.SetPresence(presence, statusText, at)
+	})
+}
+
+// TouchClient records activity of the client. It returns nil unless that brought the client back online.
+func (cr *ChatRoom) TouchClient(clientId string, at time.Time) *domain.UserPresenceChanged {
+	return cr.updatePresence(clientId, func(client *domain.Client) bool {
+		return client.Touch(at)
+	})
+}
+
+// MarkIdleClientsAway moves everyone inactive for longer than awayAfter to away
+func (cr *ChatRoom) MarkIdleClientsAway(now time.Time, awayAfter time.Duration) []*domain.UserPresenceChanged {
+	events := make([]*domain.UserPresenceChanged, 0)
+
+	for _, client := range cr.clients.GetAllClients() {
+		event := cr.updatePresence(client.Id(), func(client *domain.Client) bool {
+			return client.GoIdle(now, awayAfter)
+		})
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func (cr *ChatRoom) updatePresence(clientId string, update func(*domain.Client) bool) *domain.UserPresenceChanged {
+	client := cr.clients.GetClient(clientId)
+	if client == nil || !update(client) {
+		return nil
+	}
+	cr.version++
+
+	return domain.NewUserPresenceChangedEvent(client.Name(), client.Presence(), client.StatusText(), cr.version)
+}
+
+func (cr *ChatRoom) HasClientNamed(name string) bool {
+	for _, client := range cr.clients.GetAllClients() {
+		if client.Name() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// State returns a snapshot of everyone in the room sorted by name
+func (cr *ChatRoom) State() domain.RoomState {
+	clients := cr.clients.GetAllClients()
+	members := make([]domain.Member, 0, len(clients))
+
+	for _, client := range clients {
+		members = append(members, domain.NewMember(client))
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return domain.RoomState{Version: cr.version, Members: members}
+}
 
-	return domain.NewUserLeftRoomEvent(client.Name())
+func (cr *ChatRoom) GetClient(clientId string) *domain.Client {
+	return cr.clients.GetClient(clientId)
 }
 
 func (cr *ChatRoom) GetClients() []*domain.Client {
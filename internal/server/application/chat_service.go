@@ -2,35 +2,97 @@ package application
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/iomallach/gchad/internal/server/domain"
 	"github.com/iomallach/gchad/pkg/logging"
+	"github.com/iomallach/gchad/pkg/protocol"
 )
 
 type ChatServicer interface {
-	EnterRoom(clientId string, clientName string)
+	EnterRoom(clientId string, clientName string, token string, roomId string, bot bool) error
 	LeaveRoom(clientId string)
-	SendMessage(clientId string, msg string)
+	SendMessage(clientId string, requestId string, msg string)
+	SetPresence(clientId string, presence domain.Presence, statusText string)
+	RecordActivity(clientId string)
+	Rename(clientId string, newName string)
+	SyncRoom(clientId string)
+	ListRooms(clientId string)
+	SearchMessages(clientId string, request *domain.SearchMessage)
+	ManageWebhooks(clientId string, request domain.Messager)
+	ManageEventSubscriptions(clientId string, request domain.Messager)
+	PostToWebhook(token string, payload domain.WebhookPayload) error
+}
+
+var (
+	// ErrBusy is returned when a message cannot be taken right now and is worth trying again later
+	ErrBusy                     = errors.New("the server is busy")
+	ErrNotInRoom                = errors.New("not in a room")
+	ErrUnknownRoom              = errors.New("no such room")
+	ErrInvalidPresence          = errors.New("invalid presence")
+	ErrNameTaken                = errors.New("the name is already taken")
+	ErrForbidden                = errors.New("only moderators may do that")
+	ErrUnknownEventSubscription = errors.New("no such event subscription")
+)
+
+// PresenceConfiguration controls automatic away detection. A zero AwayAfter disables it.
+type PresenceConfiguration struct {
+	AwayAfter       time.Duration
+	IdleCheckPeriod time.Duration
+	// IdleChecks replaces the ticker firing every IdleCheckPeriod when set, e.g. for tests to drive the checks
+	IdleChecks <-chan time.Time
+}
+
+// roomMessage is a chat message on its way to everyone in the room, the client that sent it being
+// acknowledged once it is saved if it gave the message a request id
+type roomMessage struct {
+	room      *ChatRoom
+	msg       *domain.UserMessage
+	clientId  string
+	requestId string
 }
 
 type ChatService struct {
-	room     *ChatRoom // TODO: later needs to be a repository of chat rooms
-	events   chan domain.ApplicationEvent
-	messages chan *domain.UserMessage
-	notifier Notifier
-	clock    ClockGen
-	logger   logging.Logger
+	rooms          *RoomRepository
+	store          MessageStore
+	webhooks       *WebhookRegistry
+	subscriptions  *EventSubscriptions
+	events         chan domain.ApplicationEvent
+	messages       chan roomMessage
+	notifier       Notifier
+	clock          ClockGen
+	presenceConfig PresenceConfiguration
+	logger         logging.Logger
+	// stopped is closed once the events are no longer handled
+	stopped chan struct{}
 }
 
-func NewChatService(room *ChatRoom, notifier Notifier, clock ClockGen, eventsChanSize int, messagesChanSize int, logger logging.Logger) *ChatService {
+func NewChatService(
+	rooms *RoomRepository,
+	store MessageStore,
+	webhooks *WebhookRegistry,
+	subscriptions *EventSubscriptions,
+	notifier Notifier,
+	clock ClockGen,
+	presenceConfig PresenceConfiguration,
+	eventsChanSize int,
+	messagesChanSize int,
+	logger logging.Logger,
+) *ChatService {
 	return &ChatService{
-		room:     room,
-		events:   make(chan domain.ApplicationEvent, eventsChanSize),
-		messages: make(chan *domain.UserMessage, messagesChanSize),
-		notifier: notifier,
-		clock:    clock,
-		logger:   logger,
+		rooms:          rooms,
+		store:          store,
+		webhooks:       webhooks,
+		subscriptions:  subscriptions,
+		events:         make(chan domain.ApplicationEvent, eventsChanSize),
+		messages:       make(chan roomMessage, messagesChanSize),
+		notifier:       notifier,
+		clock:          clock,
+		presenceConfig: presenceConfig,
+		logger:         logger,
+		stopped:        make(chan struct{}),
 	}
 }
 
@@ -39,41 +101,197 @@ func (cs *ChatService) Start(ctx context.Context) {
 	go cs.handleMessages(ctx)
 }
 
-func (cs *ChatService) EnterRoom(clientId string, clientName string) {
-	event := cs.room.LetClientIn(domain.NewClient(clientId, clientName))
+// HasRoom tells whether a room can be entered, an empty id stands for the default room
+func (cs *ChatService) HasRoom(roomId string) bool {
+	if roomId == "" {
+		return cs.rooms.DefaultRoom() != nil
+	}
+
+	return cs.rooms.GetRoom(roomId) != nil
+}
+
+// EnterRoom lets the client into the room, an empty id stands for the default room, returning once
+// it is in so that whatever the client says next goes to the room. Bots are marked as such to the
+// other members, and clients whose token is the one of a moderator of the room get the role.
+func (cs *ChatService) EnterRoom(clientId string, clientName string, token string, roomId string, bot bool) error {
+	event := domain.NewClientConnectedEvent(clientId, clientName, token, roomId, bot)
+	if !cs.publishEvent(event) {
+		return ErrBusy
+	}
 
 	select {
-	case cs.events <- event:
-	default:
-		cs.logger.Error("event channel full", make(map[string]any))
+	case err := <-event.Joined:
+		return err
+	case <-cs.stopped:
+		return ErrBusy
 	}
 }
 
 func (cs *ChatService) LeaveRoom(clientId string) {
-	event := cs.room.LetClientOut(clientId)
+	cs.publishEvent(domain.NewClientDisconnectedEvent(clientId))
+}
+
+// SendMessage posts to the room of the client, which is acknowledged or told why not when it gave
+// the message a request id. The sender is looked up by the event loop, the client being able to
+// leave in the meantime.
+func (cs *ChatService) SendMessage(clientId string, requestId string, msg string) {
+	if !cs.publishEvent(domain.NewUserSentMessageEvent(clientId, requestId, msg)) {
+		cs.rejectRequest(clientId, requestId, protocol.Chat, protocol.CodeBusy, ErrBusy)
+	}
+}
+
+func (cs *ChatService) SetPresence(clientId string, presence domain.Presence, statusText string) {
+	if !presence.Valid() {
+		cs.logger.Error("invalid presence requested", map[string]any{"client_id": clientId, "presence": string(presence)})
+		cs.reject(clientId, protocol.SetPresence, protocol.CodeInvalidPresence, fmt.Errorf("%w: %q", ErrInvalidPresence, presence))
+		return
+	}
+
+	cs.publishRequest(clientId, protocol.SetPresence, domain.NewUserRequestedPresenceEvent(clientId, presence, statusText))
+}
+
+func (cs *ChatService) RecordActivity(clientId string) {
+	cs.publishEvent(domain.NewUserActiveEvent(clientId))
+}
+
+func (cs *ChatService) Rename(clientId string, newName string) {
+	if err := domain.ValidateName(newName); err != nil {
+		cs.logger.Error(fmt.Sprintf("invalid name requested: %s", err.Error()), map[string]any{"client_id": clientId})
+		cs.reject(clientId, protocol.Rename, protocol.CodeInvalidName, err)
+		return
+	}
+
+	cs.publishRequest(clientId, protocol.Rename, domain.NewUserRequestedRenameEvent(clientId, newName))
+}
+
+// SyncRoom sends the client a fresh snapshot of the room state
+func (cs *ChatService) SyncRoom(clientId string) {
+	cs.publishRequest(clientId, protocol.SyncRoom, domain.NewRoomStateRequestedEvent(clientId))
+}
+
+func (cs *ChatService) ListRooms(clientId string) {
+	cs.publishRequest(clientId, protocol.ListRooms, domain.NewRoomListRequestedEvent(clientId))
+}
+
+// SearchMessages looks through the messages of the client's room, the results are sent back to it alone
+func (cs *ChatService) SearchMessages(clientId string, request *domain.SearchMessage) {
+	cs.publishRequest(clientId, protocol.Search, domain.NewSearchRequestedEvent(clientId, request))
+}
+
+// ManageWebhooks creates, revokes or lists the webhooks of the client's room, which only moderators may.
+// The request is a CreateWebhookMessage, a RevokeWebhookMessage or a ListWebhooksMessage.
+func (cs *ChatService) ManageWebhooks(clientId string, request domain.Messager) {
+	if create, ok := request.(*domain.CreateWebhookMessage); ok {
+		if err := domain.ValidateName(create.Name); err != nil {
+			cs.logger.Error(fmt.Sprintf("invalid webhook name requested: %s", err.Error()), map[string]any{"client_id": clientId})
+			cs.reject(clientId, request.MessageType(), protocol.CodeInvalidName, err)
+			return
+		}
+	}
+
+	cs.publishRequest(clientId, request.MessageType(), domain.NewWebhooksRequestedEvent(clientId, request))
+}
+
+// ManageEventSubscriptions subscribes to, unsubscribes from or lists the events of the client's room,
+// which only moderators may. The request is a SubscribeEventsMessage, an UnsubscribeEventsMessage
+// or a ListEventSubscriptionsMessage.
+func (cs *ChatService) ManageEventSubscriptions(clientId string, request domain.Messager) {
+	if subscribe, ok := request.(*domain.SubscribeEventsMessage); ok {
+		if err := subscribe.Validate(); err != nil {
+			cs.logger.Error(fmt.Sprintf("invalid event subscription requested: %s", err.Error()), map[string]any{"client_id": clientId})
+			cs.reject(clientId, request.MessageType(), protocol.CodeInvalidMessage, err)
+			return
+		}
+	}
+
+	cs.publishRequest(clientId, request.MessageType(), domain.NewEventSubscriptionsRequestedEvent(clientId, request))
+}
+
+// PostToWebhook posts the payload into the room of the webhook, under the name of the webhook
+// unless the payload gives another one
+func (cs *ChatService) PostToWebhook(token string, payload domain.WebhookPayload) error {
+	webhook, ok := cs.webhooks.Get(token)
+	if !ok {
+		return domain.ErrUnknownWebhook
+	}
+	if err := payload.Validate(); err != nil {
+		return err
+	}
+	room := cs.rooms.GetRoom(webhook.RoomId)
+	if room == nil {
+		return domain.ErrUnknownWebhook
+	}
+
+	from := webhook.Name
+	if payload.Username != "" {
+		from = payload.Username
+	}
+	webhookMessage := domain.NewWebhookMessage(payload, cs.clock(), from)
+	webhookMessage.Mentions = domain.ParseMentions(payload.Text, room.HasClientNamed)
+
+	select {
+	case cs.messages <- roomMessage{room: room, msg: webhookMessage}:
+		return nil
+	default:
+		cs.logger.Error("message channel full", map[string]any{"webhook": webhook.Name})
+		return ErrBusy
+	}
+}
 
+// publishEvent reports whether the event could be taken
+func (cs *ChatService) publishEvent(event domain.ApplicationEvent) bool {
 	select {
 	case cs.events <- event:
+		return true
 	default:
 		cs.logger.Error("event channel full", make(map[string]any))
+		return false
 	}
 }
 
-func (cs *ChatService) SendMessage(clientId string, msg string) {
-	userMessage := domain.NewUserMessage(msg, cs.clock(), clientId)
+// publishRequest publishes the event of what the client asked for, telling the client when it
+// could not be taken
+func (cs *ChatService) publishRequest(clientId string, request protocol.MessageType, event domain.ApplicationEvent) {
+	if !cs.publishEvent(event) {
+		cs.reject(clientId, request, protocol.CodeBusy, ErrBusy)
+	}
+}
 
-	select {
-	case cs.messages <- userMessage:
+// ErrorCodeOf is the code clients are told they were not let into a room with
+func ErrorCodeOf(err error) protocol.ErrorCode {
+	switch {
+	case errors.Is(err, ErrUnknownRoom):
+		return protocol.CodeNotFound
+	case errors.Is(err, ErrBusy):
+		return protocol.CodeBusy
+	case errors.Is(err, ErrNameTaken):
+		return protocol.CodeNameTaken
 	default:
-		cs.logger.Error("message channel full", make(map[string]any))
+		return domain.ErrorCodeOf(err)
 	}
 }
 
+// reject tells the client why its request was turned down, what to log being up to the caller
+func (cs *ChatService) reject(clientId string, request protocol.MessageType, code protocol.ErrorCode, err error) {
+	cs.rejectRequest(clientId, "", request, code, err)
+}
+
+// rejectRequest is reject for a message the client gave a request id
+func (cs *ChatService) rejectRequest(clientId string, requestId string, request protocol.MessageType, code protocol.ErrorCode, err error) {
+	cs.notifier.SendToClient(clientId, domain.NewErrorSystemMessage(request, code, err.Error()).WithRequestId(requestId))
+}
+
 func (cs *ChatService) handleMessages(ctx context.Context) {
 	for {
 		select {
 		case msg := <-cs.messages:
-			cs.notifier.BroadcastToRoom(cs.room, msg)
+			sequence := cs.store.Save(msg.room.Id(), msg.msg)
+			if msg.requestId != "" {
+				// ahead of the broadcast, so that the sender knows the message once it arrives
+				cs.notifier.SendToClient(msg.clientId, domain.NewAckSystemMessage(msg.requestId, msg.msg.Id, sequence))
+			}
+			cs.notifier.BroadcastToRoom(msg.room, msg.msg)
+			cs.subscriptions.PublishMessage(msg.room.Id(), msg.msg)
 		case <-ctx.Done():
 			cs.logger.Info("message handler context done, exiting", make(map[string]any))
 			return
@@ -81,31 +299,33 @@ func (cs *ChatService) handleMessages(ctx context.Context) {
 	}
 }
 
+// handleEvents is the only place the room state changes, see ChatRoom
 func (cs *ChatService) handleEvents(ctx context.Context) {
-	// TODO: two things to consider: whether it belongs here; configure it some place else
-	// this is not exactly an event, but we want to broadcast the stats on some basis to keep
-	// clients in sync
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+	defer close(cs.stopped)
+
+	// a nil channel never fires, which keeps auto away disabled unless configured
+	var idleCheck <-chan time.Time
+	switch {
+	case cs.presenceConfig.AwayAfter <= 0:
+	case cs.presenceConfig.IdleChecks != nil:
+		idleCheck = cs.presenceConfig.IdleChecks
+	default:
+		idleTicker := time.NewTicker(cs.presenceConfig.IdleCheckPeriod)
+		defer idleTicker.Stop()
+		idleCheck = idleTicker.C
+	}
 
 	for {
 		select {
 		case event := <-cs.events:
-			switch e := event.(type) {
-			case *domain.UserJoinedRoom:
-				joinedMsg := domain.NewUserJoinedSystemMessage(e.Name, cs.clock())
-				cs.notifier.BroadcastToRoom(cs.room, joinedMsg)
-				statsMsg := domain.NewStatsSystemMessage(len(cs.room.GetClients()))
-				cs.notifier.BroadcastToRoom(cs.room, statsMsg)
-
-			case *domain.UserLeftRoom:
-				leftMessage := domain.NewUserLeftSystemMessage(e.Name, cs.clock())
-				cs.notifier.BroadcastToRoom(cs.room, leftMessage)
-			}
+			cs.handleEvent(event)
 
-		case <-ticker.C:
-			statsMsg := domain.NewStatsSystemMessage(len(cs.room.GetClients()))
-			cs.notifier.BroadcastToRoom(cs.room, statsMsg)
+		case <-idleCheck:
+			for _, room := range cs.rooms.GetRooms() {
+				for _, changed := range room.MarkIdleClientsAway(cs.clock(), cs.presenceConfig.AwayAfter) {
+					cs.broadcastPresence(room, changed)
+				}
+			}
 
 		case <-ctx.Done():
 			cs.logger.Info("event handler context done, exiting", make(map[string]any))
@@ -113,3 +333,195 @@ func (cs *ChatService) handleEvents(ctx context.Context) {
 		}
 	}
 }
+
+func (cs *ChatService) handleEvent(event domain.ApplicationEvent) {
+	switch e := event.(type) {
+	case *domain.ClientConnected:
+		room := cs.rooms.DefaultRoom()
+		if e.RoomId != "" {
+			room = cs.rooms.GetRoom(e.RoomId)
+		}
+		if room == nil {
+			cs.logger.Error("client asked for a room that does not exist", map[string]any{"client_id": e.ClientId, "room_id": e.RoomId})
+			e.Joined <- fmt.Errorf("%w: %s", ErrUnknownRoom, e.RoomId)
+			return
+		}
+		if room.HasClientNamed(e.Name) {
+			cs.logger.Error("name is already taken", map[string]any{"client_id": e.ClientId, "name": e.Name})
+			e.Joined <- ErrNameTaken
+			return
+		}
+
+		client := domain.NewClient(e.ClientId, e.Name)
+		if e.Bot {
+			client = domain.NewBotClient(e.ClientId, e.Name)
+		}
+		if room.IsModerator(e.Name, e.Token) {
+			client.SetRole(domain.RoleModerator)
+		}
+		client.Touch(cs.clock())
+		joined := room.LetClientIn(client)
+
+		joinedMsg := domain.NewUserJoinedSystemMessage(joined.Name, joined.Role, joined.Bot, joined.Version, cs.clock())
+		cs.notifier.BroadcastToRoom(room, joinedMsg)
+		cs.notifier.SendToClient(joined.ClientId, domain.NewMemberListSystemMessage(room.State()))
+		cs.subscriptions.Publish(room.Id(), domain.RoomEvent{Kind: domain.EventUserJoined, Timestamp: cs.clock(), Name: joined.Name})
+		e.Joined <- nil
+
+	case *domain.ClientDisconnected:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			cs.logger.Error("client left a room it was not in", map[string]any{"client_id": e.ClientId})
+			return
+		}
+
+		left := room.LetClientOut(e.ClientId)
+		leftMessage := domain.NewUserLeftSystemMessage(left.Name, left.Version, cs.clock())
+		cs.notifier.BroadcastToRoom(room, leftMessage)
+		cs.subscriptions.Publish(room.Id(), domain.RoomEvent{Kind: domain.EventUserLeft, Timestamp: cs.clock(), Name: left.Name})
+
+	case *domain.UserRequestedPresence:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			return
+		}
+
+		if changed := room.SetPresence(e.ClientId, e.Presence, e.StatusText, cs.clock()); changed != nil {
+			cs.broadcastPresence(room, changed)
+		}
+
+	case *domain.UserActive:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			return
+		}
+
+		if changed := room.TouchClient(e.ClientId, cs.clock()); changed != nil {
+			cs.broadcastPresence(room, changed)
+		}
+
+	case *domain.UserSentMessage:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			cs.logger.Error("message from a client that is not in a room", map[string]any{"client_id": e.ClientId})
+			cs.rejectRequest(e.ClientId, e.RequestId, protocol.Chat, protocol.CodeNotInRoom, ErrNotInRoom)
+			return
+		}
+		client := room.GetClient(e.ClientId)
+
+		userMessage := domain.NewUserMessage(e.Text, cs.clock(), client.Name())
+		userMessage.Mentions = domain.ParseMentions(e.Text, room.HasClientNamed)
+
+		select {
+		case cs.messages <- roomMessage{room, userMessage, e.ClientId, e.RequestId}:
+		default:
+			cs.logger.Error("message channel full", make(map[string]any))
+			cs.rejectRequest(e.ClientId, e.RequestId, protocol.Chat, protocol.CodeBusy, ErrBusy)
+		}
+
+		// speaking up counts as activity
+		if changed := room.TouchClient(e.ClientId, cs.clock()); changed != nil {
+			cs.broadcastPresence(room, changed)
+		}
+
+	case *domain.UserRequestedRename:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			return
+		}
+
+		// going by the name already is no clash, the room just has nothing to rename
+		if room.HasClientNamed(e.NewName) && room.GetClient(e.ClientId).Name() != e.NewName {
+			cs.logger.Error("name is already taken", map[string]any{"client_id": e.ClientId, "name": e.NewName})
+			cs.reject(e.ClientId, protocol.Rename, protocol.CodeNameTaken, ErrNameTaken)
+			return
+		}
+
+		if renamed := room.RenameClient(e.ClientId, e.NewName); renamed != nil {
+			renamedMsg := domain.NewUserRenamedSystemMessage(renamed.OldName, renamed.NewName, renamed.Version, cs.clock())
+			cs.notifier.BroadcastToRoom(room, renamedMsg)
+		}
+
+	case *domain.RoomStateRequested:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			return
+		}
+
+		cs.notifier.SendToClient(e.ClientId, domain.NewMemberListSystemMessage(room.State()))
+
+	case *domain.RoomListRequested:
+		cs.notifier.SendToClient(e.ClientId, domain.NewRoomListSystemMessage(cs.rooms.Summaries()))
+
+	case *domain.SearchRequested:
+		room := cs.rooms.RoomOf(e.ClientId)
+		if room == nil {
+			return
+		}
+
+		query := e.Request.Query()
+		page := cs.store.Search(room.Id(), query)
+		cs.notifier.SendToClient(e.ClientId, domain.NewSearchResultsSystemMessage(e.Request, query, page))
+
+	case *domain.WebhooksRequested:
+		room, client := cs.moderatorRoom(e.ClientId, e.Request.MessageType())
+		if room == nil {
+			return
+		}
+
+		switch request := e.Request.(type) {
+		case *domain.CreateWebhookMessage:
+			webhook := cs.webhooks.Create(room.Id(), request.Name, client.Name(), cs.clock())
+			cs.logger.Info(fmt.Sprintf("webhook %s created by %s", webhook.Name, client.Name()), map[string]any{"room_id": room.Id()})
+		case *domain.RevokeWebhookMessage:
+			if !cs.webhooks.Revoke(room.Id(), request.Token) {
+				cs.logger.Error("no such webhook to revoke", map[string]any{"client_id": e.ClientId})
+				cs.reject(e.ClientId, protocol.RevokeWebhook, protocol.CodeNotFound, domain.ErrUnknownWebhook)
+			}
+		}
+
+		cs.notifier.SendToClient(e.ClientId, domain.NewWebhookListSystemMessage(cs.webhooks.ForRoom(room.Id())))
+
+	case *domain.EventSubscriptionsRequested:
+		room, client := cs.moderatorRoom(e.ClientId, e.Request.MessageType())
+		if room == nil {
+			return
+		}
+
+		switch request := e.Request.(type) {
+		case *domain.SubscribeEventsMessage:
+			subscription := cs.subscriptions.Subscribe(room.Id(), request, client.Name(), cs.clock())
+			cs.logger.Info(fmt.Sprintf("%s subscribed %s to events", client.Name(), subscription.Url), map[string]any{"room_id": room.Id()})
+		case *domain.UnsubscribeEventsMessage:
+			if !cs.subscriptions.Unsubscribe(room.Id(), request.Id) {
+				cs.logger.Error("no such event subscription", map[string]any{"client_id": e.ClientId})
+				cs.reject(e.ClientId, protocol.UnsubscribeEvents, protocol.CodeNotFound, ErrUnknownEventSubscription)
+			}
+		}
+
+		cs.notifier.SendToClient(e.ClientId, domain.NewEventSubscriptionListSystemMessage(cs.subscriptions.ForRoom(room.Id())))
+	}
+}
+
+// moderatorRoom returns the room of the client along with it, or nil if the client is not a moderator
+// of it, turning the request down
+func (cs *ChatService) moderatorRoom(clientId string, request protocol.MessageType) (*ChatRoom, *domain.Client) {
+	room := cs.rooms.RoomOf(clientId)
+	if room == nil {
+		return nil, nil
+	}
+
+	client := room.GetClient(clientId)
+	if client.Role() != domain.RoleModerator {
+		cs.logger.Error("only moderators may do that", map[string]any{"client_id": clientId})
+		cs.reject(clientId, request, protocol.CodeForbidden, ErrForbidden)
+		return nil, nil
+	}
+
+	return room, client
+}
+
+func (cs *ChatService) broadcastPresence(room *ChatRoom, changed *domain.UserPresenceChanged) {
+	presenceMsg := domain.NewPresenceSystemMessage(changed.Name, changed.Presence, changed.StatusText, changed.Version, cs.clock())
+	cs.notifier.BroadcastToRoom(room, presenceMsg)
+}
@@ -25,6 +25,21 @@ func (r *ClientRegistry) AddClient(client *domain.Client) {
 	r.clients[client.Id()] = client
 }
 
+// ReplaceClient swaps a registered client for a new version of it and returns the previous one.
+// Nothing is replaced if the client is no longer registered.
+func (r *ClientRegistry) ReplaceClient(client *domain.Client) *domain.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, ok := r.clients[client.Id()]
+	if !ok {
+		return nil
+	}
+	r.clients[client.Id()] = client
+
+	return previous
+}
+
 func (r *ClientRegistry) RemoveClient(clientId string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
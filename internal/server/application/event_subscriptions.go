@@ -0,0 +1,127 @@
+package application
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+)
+
+// EventDispatcher delivers room events to subscribers. Dispatch is called from the chat service loops,
+// so it hands the event over and returns, delivering it later.
+type EventDispatcher interface {
+	Dispatch(subscription domain.EventSubscription, event domain.RoomEvent)
+}
+
+// EventSubscriptions keeps the event subscriptions of every room and tells the dispatcher about the
+// events they want. It is changed by the chat service event loop and read by its message loop,
+// hence the lock.
+type EventSubscriptions struct {
+	mu            sync.RWMutex
+	subscriptions map[string]domain.EventSubscription
+	idGen         IdGen
+	secretGen     IdGen
+	dispatcher    EventDispatcher
+}
+
+func NewEventSubscriptions(idGen IdGen, secretGen IdGen, dispatcher EventDispatcher) *EventSubscriptions {
+	return &EventSubscriptions{
+		subscriptions: make(map[string]domain.EventSubscription),
+		idGen:         idGen,
+		secretGen:     secretGen,
+		dispatcher:    dispatcher,
+	}
+}
+
+// Subscribe expects the request to be valid, see domain.SubscribeEventsMessage.Validate
+func (s *EventSubscriptions) Subscribe(roomId string, request *domain.SubscribeEventsMessage, createdBy string, at time.Time) domain.EventSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription := domain.EventSubscription{
+		Id:        s.idGen(),
+		RoomId:    roomId,
+		Url:       request.Url,
+		Events:    request.Events,
+		Keywords:  request.Keywords,
+		Secret:    s.secretGen(),
+		CreatedBy: createdBy,
+		CreatedAt: at,
+	}
+	s.subscriptions[subscription.Id] = subscription
+
+	return subscription
+}
+
+// Unsubscribe reports whether the room had a subscription with that id
+func (s *EventSubscriptions) Unsubscribe(roomId string, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[id]
+	if !ok || subscription.RoomId != roomId {
+		return false
+	}
+	delete(s.subscriptions, id)
+
+	return true
+}
+
+// ForRoom returns the subscriptions of the room, oldest first
+func (s *EventSubscriptions) ForRoom(roomId string) []domain.EventSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := make([]domain.EventSubscription, 0)
+	for _, subscription := range s.subscriptions {
+		if subscription.RoomId == roomId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].Id < subscriptions[j].Id
+		}
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+
+	return subscriptions
+}
+
+// Publish dispatches the event to the subscriptions of the room wanting its kind
+func (s *EventSubscriptions) Publish(roomId string, event domain.RoomEvent) {
+	event.Id = s.idGen()
+	event.RoomId = roomId
+
+	for _, subscription := range s.ForRoom(roomId) {
+		if subscription.Wants(event.Kind) {
+			s.dispatcher.Dispatch(subscription, event)
+		}
+	}
+}
+
+// PublishMessage tells the subscriptions of the room about the message, the ones watching for
+// keywords only when it has some of theirs
+func (s *EventSubscriptions) PublishMessage(roomId string, msg *domain.UserMessage) {
+	// the dispatcher encodes it later, long after the message went out
+	message := *msg
+
+	s.Publish(roomId, domain.RoomEvent{Kind: domain.EventMessagePosted, Timestamp: message.Timestamp, Message: &message})
+
+	for _, subscription := range s.ForRoom(roomId) {
+		if !subscription.Wants(domain.EventKeywordMentioned) {
+			continue
+		}
+		if keywords := subscription.MatchKeywords(message.Text); len(keywords) > 0 {
+			s.dispatcher.Dispatch(subscription, domain.RoomEvent{
+				Id:        s.idGen(),
+				Kind:      domain.EventKeywordMentioned,
+				RoomId:    roomId,
+				Timestamp: message.Timestamp,
+				Message:   &message,
+				Keywords:  keywords,
+			})
+		}
+	}
+}
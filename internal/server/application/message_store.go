@@ -0,0 +1,170 @@
+package application
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+)
+
+// MessageStore keeps the chat messages of every room so that they can be searched
+type MessageStore interface {
+	// Save keeps the message and gives it its id, returning where it falls among the messages of the
+	// room, counting from 1
+	Save(roomId string, msg *domain.UserMessage) uint64
+	Search(roomId string, query domain.SearchQuery) domain.SearchPage
+}
+
+// roomIndex is the messages of a room along with an inverted index of their words.
+// Sequence numbers grow with every message saved, so both the order and every posting list
+// are sorted oldest first. Saved counts the messages of the room, evicted ones included.
+type roomIndex struct {
+	saved    uint64
+	order    []uint64
+	messages map[uint64]domain.UserMessage
+	postings map[string][]uint64
+}
+
+func newRoomIndex() *roomIndex {
+	return &roomIndex{
+		messages: make(map[uint64]domain.UserMessage),
+		postings: make(map[string][]uint64),
+	}
+}
+
+// InMemoryMessageStore is an embedded MessageStore keeping the latest messages of each room,
+// the oldest ones being dropped from the index once a room holds more than maxPerRoom
+type InMemoryMessageStore struct {
+	mu         sync.RWMutex
+	rooms      map[string]*roomIndex
+	sequence   uint64
+	maxPerRoom int
+}
+
+func NewInMemoryMessageStore(maxPerRoom int) *InMemoryMessageStore {
+	return &InMemoryMessageStore{
+		rooms:      make(map[string]*roomIndex),
+		maxPerRoom: maxPerRoom,
+	}
+}
+
+func (s *InMemoryMessageStore) Save(roomId string, msg *domain.UserMessage) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sequence++
+	msg.Id = strconv.FormatUint(s.sequence, 10)
+
+	room, ok := s.rooms[roomId]
+	if !ok {
+		room = newRoomIndex()
+		s.rooms[roomId] = room
+	}
+
+	room.saved++
+	room.order = append(room.order, s.sequence)
+	room.messages[s.sequence] = *msg
+	for _, token := range domain.Tokenize(msg.Text) {
+		room.postings[token] = append(room.postings[token], s.sequence)
+	}
+
+	if len(room.order) > s.maxPerRoom {
+		room.evictOldest()
+	}
+
+	return room.saved
+}
+
+// evictOldest drops the first message, which is also first in each of its posting lists
+func (r *roomIndex) evictOldest() {
+	oldest := r.order[0]
+	r.order = r.order[1:]
+
+	for _, token := range domain.Tokenize(r.messages[oldest].Text) {
+		postings := r.postings[token][1:]
+		if len(postings) == 0 {
+			delete(r.postings, token)
+		} else {
+			r.postings[token] = postings
+		}
+	}
+	delete(r.messages, oldest)
+}
+
+func (s *InMemoryMessageStore) Search(roomId string, query domain.SearchQuery) domain.SearchPage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := domain.SearchPage{Results: make([]domain.UserMessage, 0)}
+	room, ok := s.rooms[roomId]
+	if !ok {
+		return page
+	}
+
+	candidates := room.order
+	if len(query.Terms) > 0 {
+		candidates = room.lookup(query.Terms)
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		msg := room.messages[candidates[i]]
+		if !query.Matches(msg) {
+			continue
+		}
+		if page.Total >= query.Offset && len(page.Results) < query.Limit {
+			page.Results = append(page.Results, msg)
+		}
+		page.Total++
+	}
+
+	return page
+}
+
+// lookup returns the messages containing every term, oldest first
+func (r *roomIndex) lookup(terms []string) []uint64 {
+	lists := make([][]uint64, 0, len(terms))
+	for _, term := range terms {
+		postings, ok := r.postings[term]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, postings)
+	}
+
+	// starting from the shortest list keeps every intersection as small as it gets
+	shortest := 0
+	for i, list := range lists {
+		if len(list) < len(lists[shortest]) {
+			shortest = i
+		}
+	}
+
+	found := lists[shortest]
+	for i, list := range lists {
+		if i != shortest {
+			found = intersect(found, list)
+		}
+	}
+
+	return found
+}
+
+// intersect merges two sorted posting lists into the sequence numbers found in both
+func intersect(a []uint64, b []uint64) []uint64 {
+	both := make([]uint64, 0, min(len(a), len(b)))
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+
+	return both
+}
@@ -4,4 +4,5 @@ import "github.com/iomallach/gchad/internal/server/domain"
 
 type Notifier interface {
 	BroadcastToRoom(*ChatRoom, domain.Messager)
+	SendToClient(clientId string, msg domain.Messager)
 }
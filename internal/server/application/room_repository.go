@@ -0,0 +1,66 @@
+package application
+
+import (
+	"github.com/iomallach/gchad/internal/server/domain"
+)
+
+// RoomRepository holds the rooms hosted by the server. The rooms are fixed at startup,
+// the first one being where clients not asking for a particular room end up.
+type RoomRepository struct {
+	rooms []*ChatRoom
+}
+
+func NewRoomRepository(rooms ...*ChatRoom) *RoomRepository {
+	return &RoomRepository{
+		rooms: rooms,
+	}
+}
+
+// GetRoom returns nil if there is no room with the given id
+func (r *RoomRepository) GetRoom(roomId string) *ChatRoom {
+	for _, room := range r.rooms {
+		if room.Id() == roomId {
+			return room
+		}
+	}
+
+	return nil
+}
+
+// DefaultRoom returns nil if no rooms are hosted
+func (r *RoomRepository) DefaultRoom() *ChatRoom {
+	if len(r.rooms) == 0 {
+		return nil
+	}
+
+	return r.rooms[0]
+}
+
+// RoomOf returns the room the client is in, or nil if it is in none. A connection is only ever in one room.
+func (r *RoomRepository) RoomOf(clientId string) *ChatRoom {
+	for _, room := range r.rooms {
+		if room.GetClient(clientId) != nil {
+			return room
+		}
+	}
+
+	return nil
+}
+
+func (r *RoomRepository) GetRooms() []*ChatRoom {
+	return r.rooms
+}
+
+func (r *RoomRepository) Summaries() []domain.RoomSummary {
+	summaries := make([]domain.RoomSummary, 0, len(r.rooms))
+
+	for _, room := range r.rooms {
+		summaries = append(summaries, domain.RoomSummary{
+			Id:      room.Id(),
+			Name:    room.Name(),
+			Members: len(room.GetClients()),
+		})
+	}
+
+	return summaries
+}
@@ -0,0 +1,94 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+)
+
+// WebhookRegistry keeps the webhooks of every room. It is read by the HTTP handlers
+// and changed by the chat service, hence the lock.
+type WebhookRegistry struct {
+	mu       sync.RWMutex
+	webhooks map[string]domain.Webhook
+	tokenGen IdGen
+}
+
+func NewWebhookRegistry(tokenGen IdGen) *WebhookRegistry {
+	return &WebhookRegistry{
+		webhooks: make(map[string]domain.Webhook),
+		tokenGen: tokenGen,
+	}
+}
+
+// RandomToken is 32 random hex digits, webhook tokens being the only thing guarding them
+func RandomToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+
+	return hex.EncodeToString(token)
+}
+
+func (r *WebhookRegistry) Create(roomId string, name string, createdBy string, at time.Time) domain.Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook := domain.Webhook{
+		Token:     r.tokenGen(),
+		RoomId:    roomId,
+		Name:      name,
+		CreatedBy: createdBy,
+		CreatedAt: at,
+	}
+	r.webhooks[webhook.Token] = webhook
+
+	return webhook
+}
+
+// Revoke reports whether the room had a webhook with that token
+func (r *WebhookRegistry) Revoke(roomId string, token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook, ok := r.webhooks[token]
+	if !ok || webhook.RoomId != roomId {
+		return false
+	}
+	delete(r.webhooks, token)
+
+	return true
+}
+
+func (r *WebhookRegistry) Get(token string) (domain.Webhook, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhook, ok := r.webhooks[token]
+
+	return webhook, ok
+}
+
+// ForRoom returns the webhooks of the room, oldest first
+func (r *WebhookRegistry) ForRoom(roomId string) []domain.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhooks := make([]domain.Webhook, 0)
+	for _, webhook := range r.webhooks {
+		if webhook.RoomId == roomId {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].Name < webhooks[j].Name
+		}
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks
+}
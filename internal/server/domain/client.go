@@ -1,8 +1,80 @@
 package domain
 
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Presence string
+
+const (
+	PresenceOnline       Presence = "online"
+	PresenceAway         Presence = "away"
+	PresenceDoNotDisturb Presence = "dnd"
+)
+
+const MaxStatusTextLength = 64
+
+const MaxNameLength = 20
+
+var (
+	ErrEmptyName               = errors.New("name cannot be empty")
+	ErrNameTooLong             = errors.New("name is too long")
+	ErrNameHasWhitespace       = errors.New("name cannot contain whitespace")
+	ErrNameHasControlCharacter = errors.New("name cannot contain control characters")
+)
+
+// ValidateName goes by the rules text is sanitized with, names with anything sanitizing would
+// strip being refused rather than changed
+func ValidateName(name string) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return ErrNameHasWhitespace
+	}
+	sanitized, err := sanitize(name, MaxNameLength, ErrNameTooLong)
+	if err != nil {
+		return err
+	}
+	if sanitized != name {
+		return ErrNameHasControlCharacter
+	}
+
+	return nil
+}
+
+type Role string
+
+const (
+	RoleMember    Role = "member"
+	RoleModerator Role = "moderator"
+)
+
+func (p Presence) Valid() bool {
+	switch p {
+	case PresenceOnline, PresenceAway, PresenceDoNotDisturb:
+		return true
+	default:
+		return false
+	}
+}
+
 type Client struct {
 	id   string
 	name string
+	role Role
+	// bot is set for programs rather than people, who clients tell apart
+	bot bool
+
+	presence   Presence
+	statusText string
+	lastActive time.Time
+	// autoAway is set when the away presence was assigned due to inactivity
+	// rather than by the user, so that any activity brings the client back online
+	autoAway bool
 }
 
 func (c *Client) Id() string {
@@ -14,9 +86,88 @@ func (c *Client) Name() string {
 	return c.name
 }
 
+func (c *Client) Role() Role {
+	return c.role
+}
+
+func (c *Client) SetRole(role Role) {
+	c.role = role
+}
+
+func (c *Client) IsBot() bool {
+	return c.bot
+}
+
+// Renamed returns a copy of the client going by a new name. Clients are shared between
+// goroutines, so the name is never changed in place.
+func (c *Client) Renamed(name string) *Client {
+	renamed := *c
+	renamed.name = name
+
+	return &renamed
+}
+
+func (c *Client) Presence() Presence {
+	return c.presence
+}
+
+func (c *Client) StatusText() string {
+	return c.statusText
+}
+
+// SetPresence sets the presence chosen by the user and reports whether anything changed
+func (c *Client) SetPresence(presence Presence, statusText string, at time.Time) bool {
+	if runes := []rune(statusText); len(runes) > MaxStatusTextLength {
+		statusText = string(runes[:MaxStatusTextLength])
+	}
+
+	changed := c.presence != presence || c.statusText != statusText || c.autoAway
+	c.presence = presence
+	c.statusText = statusText
+	c.autoAway = false
+	c.lastActive = at
+
+	return changed
+}
+
+// Touch records user activity and reports whether it brought the client back from auto away
+func (c *Client) Touch(at time.Time) bool {
+	c.lastActive = at
+
+	if c.autoAway {
+		c.presence = PresenceOnline
+		c.autoAway = false
+		return true
+	}
+
+	return false
+}
+
+// GoIdle marks an online client away if it has been inactive for longer than awayAfter
+// and reports whether the presence changed
+func (c *Client) GoIdle(now time.Time, awayAfter time.Duration) bool {
+	if c.presence != PresenceOnline || now.Sub(c.lastActive) < awayAfter {
+		return false
+	}
+
+	c.presence = PresenceAway
+	c.autoAway = true
+
+	return true
+}
+
 func NewClient(id string, name string) *Client {
 	return &Client{
-		id,
-		name,
+		id:       id,
+		name:     name,
+		role:     RoleMember,
+		presence: PresenceOnline,
 	}
 }
+
+func NewBotClient(id string, name string) *Client {
+	client := NewClient(id, name)
+	client.bot = true
+
+	return client
+}
@@ -4,26 +4,240 @@ type ApplicationEvent interface {
 	Event()
 }
 
+// ClientConnected is published when a client connects and asks to be let into a room, Joined
+// being told whether it was. Token is what the client proves it is a moderator with, if anything.
+type ClientConnected struct {
+	ClientId string
+	Name     string
+	Token    string
+	RoomId   string
+	Bot      bool
+	Joined   chan error
+}
+
+func NewClientConnectedEvent(clientId string, name string, token string, roomId string, bot bool) *ClientConnected {
+	return &ClientConnected{
+		ClientId: clientId,
+		Name:     name,
+		Token:    token,
+		RoomId:   roomId,
+		Bot:      bot,
+		Joined:   make(chan error, 1),
+	}
+}
+
+func (cc *ClientConnected) Event() {}
+
+type ClientDisconnected struct {
+	ClientId string
+}
+
+func NewClientDisconnectedEvent(clientId string) *ClientDisconnected {
+	return &ClientDisconnected{
+		ClientId: clientId,
+	}
+}
+
+func (cd *ClientDisconnected) Event() {}
+
 type UserLeftRoom struct {
-	Name string
+	Name    string
+	Version uint64
 }
 
-func NewUserLeftRoomEvent(name string) *UserLeftRoom {
+func NewUserLeftRoomEvent(name string, version uint64) *UserLeftRoom {
 	return &UserLeftRoom{
-		Name: name,
+		Name:    name,
+		Version: version,
 	}
 }
 
 func (ulr *UserLeftRoom) Event() {}
 
 type UserJoinedRoom struct {
-	Name string
+	ClientId string
+	Name     string
+	Role     Role
+	Bot      bool
+	Version  uint64
 }
 
-func NewUserJoinedRoomEvent(name string) *UserJoinedRoom {
+func NewUserJoinedRoomEvent(clientId string, name string, role Role, bot bool, version uint64) *UserJoinedRoom {
 	return &UserJoinedRoom{
-		Name: name,
+		ClientId: clientId,
+		Name:     name,
+		Role:     role,
+		Bot:      bot,
+		Version:  version,
 	}
 }
 
 func (ujr *UserJoinedRoom) Event() {}
+
+type UserRequestedPresence struct {
+	ClientId   string
+	Presence   Presence
+	StatusText string
+}
+
+func NewUserRequestedPresenceEvent(clientId string, presence Presence, statusText string) *UserRequestedPresence {
+	return &UserRequestedPresence{
+		ClientId:   clientId,
+		Presence:   presence,
+		StatusText: statusText,
+	}
+}
+
+func (urp *UserRequestedPresence) Event() {}
+
+type UserPresenceChanged struct {
+	Name       string
+	Presence   Presence
+	StatusText string
+	Version    uint64
+}
+
+func NewUserPresenceChangedEvent(name string, presence Presence, statusText string, version uint64) *UserPresenceChanged {
+	return &UserPresenceChanged{
+		Name:       name,
+		Presence:   presence,
+		StatusText: statusText,
+		Version:    version,
+	}
+}
+
+func (upc *UserPresenceChanged) Event() {}
+
+type UserActive struct {
+	ClientId string
+}
+
+func NewUserActiveEvent(clientId string) *UserActive {
+	return &UserActive{
+		ClientId: clientId,
+	}
+}
+
+func (ua *UserActive) Event() {}
+
+// UserSentMessage is published when a client posts to its room, RequestId being what the message is
+// acknowledged under, if anything
+type UserSentMessage struct {
+	ClientId  string
+	RequestId string
+	Text      string
+}
+
+func NewUserSentMessageEvent(clientId string, requestId string, text string) *UserSentMessage {
+	return &UserSentMessage{
+		ClientId:  clientId,
+		RequestId: requestId,
+		Text:      text,
+	}
+}
+
+func (usm *UserSentMessage) Event() {}
+
+type UserRequestedRename struct {
+	ClientId string
+	NewName  string
+}
+
+func NewUserRequestedRenameEvent(clientId string, newName string) *UserRequestedRename {
+	return &UserRequestedRename{
+		ClientId: clientId,
+		NewName:  newName,
+	}
+}
+
+func (urr *UserRequestedRename) Event() {}
+
+type UserRenamed struct {
+	OldName string
+	NewName string
+	Version uint64
+}
+
+func NewUserRenamedEvent(oldName string, newName string, version uint64) *UserRenamed {
+	return &UserRenamed{
+		OldName: oldName,
+		NewName: newName,
+		Version: version,
+	}
+}
+
+func (ur *UserRenamed) Event() {}
+
+// RoomStateRequested is published when a client lost track of the room state and needs a snapshot
+type RoomStateRequested struct {
+	ClientId string
+}
+
+func NewRoomStateRequestedEvent(clientId string) *RoomStateRequested {
+	return &RoomStateRequested{
+		ClientId: clientId,
+	}
+}
+
+func (rsr *RoomStateRequested) Event() {}
+
+// RoomListRequested is published when a client asks which rooms are hosted
+type RoomListRequested struct {
+	ClientId string
+}
+
+func NewRoomListRequestedEvent(clientId string) *RoomListRequested {
+	return &RoomListRequested{
+		ClientId: clientId,
+	}
+}
+
+func (rlr *RoomListRequested) Event() {}
+
+// SearchRequested is published when a client searches the messages of its room
+type SearchRequested struct {
+	ClientId string
+	Request  *SearchMessage
+}
+
+func NewSearchRequestedEvent(clientId string, request *SearchMessage) *SearchRequested {
+	return &SearchRequested{
+		ClientId: clientId,
+		Request:  request,
+	}
+}
+
+func (sr *SearchRequested) Event() {}
+
+// WebhooksRequested is published when a client creates, revokes or lists the webhooks of its room.
+// Request is a CreateWebhookMessage, a RevokeWebhookMessage or a ListWebhooksMessage.
+type WebhooksRequested struct {
+	ClientId string
+	Request  Messager
+}
+
+func NewWebhooksRequestedEvent(clientId string, request Messager) *WebhooksRequested {
+	return &WebhooksRequested{
+		ClientId: clientId,
+		Request:  request,
+	}
+}
+
+func (wr *WebhooksRequested) Event() {}
+
+// EventSubscriptionsRequested is published when a client subscribes to, unsubscribes from or lists
+// the event subscriptions of its room. Request is a SubscribeEventsMessage, an UnsubscribeEventsMessage
+// or a ListEventSubscriptionsMessage.
+type EventSubscriptionsRequested struct {
+	ClientId string
+	Request  Messager
+}
+
+func NewEventSubscriptionsRequestedEvent(clientId string, request Messager) *EventSubscriptionsRequested {
+	return &EventSubscriptionsRequested{
+		ClientId: clientId,
+		Request:  request,
+	}
+}
+
+func (esr *EventSubscriptionsRequested) Event() {}
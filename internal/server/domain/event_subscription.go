@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+// EventKind is what a room event subscription can ask to be told about
+type EventKind string
+
+const (
+	EventMessagePosted    EventKind = "message_posted"
+	EventUserJoined       EventKind = "user_joined"
+	EventUserLeft         EventKind = "user_left"
+	EventKeywordMentioned EventKind = "keyword_mentioned"
+)
+
+const (
+	MaxSubscriptionKeywords = 20
+	// callbackLookupTimeout bounds resolving the host of a callback url
+	callbackLookupTimeout = 5 * time.Second
+)
+
+var (
+	ErrInvalidCallbackUrl = errors.New("the callback url must be an absolute http or https url")
+	ErrPrivateCallbackUrl = errors.New("the callback url must point to a public address")
+	ErrNoEvents           = errors.New("no events to subscribe to")
+	ErrNoKeywords         = errors.New("keyword_mentioned needs keywords")
+	ErrTooManyKeywords    = fmt.Errorf("there are more than %d keywords", MaxSubscriptionKeywords)
+)
+
+func (k EventKind) Valid() bool {
+	switch k {
+	case EventMessagePosted, EventUserJoined, EventUserLeft, EventKeywordMentioned:
+		return true
+	}
+
+	return false
+}
+
+// EventSubscription has the events of a room posted to the url, signed with the secret, see SignEvent
+type EventSubscription struct {
+	Id        string      `json:"id"`
+	RoomId    string      `json:"room_id"`
+	Url       string      `json:"url"`
+	Events    []EventKind `json:"events"`
+	Keywords  []string    `json:"keywords,omitempty"`
+	Secret    string      `json:"secret"`
+	CreatedBy string      `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (s EventSubscription) Wants(kind EventKind) bool {
+	return slices.Contains(s.Events, kind)
+}
+
+// MatchKeywords returns the keywords of the subscription the text has as whole words, ignoring case
+func (s EventSubscription) MatchKeywords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+
+	matched := make([]string, 0)
+	for _, keyword := range s.Keywords {
+		if slices.Contains(words, strings.ToLower(keyword)) {
+			matched = append(matched, keyword)
+		}
+	}
+
+	return matched
+}
+
+// RoomEvent is what subscribers are posted. Message is set for posted messages and keyword mentions,
+// Name for users joining and leaving, Keywords for keyword mentions. Id stays the same across retries.
+type RoomEvent struct {
+	Id        string       `json:"id"`
+	Kind      EventKind    `json:"event"`
+	RoomId    string       `json:"room"`
+	Timestamp time.Time    `json:"timestamp"`
+	Message   *UserMessage `json:"message,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	Keywords  []string     `json:"keywords,omitempty"`
+}
+
+// SignEvent is the signature sent along with an event, the hex encoded HMAC-SHA256 of the
+// timestamp, a dot and the body, keyed with the secret of the subscription
+func SignEvent(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SubscribeEventsMessage is sent by a moderator to have events of the room posted to the url
+type SubscribeEventsMessage struct {
+	Url      string      `json:"url"`
+	Events   []EventKind `json:"events"`
+	Keywords []string    `json:"keywords,omitempty"`
+}
+
+func (m *SubscribeEventsMessage) MessageType() protocol.MessageType {
+	return protocol.SubscribeEvents
+}
+
+func (m *SubscribeEventsMessage) Validate() error {
+	callback, err := url.Parse(m.Url)
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+		return ErrInvalidCallbackUrl
+	}
+	if len(m.Events) == 0 {
+		return ErrNoEvents
+	}
+	for _, kind := range m.Events {
+		if !kind.Valid() {
+			return fmt.Errorf("unknown event %q", kind)
+		}
+	}
+	if slices.Contains(m.Events, EventKeywordMentioned) && len(m.Keywords) == 0 {
+		return ErrNoKeywords
+	}
+	if len(m.Keywords) > MaxSubscriptionKeywords {
+		return ErrTooManyKeywords
+	}
+
+	return validateCallbackHost(callback.Hostname())
+}
+
+// validateCallbackHost refuses hosts resolving to any address which is not public, so that
+// subscriptions cannot have the server post into its own network
+func validateCallbackHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackLookupTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCallbackUrl, err.Error())
+	}
+	for _, addr := range addrs {
+		if !IsPublicAddress(addr) {
+			return ErrPrivateCallbackUrl
+		}
+	}
+
+	return nil
+}
+
+// nonPublicPrefixes are the special purpose ranges the netip.Addr methods do not tell apart
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// IsPublicAddress tells whether the address is a unicast one on the internet, rather than a
+// loopback, private, link local or otherwise special purpose one
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UnsubscribeEventsMessage is sent by a moderator to drop a subscription of the room
+type UnsubscribeEventsMessage struct {
+	Id string `json:"id"`
+}
+
+func (m *UnsubscribeEventsMessage) MessageType() protocol.MessageType {
+	return protocol.UnsubscribeEvents
+}
+
+// ListEventSubscriptionsMessage is sent by a moderator that wants to know the subscriptions of the room
+type ListEventSubscriptionsMessage struct{}
+
+func (m *ListEventSubscriptionsMessage) MessageType() protocol.MessageType {
+	return protocol.ListEventSubscriptions
+}
+
+// EventSubscriptionListSystemMessage tells a moderator the subscriptions of the room, after every change
+// they make too
+type EventSubscriptionListSystemMessage struct {
+	Subscriptions []EventSubscription `json:"subscriptions"`
+}
+
+func NewEventSubscriptionListSystemMessage(subscriptions []EventSubscription) *EventSubscriptionListSystemMessage {
+	return &EventSubscriptionListSystemMessage{
+		Subscriptions: subscriptions,
+	}
+}
+
+func (m *EventSubscriptionListSystemMessage) MessageType() protocol.MessageType {
+	return protocol.EventSubscriptionList
+}
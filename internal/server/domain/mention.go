@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const mentionPrefix = '@'
+
+// Mention marks a reference to a room member in a message text.
+// Start and End are byte offsets into the text, covering the prefix and the name.
+type Mention struct {
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// ParseMentions finds @name references in text. A candidate only counts as a mention if
+// isMember recognises the name; trailing punctuation is dropped until it does, so that
+// "@jane," mentions jane.
+func ParseMentions(text string, isMember func(name string) bool) []Mention {
+	mentions := make([]Mention, 0)
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != mentionPrefix || !startsWord(text, i) {
+			i += size
+			continue
+		}
+
+		nameStart := i + size
+		nameEnd := nameStart + wordLength(text[nameStart:])
+		name := text[nameStart:nameEnd]
+
+		for name != "" && !isMember(name) {
+			last, lastSize := utf8.DecodeLastRuneInString(name)
+			if !unicode.IsPunct(last) {
+				name = ""
+				break
+			}
+			name = name[:len(name)-lastSize]
+		}
+
+		if name != "" {
+			mentions = append(mentions, Mention{Name: name, Start: i, End: nameStart + len(name)})
+		}
+
+		i = nameEnd
+	}
+
+	return mentions
+}
+
+func startsWord(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	previous, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(previous) || strings.ContainsRune("([{\"'", previous)
+}
+
+func wordLength(text string) int {
+	if end := strings.IndexFunc(text, unicode.IsSpace); end >= 0 {
+		return end
+	}
+
+	return len(text)
+}
@@ -1,74 +1,68 @@
 package domain
 
 import (
-	"encoding/json"
-	"fmt"
 	"time"
-)
-
-type MessageType string
 
-const (
-	SystemUserJoined   MessageType = "user_joined"
-	SystemUserLeft     MessageType = "user_left"
-	UserMsg            MessageType = "chat"
-	SystemStatsMessage MessageType = "stats"
+	"github.com/iomallach/gchad/pkg/protocol"
 )
 
 type Messager interface {
-	MessageType() MessageType
-}
-
-type StatsSystemMessage struct {
-	ClientsOnline int `json:"clients_online"`
-}
-
-func NewStatsSystemMessage(clientsOnline int) *StatsSystemMessage {
-	return &StatsSystemMessage{
-		ClientsOnline: clientsOnline,
-	}
-}
-
-func (m *StatsSystemMessage) MessageType() MessageType {
-	return SystemStatsMessage
+	MessageType() protocol.MessageType
 }
 
 type UserJoinedSystemMessage struct {
 	Timestamp time.Time `json:"timestamp"`
 	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	Bot       bool      `json:"bot,omitempty"`
+	Version   uint64    `json:"version"`
 }
 
-func NewUserJoinedSystemMessage(name string, timestamp time.Time) *UserJoinedSystemMessage {
+func NewUserJoinedSystemMessage(name string, role Role, bot bool, version uint64, timestamp time.Time) *UserJoinedSystemMessage {
 	return &UserJoinedSystemMessage{
 		Timestamp: timestamp,
 		Name:      name,
+		Role:      role,
+		Bot:       bot,
+		Version:   version,
 	}
 }
 
-func (m *UserJoinedSystemMessage) MessageType() MessageType {
-	return SystemUserJoined
+func (m *UserJoinedSystemMessage) MessageType() protocol.MessageType {
+	return protocol.UserJoined
 }
 
 type UserLeftSystemMessage struct {
 	Timestamp time.Time `json:"timestamp"`
 	Name      string    `json:"name"`
+	Version   uint64    `json:"version"`
 }
 
-func NewUserLeftSystemMessage(name string, timestamp time.Time) *UserLeftSystemMessage {
+func NewUserLeftSystemMessage(name string, version uint64, timestamp time.Time) *UserLeftSystemMessage {
 	return &UserLeftSystemMessage{
 		Timestamp: timestamp,
 		Name:      name,
+		Version:   version,
 	}
 }
 
-func (m *UserLeftSystemMessage) MessageType() MessageType {
-	return SystemUserLeft
+func (m *UserLeftSystemMessage) MessageType() protocol.MessageType {
+	return protocol.UserLeft
 }
 
+// UserMessage is given its Id by the MessageStore once the server accepts it, the ones
+// clients send have none. Messages posted to a webhook are marked as such and may carry attachments.
 type UserMessage struct {
-	Timestamp time.Time `json:"timestamp"`
-	Text      string    `json:"text"`
-	From      string    `json:"from"`
+	Id          string       `json:"id,omitempty"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Text        string       `json:"text"`
+	From        string       `json:"from"`
+	Mentions    []Mention    `json:"mentions,omitempty"`
+	Webhook     bool         `json:"webhook,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// RequestId is the one of the frame a client sent the message in, which the ack answering it
+	// carries. It never goes out with the message.
+	RequestId string `json:"-"`
 }
 
 func NewUserMessage(msg string, timestamp time.Time, from string) *UserMessage {
@@ -79,53 +73,281 @@ func NewUserMessage(msg string, timestamp time.Time, from string) *UserMessage {
 	}
 }
 
-func (m *UserMessage) MessageType() MessageType {
-	return UserMsg
+func NewWebhookMessage(payload WebhookPayload, timestamp time.Time, from string) *UserMessage {
+	msg := NewUserMessage(payload.Text, timestamp, from)
+	msg.Webhook = true
+	msg.Attachments = payload.Attachments
+
+	return msg
 }
 
-type Message struct {
-	Type    MessageType     `json:"type"`
-	Payload json.RawMessage `json:"payload"`
+func (m *UserMessage) MessageType() protocol.MessageType {
+	return protocol.Chat
 }
 
-func UnmarshalMessage(data []byte) (Messager, error) {
-	var envelope Message
+type PresenceSystemMessage struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Name       string    `json:"name"`
+	Presence   Presence  `json:"presence"`
+	StatusText string    `json:"status_text,omitempty"`
+	Version    uint64    `json:"version"`
+}
 
-	if err := json.Unmarshal(data, &envelope); err != nil {
-		return nil, err
+func NewPresenceSystemMessage(name string, presence Presence, statusText string, version uint64, timestamp time.Time) *PresenceSystemMessage {
+	return &PresenceSystemMessage{
+		Timestamp:  timestamp,
+		Name:       name,
+		Presence:   presence,
+		StatusText: statusText,
+		Version:    version,
 	}
+}
+
+func (m *PresenceSystemMessage) MessageType() protocol.MessageType {
+	return protocol.Presence
+}
+
+// SetPresenceMessage is sent by a client to change its own presence
+type SetPresenceMessage struct {
+	Presence   Presence `json:"presence"`
+	StatusText string   `json:"status_text,omitempty"`
+}
+
+func (m *SetPresenceMessage) MessageType() protocol.MessageType {
+	return protocol.SetPresence
+}
+
+// ActivityMessage is sent by a client to signal the user is active without sending a message,
+// e.g. while typing
+type ActivityMessage struct{}
+
+func (m *ActivityMessage) MessageType() protocol.MessageType {
+	return protocol.Activity
+}
 
-	var msg Messager
-	switch envelope.Type {
-	case SystemUserJoined:
-		msg = &UserJoinedSystemMessage{}
-	case SystemUserLeft:
-		msg = &UserLeftSystemMessage{}
-	case UserMsg:
-		msg = &UserMessage{}
-	case SystemStatsMessage:
-		msg = &StatsSystemMessage{}
-	default:
-		return nil, fmt.Errorf("unknown message %s of type: %s", envelope.Payload, envelope.Type)
+// MemberListSystemMessage is an authoritative snapshot of the room, sent to a client when it joins
+// or asks to be brought back in sync. Every later delta carries the version following it.
+type MemberListSystemMessage struct {
+	Version uint64   `json:"version"`
+	Members []Member `json:"members"`
+}
+
+func NewMemberListSystemMessage(state RoomState) *MemberListSystemMessage {
+	return &MemberListSystemMessage{
+		Version: state.Version,
+		Members: state.Members,
 	}
+}
 
-	if err := json.Unmarshal(envelope.Payload, &msg); err != nil {
-		return nil, err
+func (m *MemberListSystemMessage) MessageType() protocol.MessageType {
+	return protocol.MemberList
+}
+
+type UserRenamedSystemMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	OldName   string    `json:"old_name"`
+	NewName   string    `json:"new_name"`
+	Version   uint64    `json:"version"`
+}
+
+func NewUserRenamedSystemMessage(oldName string, newName string, version uint64, timestamp time.Time) *UserRenamedSystemMessage {
+	return &UserRenamedSystemMessage{
+		Timestamp: timestamp,
+		OldName:   oldName,
+		NewName:   newName,
+		Version:   version,
 	}
+}
 
-	return msg, nil
+func (m *UserRenamedSystemMessage) MessageType() protocol.MessageType {
+	return protocol.UserRenamed
+}
+
+// RenameMessage is sent by a client to change its own name
+type RenameMessage struct {
+	Name string `json:"name"`
+}
+
+func (m *RenameMessage) MessageType() protocol.MessageType {
+	return protocol.Rename
+}
+
+// SyncRoomMessage is sent by a client that missed a room state delta
+type SyncRoomMessage struct{}
+
+func (m *SyncRoomMessage) MessageType() protocol.MessageType {
+	return protocol.SyncRoom
+}
+
+type RoomListSystemMessage struct {
+	Rooms []RoomSummary `json:"rooms"`
+}
+
+func NewRoomListSystemMessage(rooms []RoomSummary) *RoomListSystemMessage {
+	return &RoomListSystemMessage{
+		Rooms: rooms,
+	}
+}
+
+func (m *RoomListSystemMessage) MessageType() protocol.MessageType {
+	return protocol.RoomList
+}
+
+// ListRoomsMessage is sent by a client that wants to know which rooms it can join
+type ListRoomsMessage struct{}
+
+func (m *ListRoomsMessage) MessageType() protocol.MessageType {
+	return protocol.ListRooms
+}
+
+// SearchMessage is sent by a client looking for messages of its room containing all the terms.
+// Results come newest first, Offset skipping that many of them.
+type SearchMessage struct {
+	Terms  string     `json:"terms"`
+	From   string     `json:"from,omitempty"`
+	Before *time.Time `json:"before,omitempty"`
+	Offset int        `json:"offset,omitempty"`
+	Limit  int        `json:"limit,omitempty"`
+}
+
+func (m *SearchMessage) MessageType() protocol.MessageType {
+	return protocol.Search
+}
+
+// Query turns the request into a SearchQuery, filling in the defaults
+func (m *SearchMessage) Query() SearchQuery {
+	var before time.Time
+	if m.Before != nil {
+		before = *m.Before
+	}
+
+	return NewSearchQuery(m.Terms, m.From, before, m.Offset, m.Limit)
+}
+
+// SearchResultsSystemMessage answers a SearchMessage, repeating the query so that the client can ask
+// for the next page
+type SearchResultsSystemMessage struct {
+	Terms   string        `json:"terms"`
+	From    string        `json:"from,omitempty"`
+	Before  *time.Time    `json:"before,omitempty"`
+	Offset  int           `json:"offset"`
+	Total   int           `json:"total"`
+	Results []UserMessage `json:"results"`
+}
+
+func NewSearchResultsSystemMessage(request *SearchMessage, query SearchQuery, page SearchPage) *SearchResultsSystemMessage {
+	return &SearchResultsSystemMessage{
+		Terms:   request.Terms,
+		From:    request.From,
+		Before:  request.Before,
+		Offset:  query.Offset,
+		Total:   page.Total,
+		Results: page.Results,
+	}
+}
+
+func (m *SearchResultsSystemMessage) MessageType() protocol.MessageType {
+	return protocol.SearchResults
+}
+
+// ErrorSystemMessage tells a client a message of its own was turned down, Request being the type of
+// that message, unless the message could not be decoded, and RequestId the id the client gave it
+type ErrorSystemMessage struct {
+	Code      protocol.ErrorCode   `json:"code"`
+	Message   string               `json:"message"`
+	Request   protocol.MessageType `json:"request,omitempty"`
+	RequestId string               `json:"request_id,omitempty"`
 }
 
-func MarshallMessage(data Messager) ([]byte, error) {
-	json_data, err := json.Marshal(data)
-	if err != nil {
+func NewErrorSystemMessage(request protocol.MessageType, code protocol.ErrorCode, message string) *ErrorSystemMessage {
+	return &ErrorSystemMessage{
+		Code:    code,
+		Message: message,
+		Request: request,
+	}
+}
+
+// WithRequestId ties the error to the message of the client that had the id
+func (m *ErrorSystemMessage) WithRequestId(requestId string) *ErrorSystemMessage {
+	m.RequestId = requestId
+	return m
+}
+
+func (m *ErrorSystemMessage) MessageType() protocol.MessageType {
+	return protocol.Error
+}
+
+// AckSystemMessage tells a client its chat message with the request id is in the room, under the id
+// the server gave it. Sequence is where the message falls among the ones of the room, counting from 1.
+type AckSystemMessage struct {
+	RequestId string `json:"request_id"`
+	Id        string `json:"id"`
+	Sequence  uint64 `json:"sequence"`
+}
+
+func NewAckSystemMessage(requestId string, id string, sequence uint64) *AckSystemMessage {
+	return &AckSystemMessage{
+		RequestId: requestId,
+		Id:        id,
+		Sequence:  sequence,
+	}
+}
+
+func (m *AckSystemMessage) MessageType() protocol.MessageType {
+	return protocol.Ack
+}
+
+var decoders = protocol.Decoders[Messager]{
+	protocol.Hello:                  decode[protocol.HelloMessage],
+	protocol.Error:                  decodePointer[ErrorSystemMessage],
+	protocol.Ack:                    decodePointer[AckSystemMessage],
+	protocol.UserJoined:             decodePointer[UserJoinedSystemMessage],
+	protocol.UserLeft:               decodePointer[UserLeftSystemMessage],
+	protocol.Chat:                   decodePointer[UserMessage],
+	protocol.Presence:               decodePointer[PresenceSystemMessage],
+	protocol.SetPresence:            decodePointer[SetPresenceMessage],
+	protocol.Activity:               decodePointer[ActivityMessage],
+	protocol.MemberList:             decodePointer[MemberListSystemMessage],
+	protocol.UserRenamed:            decodePointer[UserRenamedSystemMessage],
+	protocol.Rename:                 decodePointer[RenameMessage],
+	protocol.SyncRoom:               decodePointer[SyncRoomMessage],
+	protocol.RoomList:               decodePointer[RoomListSystemMessage],
+	protocol.ListRooms:              decodePointer[ListRoomsMessage],
+	protocol.Search:                 decodePointer[SearchMessage],
+	protocol.SearchResults:          decodePointer[SearchResultsSystemMessage],
+	protocol.CreateWebhook:          decodePointer[CreateWebhookMessage],
+	protocol.RevokeWebhook:          decodePointer[RevokeWebhookMessage],
+	protocol.ListWebhooks:           decodePointer[ListWebhooksMessage],
+	protocol.WebhookList:            decodePointer[WebhookListSystemMessage],
+	protocol.SubscribeEvents:        decodePointer[SubscribeEventsMessage],
+	protocol.UnsubscribeEvents:      decodePointer[UnsubscribeEventsMessage],
+	protocol.ListEventSubscriptions: decodePointer[ListEventSubscriptionsMessage],
+	protocol.EventSubscriptionList:  decodePointer[EventSubscriptionListSystemMessage],
+}
+
+// NewCodec is the codec of the websocket subprotocol agreed on with a client
+func NewCodec(subprotocol string) protocol.Codec[Messager] {
+	return protocol.NewCodec(subprotocol, decoders)
+}
+
+func decode[M Messager](payload protocol.Payload) (Messager, error) {
+	var msg M
+	if err := payload.Decode(&msg); err != nil {
 		return nil, err
 	}
 
-	envelope := Message{
-		Type:    data.MessageType(),
-		Payload: json_data,
+	return msg, nil
+}
+
+// decodePointer is for the messages of the server, all of them having pointer receivers
+func decodePointer[T any, M interface {
+	*T
+	Messager
+}](payload protocol.Payload) (Messager, error) {
+	msg := M(new(T))
+	if err := payload.Decode(msg); err != nil {
+		return nil, err
 	}
 
-	return json.Marshal(envelope)
+	return msg, nil
 }
@@ -0,0 +1,33 @@
+package domain
+
+type Member struct {
+	Name       string   `json:"name"`
+	Role       Role     `json:"role"`
+	Presence   Presence `json:"presence"`
+	StatusText string   `json:"status_text,omitempty"`
+	Bot        bool     `json:"bot,omitempty"`
+}
+
+func NewMember(client *Client) Member {
+	return Member{
+		Name:       client.Name(),
+		Role:       client.Role(),
+		Presence:   client.Presence(),
+		StatusText: client.StatusText(),
+		Bot:        client.IsBot(),
+	}
+}
+
+// RoomState is everything a client needs to know about who is in the room.
+// Version grows by one with every change to it.
+type RoomState struct {
+	Version uint64
+	Members []Member
+}
+
+// RoomSummary describes a room to clients picking one to join
+type RoomSummary struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+}
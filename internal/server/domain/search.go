@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxSearchResults caps how many messages a single page of search results carries
+const MaxSearchResults = 20
+
+var ErrEmptySearch = errors.New("nothing to search for")
+
+// SearchQuery asks for the messages of a room containing every one of the terms,
+// optionally only those sent by a given name and before a given time
+type SearchQuery struct {
+	Terms  []string
+	From   string
+	Before time.Time
+	Offset int
+	Limit  int
+}
+
+// NewSearchQuery tokenizes the terms the way message texts are indexed and keeps the page
+// within bounds, a non positive limit asking for the largest page
+func NewSearchQuery(terms string, from string, before time.Time, offset int, limit int) SearchQuery {
+	if limit <= 0 || limit > MaxSearchResults {
+		limit = MaxSearchResults
+	}
+
+	return SearchQuery{
+		Terms:  Tokenize(terms),
+		From:   from,
+		Before: before,
+		Offset: max(0, offset),
+		Limit:  limit,
+	}
+}
+
+// Matches tells whether the message passes the sender and time filters of the query, the terms
+// are left to the index
+func (q SearchQuery) Matches(msg UserMessage) bool {
+	if q.From != "" && !strings.EqualFold(msg.From, q.From) {
+		return false
+	}
+
+	return q.Before.IsZero() || msg.Timestamp.Before(q.Before)
+}
+
+// SearchPage is one page of the messages matching a query, newest first
+type SearchPage struct {
+	Total   int
+	Results []UserMessage
+}
+
+// Tokenize splits text into the lower cased words it is searchable by, each one once
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			tokens = append(tokens, word)
+		}
+	}
+
+	return tokens
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+const MaxTextLength = 4000
+
+var (
+	ErrEmptyMessage  = errors.New("the message is empty")
+	ErrTextTooLong   = fmt.Errorf("the text is longer than %d characters", MaxTextLength)
+	ErrStatusTooLong = fmt.Errorf("the status is longer than %d characters", MaxStatusTextLength)
+	ErrInvalidUTF8   = errors.New("the text is not valid UTF-8")
+)
+
+// ValidateInbound is the check every message of a client goes through before it is handled. The
+// text it carries is stripped of control characters in place.
+func ValidateInbound(msg Messager) error {
+	switch msg := msg.(type) {
+	case *UserMessage:
+		text, err := sanitize(msg.Text, MaxTextLength, ErrTextTooLong)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(text) == "" {
+			return ErrEmptyMessage
+		}
+		msg.Text = text
+	case *SetPresenceMessage:
+		status, err := sanitize(msg.StatusText, MaxStatusTextLength, ErrStatusTooLong)
+		if err != nil {
+			return err
+		}
+		msg.StatusText = status
+	case *SearchMessage:
+		terms, err := sanitize(msg.Terms, MaxTextLength, ErrTextTooLong)
+		if err != nil {
+			return err
+		}
+		// terms with nothing to index, such as punctuation, would match every message
+		if len(Tokenize(terms)) == 0 && msg.From == "" && msg.Before == nil {
+			return ErrEmptySearch
+		}
+		msg.Terms = terms
+	}
+
+	return nil
+}
+
+// sanitize drops the control characters of text but for line breaks and tabs, which are up to the
+// clients to render
+func sanitize(text string, maxLength int, errTooLong error) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", ErrInvalidUTF8
+	}
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+	if utf8.RuneCountInString(text) > maxLength {
+		return "", errTooLong
+	}
+
+	return text, nil
+}
+
+// ErrorCodeOf is the code clients are told a message was turned down with
+func ErrorCodeOf(err error) protocol.ErrorCode {
+	switch {
+	case errors.Is(err, ErrEmptyMessage), errors.Is(err, ErrEmptySearch):
+		return protocol.CodeEmptyMessage
+	case errors.Is(err, ErrTextTooLong), errors.Is(err, ErrStatusTooLong):
+		return protocol.CodeTextTooLong
+	default:
+		return protocol.CodeInvalidMessage
+	}
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+const (
+	MaxWebhookTextLength = 4000
+	MaxAttachments       = 10
+)
+
+var (
+	ErrEmptyWebhookMessage = errors.New("the message has neither text nor attachments")
+	ErrWebhookTextTooLong  = fmt.Errorf("the text is longer than %d characters", MaxWebhookTextLength)
+	ErrTooManyAttachments  = fmt.Errorf("there are more than %d attachments", MaxAttachments)
+	ErrEmptyAttachment     = errors.New("an attachment has neither title, url nor text")
+	ErrUnknownWebhook      = errors.New("no such webhook")
+)
+
+// Webhook lets whoever holds the token post into the room over HTTP, under the name of the webhook
+// unless the payload asks for another one
+type Webhook struct {
+	Token     string    `json:"token"`
+	RoomId    string    `json:"room_id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Attachment is a link or a snippet posted along with a webhook message, e.g. the build that failed
+type Attachment struct {
+	Title string `json:"title,omitempty"`
+	Url   string `json:"url,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// WebhookPayload is what is posted to a webhook
+type WebhookPayload struct {
+	Text        string       `json:"text"`
+	Username    string       `json:"username,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Validate strips the text and attachments of control characters in place, as ValidateInbound does
+// for the text of clients
+func (p *WebhookPayload) Validate() error {
+	text, err := sanitize(p.Text, MaxWebhookTextLength, ErrWebhookTextTooLong)
+	if err != nil {
+		return err
+	}
+	if len(p.Attachments) > MaxAttachments {
+		return ErrTooManyAttachments
+	}
+	// the attachments are copied, rather than changed under the caller
+	attachments := make([]Attachment, 0, len(p.Attachments))
+	for _, attachment := range p.Attachments {
+		attachment, err := attachment.sanitized()
+		if err != nil {
+			return err
+		}
+		if attachment == (Attachment{}) {
+			return ErrEmptyAttachment
+		}
+		attachments = append(attachments, attachment)
+	}
+	if strings.TrimSpace(text) == "" && len(attachments) == 0 {
+		return ErrEmptyWebhookMessage
+	}
+	if p.Username != "" {
+		if err := ValidateName(p.Username); err != nil {
+			return fmt.Errorf("username: %w", err)
+		}
+	}
+
+	p.Text = text
+	if p.Attachments != nil {
+		p.Attachments = attachments
+	}
+
+	return nil
+}
+
+func (a Attachment) sanitized() (Attachment, error) {
+	var err error
+	for _, field := range []*string{&a.Title, &a.Url, &a.Text} {
+		if *field, err = sanitize(*field, MaxWebhookTextLength, ErrWebhookTextTooLong); err != nil {
+			return Attachment{}, err
+		}
+	}
+
+	return a, nil
+}
+
+// CreateWebhookMessage is sent by a moderator to create a webhook for the room
+type CreateWebhookMessage struct {
+	Name string `json:"name"`
+}
+
+func (m *CreateWebhookMessage) MessageType() protocol.MessageType {
+	return protocol.CreateWebhook
+}
+
+// RevokeWebhookMessage is sent by a moderator to stop a webhook of the room from working
+type RevokeWebhookMessage struct {
+	Token string `json:"token"`
+}
+
+func (m *RevokeWebhookMessage) MessageType() protocol.MessageType {
+	return protocol.RevokeWebhook
+}
+
+// ListWebhooksMessage is sent by a moderator that wants to know the webhooks of the room
+type ListWebhooksMessage struct{}
+
+func (m *ListWebhooksMessage) MessageType() protocol.MessageType {
+	return protocol.ListWebhooks
+}
+
+// WebhookListSystemMessage tells a moderator the webhooks of the room, after every change they make too
+type WebhookListSystemMessage struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+func NewWebhookListSystemMessage(webhooks []Webhook) *WebhookListSystemMessage {
+	return &WebhookListSystemMessage{
+		Webhooks: webhooks,
+	}
+}
+
+func (m *WebhookListSystemMessage) MessageType() protocol.MessageType {
+	return protocol.WebhookList
+}
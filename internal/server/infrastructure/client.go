@@ -2,12 +2,15 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/iomallach/gchad/internal/server/domain"
 	"github.com/iomallach/gchad/pkg/logging"
 	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
 )
 
 type ClientConfiguration struct {
@@ -15,14 +18,24 @@ type ClientConfiguration struct {
 	PongWait        time.Duration
 	PingPeriod      time.Duration
 	RecieveChanWait time.Duration
+	// HandshakeWait is how long a client has to say hello before it is taken for one that predates
+	// the handshake
+	HandshakeWait time.Duration
+	// MaxMessageSize is the size in bytes of the largest frame read off a client, which is
+	// disconnected for sending a larger one
+	MaxMessageSize  int64
 	SendChannelSize int
 	RecvChannelSize int
+	// Compression applies to the clients that agreed on permessage-deflate
+	Compression network.Compression
 }
 
 type Client struct {
 	id            string
 	name          string
 	conn          network.Connection
+	codec         protocol.Codec[domain.Messager]
+	session       protocol.Session
 	send          chan domain.Messager
 	recv          chan domain.Messager
 	configuration ClientConfiguration
@@ -37,6 +50,15 @@ func (c *Client) Send() chan domain.Messager {
 	return c.send
 }
 
+// Session is what the client and the server agreed on, which is settled before the pumps start
+func (c *Client) Session() protocol.Session {
+	return c.session
+}
+
+func (c *Client) SetSession(session protocol.Session) {
+	c.session = session
+}
+
 func (c *Client) Start(ctx context.Context) {
 	go c.ReadMessages(ctx)
 	go c.WriteMessages(ctx)
@@ -46,6 +68,7 @@ func NewClient(
 	id string,
 	name string,
 	conn network.Connection,
+	codec protocol.Codec[domain.Messager],
 	recv chan domain.Messager,
 	send chan domain.Messager,
 	configuration ClientConfiguration,
@@ -55,6 +78,8 @@ func NewClient(
 		id:            id,
 		name:          name,
 		conn:          conn,
+		codec:         codec,
+		session:       protocol.Legacy(),
 		send:          send,
 		recv:          recv,
 		configuration: configuration,
@@ -74,6 +99,7 @@ func (c *Client) ReadMessages(ctx context.Context) {
 	c.conn.SetPongHandler(func(string) error {
 		return c.conn.SetReadDeadline(time.Now().Add(c.configuration.PongWait))
 	})
+	c.conn.SetReadLimit(c.configuration.MaxMessageSize)
 
 	for {
 		if ctx.Err() != nil {
@@ -82,6 +108,11 @@ func (c *Client) ReadMessages(ctx context.Context) {
 		}
 
 		_, message, err := c.conn.ReadMessage()
+		if errors.Is(err, network.ErrMessageTooLarge) {
+			// the rest of the frame is never read, so the connection is closed
+			c.logger.Info("message too large, disconnecting", map[string]any{"client_id": c.Id(), "limit": c.configuration.MaxMessageSize})
+			return
+		}
 		if err != nil {
 			c.logger.Error(
 				fmt.Sprintf("could not read the message: %s", err.Error()),
@@ -90,14 +121,28 @@ func (c *Client) ReadMessages(ctx context.Context) {
 			return
 		}
 
-		domainMessage, err := domain.UnmarshalMessage(message)
+		domainMessage, requestId, err := c.codec.UnmarshalRequest(message)
+		if errors.Is(err, protocol.ErrUnknownType) {
+			// the client speaks a later version of the protocol
+			c.logger.Debug(fmt.Sprintf("skipping the message: %s", err.Error()), map[string]any{"client_id": c.Id()})
+			continue
+		}
 		if err != nil {
 			c.logger.Error(
 				fmt.Sprintf("failed to unmarshall the message: %s", err.Error()),
 				map[string]any{"client_id": c.Id()},
 			)
+			c.reply(domain.NewErrorSystemMessage("", protocol.CodeMalformedMessage, "the message could not be decoded").WithRequestId(requestId))
+			continue
+		}
+		if err := c.validate(message, domainMessage); err != nil {
+			c.logger.Debug(fmt.Sprintf("invalid message: %s", err.Error()), map[string]any{"client_id": c.Id()})
+			c.reply(domain.NewErrorSystemMessage(domainMessage.MessageType(), domain.ErrorCodeOf(err), err.Error()).WithRequestId(requestId))
 			continue
 		}
+		if chat, ok := domainMessage.(*domain.UserMessage); ok {
+			chat.RequestId = requestId
+		}
 
 		select {
 		case c.recv <- domainMessage:
@@ -112,16 +157,18 @@ func (c *Client) WriteMessages(ctx context.Context) {
 	ticker := time.NewTicker(c.configuration.PingPeriod)
 	defer ticker.Stop()
 	defer c.conn.Close()
-	defer close(c.send)
 
 	for {
 		select {
 		case <-ctx.Done():
 			c.logger.Debug("cancelling write pump", map[string]any{"client_id": c.Id()})
+			if err := c.conn.WriteCloseMessage([]byte{}); err != nil {
+				c.logger.Error(fmt.Sprintf("failed to write close message: %s", err.Error()), map[string]any{"client_id": c.Id()})
+			}
 			return
 		case domanMessage, ok := <-c.send:
 			if !ok {
-				c.logger.Info(
+				c.logger.Error(
 					"send channel has been closed. Sending close message and terminating",
 					map[string]any{"client_id": c.Id()},
 				)
@@ -134,7 +181,7 @@ func (c *Client) WriteMessages(ctx context.Context) {
 
 			c.logger.Debug("sending message", map[string]any{"client_id": c.Id()})
 
-			message, err := domain.MarshallMessage(domanMessage)
+			message, err := c.codec.Marshal(domanMessage)
 			if err != nil {
 				c.logger.Error("failed to marshall a message", map[string]any{"client_id": c.Id()})
 			}
@@ -143,7 +190,7 @@ func (c *Client) WriteMessages(ctx context.Context) {
 				c.logger.Error(fmt.Sprintf("failed to set write deadline: %s", err.Error()), map[string]any{"client_id": c.Id()})
 				return
 			}
-			if err := c.conn.WriteTextMessage(message); err != nil {
+			if err := c.writeMessage(message); err != nil {
 				c.logger.Error(fmt.Sprintf("failed to write message: %s", err.Error()), map[string]any{"client_id": c.Id()})
 				return
 			}
@@ -171,3 +218,30 @@ func (c *Client) WriteMessages(ctx context.Context) {
 		}
 	}
 }
+
+// validate checks the frame, which has to be UTF-8 for the text codec, and then the message
+func (c *Client) validate(frame []byte, msg domain.Messager) error {
+	if !c.codec.Binary() && !utf8.Valid(frame) {
+		return domain.ErrInvalidUTF8
+	}
+
+	return domain.ValidateInbound(msg)
+}
+
+// reply tells the client about its own message, never waiting for the write pump
+func (c *Client) reply(msg domain.Messager) {
+	select {
+	case c.send <- msg:
+	default:
+		c.logger.Error("send channel is full, skipping the reply", map[string]any{"client_id": c.Id()})
+	}
+}
+
+// writeMessage writes the frame of the kind the codec uses
+func (c *Client) writeMessage(data []byte) error {
+	if c.codec.Binary() {
+		return c.conn.WriteBinaryMessage(data)
+	}
+
+	return c.conn.WriteTextMessage(data)
+}
@@ -0,0 +1,268 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/logging"
+)
+
+// The headers going along with every event posted to a subscriber. The signature is
+// "sha256=" followed by domain.SignEvent of the timestamp and the body.
+const (
+	EventHeader     = "X-Gchad-Event"
+	DeliveryHeader  = "X-Gchad-Delivery"
+	TimestampHeader = "X-Gchad-Timestamp"
+	SignatureHeader = "X-Gchad-Signature"
+)
+
+var (
+	errQueueFull = errors.New("the delivery queue is full")
+	errStopped   = errors.New("the server stopped before delivering")
+	errRedirect  = errors.New("the subscriber redirected, which is not followed")
+)
+
+// NewCallbackClient is the client to post events with. It connects to public addresses only, the
+// address being checked as it is dialed so that a host resolving elsewhere than when the
+// subscription was validated is refused too, and does not follow redirects.
+func NewCallbackClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be what is dialed, rather than the subscriber
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errRedirect
+		},
+	}
+}
+
+func dialPublicOnly(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !domain.IsPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", domain.ErrPrivateCallbackUrl, addrPort.Addr())
+	}
+
+	return nil
+}
+
+type DeliveryConfiguration struct {
+	Workers   int
+	QueueSize int
+	// Timeout is how long a single attempt may take
+	Timeout     time.Duration
+	MaxAttempts int
+	// MinBackoff is the wait after the first failed attempt, doubling with every other one up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DeadLetter is an event given up on, along with why
+type DeadLetter struct {
+	Subscription string           `json:"subscription"`
+	Url          string           `json:"url"`
+	Event        domain.RoomEvent `json:"event"`
+	Attempts     int              `json:"attempts"`
+	Error        string           `json:"error"`
+	FailedAt     time.Time        `json:"failed_at"`
+}
+
+type DeadLetterLog interface {
+	Record(letter DeadLetter) error
+}
+
+// FileDeadLetterLog appends dead letters to a file, one JSON object per line
+type FileDeadLetterLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileDeadLetterLog(path string) *FileDeadLetterLog {
+	return &FileDeadLetterLog{path: path}
+}
+
+func (l *FileDeadLetterLog) Record(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+type delivery struct {
+	subscription domain.EventSubscription
+	event        domain.RoomEvent
+}
+
+// HTTPEventDispatcher posts room events to the urls of their subscriptions from a pool of workers,
+// retrying failed attempts with an exponential backoff. Events that cannot be delivered end up
+// in the dead letter log.
+type HTTPEventDispatcher struct {
+	client      *http.Client
+	config      DeliveryConfiguration
+	deadLetters DeadLetterLog
+	queue       chan delivery
+	logger      logging.Logger
+}
+
+func NewHTTPEventDispatcher(client *http.Client, config DeliveryConfiguration, deadLetters DeadLetterLog, logger logging.Logger) *HTTPEventDispatcher {
+	return &HTTPEventDispatcher{
+		client:      client,
+		config:      config,
+		deadLetters: deadLetters,
+		queue:       make(chan delivery, config.QueueSize),
+		logger:      logger,
+	}
+}
+
+func (d *HTTPEventDispatcher) Start(ctx context.Context) {
+	for range d.config.Workers {
+		go d.work(ctx)
+	}
+}
+
+// Dispatch queues the event, it is given up on right away if the queue is full
+func (d *HTTPEventDispatcher) Dispatch(subscription domain.EventSubscription, event domain.RoomEvent) {
+	select {
+	case d.queue <- delivery{subscription, event}:
+	default:
+		d.giveUp(delivery{subscription, event}, 0, errQueueFull)
+	}
+}
+
+func (d *HTTPEventDispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.deliver(ctx, delivery)
+		case <-ctx.Done():
+			// whatever is left is not lost without a trace
+			for {
+				select {
+				case delivery := <-d.queue:
+					d.giveUp(delivery, 0, errStopped)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *HTTPEventDispatcher) deliver(ctx context.Context, delivery delivery) {
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		d.giveUp(delivery, 0, err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, delivery, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= d.config.MaxAttempts {
+			d.giveUp(delivery, attempt, err)
+			return
+		}
+
+		d.logger.Debug(fmt.Sprintf("delivery failed, retrying: %s", err.Error()), map[string]any{"subscription": delivery.subscription.Id, "attempt": attempt})
+		select {
+		case <-time.After(d.backoff(attempt)):
+		case <-ctx.Done():
+			d.giveUp(delivery, attempt, err)
+			return
+		}
+	}
+}
+
+// post makes a single attempt, reporting whether it is worth another one if it failed
+func (d *HTTPEventDispatcher) post(ctx context.Context, delivery delivery, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, string(delivery.event.Kind))
+	request.Header.Set(DeliveryHeader, delivery.event.Id)
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, "sha256="+domain.SignEvent(delivery.subscription.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		// the subscriber will not have moved by the next attempt
+		retry := !errors.Is(err, domain.ErrPrivateCallbackUrl) && !errors.Is(err, errRedirect)
+		return retry, err
+	}
+	defer response.Body.Close()
+	// read a little of the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4<<10))
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return true, fmt.Errorf("the subscriber answered %s", response.Status)
+	default:
+		return false, fmt.Errorf("the subscriber answered %s", response.Status)
+	}
+}
+
+func (d *HTTPEventDispatcher) backoff(attempt int) time.Duration {
+	delay := d.config.MinBackoff
+	for i := 1; i < attempt && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.config.MaxBackoff)
+}
+
+func (d *HTTPEventDispatcher) giveUp(delivery delivery, attempts int, err error) {
+	d.logger.Error(fmt.Sprintf("gave up on delivering an event: %s", err.Error()), map[string]any{"subscription": delivery.subscription.Id, "event_id": delivery.event.Id})
+
+	letter := DeadLetter{
+		Subscription: delivery.subscription.Id,
+		Url:          delivery.subscription.Url,
+		Event:        delivery.event,
+		Attempts:     attempts,
+		Error:        err.Error(),
+		FailedAt:     time.Now(),
+	}
+	if err := d.deadLetters.Record(letter); err != nil {
+		d.logger.Error(fmt.Sprintf("failed to record a dead letter: %s", err.Error()), map[string]any{"event_id": delivery.event.Id})
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/iomallach/gchad/internal/server/application"
 	"github.com/iomallach/gchad/internal/server/domain"
 	"github.com/iomallach/gchad/pkg/logging"
 	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
 )
 
 type Handler struct {
@@ -20,6 +23,7 @@ type Handler struct {
 	idGen        application.IdGen
 	logger       logging.Logger
 	appCtx       context.Context
+	streams      *eventStreams
 }
 
 func NewHandler(
@@ -39,13 +43,13 @@ func NewHandler(
 		idGen:        idGen,
 		logger:       logger,
 		appCtx:       appCtx,
+		streams:      newEventStreams(),
 	}
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	clientName := r.URL.Query().Get("name")
-	if clientName == "" {
-		h.logger.Error("client name not provided, skipping", map[string]any{})
+	admission, ok := h.admit(w, r)
+	if !ok {
 		return
 	}
 
@@ -56,29 +60,192 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientId := h.idGen()
-	wsConn := network.NewWebsocketsConnection(conn, h.logger)
+	h.serve(network.NewWebsocketsConnection(conn, h.logger), admission)
+}
+
+// admission is who is connecting to which room
+type admission struct {
+	clientName string
+	// token proves the client is a moderator, it is a secret which is not logged
+	token  string
+	roomId string
+	bot    bool
+}
+
+// admit reads who is connecting to which room, answering the request itself when it is not ok
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request) (admission, bool) {
+	clientName := r.URL.Query().Get("name")
+	if err := domain.ValidateName(clientName); err != nil {
+		h.logger.Error(fmt.Sprintf("invalid client name, skipping: %s", err.Error()), map[string]any{})
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return admission{}, false
+	}
+	// no room means the default one, which keeps clients unaware of rooms working
+	roomId := r.URL.Query().Get("room")
+	// bots say so when connecting, anything but a true value meaning a person
+	bot, _ := strconv.ParseBool(r.URL.Query().Get("bot"))
+	if !h.chatService.HasRoom(roomId) {
+		h.logger.Error("unknown room, skipping", map[string]any{"room_id": roomId})
+		http.Error(w, fmt.Sprintf("no such room: %s", roomId), http.StatusNotFound)
+		return admission{}, false
+	}
+
+	return admission{
+		clientName: clientName,
+		token:      r.URL.Query().Get("moderator_token"),
+		roomId:     roomId,
+		bot:        bot,
+	}, true
+}
+
+// serve keeps the client in the room until the connection goes away, whatever the transport
+func (h *Handler) serve(conn network.Connection, admission admission) {
+	clientId, clientName, roomId := h.idGen(), admission.clientName, admission.roomId
+	if err := conn.SetCompression(h.clientConfig.Compression); err != nil {
+		h.logger.Error(fmt.Sprintf("failed to set the compression: %s", err.Error()), map[string]any{})
+	}
 	recv := make(chan domain.Messager, h.clientConfig.RecvChannelSize)
 	send := make(chan domain.Messager, h.clientConfig.SendChannelSize)
-	client := NewClient(clientId, clientName, wsConn, recv, send, h.clientConfig, h.logger)
-	h.logger.Info(fmt.Sprintf("client %s connected", clientName), map[string]any{})
+	// clients asking for no subprotocol get the JSON codec, as they did before there were others
+	codec := domain.NewCodec(conn.Subprotocol())
+	client := NewClient(clientId, clientName, conn, codec, recv, send, h.clientConfig, h.logger)
+	h.logger.Info(fmt.Sprintf("client %s connected", clientName), map[string]any{"subprotocol": codec.Subprotocol()})
 
 	h.notifier.RegisterClient(client)
-	h.chatService.EnterRoom(clientId, clientName)
 
 	ctx, cancel := context.WithCancel(h.appCtx)
 
+	read := make(chan struct{})
+	go func() {
+		client.ReadMessages(ctx)
+		close(read)
+	}()
+
+	first, ok := h.handshake(client, conn, recv)
+	if !ok {
+		<-read
+		cancel()
+		h.notifier.UnregisterClient(clientId)
+		return
+	}
+	// the client is in the room before anything it said is handled
+	if err := h.chatService.EnterRoom(clientId, clientName, admission.token, roomId, admission.bot); err != nil {
+		h.logger.Error(fmt.Sprintf("failed to enter the room: %s", err.Error()), map[string]any{"client_id": clientId, "room_id": roomId})
+		h.refuse(client, conn, err)
+		<-read
+		cancel()
+		h.notifier.UnregisterClient(clientId)
+		return
+	}
+
+	forwarded := make(chan struct{})
 	go client.WriteMessages(ctx)
-	go h.forwardMessages(ctx, clientName, recv)
+	go func() {
+		if first != nil {
+			h.forwardMessage(clientId, first)
+		}
+		h.forwardMessages(ctx, clientId, recv)
+		close(forwarded)
+	}()
 	h.logger.Info(fmt.Sprintf("client %s started", clientName), map[string]any{})
 
-	// TODO: blocks this goroutine, need to unblock it later
-	client.ReadMessages(ctx)
+	<-read
+	// whatever the client sent right before hanging up still goes out before it leaves the room
+	<-forwarded
 	cancel()
 	h.chatService.LeaveRoom(clientId)
 	h.notifier.UnregisterClient(clientId)
 }
 
+// handshake waits for the hello clients open with and queues the welcome answering it, ahead of
+// whatever the room sends. A client saying anything else first, or nothing for a while, predates the
+// handshake, what it said being returned to be handled once it is in the room. It is not ok when
+// the client is gone or speaks a version of the protocol the server does not.
+func (h *Handler) handshake(client *Client, conn network.Connection, recv chan domain.Messager) (domain.Messager, bool) {
+	timer := time.NewTimer(h.clientConfig.HandshakeWait)
+	defer timer.Stop()
+
+	var msg domain.Messager
+	select {
+	case received, ok := <-recv:
+		if !ok {
+			return nil, false
+		}
+		msg = received
+	case <-timer.C:
+		h.logger.Debug("no hello, speaking the first version of the protocol", map[string]any{"client_id": client.Id()})
+		return nil, true
+	}
+
+	hello, ok := msg.(protocol.HelloMessage)
+	if !ok {
+		return msg, true
+	}
+	session, err := protocol.Negotiate(hello, protocol.Capabilities())
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("handshake failed: %s", err.Error()), map[string]any{"client_id": client.Id()})
+		h.reject(client, conn, websocket.FormatCloseMessage(websocket.CloseProtocolError, err.Error()))
+		return nil, false
+	}
+
+	client.SetSession(session)
+	select {
+	case client.Send() <- session.Welcome():
+	default:
+		h.logger.Error("send channel is full, skipping the welcome", map[string]any{"client_id": client.Id()})
+	}
+	h.logger.Debug("handshake done", map[string]any{"client_id": client.Id(), "version": session.Version, "capabilities": session.Capabilities})
+
+	return nil, true
+}
+
+// refuse tells the client why it was not let into the room and closes the connection, the write
+// pump not having started. What was queued, the welcome, goes out ahead of the refusal.
+func (h *Handler) refuse(client *Client, conn network.Connection, err error) {
+	queued := []domain.Messager{}
+	for drained := false; !drained; {
+		select {
+		case msg := <-client.Send():
+			queued = append(queued, msg)
+		default:
+			drained = true
+		}
+	}
+
+	for _, msg := range append(queued, domain.NewErrorSystemMessage("", application.ErrorCodeOf(err), err.Error())) {
+		if writeErr := h.writeNow(client, conn, msg); writeErr != nil {
+			h.logger.Error(fmt.Sprintf("failed to write the refusal: %s", writeErr.Error()), map[string]any{"client_id": client.Id()})
+			break
+		}
+	}
+
+	h.reject(client, conn, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+}
+
+func (h *Handler) writeNow(client *Client, conn network.Connection, msg domain.Messager) error {
+	data, err := client.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(h.clientConfig.WriteWait)); err != nil {
+		return err
+	}
+
+	return client.writeMessage(data)
+}
+
+func (h *Handler) reject(client *Client, conn network.Connection, closeMessage []byte) {
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(h.clientConfig.WriteWait)); err != nil {
+		h.logger.Error(fmt.Sprintf("failed to set write deadline: %s", err.Error()), map[string]any{"client_id": client.Id()})
+		return
+	}
+	if err := conn.WriteCloseMessage(closeMessage); err != nil {
+		h.logger.Error(fmt.Sprintf("failed to write close message: %s", err.Error()), map[string]any{"client_id": client.Id()})
+	}
+}
+
 func (h *Handler) forwardMessages(ctx context.Context, clientId string, recv chan domain.Messager) {
 	for {
 		select {
@@ -87,11 +254,32 @@ func (h *Handler) forwardMessages(ctx context.Context, clientId string, recv cha
 				h.logger.Debug("client has closed, exiting forwardMessages", map[string]any{"client_id": clientId})
 				return
 			}
-			if userMsg, ok := msg.(*domain.UserMessage); ok {
-				h.chatService.SendMessage(clientId, userMsg.Text)
-			}
+			h.forwardMessage(clientId, msg)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func (h *Handler) forwardMessage(clientId string, msg domain.Messager) {
+	switch msg := msg.(type) {
+	case *domain.UserMessage:
+		h.chatService.SendMessage(clientId, msg.RequestId, msg.Text)
+	case *domain.SetPresenceMessage:
+		h.chatService.SetPresence(clientId, msg.Presence, msg.StatusText)
+	case *domain.ActivityMessage:
+		h.chatService.RecordActivity(clientId)
+	case *domain.RenameMessage:
+		h.chatService.Rename(clientId, msg.Name)
+	case *domain.SyncRoomMessage:
+		h.chatService.SyncRoom(clientId)
+	case *domain.ListRoomsMessage:
+		h.chatService.ListRooms(clientId)
+	case *domain.SearchMessage:
+		h.chatService.SearchMessages(clientId, msg)
+	case *domain.CreateWebhookMessage, *domain.RevokeWebhookMessage, *domain.ListWebhooksMessage:
+		h.chatService.ManageWebhooks(clientId, msg)
+	case *domain.SubscribeEventsMessage, *domain.UnsubscribeEventsMessage, *domain.ListEventSubscriptionsMessage:
+		h.chatService.ManageEventSubscriptions(clientId, msg)
+	}
+}
@@ -54,6 +54,24 @@ func (n *ClientNotifier) BroadcastToRoom(room *application.ChatRoom, msg domain.
 	}
 }
 
+func (n *ClientNotifier) SendToClient(clientId string, msg domain.Messager) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	adapter, ok := n.clients[clientId]
+	if !ok {
+		n.logger.Debug("attempted to send to client that doesn't exist", map[string]any{"client_id": clientId})
+		return
+	}
+
+	select {
+	case adapter.send <- msg:
+		n.logger.Debug("message queued for client", map[string]any{"client_id": clientId, "message_type": string(msg.MessageType())})
+	default:
+		n.logger.Error("failed to queue message, channel is full or closed", map[string]any{"client_id": clientId})
+	}
+}
+
 func (n *ClientNotifier) RegisterClient(client *Client) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
@@ -62,6 +80,7 @@ func (n *ClientNotifier) RegisterClient(client *Client) {
 		n.logger.Error("client already exists, skipping adding", map[string]any{"client_id": client.Id()})
 	} else {
 		n.clients[client.Id()] = client
+		n.logger.Debug("client registered", map[string]any{"client_id": client.Id()})
 	}
 }
 
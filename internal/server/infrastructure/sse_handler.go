@@ -0,0 +1,88 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/iomallach/gchad/internal/server/application"
+	"github.com/iomallach/gchad/pkg/network"
+)
+
+// eventStreams are the open server-sent event streams by session, the secret their posts are taken on
+type eventStreams struct {
+	mu      sync.Mutex
+	streams map[string]*network.SSEConnection
+}
+
+func newEventStreams() *eventStreams {
+	return &eventStreams{streams: make(map[string]*network.SSEConnection)}
+}
+
+func (s *eventStreams) add(conn *network.SSEConnection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[conn.Session()] = conn
+}
+
+func (s *eventStreams) remove(session string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, session)
+}
+
+func (s *eventStreams) get(session string) (*network.SSEConnection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn, ok := s.streams[session]
+
+	return conn, ok
+}
+
+// ServeEvents serves clients over server-sent events, taking the query parameters ServeHTTP does
+func (h *Handler) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	admission, ok := h.admit(w, r)
+	if !ok {
+		return
+	}
+
+	conn := network.NewSSEConnection(w, r, application.RandomToken(), h.logger)
+	// the stream is closed before the handler returns, which is when the response is done with
+	defer conn.Close()
+	h.streams.add(conn)
+	defer h.streams.remove(conn.Session())
+
+	if err := conn.Open(); err != nil {
+		h.logger.Error(fmt.Sprintf("failed to open the event stream: %s", err.Error()), map[string]any{})
+		return
+	}
+	h.logger.Info("opened an event stream", map[string]any{})
+
+	h.serve(conn, admission)
+}
+
+// ServeMessages takes what the clients of ServeEvents post under their session
+func (h *Handler) ServeMessages(w http.ResponseWriter, r *http.Request) {
+	// the session is the secret, which is not logged
+	conn, ok := h.streams.get(r.URL.Query().Get("session"))
+	if !ok {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+
+	err := conn.Receive(r.Context(), r.URL.Query().Get("control"), r.Body)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, network.ErrMessageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, network.ErrUnknownControl):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case r.Context().Err() != nil:
+		// the client gave up on the post
+	default:
+		// the stream is closing
+		http.Error(w, err.Error(), http.StatusGone)
+	}
+}
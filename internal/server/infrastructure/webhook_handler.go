@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/iomallach/gchad/internal/server/application"
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/logging"
+)
+
+// maxWebhookPayload is well above what the longest text with every attachment takes
+const maxWebhookPayload = 64 << 10
+
+// WebhookHandler posts into rooms for whoever holds the token of a webhook, such as CI jobs and
+// alerting, which would rather not keep a websocket open. It expects to be routed POST /hooks/{token}.
+type WebhookHandler struct {
+	chatService *application.ChatService
+	logger      logging.Logger
+}
+
+func NewWebhookHandler(chatService *application.ChatService, logger logging.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		chatService: chatService,
+		logger:      logger,
+	}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload domain.WebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookPayload)).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	err := h.chatService.PostToWebhook(r.PathValue("token"), payload)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, domain.ErrUnknownWebhook):
+		// the token is the secret, which is not logged
+		h.logger.Error("post to an unknown webhook", map[string]any{})
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, application.ErrBusy):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
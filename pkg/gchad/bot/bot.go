@@ -0,0 +1,253 @@
+// Package bot runs chat bots on top of the gchad client. A bot joins rooms and routes the messages
+// said in them to the handlers registered for commands, mentions of the bot and patterns:
+//
+//	b := bot.New("echo")
+//	b.Command("echo", func(ctx *bot.Context) error {
+//		return ctx.Reply(ctx.Text)
+//	})
+//	err := b.Run(ctx, "ws://localhost:8080/chat", "general", "random")
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/logging"
+)
+
+// closeTimeout is how long the bot waits for what it still has to say when stopping
+const closeTimeout = 5 * time.Second
+
+// Handler answers a message. Errors are logged, the bot carries on.
+type Handler func(ctx *Context) error
+
+type routeKind int
+
+const (
+	commandRoute routeKind = iota
+	mentionRoute
+	patternRoute
+)
+
+type route struct {
+	kind    routeKind
+	command string
+	pattern *regexp.Regexp
+	handler Handler
+}
+
+// Bot routes every message to the first handler it matches, in the order they were registered.
+// Messages of the bot itself and of other bots are ignored, which keeps bots from talking in circles.
+type Bot struct {
+	name    string
+	prefix  string
+	logger  logging.Logger
+	options []gchad.Option
+	routes  []route
+}
+
+type Option func(*Bot)
+
+// WithPrefix replaces "!" as the start of commands
+func WithPrefix(prefix string) Option {
+	return func(b *Bot) {
+		b.prefix = prefix
+	}
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return func(b *Bot) {
+		b.logger = logger
+	}
+}
+
+// WithClientOptions are passed on to gchad.Dial for every room
+func WithClientOptions(options ...gchad.Option) Option {
+	return func(b *Bot) {
+		b.options = append(b.options, options...)
+	}
+}
+
+func New(name string, options ...Option) *Bot {
+	b := &Bot{
+		name:   name,
+		prefix: "!",
+		logger: nopLogger{},
+	}
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
+
+// Command handles messages starting with the prefix and the name, e.g. "!remind 10m stretch",
+// the rest of the message being in Context.Text and Context.Args
+func (b *Bot) Command(name string, handler Handler) {
+	b.routes = append(b.routes, route{kind: commandRoute, command: name, handler: handler})
+}
+
+// Mention handles messages mentioning the bot, e.g. "@echo are you there?"
+func (b *Bot) Mention(handler Handler) {
+	b.routes = append(b.routes, route{kind: mentionRoute, handler: handler})
+}
+
+// Hear handles messages matching the pattern, its submatches being in Context.Match.
+// It panics if the pattern does not compile, like regexp.MustCompile.
+func (b *Bot) Hear(pattern string, handler Handler) {
+	b.routes = append(b.routes, route{kind: patternRoute, pattern: regexp.MustCompile(pattern), handler: handler})
+}
+
+// Run joins the rooms, the default one of the server if none is given, and answers messages until
+// the context is done or a room is lost for good
+func (b *Bot) Run(ctx context.Context, address string, rooms ...string) error {
+	if len(rooms) == 0 {
+		rooms = []string{""}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	clients := make([]*gchad.Client, 0, len(rooms))
+	subscriptions := make([]*gchad.Subscription, 0, len(rooms))
+	for _, room := range rooms {
+		subscription := gchad.NewSubscription(gchad.TypeChat)
+		options := append([]gchad.Option{gchad.WithLogger(b.logger)}, b.options...)
+		options = append(options, gchad.WithRoom(room), gchad.AsBot(), gchad.WithSubscription(subscription))
+
+		client, err := gchad.Dial(ctx, address, b.name, options...)
+		if err != nil {
+			b.closeAll(clients)
+			return fmt.Errorf("failed to join room %q: %w", room, err)
+		}
+		clients = append(clients, client)
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	var wg sync.WaitGroup
+	lost := make(chan error, len(clients))
+	for i, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.serve(ctx, client, subscriptions[i])
+			if err := client.Err(); err != nil {
+				lost <- fmt.Errorf("lost room %q: %w", client.Room(), err)
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-lost:
+	}
+	cancel()
+	b.closeAll(clients)
+	wg.Wait()
+
+	return err
+}
+
+// serve answers the messages of a room one at a time until the client is done or the bot stops
+func (b *Bot) serve(ctx context.Context, client *gchad.Client, subscription *gchad.Subscription) {
+	defer subscription.Unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			switch msg := event.(type) {
+			case gchad.ChatMessage:
+				b.handle(ctx, client, msg)
+			case gchad.ErrorMessage:
+				// e.g. a reply too long for the server to take
+				b.logger.Error(fmt.Sprintf("the server turned down a message: %s", msg.Error()), map[string]any{"room": client.Room(), "code": string(msg.Code)})
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Bot) handle(ctx context.Context, client *gchad.Client, msg gchad.ChatMessage) {
+	if msg.From == client.Name() || isBot(client.Members(), msg.From) {
+		return
+	}
+
+	for _, route := range b.routes {
+		botCtx, ok := b.match(route, client, msg)
+		if !ok {
+			continue
+		}
+
+		botCtx.Context = ctx
+		if err := route.handler(botCtx); err != nil {
+			b.logger.Error(fmt.Sprintf("handler failed: %s", err.Error()), map[string]any{"room": client.Room(), "message_id": msg.Id})
+		}
+		return
+	}
+}
+
+// match builds the context of the handler when the message is for the route
+func (b *Bot) match(route route, client *gchad.Client, msg gchad.ChatMessage) (*Context, bool) {
+	botCtx := &Context{Room: client.Room(), Message: msg, Text: msg.Text, client: client}
+
+	switch route.kind {
+	case commandRoute:
+		command, rest, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+		if command != b.prefix+route.command {
+			return nil, false
+		}
+		botCtx.Text = strings.TrimSpace(rest)
+		botCtx.Args = strings.Fields(rest)
+
+	case mentionRoute:
+		if !msg.MentionsName(client.Name()) {
+			return nil, false
+		}
+
+	case patternRoute:
+		botCtx.Match = route.pattern.FindStringSubmatch(msg.Text)
+		if botCtx.Match == nil {
+			return nil, false
+		}
+	}
+
+	return botCtx, true
+}
+
+func isBot(members []gchad.Member, name string) bool {
+	for _, member := range members {
+		if member.Name == name {
+			return member.Bot
+		}
+	}
+
+	return false
+}
+
+// closeAll leaves the rooms, the ones already lost having nothing more to report
+func (b *Bot) closeAll(clients []*gchad.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	for _, client := range clients {
+		if err := client.Close(ctx); err != nil && client.Err() == nil {
+			b.logger.Error(fmt.Sprintf("failed to leave the room: %s", err.Error()), map[string]any{"room": client.Room()})
+		}
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, map[string]any) {}
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
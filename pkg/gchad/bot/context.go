@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// Context is what a handler knows about the message it answers and the room it was said in.
+// It ends when the bot stops, handlers that answer later, such as reminders, can keep it.
+type Context struct {
+	context.Context
+
+	// Room is the id of the room, empty for the default one of the server
+	Room    string
+	Message gchad.ChatMessage
+	// Text is what follows the command for commands, the whole message otherwise
+	Text string
+	// Args are the words of Text for commands
+	Args []string
+	// Match holds the submatches of the pattern for patterns
+	Match []string
+
+	client *gchad.Client
+}
+
+// Say says something in the room
+func (c *Context) Say(text string) error {
+	return c.client.Send(c, text)
+}
+
+// Reply says something in the room mentioning the author of the message
+func (c *Context) Reply(text string) error {
+	return c.Say("@" + c.Message.From + " " + text)
+}
+
+// Members returns everyone in the room
+func (c *Context) Members() []gchad.Member {
+	return c.client.Members()
+}
+
+// BotName is the name the bot goes by in the room
+func (c *Context) BotName() string {
+	return c.client.Name()
+}
@@ -0,0 +1,284 @@
+// Package bottest runs bots against a fake chat server, for testing them without a real one:
+//
+//	server := bottest.NewServer(t)
+//	go b.Run(ctx, server.Address(), "general")
+//	server.WaitForMember(t, "general", "echo")
+//	server.Say("general", "jane", "!echo hi")
+//	reply := server.Expect(t, "general")
+package bottest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	serverdomain "github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+// Timeout is how long Expect and WaitForMember wait
+var Timeout = 2 * time.Second
+
+// DefaultRoom stands for the room clients asking for none get
+const DefaultRoom = "general"
+
+// codec is the JSON one, the server offering no subprotocol
+var codec = gchad.NewCodec(protocol.SubprotocolJSON)
+
+// Server speaks enough of the protocol for bots: it lets clients into rooms, tells who comes and
+// goes, passes messages around with their mentions, and keeps what the clients said for Expect.
+// Everything else clients send is ignored.
+type Server struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	rooms  map[string]*room
+	nextId int
+}
+
+type room struct {
+	version uint64
+	members map[*websocket.Conn]gchad.Member
+	// said are the messages of connected clients, people talking through Say are left out
+	said chan gchad.ChatMessage
+}
+
+// NewServer starts a server, stopped when the test ends
+func NewServer(t testing.TB) *Server {
+	s := &Server{rooms: make(map[string]*room)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(func() {
+		s.hangUp()
+		s.server.Close()
+	})
+
+	return s
+}
+
+// Address is the chat endpoint to dial
+func (s *Server) Address() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/chat"
+}
+
+// Say has a person who is not connected say something in the room
+func (s *Server) Say(roomId string, from string, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.broadcast(s.room(roomId), s.chatMessage(s.room(roomId), from, text))
+}
+
+// Expect returns the next message a client said in the room, failing the test if none comes
+func (s *Server) Expect(t testing.TB, roomId string) gchad.ChatMessage {
+	t.Helper()
+
+	s.mu.Lock()
+	said := s.room(roomId).said
+	s.mu.Unlock()
+
+	select {
+	case msg := <-said:
+		return msg
+	case <-time.After(Timeout):
+		t.Fatalf("nothing was said in room %q", roomId)
+		return gchad.ChatMessage{}
+	}
+}
+
+// ExpectSilence fails the test if a client says something in the room within the duration
+func (s *Server) ExpectSilence(t testing.TB, roomId string, duration time.Duration) {
+	t.Helper()
+
+	s.mu.Lock()
+	said := s.room(roomId).said
+	s.mu.Unlock()
+
+	select {
+	case msg := <-said:
+		t.Fatalf("%s said %q in room %q", msg.From, msg.Text, roomId)
+	case <-time.After(duration):
+	}
+}
+
+// WaitForMember waits for a client going by the name to be in the room
+func (s *Server) WaitForMember(t testing.TB, roomId string, name string) gchad.Member {
+	t.Helper()
+
+	deadline := time.Now().Add(Timeout)
+	for time.Now().Before(deadline) {
+		if member, ok := s.member(roomId, name); ok {
+			return member
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("%s never entered room %q", name, roomId)
+	return gchad.Member{}
+}
+
+func (s *Server) member(roomId string, name string) (gchad.Member, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, member := range s.room(roomId).members {
+		if member.Name == name {
+			return member, true
+		}
+	}
+
+	return gchad.Member{}, false
+}
+
+// hangUp drops every client, the server does not stop before they are gone
+func (s *Server) hangUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, room := range s.rooms {
+		for conn := range room.members {
+			conn.Close()
+		}
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("name")
+	if err := serverdomain.ValidateName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	roomId := query.Get("room")
+	if roomId == "" {
+		roomId = DefaultRoom
+	}
+	bot, _ := strconv.ParseBool(query.Get("bot"))
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.enter(conn, roomId, gchad.Member{Name: name, Role: gchad.RoleMember, Presence: gchad.PresenceOnline, Bot: bot})
+	defer s.leave(conn, roomId)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, err := codec.Unmarshal(data)
+		if err != nil {
+			continue
+		}
+
+		if chat, ok := msg.(gchad.ChatMessage); ok {
+			s.said(conn, roomId, chat.Text)
+		}
+	}
+}
+
+func (s *Server) enter(conn *websocket.Conn, roomId string, member gchad.Member) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.room(roomId)
+	room.members[conn] = member
+	room.version++
+
+	s.broadcast(room, gchad.UserJoinedMessage{Timestamp: time.Now(), Name: member.Name, Role: member.Role, Bot: member.Bot, Version: room.version})
+
+	members := make([]gchad.Member, 0, len(room.members))
+	for _, member := range room.members {
+		members = append(members, member)
+	}
+	write(conn, gchad.MemberListMessage{Version: room.version, Members: members})
+}
+
+func (s *Server) leave(conn *websocket.Conn, roomId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.room(roomId)
+	member := room.members[conn]
+	delete(room.members, conn)
+	room.version++
+
+	s.broadcast(room, gchad.UserLeftMessage{Timestamp: time.Now(), Name: member.Name, Version: room.version})
+}
+
+func (s *Server) said(conn *websocket.Conn, roomId string, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room := s.room(roomId)
+	msg := s.chatMessage(room, room.members[conn].Name, text)
+	s.broadcast(room, msg)
+
+	select {
+	case room.said <- msg:
+	default:
+		// nobody is expecting that much, drop it rather than stall the server
+	}
+}
+
+// chatMessage numbers the message and finds the members it mentions, the way the real server does
+func (s *Server) chatMessage(room *room, from string, text string) gchad.ChatMessage {
+	s.nextId++
+
+	isMember := func(name string) bool {
+		for _, member := range room.members {
+			if member.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	mentions := make([]gchad.Mention, 0)
+	for _, mention := range serverdomain.ParseMentions(text, isMember) {
+		mentions = append(mentions, gchad.Mention{Name: mention.Name, Start: mention.Start, End: mention.End})
+	}
+
+	return gchad.ChatMessage{Id: fmt.Sprint(s.nextId), From: from, Timestamp: time.Now(), Text: text, Mentions: mentions}
+}
+
+// room returns the room, creating it on first use. The lock has to be held.
+func (s *Server) room(roomId string) *room {
+	if roomId == "" {
+		roomId = DefaultRoom
+	}
+
+	r, ok := s.rooms[roomId]
+	if !ok {
+		r = &room{members: make(map[*websocket.Conn]gchad.Member), said: make(chan gchad.ChatMessage, 64)}
+		s.rooms[roomId] = r
+	}
+
+	return r
+}
+
+// broadcast writes to every member of the room. The lock has to be held, which also keeps the
+// connections to a single writer.
+func (s *Server) broadcast(room *room, msg gchad.Message) {
+	for conn := range room.members {
+		write(conn, msg)
+	}
+}
+
+func write(conn *websocket.Conn, msg gchad.Message) {
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		return
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(Timeout))
+	_ = conn.WriteMessage(websocket.TextMessage, data)
+}
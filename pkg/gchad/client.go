@@ -0,0 +1,714 @@
+// Package gchad is a client for gchad chat servers, for writing bots and integrations.
+//
+// A client is connected to a single room:
+//
+//	events := gchad.NewSubscription(gchad.TypeChat)
+//	client, err := gchad.Dial(ctx, "ws://localhost:8080/chat", "ci", gchad.WithRoom("builds"), gchad.WithSubscription(events))
+//	...
+//	for msg := range events.Events() {
+//		chat := msg.(gchad.ChatMessage)
+//		...
+//	}
+package gchad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+const (
+	readTimeout  = 90 * time.Second
+	writeTimeout = 10 * time.Second
+	// joinTimeout is how long the server has to let the client into the room once connected
+	joinTimeout = 10 * time.Second
+)
+
+var (
+	ErrClosed      = errors.New("the client is closed")
+	ErrJoinTimeout = errors.New("timed out waiting to enter the room")
+)
+
+// Client is a connection to a room of a gchad server. It writes what is sent in order, from a
+// single goroutine, and publishes what the server sends to its subscriptions.
+type Client struct {
+	address *url.URL
+	room    string
+	opts    options
+	members *Members
+
+	outbound chan outboundMessage
+	// pongs are answered by the writing goroutine, the connection allowing a single writer
+	pongs    chan string
+	requests atomic.Uint64
+
+	mu            sync.Mutex
+	name          string
+	conn          network.Connection
+	session       protocol.Session
+	subscriptions []*Subscription
+	// pending are the deliveries of the messages sent with Post, by request id, until settled
+	pending  map[string]*Delivery
+	finished bool
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+// Dial connects to the chat endpoint of a server, e.g. ws://localhost:8080/chat, under the name and
+// returns once the server has let the client into the room
+func Dial(ctx context.Context, address string, name string, opts ...Option) (*Client, error) {
+	o := options{
+		logger:      nopLogger{},
+		reconnect:   DefaultReconnectPolicy,
+		compression: network.DefaultCompression,
+		sendBuffer:  256,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.dialer == nil {
+		o.dialer = NewDefaultDialer(o.logger)
+	}
+
+	if err := o.compression.Validate(); err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	c := &Client{
+		address:       parsed,
+		room:          o.room,
+		opts:          o,
+		members:       NewMembers(),
+		outbound:      make(chan outboundMessage, o.sendBuffer),
+		pongs:         make(chan string, 4),
+		name:          name,
+		subscriptions: o.subscriptions,
+		pending:       make(map[string]*Delivery),
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	conn, joined, err := c.connect(ctx)
+	if err != nil {
+		c.finish()
+		return nil, err
+	}
+	for _, msg := range joined {
+		c.publish(msg, c.closing)
+	}
+	go c.run(conn)
+
+	return c, nil
+}
+
+// Name is the name the client goes by in the room, which changes with Rename once the server agrees
+func (c *Client) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.name
+}
+
+// Protocol is what the client and the server agreed on when connecting, servers that predate the
+// handshake speaking the first version of the protocol without any capabilities
+func (c *Client) Protocol() protocol.Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.session
+}
+
+// Supports tells whether the server has the capability
+func (c *Client) Supports(capability protocol.Capability) bool {
+	return c.Protocol().Supports(capability)
+}
+
+// Room is the id of the room, an empty one meaning the default room of the server
+func (c *Client) Room() string {
+	return c.room
+}
+
+// Members returns everyone in the room, sorted by name
+func (c *Client) Members() []Member {
+	return c.members.List()
+}
+
+// Subscribe starts publishing the events to a new subscription from now on
+func (c *Client) Subscribe(types ...MessageType) *Subscription {
+	subscription := NewSubscription(types...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.finished {
+		close(subscription.events)
+		return subscription
+	}
+	c.subscriptions = append(c.subscriptions, subscription)
+
+	return subscription
+}
+
+// Send queues a message for the room, waiting for room in the queue if the connection falls behind
+func (c *Client) Send(ctx context.Context, text string) error {
+	return c.enqueue(ctx, ChatMessage{From: c.Name(), Timestamp: time.Now(), Text: text})
+}
+
+// Post queues a message for the room like Send, returning the delivery telling whether the server
+// took it
+func (c *Client) Post(ctx context.Context, text string) (*Delivery, error) {
+	delivery := newDelivery(strconv.FormatUint(c.requests.Add(1), 10))
+
+	c.mu.Lock()
+	if c.finished {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.pending[delivery.requestId] = delivery
+	c.mu.Unlock()
+
+	msg := ChatMessage{From: c.Name(), Timestamp: time.Now(), Text: text}
+	if err := c.queue(ctx, outboundMessage{msg, delivery}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, delivery.requestId)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (c *Client) SetPresence(ctx context.Context, presence Presence, statusText string) error {
+	return c.enqueue(ctx, setPresenceMessage{Presence: presence, StatusText: statusText})
+}
+
+// ReportActivity lets the server know the user is around, which keeps them from going away
+func (c *Client) ReportActivity(ctx context.Context) error {
+	return c.enqueue(ctx, activityMessage{})
+}
+
+// Rename asks for another name, a UserRenamedMessage telling when it is granted
+func (c *Client) Rename(ctx context.Context, name string) error {
+	return c.enqueue(ctx, renameMessage{Name: name})
+}
+
+// ListRooms asks which rooms there are, the answer arrives as a RoomListMessage
+func (c *Client) ListRooms(ctx context.Context) error {
+	return c.enqueue(ctx, listRoomsMessage{})
+}
+
+// Search looks through the messages of the room, the answer arrives as a SearchResultsMessage
+func (c *Client) Search(ctx context.Context, request SearchMessage) error {
+	return c.enqueue(ctx, request)
+}
+
+// CreateWebhook asks for a webhook posting into the room under the name, which only moderators may.
+// The answer arrives as a WebhookListMessage, as it does for RevokeWebhook and ListWebhooks.
+func (c *Client) CreateWebhook(ctx context.Context, name string) error {
+	return c.enqueue(ctx, createWebhookMessage{Name: name})
+}
+
+func (c *Client) RevokeWebhook(ctx context.Context, token string) error {
+	return c.enqueue(ctx, revokeWebhookMessage{Token: token})
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) error {
+	return c.enqueue(ctx, listWebhooksMessage{})
+}
+
+// SubscribeEvents has the server post events of the room to a url, which only moderators may.
+// The answer arrives as an EventSubscriptionListMessage, as it does for UnsubscribeEvents and
+// ListEventSubscriptions. It holds the secret the posts are signed with.
+func (c *Client) SubscribeEvents(ctx context.Context, request SubscribeEventsMessage) error {
+	return c.enqueue(ctx, request)
+}
+
+func (c *Client) UnsubscribeEvents(ctx context.Context, id string) error {
+	return c.enqueue(ctx, unsubscribeEventsMessage{Id: id})
+}
+
+func (c *Client) ListEventSubscriptions(ctx context.Context) error {
+	return c.enqueue(ctx, listEventSubscriptionsMessage{})
+}
+
+// outboundMessage is a message waiting to be written, along with its delivery if it was posted
+type outboundMessage struct {
+	msg      Message
+	delivery *Delivery
+}
+
+func (c *Client) enqueue(ctx context.Context, msg Message) error {
+	return c.queue(ctx, outboundMessage{msg: msg})
+}
+
+func (c *Client) queue(ctx context.Context, msg outboundMessage) error {
+	// the queue has room even once nothing reads it anymore
+	select {
+	case <-c.closing:
+		return ErrClosed
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.outbound <- msg:
+		return nil
+	case <-c.closing:
+		return ErrClosed
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done is closed once the client is closed or has given up on a lost connection
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err tells why the client is done, nil meaning it was closed
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close writes whatever is still queued and hangs up. When the context ends first, the connection
+// is dropped along with the rest of the queue.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.mu.Unlock()
+		<-c.done
+
+		return ctx.Err()
+	}
+}
+
+// run serves the connection and the ones replacing it until the client is closed or gives up
+func (c *Client) run(conn network.Connection) {
+	defer c.finish()
+
+	for {
+		err := c.serve(conn)
+		c.abandon()
+		if c.isClosing() {
+			c.err = err
+			return
+		}
+
+		reconnecting := c.opts.reconnect.MaxAttempts > 0
+		c.opts.logger.Error(fmt.Sprintf("lost the connection: %s", err.Error()), map[string]any{"reconnecting": reconnecting})
+		c.publish(Disconnected{Timestamp: time.Now(), Err: err, Reconnecting: reconnecting}, c.closing)
+		if !reconnecting {
+			c.err = err
+			return
+		}
+
+		lost := err
+		conn, err = c.reconnect()
+		if err != nil {
+			if c.isClosing() {
+				// closed while reconnecting, what was lost is still the reason
+				c.err = lost
+				return
+			}
+			c.err = err
+			c.publish(Disconnected{Timestamp: time.Now(), Err: err}, c.closing)
+			return
+		}
+	}
+}
+
+func (c *Client) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.finished = true
+	for _, subscription := range c.subscriptions {
+		close(subscription.events)
+	}
+	c.subscriptions = nil
+	// what was posted and is still queued never goes out
+	for requestId, delivery := range c.pending {
+		delivery.settle(AckMessage{}, ErrClosed)
+		delete(c.pending, requestId)
+	}
+	close(c.done)
+}
+
+// abandon fails the deliveries of the messages written to a connection that is gone, the ones
+// still queued going out on the next one
+func (c *Client) abandon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for requestId, delivery := range c.pending {
+		if delivery.written {
+			delivery.settle(AckMessage{}, ErrNotAcknowledged)
+			delete(c.pending, requestId)
+		}
+	}
+}
+
+// settle hands the answer of the server over to the delivery of the message with the request id
+func (c *Client) settle(requestId string, ack AckMessage, err error) {
+	c.mu.Lock()
+	delivery, ok := c.pending[requestId]
+	delete(c.pending, requestId)
+	c.mu.Unlock()
+
+	if ok {
+		delivery.settle(ack, err)
+	}
+}
+
+// serve writes the queued messages while another goroutine reads, until either fails or the client
+// is closed, in which case what is left in the queue goes out before hanging up
+func (c *Client) serve(conn network.Connection) error {
+	stop := make(chan struct{})
+	read := make(chan error, 1)
+	go func() {
+		read <- c.read(conn, stop)
+	}()
+	defer func() {
+		conn.Close()
+		close(stop)
+		<-read
+	}()
+
+	for {
+		select {
+		case msg := <-c.outbound:
+			if err := c.writeOutbound(conn, msg); err != nil {
+				return err
+			}
+		case data := <-c.pongs:
+			if err := c.writeControl(conn, func() error { return conn.WritePongMessage([]byte(data)) }); err != nil {
+				return err
+			}
+		case err := <-read:
+			read <- err
+			return err
+		case <-c.closing:
+			return c.hangUp(conn)
+		}
+	}
+}
+
+func (c *Client) hangUp(conn network.Connection) error {
+	for {
+		select {
+		case msg := <-c.outbound:
+			if err := c.writeOutbound(conn, msg); err != nil {
+				return err
+			}
+		default:
+			return c.writeControl(conn, func() error { return conn.WriteCloseMessage([]byte{}) })
+		}
+	}
+}
+
+// writeOutbound writes a posted message with its request id, for the server to answer, unless the
+// server does not acknowledge messages, in which case writing it is as far as the delivery goes
+func (c *Client) writeOutbound(conn network.Connection, msg outboundMessage) error {
+	if msg.delivery == nil {
+		return c.write(conn, msg.msg, "")
+	}
+	if !c.Supports(protocol.CapabilityAcks) {
+		err := c.write(conn, msg.msg, "")
+		if err == nil {
+			c.settle(msg.delivery.requestId, AckMessage{}, nil)
+		}
+		return err
+	}
+
+	// the server may answer before the write returns
+	c.mu.Lock()
+	msg.delivery.written = true
+	c.mu.Unlock()
+
+	return c.write(conn, msg.msg, msg.delivery.requestId)
+}
+
+func (c *Client) write(conn network.Connection, msg Message, requestId string) error {
+	codec := NewCodec(conn.Subprotocol())
+	data, err := codec.MarshalRequest(msg, requestId)
+	if err != nil {
+		c.opts.logger.Error(fmt.Sprintf("failed to marshall the message: %s", err.Error()), map[string]any{"message_type": string(msg.MessageType())})
+		if requestId != "" {
+			c.settle(requestId, AckMessage{}, err)
+		}
+		return nil
+	}
+
+	if codec.Binary() {
+		return c.writeControl(conn, func() error { return conn.WriteBinaryMessage(data) })
+	}
+
+	return c.writeControl(conn, func() error { return conn.WriteTextMessage(data) })
+}
+
+func (c *Client) writeControl(conn network.Connection, write func() error) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	return write()
+}
+
+// read publishes what the server sends until the connection fails
+func (c *Client) read(conn network.Connection, stop <-chan struct{}) error {
+	for {
+		msg, err := c.readMessage(conn, readTimeout)
+		if err != nil {
+			return err
+		}
+		c.publish(msg, stop)
+	}
+}
+
+func (c *Client) readMessage(conn network.Connection, timeout time.Duration) (Message, error) {
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := NewCodec(conn.Subprotocol()).Unmarshal(data)
+		if errors.Is(err, protocol.ErrUnknownType) {
+			// the server speaks a later version of the protocol
+			c.opts.logger.Debug(fmt.Sprintf("skipping the message: %s", err.Error()), map[string]any{})
+			continue
+		}
+		if err != nil {
+			c.opts.logger.Error(fmt.Sprintf("failed to unmarshall the message: %s", err.Error()), map[string]any{"message": string(data)})
+			continue
+		}
+		c.track(msg)
+
+		return msg, nil
+	}
+}
+
+// track keeps the members and the name of the client up to date
+func (c *Client) track(msg Message) {
+	var err error
+
+	switch msg := msg.(type) {
+	case UserJoinedMessage:
+		err = c.members.Join(msg.Name, msg.Role, msg.Bot, msg.Version)
+	case UserLeftMessage:
+		err = c.members.Leave(msg.Name, msg.Version)
+	case UserRenamedMessage:
+		err = c.members.Rename(msg.OldName, msg.NewName, msg.Version)
+		c.mu.Lock()
+		if c.name == msg.OldName {
+			c.name = msg.NewName
+		}
+		c.mu.Unlock()
+	case PresenceMessage:
+		err = c.members.UpdatePresence(msg.Name, msg.Presence, msg.StatusText, msg.Version)
+	case MemberListMessage:
+		c.members.Replace(msg.Members, msg.Version)
+	case AckMessage:
+		c.settle(msg.RequestId, msg, nil)
+	case ErrorMessage:
+		if msg.RequestId != "" {
+			c.settle(msg.RequestId, AckMessage{}, msg)
+		}
+	}
+
+	if err != nil {
+		// a room state change has been missed, ask for a fresh snapshot
+		c.opts.logger.Error(err.Error(), map[string]any{"version": c.members.Version()})
+		select {
+		case c.outbound <- outboundMessage{msg: syncRoomMessage{}}:
+		default:
+		}
+	}
+}
+
+func (c *Client) publish(msg Message, stop <-chan struct{}) {
+	c.mu.Lock()
+	subscriptions := slices.Clone(c.subscriptions)
+	c.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.deliver(msg, stop)
+	}
+}
+
+// connect dials, says hello and waits for the member list the server sends to everyone it lets
+// into a room, so that nothing is sent before the server knows where it goes. It returns the
+// messages read meanwhile, for the caller to publish.
+func (c *Client) connect(ctx context.Context) (network.Connection, []Message, error) {
+	conn, err := c.opts.dialer.Dial(ctx, c.url())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := conn.SetCompression(c.opts.compression); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to set the compression: %w", err)
+	}
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case c.pongs <- data:
+		default:
+		}
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+
+	// closing the connection is what interrupts the read when the context ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	hello := protocol.HelloMessage{Version: protocol.Version, Capabilities: protocol.Capabilities()}
+	if err := c.write(conn, hello, ""); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, fmt.Errorf("failed to say hello: %w", err)
+	}
+
+	c.members.Reset()
+	// servers that predate the handshake let the client in without a welcome
+	session := protocol.Legacy()
+	deadline := time.Now().Add(joinTimeout)
+	var joined []Message
+	for {
+		msg, err := c.readMessage(conn, time.Until(deadline))
+		if err != nil {
+			conn.Close()
+			switch {
+			case ctx.Err() != nil:
+				return nil, nil, ctx.Err()
+			case errors.Is(err, network.ErrReadTimeOut):
+				return nil, nil, ErrJoinTimeout
+			}
+			return nil, nil, fmt.Errorf("failed to enter the room: %w", err)
+		}
+		if welcome, ok := msg.(protocol.WelcomeMessage); ok {
+			session = protocol.NewSession(welcome)
+			continue
+		}
+		// the server tells why it did not let the client in before closing the connection
+		if refusal, ok := msg.(ErrorMessage); ok && refusal.Request == "" {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to enter the room: %w", refusal)
+		}
+		joined = append(joined, msg)
+
+		if _, ok := msg.(MemberListMessage); ok {
+			break
+		}
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.session = session
+	c.mu.Unlock()
+
+	return conn, joined, nil
+}
+
+// reconnect tries again as the policy says, publishing Reconnected once it succeeds
+func (c *Client) reconnect() (network.Connection, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	policy := c.opts.reconnect
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(policy.Delay(attempt)):
+		case <-ctx.Done():
+			return nil, ErrClosed
+		}
+
+		var conn network.Connection
+		var joined []Message
+		conn, joined, err = c.connect(ctx)
+		if err == nil {
+			c.opts.logger.Info("reconnected", map[string]any{"attempts": attempt})
+			c.publish(Reconnected{Timestamp: time.Now(), Attempts: attempt}, c.closing)
+			for _, msg := range joined {
+				c.publish(msg, c.closing)
+			}
+			return conn, nil
+		}
+		c.opts.logger.Error(fmt.Sprintf("failed to reconnect: %s", err.Error()), map[string]any{"attempt": attempt})
+	}
+
+	return nil, fmt.Errorf("gave up reconnecting after %d attempts: %w", policy.MaxAttempts, err)
+}
+
+func (c *Client) url() string {
+	address := *c.address
+	query := address.Query()
+	query.Set("name", c.Name())
+	if c.room != "" {
+		query.Set("room", c.room)
+	}
+	if c.opts.token != "" {
+		query.Set("moderator_token", c.opts.token)
+	}
+	if c.opts.bot {
+		query.Set("bot", "true")
+	}
+	address.RawQuery = query.Encode()
+
+	return address.String()
+}
+
+func (c *Client) isClosing() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
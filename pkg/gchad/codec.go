@@ -0,0 +1,35 @@
+package gchad
+
+import (
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+var decoders = protocol.Decoders[Message]{
+	protocol.Welcome:               decode[protocol.WelcomeMessage],
+	protocol.Error:                 decode[ErrorMessage],
+	protocol.Ack:                   decode[AckMessage],
+	protocol.Chat:                  decode[ChatMessage],
+	protocol.UserJoined:            decode[UserJoinedMessage],
+	protocol.UserLeft:              decode[UserLeftMessage],
+	protocol.Presence:              decode[PresenceMessage],
+	protocol.MemberList:            decode[MemberListMessage],
+	protocol.UserRenamed:           decode[UserRenamedMessage],
+	protocol.RoomList:              decode[RoomListMessage],
+	protocol.SearchResults:         decode[SearchResultsMessage],
+	protocol.WebhookList:           decode[WebhookListMessage],
+	protocol.EventSubscriptionList: decode[EventSubscriptionListMessage],
+}
+
+// NewCodec is the codec of the websocket subprotocol the server agreed on
+func NewCodec(subprotocol string) protocol.Codec[Message] {
+	return protocol.NewCodec(subprotocol, decoders)
+}
+
+func decode[M Message](payload protocol.Payload) (Message, error) {
+	var msg M
+	if err := payload.Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
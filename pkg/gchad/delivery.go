@@ -0,0 +1,56 @@
+package gchad
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNotAcknowledged is what a Delivery fails with when the connection the message was written to
+// is gone before the server answered, the message having made it to the room or not
+var ErrNotAcknowledged = errors.New("the connection was lost before the server acknowledged the message")
+
+// Delivery is what becomes of a message sent with Post: the server acknowledges it, turns it down
+// with an ErrorMessage, or the connection goes away first. Servers that predate acks are taken at
+// their word once the message is written, the ack then being empty.
+type Delivery struct {
+	requestId string
+	done      chan struct{}
+	once      sync.Once
+	ack       AckMessage
+	err       error
+	written   bool // guarded by the mutex of the client
+}
+
+func newDelivery(requestId string) *Delivery {
+	return &Delivery{requestId: requestId, done: make(chan struct{})}
+}
+
+// RequestId is the id the message goes out with, which the answer of the server carries
+func (d *Delivery) RequestId() string {
+	return d.requestId
+}
+
+// Done is closed once the delivery is settled
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait returns the ack of the server, or why the message did not make it, which is the ErrorMessage
+// of the server when it turned the message down
+func (d *Delivery) Wait(ctx context.Context) (AckMessage, error) {
+	select {
+	case <-d.done:
+		return d.ack, d.err
+	case <-ctx.Done():
+		return AckMessage{}, ctx.Err()
+	}
+}
+
+func (d *Delivery) settle(ack AckMessage, err error) {
+	d.once.Do(func() {
+		d.ack = ack
+		d.err = err
+		close(d.done)
+	})
+}
@@ -0,0 +1,152 @@
+package gchad
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrRoomStateGap = errors.New("missed a room state change, a fresh snapshot is needed")
+
+// Members mirrors the room state held by the server. It starts from a snapshot and
+// applies versioned deltas in order; a delta skipping a version means something was
+// lost, and nothing more is applied until the next snapshot arrives.
+type Members struct {
+	mu      sync.RWMutex
+	members map[string]Member
+	version uint64
+	synced  bool
+}
+
+func NewMembers() *Members {
+	return &Members{
+		members: make(map[string]Member),
+	}
+}
+
+// advance reports whether a delta of the given version is the next one to apply
+func (m *Members) advance(version uint64) (bool, error) {
+	switch {
+	case !m.synced:
+		// the snapshot is on its way and will include this change
+		return false, nil
+	case version <= m.version:
+		// already part of the snapshot
+		return false, nil
+	case version != m.version+1:
+		m.synced = false
+		return false, ErrRoomStateGap
+	}
+
+	m.version = version
+	return true, nil
+}
+
+func (m *Members) Join(name string, role Role, bot bool, version uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok, err := m.advance(version)
+	if ok {
+		m.members[name] = Member{Name: name, Role: role, Presence: PresenceOnline, Bot: bot}
+	}
+
+	return err
+}
+
+func (m *Members) Leave(name string, version uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok, err := m.advance(version)
+	if ok {
+		delete(m.members, name)
+	}
+
+	return err
+}
+
+func (m *Members) UpdatePresence(name string, presence Presence, statusText string, version uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok, err := m.advance(version)
+	if ok {
+		member := m.members[name]
+		member.Presence = presence
+		member.StatusText = statusText
+		m.members[name] = member
+	}
+
+	return err
+}
+
+func (m *Members) Rename(oldName string, newName string, version uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok, err := m.advance(version)
+	if ok {
+		member := m.members[oldName]
+		delete(m.members, oldName)
+		member.Name = newName
+		m.members[newName] = member
+	}
+
+	return err
+}
+
+// Replace drops whatever is known about the room in favour of a fresh snapshot
+func (m *Members) Replace(members []Member, version uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.members = make(map[string]Member, len(members))
+	for _, member := range members {
+		m.members[member.Name] = member
+	}
+	m.version = version
+	m.synced = true
+}
+
+func (m *Members) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.members = make(map[string]Member)
+	m.version = 0
+	m.synced = false
+}
+
+func (m *Members) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.members)
+}
+
+func (m *Members) Version() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.version
+}
+
+// List returns the members sorted by name
+func (m *Members) List() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := make([]Member, 0, len(m.members))
+
+	for _, member := range m.members {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return strings.ToLower(members[i].Name) < strings.ToLower(members[j].Name)
+	})
+
+	return members
+}
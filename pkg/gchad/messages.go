@@ -0,0 +1,398 @@
+package gchad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+// The types and error codes are the ones of the protocol
+type (
+	MessageType = protocol.MessageType
+	ErrorCode   = protocol.ErrorCode
+)
+
+// Message is anything exchanged with the server, the terminal client speaking through these too
+type Message interface {
+	MessageType() MessageType
+}
+
+// ChatMessage carries the Id the server stored it under, messages sent by the client have none.
+// Messages posted to a webhook of the room are marked as such and may carry attachments.
+type ChatMessage struct {
+	Id          string       `json:"id,omitempty"`
+	From        string       `json:"from"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Text        string       `json:"text"`
+	Mentions    []Mention    `json:"mentions,omitempty"`
+	Webhook     bool         `json:"webhook,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+func (m ChatMessage) MessageType() MessageType {
+	return protocol.Chat
+}
+
+// Mention is a reference to a room member found by the server.
+// Start and End are byte offsets into the message text.
+type Mention struct {
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Attachment is a link or a snippet posted to a webhook along with the message
+type Attachment struct {
+	Title string `json:"title,omitempty"`
+	Url   string `json:"url,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+func (m ChatMessage) MentionsName(name string) bool {
+	for _, mention := range m.Mentions {
+		if mention.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+type UserJoinedMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	Bot       bool      `json:"bot,omitempty"`
+	Version   uint64    `json:"version"`
+}
+
+func (m UserJoinedMessage) MessageType() MessageType {
+	return protocol.UserJoined
+}
+
+type UserLeftMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	Version   uint64    `json:"version"`
+}
+
+func (m UserLeftMessage) MessageType() MessageType {
+	return protocol.UserLeft
+}
+
+type PresenceMessage struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Name       string    `json:"name"`
+	Presence   Presence  `json:"presence"`
+	StatusText string    `json:"status_text,omitempty"`
+	Version    uint64    `json:"version"`
+}
+
+func (m PresenceMessage) MessageType() MessageType {
+	return protocol.Presence
+}
+
+type setPresenceMessage struct {
+	Presence   Presence `json:"presence"`
+	StatusText string   `json:"status_text,omitempty"`
+}
+
+func (m setPresenceMessage) MessageType() MessageType {
+	return protocol.SetPresence
+}
+
+type activityMessage struct{}
+
+func (m activityMessage) MessageType() MessageType {
+	return protocol.Activity
+}
+
+type MemberListMessage struct {
+	Version uint64   `json:"version"`
+	Members []Member `json:"members"`
+}
+
+func (m MemberListMessage) MessageType() MessageType {
+	return protocol.MemberList
+}
+
+type UserRenamedMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	OldName   string    `json:"old_name"`
+	NewName   string    `json:"new_name"`
+	Version   uint64    `json:"version"`
+}
+
+func (m UserRenamedMessage) MessageType() MessageType {
+	return protocol.UserRenamed
+}
+
+type renameMessage struct {
+	Name string `json:"name"`
+}
+
+func (m renameMessage) MessageType() MessageType {
+	return protocol.Rename
+}
+
+type syncRoomMessage struct{}
+
+func (m syncRoomMessage) MessageType() MessageType {
+	return protocol.SyncRoom
+}
+
+// RoomSummary describes a room hosted by the server
+type RoomSummary struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+}
+
+type RoomListMessage struct {
+	Rooms []RoomSummary `json:"rooms"`
+}
+
+func (m RoomListMessage) MessageType() MessageType {
+	return protocol.RoomList
+}
+
+// listRoomsMessage asks the server for a RoomListMessage
+type listRoomsMessage struct{}
+
+func (m listRoomsMessage) MessageType() MessageType {
+	return protocol.ListRooms
+}
+
+// SearchMessage asks the server for the messages of the room containing every one of the terms,
+// newest first, skipping Offset of them
+type SearchMessage struct {
+	Terms  string     `json:"terms"`
+	From   string     `json:"from,omitempty"`
+	Before *time.Time `json:"before,omitempty"`
+	Offset int        `json:"offset,omitempty"`
+	Limit  int        `json:"limit,omitempty"`
+}
+
+func (m SearchMessage) MessageType() MessageType {
+	return protocol.Search
+}
+
+// SearchResultsMessage is a page of the results of a SearchMessage, which it repeats
+type SearchResultsMessage struct {
+	Terms   string        `json:"terms"`
+	From    string        `json:"from,omitempty"`
+	Before  *time.Time    `json:"before,omitempty"`
+	Offset  int           `json:"offset"`
+	Total   int           `json:"total"`
+	Results []ChatMessage `json:"results"`
+}
+
+func (m SearchResultsMessage) MessageType() MessageType {
+	return protocol.SearchResults
+}
+
+// Request is the search the results answer, moved to another offset
+func (m SearchResultsMessage) Request(offset int) SearchMessage {
+	return SearchMessage{Terms: m.Terms, From: m.From, Before: m.Before, Offset: max(0, offset)}
+}
+
+// Webhook lets whoever holds the token post into the room over HTTP, see WebhookListMessage
+type Webhook struct {
+	Token     string    `json:"token"`
+	RoomId    string    `json:"room_id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createWebhookMessage asks the server for a webhook posting under the name, only moderators may
+type createWebhookMessage struct {
+	Name string `json:"name"`
+}
+
+func (m createWebhookMessage) MessageType() MessageType {
+	return protocol.CreateWebhook
+}
+
+// revokeWebhookMessage asks the server to stop the webhook from working, only moderators may
+type revokeWebhookMessage struct {
+	Token string `json:"token"`
+}
+
+func (m revokeWebhookMessage) MessageType() MessageType {
+	return protocol.RevokeWebhook
+}
+
+// listWebhooksMessage asks the server for a WebhookListMessage, only moderators may
+type listWebhooksMessage struct{}
+
+func (m listWebhooksMessage) MessageType() MessageType {
+	return protocol.ListWebhooks
+}
+
+// WebhookListMessage lists the webhooks of the room, answering every one of the webhook requests
+type WebhookListMessage struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+func (m WebhookListMessage) MessageType() MessageType {
+	return protocol.WebhookList
+}
+
+// EventKind is what an event subscription can ask the server to post
+type EventKind string
+
+const (
+	EventMessagePosted    EventKind = "message_posted"
+	EventUserJoined       EventKind = "user_joined"
+	EventUserLeft         EventKind = "user_left"
+	EventKeywordMentioned EventKind = "keyword_mentioned"
+)
+
+// EventSubscription has the server post events of the room to the url, signed with the secret
+type EventSubscription struct {
+	Id        string      `json:"id"`
+	RoomId    string      `json:"room_id"`
+	Url       string      `json:"url"`
+	Events    []EventKind `json:"events"`
+	Keywords  []string    `json:"keywords,omitempty"`
+	Secret    string      `json:"secret"`
+	CreatedBy string      `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// SubscribeEventsMessage asks the server to post events of the room to the url, only moderators may.
+// Keyword mentions need keywords.
+type SubscribeEventsMessage struct {
+	Url      string      `json:"url"`
+	Events   []EventKind `json:"events"`
+	Keywords []string    `json:"keywords,omitempty"`
+}
+
+func (m SubscribeEventsMessage) MessageType() MessageType {
+	return protocol.SubscribeEvents
+}
+
+type unsubscribeEventsMessage struct {
+	Id string `json:"id"`
+}
+
+func (m unsubscribeEventsMessage) MessageType() MessageType {
+	return protocol.UnsubscribeEvents
+}
+
+// listEventSubscriptionsMessage asks the server for an EventSubscriptionListMessage, only moderators may
+type listEventSubscriptionsMessage struct{}
+
+func (m listEventSubscriptionsMessage) MessageType() MessageType {
+	return protocol.ListEventSubscriptions
+}
+
+// EventSubscriptionListMessage lists the event subscriptions of the room, answering every one of
+// the subscription requests
+type EventSubscriptionListMessage struct {
+	Subscriptions []EventSubscription `json:"subscriptions"`
+}
+
+func (m EventSubscriptionListMessage) MessageType() MessageType {
+	return protocol.EventSubscriptionList
+}
+
+// ErrorMessage tells the client the server turned down a message of its own, Request being the type
+// of that message unless the server could not make it out, and RequestId the id the client gave it
+type ErrorMessage struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Request   MessageType `json:"request,omitempty"`
+	RequestId string      `json:"request_id,omitempty"`
+}
+
+func (m ErrorMessage) MessageType() MessageType {
+	return protocol.Error
+}
+
+// Error lets the message be returned as an error, e.g. by bots
+func (m ErrorMessage) Error() string {
+	if m.Request == "" {
+		return m.Message
+	}
+
+	return fmt.Sprintf("%s: %s", m.Request, m.Message)
+}
+
+// AckMessage tells the client the server took its chat message with the request id, under the id the
+// server gave it. Sequence is where the message falls among the ones of the room, counting from 1.
+type AckMessage struct {
+	RequestId string `json:"request_id"`
+	Id        string `json:"id"`
+	Sequence  uint64 `json:"sequence"`
+}
+
+func (m AckMessage) MessageType() MessageType {
+	return protocol.Ack
+}
+
+type Presence string
+
+const (
+	PresenceOnline       Presence = "online"
+	PresenceAway         Presence = "away"
+	PresenceDoNotDisturb Presence = "dnd"
+)
+
+type Role string
+
+const (
+	RoleMember    Role = "member"
+	RoleModerator Role = "moderator"
+)
+
+type Member struct {
+	Name       string   `json:"name"`
+	Role       Role     `json:"role"`
+	Presence   Presence `json:"presence"`
+	StatusText string   `json:"status_text,omitempty"`
+	Bot        bool     `json:"bot,omitempty"`
+}
+
+const (
+	TypeChat                  = protocol.Chat
+	TypeUserJoined            = protocol.UserJoined
+	TypeUserLeft              = protocol.UserLeft
+	TypeUserRenamed           = protocol.UserRenamed
+	TypePresence              = protocol.Presence
+	TypeMemberList            = protocol.MemberList
+	TypeRoomList              = protocol.RoomList
+	TypeSearch                = protocol.SearchResults
+	TypeWebhookList           = protocol.WebhookList
+	TypeEventSubscriptionList = protocol.EventSubscriptionList
+	TypeError                 = protocol.Error
+	TypeAck                   = protocol.Ack
+
+	TypeDisconnected MessageType = "disconnected"
+	TypeReconnected  MessageType = "reconnected"
+)
+
+// Disconnected is published when the connection is lost. Reconnecting tells whether the client
+// is going to try again, otherwise it is done and Err is what Close will return.
+type Disconnected struct {
+	Timestamp    time.Time
+	Err          error
+	Reconnecting bool
+}
+
+func (m Disconnected) MessageType() MessageType {
+	return TypeDisconnected
+}
+
+// Reconnected is published once a lost connection is back, after Attempts tries. The server has
+// let the client back into the room and a MemberListMessage follows.
+type Reconnected struct {
+	Timestamp time.Time
+	Attempts  int
+}
+
+func (m Reconnected) MessageType() MessageType {
+	return TypeReconnected
+}
@@ -0,0 +1,198 @@
+package gchad
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/pkg/logging"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+// Dialer opens the connection to the server
+type Dialer interface {
+	Dial(ctx context.Context, url string) (network.Connection, error)
+}
+
+type websocketDialer struct {
+	dialer *websocket.Dialer
+	logger logging.Logger
+}
+
+// NewWebsocketDialer dials with the given gorilla dialer, which sets the TLS, proxy and timeout options
+func NewWebsocketDialer(dialer *websocket.Dialer, logger logging.Logger) Dialer {
+	return &websocketDialer{dialer, logger}
+}
+
+// NewDefaultDialer dials as websocket.DefaultDialer does, offering the server every subprotocol,
+// the binary one first, and permessage-deflate
+func NewDefaultDialer(logger logging.Logger) Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = protocol.Subprotocols()
+	dialer.EnableCompression = true
+
+	return NewWebsocketDialer(&dialer, logger)
+}
+
+func (d *websocketDialer) Dial(ctx context.Context, url string) (network.Connection, error) {
+	conn, _, err := d.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return network.NewWebsocketsConnection(conn, d.logger), nil
+}
+
+type sseDialer struct {
+	client *http.Client
+	logger logging.Logger
+}
+
+// NewSSEDialer dials the event and message endpoints next to the chat one
+func NewSSEDialer(client *http.Client, logger logging.Logger) Dialer {
+	return &sseDialer{client, logger}
+}
+
+func (d *sseDialer) Dial(ctx context.Context, address string) (network.Connection, error) {
+	events, messages, err := sseUrls(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return network.DialSSE(ctx, d.client, events, messages, d.logger)
+}
+
+// sseUrls are the event and message endpoints next to the chat one
+func sseUrls(address string) (string, string, error) {
+	chat, err := url.Parse(address)
+	if err != nil {
+		return "", "", err
+	}
+	switch chat.Scheme {
+	case "ws", "http":
+		chat.Scheme = "http"
+	case "wss", "https":
+		chat.Scheme = "https"
+	default:
+		return "", "", fmt.Errorf("unsupported scheme %q", chat.Scheme)
+	}
+
+	events, messages := *chat, *chat
+	events.Path = path.Join(path.Dir(chat.Path), "events")
+	messages.Path = path.Join(path.Dir(chat.Path), "messages")
+	messages.RawQuery = ""
+
+	return events.String(), messages.String(), nil
+}
+
+// ReconnectPolicy says how a lost connection is retried: after MinDelay, doubling the delay up to
+// MaxDelay with every failed attempt, giving up after MaxAttempts of them. No attempts means the
+// client does not reconnect.
+type ReconnectPolicy struct {
+	MaxAttempts int
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+}
+
+var (
+	NeverReconnect         = ReconnectPolicy{}
+	DefaultReconnectPolicy = ReconnectPolicy{MaxAttempts: 10, MinDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+)
+
+// Delay is how long to wait before the attempt, counting from 1
+func (p ReconnectPolicy) Delay(attempt int) time.Duration {
+	delay := p.MinDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+type options struct {
+	room          string
+	token         string
+	bot           bool
+	dialer        Dialer
+	logger        logging.Logger
+	reconnect     ReconnectPolicy
+	compression   network.Compression
+	sendBuffer    int
+	subscriptions []*Subscription
+}
+
+type Option func(*options)
+
+// WithRoom joins the room instead of the default one of the server
+func WithRoom(room string) Option {
+	return func(o *options) {
+		o.room = room
+	}
+}
+
+// AsModerator proves the client is the moderator the server knows under its name by the token
+func AsModerator(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// AsBot has the client marked as a bot to the other members of the room
+func AsBot() Option {
+	return func(o *options) {
+		o.bot = true
+	}
+}
+
+func WithDialer(dialer Dialer) Option {
+	return func(o *options) {
+		o.dialer = dialer
+	}
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithReconnect replaces DefaultReconnectPolicy
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnect = policy
+	}
+}
+
+// WithSubscription publishes the events to the subscription from the moment the client connects,
+// see NewSubscription
+func WithSubscription(subscription *Subscription) Option {
+	return func(o *options) {
+		o.subscriptions = append(o.subscriptions, subscription)
+	}
+}
+
+// WithCompression replaces network.DefaultCompression, which applies once the dialer and the
+// server agreed on permessage-deflate
+func WithCompression(compression network.Compression) Option {
+	return func(o *options) {
+		o.compression = compression
+	}
+}
+
+// WithSendBuffer sets how many messages can be queued before Send has to wait, 256 by default
+func WithSendBuffer(size int) Option {
+	return func(o *options) {
+		o.sendBuffer = size
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, map[string]any) {}
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
@@ -0,0 +1,62 @@
+package gchad
+
+import "sync"
+
+// subscriptionBuffer is how many events a subscription holds before the client waits for it
+const subscriptionBuffer = 64
+
+// Subscription receives the events of a client, every one of them or only those of the given types.
+// The client waits for a subscriber that falls behind rather than dropping events, so they have to
+// be read for as long as the subscription lasts.
+type Subscription struct {
+	events chan Message
+	types  map[MessageType]bool
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewSubscription creates a subscription to pass to Dial, which gets the events from the moment
+// the client connects, including the ones of entering the room
+func NewSubscription(types ...MessageType) *Subscription {
+	subscription := &Subscription{
+		events: make(chan Message, subscriptionBuffer),
+		types:  make(map[MessageType]bool, len(types)),
+		done:   make(chan struct{}),
+	}
+	for _, messageType := range types {
+		subscription.types[messageType] = true
+	}
+
+	return subscription
+}
+
+// Events are the messages from the server, along with Disconnected and Reconnected.
+// The channel is closed once the client is done.
+func (s *Subscription) Events() <-chan Message {
+	return s.events
+}
+
+// Unsubscribe stops the events, the channel is not closed until the client is done though
+func (s *Subscription) Unsubscribe() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *Subscription) wants(msg Message) bool {
+	return len(s.types) == 0 || s.types[msg.MessageType()]
+}
+
+// deliver waits for the subscriber to take the message, unless it unsubscribes or stop is closed
+func (s *Subscription) deliver(msg Message, stop <-chan struct{}) {
+	if !s.wants(msg) {
+		return
+	}
+
+	select {
+	case s.events <- msg:
+	case <-s.done:
+	case <-stop:
+	}
+}
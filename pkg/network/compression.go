@@ -0,0 +1,33 @@
+package network
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCompressionLevel = errors.New("invalid compression level")
+
+// Compression says how messages are compressed once both ends agreed on permessage-deflate
+type Compression struct {
+	// Level is a compress/flate one, from flate.HuffmanOnly to flate.BestCompression
+	Level int
+	// Threshold is the size under which messages go out as they are, compressing those costing
+	// more than it saves
+	Threshold int
+}
+
+var DefaultCompression = Compression{Level: flate.BestSpeed, Threshold: 256}
+
+func (c Compression) Validate() error {
+	if c.Level < flate.HuffmanOnly || c.Level > flate.BestCompression {
+		return fmt.Errorf("%w: %d, expected one from %d to %d", ErrInvalidCompressionLevel, c.Level, flate.HuffmanOnly, flate.BestCompression)
+	}
+
+	return nil
+}
+
+// Compresses tells whether a message of the size is worth compressing
+func (c Compression) Compresses(size int) bool {
+	return size >= c.Threshold
+}
@@ -1,16 +1,27 @@
+// Package network is the connection between gchad servers and clients, which is a websocket or,
+// for networks whose proxies break websockets, server-sent events the client answers with posts.
+// Either one behaves as a websocket speaking the JSON subprotocol does.
 package network
 
 import "time"
 
 type Connection interface {
 	Close() error
+	// Subprotocol is the websocket subprotocol the ends agreed on, if any
+	Subprotocol() string
 	ReadMessage() (int, []byte, error)
 	SetWriteDeadline(time.Time) error
 	SetReadDeadline(time.Time) error
+	// SetReadLimit is the size of the largest message read, a larger one failing the read with
+	// ErrMessageTooLarge and closing the connection. No limit is set by default.
+	SetReadLimit(int64)
 	SetPongHandler(func(string) error)
 	SetPingHandler(func(string) error)
 	WriteCloseMessage([]byte) error
+	// SetCompression says how the messages written from now on are compressed
+	SetCompression(Compression) error
 	WriteTextMessage([]byte) error
+	WriteBinaryMessage([]byte) error
 	WritePingMessage([]byte) error
 	WritePongMessage([]byte) error
 }
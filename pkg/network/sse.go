@@ -0,0 +1,153 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Messages are unnamed events, the session and control ones being named
+const (
+	eventSession = "session"
+	eventPing    = "ping"
+	eventPong    = "pong"
+	eventClose   = "close"
+)
+
+// Posts carry a message, unless the control query parameter says they stand for a control frame
+const (
+	ControlPing  = "ping"
+	ControlPong  = "pong"
+	ControlClose = "close"
+)
+
+var (
+	// ErrBinaryMessage is what writing a binary message over server-sent events fails with
+	ErrBinaryMessage = errors.New("binary messages are not supported over server-sent events")
+	// ErrUnknownControl is what a post standing for a control frame the transport has not got fails with
+	ErrUnknownControl = errors.New("unknown control")
+)
+
+type event struct {
+	name string
+	data []byte
+}
+
+// writeEvent writes the data, text without carriage returns, as a server-sent event
+func writeEvent(w io.Writer, name string, data []byte) error {
+	var buf bytes.Buffer
+	if name != "" {
+		buf.WriteString("event: " + name + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readEvent reads up to the blank line ending an event, skipping the fields the transport does not use
+func readEvent(r *bufio.Reader) (event, error) {
+	var e event
+	var lines [][]byte
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return event{}, err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+
+		if len(line) == 0 {
+			if e.name == "" && lines == nil {
+				continue
+			}
+			e.data = bytes.Join(lines, []byte("\n"))
+			return e, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			e.name = string(value)
+		case "data":
+			lines = append(lines, value)
+		}
+	}
+}
+
+// closeEventData turns the payload of a websocket close frame into the code followed by the reason
+func closeEventData(data []byte) []byte {
+	if len(data) < 2 {
+		return nil
+	}
+
+	code := int(binary.BigEndian.Uint16(data))
+	return append([]byte(strconv.Itoa(code)+" "), data[2:]...)
+}
+
+// closeEventError tells the close codes apart as TranslateReadError does
+func closeEventError(data []byte) error {
+	code, _, _ := bytes.Cut(data, []byte(" "))
+	if len(code) == 0 {
+		return ErrConnectionClosedNormally
+	}
+
+	switch code, _ := strconv.Atoi(string(code)); code {
+	case websocket.CloseNormalClosure, websocket.CloseGoingAway:
+		return ErrConnectionClosedNormally
+	default:
+		return ErrConnectionClosedAbnormally
+	}
+}
+
+// readDeadline can be moved while a read waits on it, as pong handlers do from other goroutines
+type readDeadline struct {
+	mu      sync.Mutex
+	t       time.Time
+	changed chan struct{}
+}
+
+func newReadDeadline() *readDeadline {
+	return &readDeadline{changed: make(chan struct{}, 1)}
+}
+
+func (d *readDeadline) set(t time.Time) {
+	d.mu.Lock()
+	d.t = t
+	d.mu.Unlock()
+
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// timer fires when the deadline passes, never when there is none
+func (d *readDeadline) timer() (<-chan time.Time, func()) {
+	d.mu.Lock()
+	t := d.t
+	d.mu.Unlock()
+
+	if t.IsZero() {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(time.Until(t))
+
+	return timer.C, func() { timer.Stop() }
+}
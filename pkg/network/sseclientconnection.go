@@ -0,0 +1,330 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/pkg/logging"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+// ErrBadSSEHandshake is what DialSSE fails with when the server does not open an event stream
+var ErrBadSSEHandshake = errors.New("bad server-sent events handshake")
+
+// SSEClientConnection is the client end of an SSEConnection
+type SSEClientConnection struct {
+	client      *http.Client
+	messagesUrl string
+	session     string
+	logger      logging.Logger
+
+	body   io.ReadCloser
+	cancel context.CancelFunc
+	// events is closed once the stream ends
+	events chan event
+
+	readDeadline *readDeadline
+	readLimit    atomic.Int64
+
+	mu            sync.Mutex
+	writeDeadline time.Time
+	pingHandler   func(string) error
+	pongHandler   func(string) error
+
+	// writeMu keeps the posts in the order they are written
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// DialSSE opens the event stream and posts to messagesUrl, the client having no timeout
+func DialSSE(ctx context.Context, client *http.Client, eventsUrl string, messagesUrl string, logger logging.Logger) (*SSEClientConnection, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	// the stream outlives ctx, which only bounds the dial
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, eventsUrl, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("%w: %s: %s", ErrBadSSEHandshake, resp.Status, strings.TrimSpace(string(reason)))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := readEvent(reader)
+	if err == nil && (first.name != eventSession || len(first.data) == 0) {
+		err = fmt.Errorf("%w: the stream opened with no session", ErrBadSSEHandshake)
+	}
+	if err == nil && !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		resp.Body.Close()
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	c := &SSEClientConnection{
+		client:       client,
+		messagesUrl:  messagesUrl,
+		session:      string(first.data),
+		logger:       logger,
+		body:         resp.Body,
+		cancel:       cancel,
+		events:       make(chan event),
+		readDeadline: newReadDeadline(),
+		closed:       make(chan struct{}),
+	}
+	go c.readEvents(reader)
+
+	return c, nil
+}
+
+func (c *SSEClientConnection) readEvents(reader *bufio.Reader) {
+	defer close(c.events)
+
+	for {
+		e, err := readEvent(reader)
+		if err != nil {
+			if !c.isClosed() {
+				c.logger.Debug(fmt.Sprintf("the event stream ended: %s", err.Error()), map[string]any{"session": c.session})
+			}
+			return
+		}
+
+		select {
+		case c.events <- e:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// Session is the one the server opened the stream with
+func (c *SSEClientConnection) Session() string {
+	return c.session
+}
+
+func (c *SSEClientConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.cancel()
+	})
+
+	return c.body.Close()
+}
+
+func (c *SSEClientConnection) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *SSEClientConnection) Subprotocol() string {
+	return protocol.SubprotocolJSON
+}
+
+// ReadMessage reads the next message, calling the handlers of the control events read before it
+func (c *SSEClientConnection) ReadMessage() (int, []byte, error) {
+	for {
+		expired, stop := c.readDeadline.timer()
+
+		select {
+		case e, ok := <-c.events:
+			stop()
+			if !ok {
+				return 0, nil, c.translateStreamError()
+			}
+
+			switch e.name {
+			case "":
+				if limit := c.readLimit.Load(); limit > 0 && int64(len(e.data)) > limit {
+					c.Close()
+					return 0, nil, ErrMessageTooLarge
+				}
+				c.logger.Debug("read message", map[string]any{"bytes_read": len(e.data), "message": string(e.data)})
+				return websocket.TextMessage, e.data, nil
+			case eventPing, eventPong:
+				if err := c.receiveControl(e.name, string(e.data)); err != nil {
+					return 0, nil, err
+				}
+			case eventClose:
+				return 0, nil, closeEventError(e.data)
+			}
+		case <-c.readDeadline.changed:
+			stop()
+		case <-expired:
+			return 0, nil, ErrReadTimeOut
+		}
+	}
+}
+
+// translateStreamError tells a stream the server ended from one closed here, as websockets do
+func (c *SSEClientConnection) translateStreamError() error {
+	if c.isClosed() {
+		return ErrNetworkFailure
+	}
+
+	return ErrConnectionClosedAbnormally
+}
+
+func (c *SSEClientConnection) receiveControl(name string, data string) error {
+	c.mu.Lock()
+	handler := c.pongHandler
+	if name == eventPing {
+		handler = c.pingHandler
+	}
+	c.mu.Unlock()
+
+	if handler != nil {
+		return handler(data)
+	}
+	// pings are answered by default, as they are over websockets
+	if name == eventPing {
+		return c.WritePongMessage([]byte(data))
+	}
+
+	return nil
+}
+
+func (c *SSEClientConnection) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+
+	return nil
+}
+
+func (c *SSEClientConnection) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *SSEClientConnection) SetReadLimit(limit int64) {
+	c.readLimit.Store(limit)
+}
+
+func (c *SSEClientConnection) SetPongHandler(f func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pongHandler = f
+}
+
+func (c *SSEClientConnection) SetPingHandler(f func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pingHandler = f
+}
+
+// SetCompression only validates the compression, which is up to the HTTP client here
+func (c *SSEClientConnection) SetCompression(compression Compression) error {
+	return compression.Validate()
+}
+
+// post sends the data under the session, standing for the control frame unless control is empty
+func (c *SSEClientConnection) post(control string, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.isClosed() {
+		return ErrWriteAfterClose
+	}
+
+	ctx := context.Background()
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	query := url.Values{"session": {c.session}}
+	if control != "" {
+		query.Set("control", control)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.messagesUrl+"?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return ErrWriteFailed
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrWriteTimeout
+		}
+		return ErrWriteFailed
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// the server is done with the session
+		return ErrWriteAfterClose
+	default:
+		return ErrWriteFailed
+	}
+}
+
+// WriteCloseMessage tells the server the client is leaving, the payload being ignored
+func (c *SSEClientConnection) WriteCloseMessage([]byte) error {
+	return c.post(ControlClose, nil)
+}
+
+func (c *SSEClientConnection) WriteTextMessage(data []byte) error {
+	return c.post("", data)
+}
+
+func (c *SSEClientConnection) WriteBinaryMessage([]byte) error {
+	return ErrBinaryMessage
+}
+
+func (c *SSEClientConnection) WritePingMessage(data []byte) error {
+	return c.post(ControlPing, data)
+}
+
+func (c *SSEClientConnection) WritePongMessage(data []byte) error {
+	return c.post(ControlPong, data)
+}
@@ -0,0 +1,268 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/pkg/logging"
+	"github.com/iomallach/gchad/pkg/protocol"
+)
+
+const (
+	// maxControlSize is the size of the largest control post read, as websocket control frames go
+	maxControlSize = 125
+	// inboundSize is how many posts are taken before the read, later ones waiting for it
+	inboundSize = 16
+)
+
+// SSEConnection is the server end of a connection over server-sent events
+type SSEConnection struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	session string
+	logger  logging.Logger
+
+	inbound      chan []byte
+	readDeadline *readDeadline
+	readLimit    atomic.Int64
+
+	mu          sync.Mutex
+	pingHandler func(string) error
+	pongHandler func(string) error
+
+	// writeMu keeps writes from overlapping and from happening once the handler of the stream is done
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closed    chan struct{}
+	closeErr  error
+	stop      func() bool
+}
+
+// NewSSEConnection makes the connection out of the request opening the event stream
+func NewSSEConnection(w http.ResponseWriter, r *http.Request, session string, logger logging.Logger) *SSEConnection {
+	c := &SSEConnection{
+		w:            w,
+		rc:           http.NewResponseController(w),
+		session:      session,
+		logger:       logger,
+		inbound:      make(chan []byte, inboundSize),
+		readDeadline: newReadDeadline(),
+		closed:       make(chan struct{}),
+	}
+	c.stop = context.AfterFunc(r.Context(), func() { c.closeWith(ErrConnectionClosedAbnormally) })
+
+	return c
+}
+
+// Session is what the client posts under
+func (c *SSEConnection) Session() string {
+	return c.session
+}
+
+// Open starts the event stream, the session being known to whatever calls Receive first
+func (c *SSEConnection) Open() error {
+	header := c.w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// keeps proxies such as nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.w.WriteHeader(http.StatusOK)
+
+	return c.writeEvent(eventSession, []byte(c.session))
+}
+
+// Receive takes a post of the client, a message unless control is one of the Control constants
+func (c *SSEConnection) Receive(ctx context.Context, control string, body io.Reader) error {
+	switch control {
+	case "":
+	case ControlPing, ControlPong:
+		data, err := io.ReadAll(io.LimitReader(body, maxControlSize))
+		if err != nil {
+			return ErrNetworkFailure
+		}
+		return c.receiveControl(control, string(data))
+	case ControlClose:
+		c.closeWith(ErrConnectionClosedNormally)
+		return nil
+	default:
+		return ErrUnknownControl
+	}
+
+	limit := c.readLimit.Load()
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return ErrNetworkFailure
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		c.closeWith(ErrMessageTooLarge)
+		return ErrMessageTooLarge
+	}
+
+	select {
+	case <-c.closed:
+		return c.closeErr
+	default:
+	}
+	select {
+	case c.inbound <- data:
+		return nil
+	case <-c.closed:
+		return c.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *SSEConnection) receiveControl(control string, data string) error {
+	c.mu.Lock()
+	handler := c.pongHandler
+	if control == ControlPing {
+		handler = c.pingHandler
+	}
+	c.mu.Unlock()
+
+	if handler != nil {
+		return handler(data)
+	}
+	// pings are answered by default, as they are over websockets
+	if control == ControlPing {
+		return c.WritePongMessage([]byte(data))
+	}
+
+	return nil
+}
+
+func (c *SSEConnection) Close() error {
+	c.closeWith(ErrConnectionClosedAbnormally)
+	return nil
+}
+
+// closeWith has the reads fail with the error once the write under way is done
+func (c *SSEConnection) closeWith(err error) {
+	c.closeOnce.Do(func() {
+		c.stop()
+
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		c.closeErr = err
+		close(c.closed)
+	})
+}
+
+// Done is closed along with the connection
+func (c *SSEConnection) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *SSEConnection) Subprotocol() string {
+	return protocol.SubprotocolJSON
+}
+
+func (c *SSEConnection) ReadMessage() (int, []byte, error) {
+	for {
+		expired, stop := c.readDeadline.timer()
+
+		select {
+		case data := <-c.inbound:
+			stop()
+			c.logger.Debug("read message", map[string]any{"bytes_read": len(data), "message": string(data)})
+			return websocket.TextMessage, data, nil
+		case <-c.readDeadline.changed:
+			stop()
+		case <-expired:
+			return 0, nil, ErrReadTimeOut
+		case <-c.closed:
+			stop()
+			// what came before the connection closed is read first
+			select {
+			case data := <-c.inbound:
+				return websocket.TextMessage, data, nil
+			default:
+				return 0, nil, c.closeErr
+			}
+		}
+	}
+}
+
+// SetWriteDeadline is a no-op for response writers that do not support deadlines
+func (c *SSEConnection) SetWriteDeadline(t time.Time) error {
+	if err := c.rc.SetWriteDeadline(t); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *SSEConnection) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *SSEConnection) SetReadLimit(limit int64) {
+	c.readLimit.Store(limit)
+}
+
+func (c *SSEConnection) SetPongHandler(f func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pongHandler = f
+}
+
+func (c *SSEConnection) SetPingHandler(f func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pingHandler = f
+}
+
+// SetCompression only validates the compression, which is up to the HTTP server here
+func (c *SSEConnection) SetCompression(compression Compression) error {
+	return compression.Validate()
+}
+
+func (c *SSEConnection) writeEvent(name string, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	select {
+	case <-c.closed:
+		return ErrWriteAfterClose
+	default:
+	}
+
+	if err := writeEvent(c.w, name, data); err != nil {
+		return TranslateWriteError(err)
+	}
+
+	return TranslateWriteError(c.rc.Flush())
+}
+
+// WriteCloseMessage takes the payload of a websocket close frame, the event carrying its code and
+// reason as text
+func (c *SSEConnection) WriteCloseMessage(data []byte) error {
+	return c.writeEvent(eventClose, closeEventData(data))
+}
+
+func (c *SSEConnection) WriteTextMessage(data []byte) error {
+	return c.writeEvent("", data)
+}
+
+func (c *SSEConnection) WriteBinaryMessage([]byte) error {
+	return ErrBinaryMessage
+}
+
+func (c *SSEConnection) WritePingMessage(data []byte) error {
+	return c.writeEvent(eventPing, data)
+}
+
+func (c *SSEConnection) WritePongMessage(data []byte) error {
+	return c.writeEvent(eventPong, data)
+}
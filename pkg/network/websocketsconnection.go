@@ -8,18 +8,23 @@ import (
 )
 
 type WebsocketsConnection struct {
-	conn   *websocket.Conn
-	logger logging.Logger
+	conn        *websocket.Conn
+	logger      logging.Logger
+	compression Compression
 }
 
 func NewWebsocketsConnection(conn *websocket.Conn, logger logging.Logger) *WebsocketsConnection {
-	return &WebsocketsConnection{conn, logger}
+	return &WebsocketsConnection{conn: conn, logger: logger}
 }
 
 func (ws *WebsocketsConnection) Close() error {
 	return ws.conn.Close()
 }
 
+func (ws *WebsocketsConnection) Subprotocol() string {
+	return ws.conn.Subprotocol()
+}
+
 func (ws *WebsocketsConnection) ReadMessage() (int, []byte, error) {
 	bytesRead, msg, err := ws.conn.ReadMessage()
 	if err != nil {
@@ -39,6 +44,10 @@ func (ws *WebsocketsConnection) SetReadDeadline(t time.Time) error {
 	return ws.conn.SetReadDeadline(t)
 }
 
+func (ws *WebsocketsConnection) SetReadLimit(limit int64) {
+	ws.conn.SetReadLimit(limit)
+}
+
 func (ws *WebsocketsConnection) SetPongHandler(f func(string) error) {
 	ws.conn.SetPongHandler(f)
 }
@@ -52,12 +61,37 @@ func (ws *WebsocketsConnection) writeMessage(msgCode int, data []byte) error {
 	return TranslateWriteError(err)
 }
 
+// SetCompression takes effect only when the ends agreed on permessage-deflate, which otherwise
+// compresses every message at the default level
+func (ws *WebsocketsConnection) SetCompression(compression Compression) error {
+	if err := compression.Validate(); err != nil {
+		return err
+	}
+	if err := ws.conn.SetCompressionLevel(compression.Level); err != nil {
+		return err
+	}
+	ws.compression = compression
+
+	return nil
+}
+
+// writeData compresses the messages the threshold lets through
+func (ws *WebsocketsConnection) writeData(msgCode int, data []byte) error {
+	ws.conn.EnableWriteCompression(ws.compression.Compresses(len(data)))
+
+	return ws.writeMessage(msgCode, data)
+}
+
 func (ws *WebsocketsConnection) WriteCloseMessage(data []byte) error {
 	return ws.writeMessage(websocket.CloseMessage, data)
 }
 
 func (ws *WebsocketsConnection) WriteTextMessage(data []byte) error {
-	return ws.writeMessage(websocket.TextMessage, data)
+	return ws.writeData(websocket.TextMessage, data)
+}
+
+func (ws *WebsocketsConnection) WriteBinaryMessage(data []byte) error {
+	return ws.writeData(websocket.BinaryMessage, data)
 }
 
 func (ws *WebsocketsConnection) WritePingMessage(data []byte) error {
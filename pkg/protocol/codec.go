@@ -0,0 +1,214 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// SubprotocolJSON is the one of clients that ask for none, every frame being a text one
+	SubprotocolJSON    = "gchad.json"
+	SubprotocolMsgPack = "gchad.msgpack"
+)
+
+// Subprotocols are the ones spoken, the preferred first
+func Subprotocols() []string {
+	return []string{SubprotocolMsgPack, SubprotocolJSON}
+}
+
+// Codec turns the messages of one end of a connection into frames and back
+type Codec[M Message] interface {
+	Subprotocol() string
+	// Binary tells whether the frames are binary ones rather than text
+	Binary() bool
+	Marshal(msg M) ([]byte, error)
+	// MarshalRequest is Marshal for a message the sender wants answered, the answer carrying the
+	// request id back
+	MarshalRequest(msg M, requestId string) ([]byte, error)
+	// Unmarshal fails with ErrUnknownType for the types missing from the decoders of the codec
+	Unmarshal(data []byte) (M, error)
+	// UnmarshalRequest is Unmarshal also returning the request id of the frame, empty if it has none
+	UnmarshalRequest(data []byte) (M, string, error)
+}
+
+// NewCodec is the codec of the subprotocol, JSON for clients that asked for none
+func NewCodec[M Message](subprotocol string, decoders Decoders[M]) Codec[M] {
+	if subprotocol == SubprotocolMsgPack {
+		return msgpackCodec[M]{decoders}
+	}
+
+	return jsonCodec[M]{decoders}
+}
+
+// Payload is the message of a frame, yet to be decoded into the type the frame names
+type Payload interface {
+	Decode(v any) error
+}
+
+// Decoders decode the payloads of the types a receiver knows
+type Decoders[M any] map[MessageType]func(payload Payload) (M, error)
+
+func (d Decoders[M]) decode(messageType MessageType, payload Payload) (M, error) {
+	decode, ok := d[messageType]
+	if !ok {
+		var msg M
+		return msg, fmt.Errorf("%w: %q", ErrUnknownType, messageType)
+	}
+	msg, err := decode(payload)
+	if err != nil {
+		return msg, fmt.Errorf("invalid %s message: %w", messageType, err)
+	}
+
+	return msg, nil
+}
+
+// Envelope is how JSON frames look, the payload being encoded on its own
+type Envelope struct {
+	Type      MessageType     `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	RequestId string          `json:"request_id,omitempty"`
+}
+
+type jsonCodec[M Message] struct {
+	decoders Decoders[M]
+}
+
+func (c jsonCodec[M]) Subprotocol() string {
+	return SubprotocolJSON
+}
+
+func (c jsonCodec[M]) Binary() bool {
+	return false
+}
+
+func (c jsonCodec[M]) Marshal(msg M) ([]byte, error) {
+	return c.MarshalRequest(msg, "")
+}
+
+func (c jsonCodec[M]) MarshalRequest(msg M, requestId string) ([]byte, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{Type: msg.MessageType(), Payload: payload, RequestId: requestId})
+}
+
+func (c jsonCodec[M]) Unmarshal(data []byte) (M, error) {
+	msg, _, err := c.UnmarshalRequest(data)
+	return msg, err
+}
+
+func (c jsonCodec[M]) UnmarshalRequest(data []byte) (M, string, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		var msg M
+		return msg, "", err
+	}
+
+	msg, err := c.decoders.decode(envelope.Type, jsonPayload(envelope.Payload))
+	return msg, envelope.RequestId, err
+}
+
+type jsonPayload json.RawMessage
+
+func (p jsonPayload) Decode(v any) error {
+	return json.Unmarshal(p, v)
+}
+
+// msgpackCodec writes every message as an array of its type and itself, in a single pass, followed
+// by the request id when there is one. The fields are named after the JSON ones, which keeps the
+// two codecs describing the same messages.
+type msgpackCodec[M Message] struct {
+	decoders Decoders[M]
+}
+
+func (c msgpackCodec[M]) Subprotocol() string {
+	return SubprotocolMsgPack
+}
+
+func (c msgpackCodec[M]) Binary() bool {
+	return true
+}
+
+func (c msgpackCodec[M]) Marshal(msg M) ([]byte, error) {
+	return c.MarshalRequest(msg, "")
+}
+
+func (c msgpackCodec[M]) MarshalRequest(msg M, requestId string) ([]byte, error) {
+	length := 2
+	if requestId != "" {
+		length = 3
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	if err := enc.EncodeArrayLen(length); err != nil {
+		return nil, err
+	}
+	if err := enc.EncodeString(string(msg.MessageType())); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	if requestId != "" {
+		if err := enc.EncodeString(requestId); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c msgpackCodec[M]) Unmarshal(data []byte) (M, error) {
+	msg, _, err := c.UnmarshalRequest(data)
+	return msg, err
+}
+
+func (c msgpackCodec[M]) UnmarshalRequest(data []byte) (M, string, error) {
+	var msg M
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return msg, "", err
+	}
+	if length != 2 && length != 3 {
+		return msg, "", fmt.Errorf("expected the type, the message and maybe a request id, got %d values", length)
+	}
+	messageType, err := dec.DecodeString()
+	if err != nil {
+		return msg, "", err
+	}
+
+	msg, err = c.decoders.decode(MessageType(messageType), msgpackPayload{dec})
+	if err != nil || length == 2 {
+		return msg, "", err
+	}
+	requestId, err := dec.DecodeString()
+	if err != nil {
+		return msg, "", fmt.Errorf("invalid request id: %w", err)
+	}
+
+	return msg, requestId, nil
+}
+
+type msgpackPayload struct {
+	dec *msgpack.Decoder
+}
+
+func (p msgpackPayload) Decode(v any) error {
+	return p.dec.Decode(v)
+}
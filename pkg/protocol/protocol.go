@@ -0,0 +1,173 @@
+// Package protocol is the wire protocol spoken between gchad servers and clients. Every frame holds
+// a single message along with its type, encoded by the Codec of the websocket subprotocol the ends
+// agreed on when connecting.
+//
+// A client opens the conversation with a HelloMessage telling the version it speaks and the
+// capabilities it has, and the server answers with a WelcomeMessage holding what they agreed on.
+// Clients saying nothing are taken for version 1 clients, which predate the handshake.
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// Version is the latest version of the protocol
+	Version = 2
+	// MinVersion is the oldest version still spoken, the one of clients that do not say hello
+	MinVersion = 1
+)
+
+var (
+	// ErrUnknownType is returned for messages of a type the receiver does not know, which are to
+	// be ignored, as they come from a peer speaking a later version
+	ErrUnknownType        = errors.New("unknown message type")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+)
+
+type MessageType string
+
+const (
+	Hello   MessageType = "hello"
+	Welcome MessageType = "welcome"
+	// Error tells a client the server turned down what it sent
+	Error MessageType = "error"
+	// Ack tells a client the server took a message of its own that had a request id
+	Ack MessageType = "ack"
+
+	Chat        MessageType = "chat"
+	UserJoined  MessageType = "user_joined"
+	UserLeft    MessageType = "user_left"
+	UserRenamed MessageType = "user_renamed"
+	Rename      MessageType = "rename"
+	Presence    MessageType = "presence"
+	SetPresence MessageType = "set_presence"
+	Activity    MessageType = "activity"
+	MemberList  MessageType = "member_list"
+	SyncRoom    MessageType = "sync_room"
+
+	RoomList      MessageType = "room_list"
+	ListRooms     MessageType = "list_rooms"
+	Search        MessageType = "search"
+	SearchResults MessageType = "search_results"
+
+	CreateWebhook MessageType = "create_webhook"
+	RevokeWebhook MessageType = "revoke_webhook"
+	ListWebhooks  MessageType = "list_webhooks"
+	WebhookList   MessageType = "webhook_list"
+
+	SubscribeEvents        MessageType = "subscribe_events"
+	UnsubscribeEvents      MessageType = "unsubscribe_events"
+	ListEventSubscriptions MessageType = "list_event_subscriptions"
+	EventSubscriptionList  MessageType = "event_subscription_list"
+)
+
+// Message is anything that travels in an Envelope
+type Message interface {
+	MessageType() MessageType
+}
+
+// ErrorCode tells clients why the server turned a message of theirs down, the text going along with
+// it being meant for people
+type ErrorCode string
+
+const (
+	// CodeMalformedMessage is for frames that do not decode into a message
+	CodeMalformedMessage ErrorCode = "malformed_message"
+	// CodeInvalidMessage is for messages that decode but break the rules of the protocol, e.g. text
+	// that is not UTF-8
+	CodeInvalidMessage  ErrorCode = "invalid_message"
+	CodeEmptyMessage    ErrorCode = "empty_message"
+	CodeTextTooLong     ErrorCode = "text_too_long"
+	CodeInvalidName     ErrorCode = "invalid_name"
+	CodeNameTaken       ErrorCode = "name_taken"
+	CodeInvalidPresence ErrorCode = "invalid_presence"
+	// CodeForbidden is for what only moderators may do
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeNotFound is for rooms, webhooks and event subscriptions that do not exist
+	CodeNotFound ErrorCode = "not_found"
+	// CodeNotInRoom is for messages of a client the server has not let into a room, yet or anymore
+	CodeNotInRoom ErrorCode = "not_in_room"
+	// CodeBusy is for messages the server could not take right now, which are worth sending again
+	CodeBusy ErrorCode = "busy"
+)
+
+// Capability is an optional feature of the protocol, used only when both ends have it
+type Capability string
+
+const (
+	CapabilitySearch             Capability = "search"
+	CapabilityWebhooks           Capability = "webhooks"
+	CapabilityEventSubscriptions Capability = "event_subscriptions"
+	// CapabilityAcks is for servers acknowledging the chat messages that carry a request id
+	CapabilityAcks Capability = "acks"
+)
+
+// Capabilities are the ones this version of the protocol knows of
+func Capabilities() []Capability {
+	return []Capability{CapabilitySearch, CapabilityWebhooks, CapabilityEventSubscriptions, CapabilityAcks}
+}
+
+// HelloMessage is the first message of a client
+type HelloMessage struct {
+	Version      int          `json:"version"`
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+func (m HelloMessage) MessageType() MessageType {
+	return Hello
+}
+
+// WelcomeMessage answers a HelloMessage with the version and capabilities agreed on
+type WelcomeMessage struct {
+	Version      int          `json:"version"`
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+func (m WelcomeMessage) MessageType() MessageType {
+	return Welcome
+}
+
+// Session is what both ends of a connection agreed on
+type Session struct {
+	Version      int
+	Capabilities []Capability
+}
+
+// Legacy is the session of a peer that skipped the handshake
+func Legacy() Session {
+	return Session{Version: MinVersion}
+}
+
+// NewSession is the session a WelcomeMessage tells of
+func NewSession(welcome WelcomeMessage) Session {
+	return Session{Version: welcome.Version, Capabilities: welcome.Capabilities}
+}
+
+func (s Session) Supports(capability Capability) bool {
+	return slices.Contains(s.Capabilities, capability)
+}
+
+// Welcome is the answer to the hello
+func (s Session) Welcome() WelcomeMessage {
+	return WelcomeMessage{Version: s.Version, Capabilities: s.Capabilities}
+}
+
+// Negotiate settles on the lower of the versions and the capabilities both ends have, in the order
+// the client gave them
+func Negotiate(hello HelloMessage, capabilities []Capability) (Session, error) {
+	if hello.Version < MinVersion {
+		return Session{}, fmt.Errorf("%w: %d, the oldest spoken is %d", ErrUnsupportedVersion, hello.Version, MinVersion)
+	}
+
+	session := Session{Version: min(hello.Version, Version)}
+	for _, capability := range hello.Capabilities {
+		if slices.Contains(capabilities, capability) && !session.Supports(capability) {
+			session.Capabilities = append(session.Capabilities, capability)
+		}
+	}
+
+	return session, nil
+}
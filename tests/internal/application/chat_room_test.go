@@ -2,6 +2,7 @@ package application_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/iomallach/gchad/internal/server/application"
 	"github.com/iomallach/gchad/internal/server/domain"
@@ -11,7 +12,7 @@ import (
 func TestChatRoom_LetClientIn(t *testing.T) {
 	chatRoom := application.NewChatRoom("1", "general", application.NewClientRegistry())
 	client := domain.NewClient("1", "Jane Doe")
-	expectedEvent := domain.NewUserJoinedRoomEvent("Jane Doe")
+	expectedEvent := domain.NewUserJoinedRoomEvent("1", "Jane Doe", domain.RoleMember, false, 1)
 
 	event := chatRoom.LetClientIn(client)
 	clients := chatRoom.GetClients()
@@ -31,4 +32,59 @@ func TestChatRoom_LetClientOut(t *testing.T) {
 	chatRoom.LetClientOut(client.Id())
 
 	assert.Equal(t, 0, len(chatRoom.GetClients()))
+	assert.Nil(t, chatRoom.LetClientOut(client.Id()))
+}
+
+func TestChatRoom_IsModerator(t *testing.T) {
+	chatRoom := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	chatRoom.AddModerator("Jane", "secret")
+	chatRoom.AddModerator("John", "")
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"Jane", "secret", true},
+		{"Jane", "", false},
+		{"Jane", "guess", false},
+		{"Jake", "secret", false},
+		// a moderator with no token is one nobody can prove to be
+		{"John", "", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, chatRoom.IsModerator(tt.name, tt.token), "%s with %q", tt.name, tt.token)
+	}
+}
+
+func TestChatRoom_RenameClient(t *testing.T) {
+	chatRoom := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	chatRoom.LetClientIn(domain.NewClient("1", "Jane"))
+
+	renamed := chatRoom.RenameClient("1", "Janet")
+
+	assert.Equal(t, domain.NewUserRenamedEvent("Jane", "Janet", 2), renamed)
+	assert.Equal(t, "Janet", chatRoom.GetClient("1").Name())
+	assert.Nil(t, chatRoom.RenameClient("2", "John"))
+}
+
+func TestChatRoom_StateVersion(t *testing.T) {
+	chatRoom := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	now := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	chatRoom.LetClientIn(domain.NewClient("1", "John"))
+	chatRoom.LetClientIn(domain.NewClient("2", "Jane"))
+	changed := chatRoom.SetPresence("2", domain.PresenceAway, "lunch", now)
+	unchanged := chatRoom.SetPresence("2", domain.PresenceAway, "lunch", now)
+
+	assert.Equal(t, domain.NewUserPresenceChangedEvent("Jane", domain.PresenceAway, "lunch", 3), changed)
+	assert.Nil(t, unchanged)
+	assert.Equal(t, domain.RoomState{
+		Version: 3,
+		Members: []domain.Member{
+			{Name: "Jane", Role: domain.RoleMember, Presence: domain.PresenceAway, StatusText: "lunch"},
+			{Name: "John", Role: domain.RoleMember, Presence: domain.PresenceOnline},
+		},
+	}, chatRoom.State())
 }
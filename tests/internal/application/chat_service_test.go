@@ -2,12 +2,15 @@ package application_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/iomallach/gchad/internal/server/application"
 	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/protocol"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type Broadcast struct {
@@ -15,40 +18,158 @@ type Broadcast struct {
 	msg  domain.Messager
 }
 
+type Direct struct {
+	clientId string
+	msg      domain.Messager
+}
+
 type SpyNotifier struct {
+	mu         sync.Mutex
 	broadcasts []Broadcast
+	direct     []Direct
 }
 
 func (s *SpyNotifier) BroadcastToRoom(room *application.ChatRoom, msg domain.Messager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.broadcasts = append(s.broadcasts, Broadcast{room, msg})
 }
 
+// SendToClient is called from the event loop as well as by requests turned down right away
+func (s *SpyNotifier) SendToClient(clientId string, msg domain.Messager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.direct = append(s.direct, Direct{clientId, msg})
+}
+
+func (s *SpyNotifier) Broadcasts() []Broadcast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Broadcast(nil), s.broadcasts...)
+}
+
+func (s *SpyNotifier) Direct() []Direct {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Direct(nil), s.direct...)
+}
+
+// rejections are the errors sent to the client
+func (s *SpyNotifier) rejections(clientId string) []*domain.ErrorSystemMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejections := make([]*domain.ErrorSystemMessage, 0)
+	for _, direct := range s.direct {
+		if rejection, ok := direct.msg.(*domain.ErrorSystemMessage); ok && direct.clientId == clientId {
+			rejections = append(rejections, rejection)
+		}
+	}
+
+	return rejections
+}
+
+type Dispatched struct {
+	subscription domain.EventSubscription
+	event        domain.RoomEvent
+}
+
+type SpyDispatcher struct {
+	mu         sync.Mutex
+	dispatched []Dispatched
+}
+
+func (s *SpyDispatcher) Dispatch(subscription domain.EventSubscription, event domain.RoomEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dispatched = append(s.dispatched, Dispatched{subscription, event})
+}
+
+func (s *SpyDispatcher) Dispatched() []Dispatched {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Dispatched(nil), s.dispatched...)
+}
+
 type ErrorNotifier struct {
+	mu         sync.Mutex
 	broadcasts []Broadcast
 }
 
 func (s *ErrorNotifier) BroadcastToRoom(room *application.ChatRoom, msg domain.Messager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.broadcasts = append(s.broadcasts, Broadcast{room, msg})
 }
 
+func (s *ErrorNotifier) SendToClient(clientId string, msg domain.Messager) {}
+
 type LogCall struct {
 	msg    string
 	fields map[string]any
 	level  string
 }
 
+// SpyLogger is written to from the event loop and read from the test
 type SpyLogger struct {
+	mu    sync.Mutex
 	calls []LogCall
 }
 
+func (l *SpyLogger) log(level string, msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: level})
+}
+
 func (l *SpyLogger) Error(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "ERROR"})
+	l.log("ERROR", msg, fields)
 }
 func (l *SpyLogger) Info(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "INFO"})
+	l.log("INFO", msg, fields)
 }
 func (l *SpyLogger) Debug(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "DEBUG"})
+	l.log("DEBUG", msg, fields)
+}
+
+func (l *SpyLogger) Calls() []LogCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]LogCall(nil), l.calls...)
+}
+
+func (l *SpyLogger) Errors() []LogCall {
+	errors := make([]LogCall, 0)
+
+	for _, call := range l.Calls() {
+		if call.level == "ERROR" {
+			errors = append(errors, call)
+		}
+	}
+
+	return errors
+}
+
+// broadcastsOf keeps only the broadcasts carrying a message of type T
+func broadcastsOf[T domain.Messager](broadcasts []Broadcast) []Broadcast {
+	filtered := make([]Broadcast, 0, len(broadcasts))
+
+	for _, broadcast := range broadcasts {
+		if _, ok := broadcast.msg.(T); ok {
+			filtered = append(filtered, broadcast)
+		}
+	}
+
+	return filtered
 }
 
 func TestChatService_EnterRoom(t *testing.T) {
@@ -70,6 +191,8 @@ func TestChatService_EnterRoom(t *testing.T) {
 						msg: &domain.UserJoinedSystemMessage{
 							Timestamp: frozenTime,
 							Name:      "Jane Doe",
+							Role:      domain.RoleMember,
+							Version:   1,
 						},
 					},
 					{
@@ -77,6 +200,8 @@ func TestChatService_EnterRoom(t *testing.T) {
 						msg: &domain.UserJoinedSystemMessage{
 							Timestamp: frozenTime,
 							Name:      "John Doe",
+							Role:      domain.RoleMember,
+							Version:   2,
 						},
 					},
 				}
@@ -95,20 +220,21 @@ func TestChatService_EnterRoom(t *testing.T) {
 			spyLogger := SpyLogger{calls: make([]LogCall, 0)}
 
 			spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
-			chatService := application.NewChatService(room, &spyNotifier, func() time.Time { return frozenTime }, 3, 3, &spyLogger)
+			chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 3, 3, &spyLogger)
 
 			chatService.Start(ctx)
 
 			for _, client := range tt.clients {
-				chatService.EnterRoom(client.id, client.name)
+				chatService.EnterRoom(client.id, client.name, "", "", false)
 			}
 
 			time.Sleep(50 * time.Millisecond)
 
 			expectedBroadcasts := tt.expectedBroadcasts(room, frozenTime)
-			assert.Equal(t, len(expectedBroadcasts), len(spyNotifier.broadcasts))
+			joinedBroadcasts := broadcastsOf[*domain.UserJoinedSystemMessage](spyNotifier.Broadcasts())
+			assert.Equal(t, len(expectedBroadcasts), len(joinedBroadcasts))
 
-			for idx, broadcast := range spyNotifier.broadcasts {
+			for idx, broadcast := range joinedBroadcasts {
 				userJoined, ok := broadcast.msg.(*domain.UserJoinedSystemMessage)
 				if !ok {
 					t.Errorf("expected a user joined message, got %T", broadcast)
@@ -116,11 +242,39 @@ func TestChatService_EnterRoom(t *testing.T) {
 				assert.Equal(t, expectedBroadcasts[idx].msg, userJoined)
 				assert.Equal(t, expectedBroadcasts[idx].room, broadcast.room)
 			}
-			assert.Equal(t, 0, len(spyLogger.calls))
+			assert.Equal(t, 0, len(spyLogger.Calls()))
 		})
 	}
 }
 
+func TestChatService_EnterRoom_Bot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 3, 3, &SpyLogger{})
+	chatService.Start(ctx)
+
+	chatService.EnterRoom("1", "Jane", "", "", false)
+	chatService.EnterRoom("2", "reminder", "", "", true)
+	time.Sleep(50 * time.Millisecond)
+
+	joined := broadcastsOf[*domain.UserJoinedSystemMessage](spyNotifier.Broadcasts())
+	require.Len(t, joined, 2)
+	assert.False(t, joined[0].msg.(*domain.UserJoinedSystemMessage).Bot)
+	assert.True(t, joined[1].msg.(*domain.UserJoinedSystemMessage).Bot)
+
+	require.Len(t, spyNotifier.Direct(), 2)
+	memberList, ok := spyNotifier.Direct()[1].msg.(*domain.MemberListSystemMessage)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []domain.Member{
+		{Name: "Jane", Role: domain.RoleMember, Presence: domain.PresenceOnline},
+		{Name: "reminder", Role: domain.RoleMember, Presence: domain.PresenceOnline, Bot: true},
+	}, memberList.Members)
+}
+
 func TestChatService_LeaveRoom(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -143,6 +297,7 @@ func TestChatService_LeaveRoom(t *testing.T) {
 						msg: &domain.UserLeftSystemMessage{
 							Timestamp: frozenTime,
 							Name:      "Jane Doe",
+							Version:   3,
 						},
 					},
 					{
@@ -150,6 +305,7 @@ func TestChatService_LeaveRoom(t *testing.T) {
 						msg: &domain.UserLeftSystemMessage{
 							Timestamp: frozenTime,
 							Name:      "John Doe",
+							Version:   4,
 						},
 					},
 				}
@@ -170,6 +326,7 @@ func TestChatService_LeaveRoom(t *testing.T) {
 						msg: &domain.UserLeftSystemMessage{
 							Timestamp: frozenTime,
 							Name:      "John Doe",
+							Version:   3,
 						},
 					},
 				}
@@ -189,7 +346,7 @@ func TestChatService_LeaveRoom(t *testing.T) {
 
 			spyLogger := SpyLogger{calls: make([]LogCall, 0)}
 			spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
-			chatService := application.NewChatService(room, &spyNotifier, func() time.Time { return frozenTime }, 3, 3, &spyLogger)
+			chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 3, 3, &spyLogger)
 
 			for _, client := range tt.clientsIn {
 				room.LetClientIn(domain.NewClient(client.id, client.name))
@@ -204,9 +361,9 @@ func TestChatService_LeaveRoom(t *testing.T) {
 			time.Sleep(50 * time.Millisecond)
 
 			expectedBroadcasts := tt.expectedBroadcasts(room, frozenTime)
-			assert.Equal(t, len(expectedBroadcasts), len(spyNotifier.broadcasts))
+			assert.Equal(t, len(expectedBroadcasts), len(spyNotifier.Broadcasts()))
 
-			for idx, broadcast := range spyNotifier.broadcasts {
+			for idx, broadcast := range spyNotifier.Broadcasts() {
 				userLeft, ok := broadcast.msg.(*domain.UserLeftSystemMessage)
 				if !ok {
 					t.Errorf("expected a user left message, got %T", broadcast)
@@ -216,7 +373,7 @@ func TestChatService_LeaveRoom(t *testing.T) {
 			}
 
 			assert.Len(t, room.GetClients(), tt.expectedClientsCount)
-			assert.Equal(t, 0, len(spyLogger.calls))
+			assert.Equal(t, 0, len(spyLogger.Calls()))
 		})
 	}
 }
@@ -226,31 +383,514 @@ func TestChatService_SendMessage(t *testing.T) {
 	defer cancel()
 
 	expectedMessages := []string{"Hello test", "Hello back"}
+	expectedSenders := []string{"Jane Doe", "John Doe"}
 	clientRegistry := application.NewClientRegistry()
 	room := application.NewChatRoom("1", "general", clientRegistry)
 	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
 
 	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
 	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
-	chatService := application.NewChatService(room, &spyNotifier, func() time.Time { return frozenTime }, 3, 3, &spyLogger)
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 3, 3, &spyLogger)
+
+	room.LetClientIn(domain.NewClient("1", "Jane Doe"))
+	room.LetClientIn(domain.NewClient("2", "John Doe"))
 
 	chatService.Start(ctx)
 
-	chatService.SendMessage("1", "Hello test")
-	chatService.SendMessage("2", "Hello back")
+	chatService.SendMessage("1", "", "Hello test")
+	chatService.SendMessage("2", "", "Hello back")
 
 	time.Sleep(50 * time.Millisecond)
 
-	assert.Len(t, spyLogger.calls, 0)
-	assert.Len(t, spyNotifier.broadcasts, 2)
+	assert.Len(t, spyLogger.Calls(), 0)
+	assert.Len(t, spyNotifier.Broadcasts(), 2)
 
-	for idx, broadcast := range spyNotifier.broadcasts {
+	for idx, broadcast := range spyNotifier.Broadcasts() {
 		message, ok := broadcast.msg.(*domain.UserMessage)
 		if !ok {
 			t.Errorf("expected a user message, got %T", broadcast)
 		}
 		assert.Equal(t, room, broadcast.room)
-		assert.Equal(t, expectedMessages[idx], message.Message)
+		assert.Equal(t, expectedMessages[idx], message.Text)
+		assert.Equal(t, expectedSenders[idx], message.From)
 		assert.Equal(t, frozenTime, message.Timestamp)
 	}
 }
+
+func TestChatService_SendMessage_Acknowledges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	general := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	random := application.NewChatRoom("2", "random", application.NewClientRegistry())
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(general, random), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, time.Now, application.PresenceConfiguration{}, 10, 10, &spyLogger)
+
+	general.LetClientIn(domain.NewClient("1", "Jane Doe"))
+	random.LetClientIn(domain.NewClient("2", "John Doe"))
+	chatService.Start(ctx)
+
+	chatService.SendMessage("1", "a", "first")
+	chatService.SendMessage("2", "b", "elsewhere")
+	chatService.SendMessage("1", "", "without an id")
+	chatService.SendMessage("1", "c", "second")
+	chatService.SendMessage("3", "d", "from nowhere")
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, []*domain.ErrorSystemMessage{
+		domain.NewErrorSystemMessage(protocol.Chat, protocol.CodeNotInRoom, application.ErrNotInRoom.Error()).WithRequestId("d"),
+	}, spyNotifier.rejections("3"))
+
+	acks := make([]Direct, 0)
+	for _, direct := range spyNotifier.Direct() {
+		if _, ok := direct.msg.(*domain.AckSystemMessage); ok {
+			acks = append(acks, direct)
+		}
+	}
+	// only the messages with a request id are acknowledged, counting the messages of each room
+	assert.Equal(t, []Direct{
+		{"1", domain.NewAckSystemMessage("a", "1", 1)},
+		{"2", domain.NewAckSystemMessage("b", "2", 1)},
+		{"1", domain.NewAckSystemMessage("c", "4", 3)},
+	}, acks)
+}
+
+func TestChatService_SetPresence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 3, 3, &spyLogger)
+
+	room.LetClientIn(domain.NewClient("1", "Jane Doe"))
+	chatService.Start(ctx)
+
+	chatService.SetPresence("1", domain.PresenceDoNotDisturb, "focusing")
+	// setting the same presence again is not a change and must not be broadcast
+	chatService.SetPresence("1", domain.PresenceDoNotDisturb, "focusing")
+	chatService.SetPresence("1", domain.Presence("sleeping"), "")
+
+	time.Sleep(50 * time.Millisecond)
+
+	presenceBroadcasts := broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts())
+	assert.Len(t, presenceBroadcasts, 1)
+	assert.Equal(t, domain.NewPresenceSystemMessage("Jane Doe", domain.PresenceDoNotDisturb, "focusing", 2, frozenTime), presenceBroadcasts[0].msg)
+	assert.Len(t, spyLogger.Calls(), 1)
+	assert.Equal(t, "invalid presence requested", spyLogger.Calls()[0].msg)
+}
+
+// fakeClock is moved by the test and read by the event loop
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestChatService_AutoAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	clock := &fakeClock{now: time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)}
+	idleChecks := make(chan time.Time)
+	presenceConfig := application.PresenceConfiguration{
+		AwayAfter:  5 * time.Minute,
+		IdleChecks: idleChecks,
+	}
+	// the loop takes a check only once done with the one before, so two in a row have the first one handled
+	check := func() {
+		idleChecks <- clock.Now()
+		idleChecks <- clock.Now()
+	}
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, clock.Now, presenceConfig, 3, 3, &spyLogger)
+
+	chatService.Start(ctx)
+	chatService.EnterRoom("1", "Jane Doe", "", "", false)
+	// joining sends the newcomer a snapshot of everyone in the room
+	require.Eventually(t, func() bool { return len(spyNotifier.Direct()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, "1", spyNotifier.Direct()[0].clientId)
+	assert.IsType(t, &domain.MemberListSystemMessage{}, spyNotifier.Direct()[0].msg)
+
+	clock.Advance(4 * time.Minute)
+	check()
+	assert.Empty(t, broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts()))
+
+	clock.Advance(time.Minute)
+	check()
+	presenceBroadcasts := broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts())
+	require.Len(t, presenceBroadcasts, 1)
+	assert.Equal(t, domain.PresenceAway, presenceBroadcasts[0].msg.(*domain.PresenceSystemMessage).Presence)
+
+	chatService.RecordActivity("1")
+	require.Eventually(t, func() bool {
+		return len(broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts())) == 2
+	}, time.Second, time.Millisecond)
+	presenceBroadcasts = broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts())
+	assert.Equal(t, domain.PresenceOnline, presenceBroadcasts[1].msg.(*domain.PresenceSystemMessage).Presence)
+
+	// the activity restarts the wait
+	check()
+	assert.Len(t, broadcastsOf[*domain.PresenceSystemMessage](spyNotifier.Broadcasts()), 2)
+}
+
+func TestChatService_Rename(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("1", "general", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 3, &spyLogger)
+
+	room.LetClientIn(domain.NewClient("1", "Jane"))
+	room.LetClientIn(domain.NewClient("2", "John"))
+	chatService.Start(ctx)
+
+	chatService.Rename("1", "Janet")
+	chatService.Rename("2", "Janet")
+	chatService.Rename("2", "John")
+	chatService.Rename("2", "John Doe")
+	chatService.Rename("2", "\x1b[8mJohn")
+
+	time.Sleep(50 * time.Millisecond)
+
+	renamedBroadcasts := broadcastsOf[*domain.UserRenamedSystemMessage](spyNotifier.Broadcasts())
+	assert.Len(t, renamedBroadcasts, 1)
+	assert.Equal(t, domain.NewUserRenamedSystemMessage("Jane", "Janet", 3, frozenTime), renamedBroadcasts[0].msg)
+	assert.Equal(t, "John", room.GetClient("2").Name())
+	assert.Len(t, spyLogger.Errors(), 3)
+	assert.ElementsMatch(t, []*domain.ErrorSystemMessage{
+		domain.NewErrorSystemMessage(protocol.Rename, protocol.CodeNameTaken, application.ErrNameTaken.Error()),
+		domain.NewErrorSystemMessage(protocol.Rename, protocol.CodeInvalidName, domain.ErrNameHasWhitespace.Error()),
+		domain.NewErrorSystemMessage(protocol.Rename, protocol.CodeInvalidName, domain.ErrNameHasControlCharacter.Error()),
+	}, spyNotifier.rejections("2"))
+	assert.Empty(t, spyNotifier.rejections("1"))
+}
+
+func TestChatService_Rooms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	general := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	random := application.NewChatRoom("random", "random", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(general, random), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+
+	assert.True(t, chatService.HasRoom(""))
+	assert.True(t, chatService.HasRoom("random"))
+	assert.False(t, chatService.HasRoom("missing"))
+
+	chatService.Start(ctx)
+
+	require.NoError(t, chatService.EnterRoom("1", "Jane", "", "", false))
+	require.NoError(t, chatService.EnterRoom("2", "Jane", "", "random", false))
+	err := chatService.EnterRoom("3", "John", "", "missing", false)
+	assert.ErrorIs(t, err, application.ErrUnknownRoom)
+	assert.EqualError(t, err, "no such room: missing")
+	assert.Equal(t, protocol.CodeNotFound, application.ErrorCodeOf(err))
+
+	// the client is in the room once EnterRoom returns
+	chatService.SendMessage("2", "", "hello random")
+	chatService.ListRooms("1")
+
+	time.Sleep(50 * time.Millisecond)
+
+	// the same name may be used in different rooms
+	assert.Equal(t, "Jane", general.GetClient("1").Name())
+	assert.Equal(t, "Jane", random.GetClient("2").Name())
+	assert.Len(t, spyLogger.Errors(), 1)
+	assert.Empty(t, spyNotifier.rejections("3"))
+
+	chatBroadcasts := broadcastsOf[*domain.UserMessage](spyNotifier.Broadcasts())
+	assert.Len(t, chatBroadcasts, 1)
+	assert.Equal(t, random, chatBroadcasts[0].room)
+
+	roomList := spyNotifier.Direct()[len(spyNotifier.Direct())-1]
+	assert.Equal(t, "1", roomList.clientId)
+	assert.Equal(t, domain.NewRoomListSystemMessage([]domain.RoomSummary{
+		{Id: "general", Name: "general", Members: 1},
+		{Id: "random", Name: "random", Members: 1},
+	}), roomList.msg)
+}
+
+func TestChatService_EnterRoom_NameTaken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+	chatService.Start(ctx)
+
+	require.NoError(t, chatService.EnterRoom("1", "Jane", "", "", false))
+	err := chatService.EnterRoom("2", "Jane", "", "", false)
+
+	assert.ErrorIs(t, err, application.ErrNameTaken)
+	assert.Equal(t, protocol.CodeNameTaken, application.ErrorCodeOf(err))
+	assert.Nil(t, room.GetClient("2"))
+	assert.Len(t, room.GetClients(), 1)
+	assert.Len(t, broadcastsOf[*domain.UserJoinedSystemMessage](spyNotifier.Broadcasts()), 1)
+}
+
+func TestChatService_EnterRoom_Moderator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	general := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	general.AddModerator("Jane", "secret")
+	random := application.NewChatRoom("random", "random", application.NewClientRegistry())
+	random.AddModerator("Jane", "secret")
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(general, random), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+	chatService.Start(ctx)
+
+	require.NoError(t, chatService.EnterRoom("1", "Jane", "secret", "general", false))
+	// the name alone does not make a moderator
+	require.NoError(t, chatService.EnterRoom("2", "Jane", "guess", "random", false))
+
+	assert.Equal(t, domain.RoleModerator, general.GetClient("1").Role())
+	assert.Equal(t, domain.RoleMember, random.GetClient("2").Role())
+
+	chatService.ManageWebhooks("2", &domain.ListWebhooksMessage{})
+	require.Eventually(t, func() bool { return len(spyNotifier.rejections("2")) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, protocol.CodeForbidden, spyNotifier.rejections("2")[0].Code)
+}
+
+func TestChatService_SearchMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	general := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	random := application.NewChatRoom("random", "random", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(general, random), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+
+	chatService.Start(ctx)
+
+	chatService.EnterRoom("1", "Jane", "", "general", false)
+	chatService.EnterRoom("2", "John", "", "random", false)
+
+	time.Sleep(20 * time.Millisecond)
+
+	chatService.SendMessage("1", "", "the deploy failed")
+	chatService.SendMessage("2", "", "the deploy failed in random")
+
+	time.Sleep(20 * time.Millisecond)
+
+	chatService.SearchMessages("1", &domain.SearchMessage{Terms: "Deploy"})
+
+	time.Sleep(50 * time.Millisecond)
+
+	chatBroadcasts := broadcastsOf[*domain.UserMessage](spyNotifier.Broadcasts())
+	require.Len(t, chatBroadcasts, 2)
+	sent := chatBroadcasts[0].msg.(*domain.UserMessage)
+	assert.NotEmpty(t, sent.Id)
+
+	results := spyNotifier.Direct()[len(spyNotifier.Direct())-1]
+	assert.Equal(t, "1", results.clientId)
+	assert.Equal(t, &domain.SearchResultsSystemMessage{
+		Terms:   "Deploy",
+		Total:   1,
+		Results: []domain.UserMessage{*sent},
+	}, results.msg)
+}
+
+func TestChatService_ManageWebhooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	room.AddModerator("Jane", "jane-token")
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+	tokens := []string{"first", "second"}
+	webhooks := application.NewWebhookRegistry(func() string {
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token
+	})
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), webhooks, application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+	chatService.Start(ctx)
+
+	chatService.EnterRoom("1", "Jane", "jane-token", "", false)
+	chatService.EnterRoom("2", "John", "", "", false)
+	time.Sleep(20 * time.Millisecond)
+
+	chatService.ManageWebhooks("2", &domain.CreateWebhookMessage{Name: "sneaky"})
+	chatService.ManageWebhooks("1", &domain.CreateWebhookMessage{Name: "has space"})
+	chatService.ManageWebhooks("1", &domain.CreateWebhookMessage{Name: "ci"})
+	chatService.ManageWebhooks("1", &domain.CreateWebhookMessage{Name: "alerts"})
+	chatService.ManageWebhooks("1", &domain.RevokeWebhookMessage{Token: "first"})
+	time.Sleep(50 * time.Millisecond)
+
+	lists := make([]*domain.WebhookListSystemMessage, 0)
+	for _, direct := range spyNotifier.Direct() {
+		if list, ok := direct.msg.(*domain.WebhookListSystemMessage); ok {
+			assert.Equal(t, "1", direct.clientId)
+			lists = append(lists, list)
+		}
+	}
+	require.Len(t, lists, 3)
+	ci := domain.Webhook{Token: "first", RoomId: "general", Name: "ci", CreatedBy: "Jane", CreatedAt: frozenTime}
+	alerts := domain.Webhook{Token: "second", RoomId: "general", Name: "alerts", CreatedBy: "Jane", CreatedAt: frozenTime}
+	assert.Equal(t, []domain.Webhook{ci}, lists[0].Webhooks)
+	assert.ElementsMatch(t, []domain.Webhook{ci, alerts}, lists[1].Webhooks)
+	assert.Equal(t, []domain.Webhook{alerts}, lists[2].Webhooks)
+
+	assert.Len(t, spyLogger.Errors(), 2)
+	assert.Equal(t, []domain.Webhook{alerts}, webhooks.ForRoom("general"))
+	assert.Equal(t, []*domain.ErrorSystemMessage{
+		domain.NewErrorSystemMessage(protocol.CreateWebhook, protocol.CodeForbidden, application.ErrForbidden.Error()),
+	}, spyNotifier.rejections("2"))
+	assert.Equal(t, []*domain.ErrorSystemMessage{
+		domain.NewErrorSystemMessage(protocol.CreateWebhook, protocol.CodeInvalidName, domain.ErrNameHasWhitespace.Error()),
+	}, spyNotifier.rejections("1"))
+}
+
+func TestChatService_PostToWebhook(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+	webhooks := application.NewWebhookRegistry(func() string { return "secret" })
+	webhooks.Create("general", "ci", "Jane", frozenTime)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), webhooks, application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, &SpyDispatcher{}), &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+	room.LetClientIn(domain.NewClient("1", "Jane"))
+	chatService.Start(ctx)
+
+	attachments := []domain.Attachment{{Title: "build #12", Url: "https://ci.example/12"}}
+	require.NoError(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: "@Jane the build failed", Attachments: attachments}))
+	require.NoError(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: "deployed", Username: "deployer"}))
+	require.NoError(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: "\x1b[2Jcleared"}))
+
+	assert.ErrorIs(t, chatService.PostToWebhook("guess", domain.WebhookPayload{Text: "hi"}), domain.ErrUnknownWebhook)
+	assert.ErrorIs(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: " "}), domain.ErrEmptyWebhookMessage)
+	assert.ErrorIs(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: "hi", Username: "two words"}), domain.ErrNameHasWhitespace)
+	assert.ErrorIs(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Text: "hi", Username: "ci\x1b[0m"}), domain.ErrNameHasControlCharacter)
+	assert.ErrorIs(t, chatService.PostToWebhook("secret", domain.WebhookPayload{Attachments: []domain.Attachment{{}}}), domain.ErrEmptyAttachment)
+
+	time.Sleep(50 * time.Millisecond)
+
+	chatBroadcasts := broadcastsOf[*domain.UserMessage](spyNotifier.Broadcasts())
+	require.Len(t, chatBroadcasts, 3)
+	failed := chatBroadcasts[0].msg.(*domain.UserMessage)
+	assert.Equal(t, "ci", failed.From)
+	assert.True(t, failed.Webhook)
+	assert.Equal(t, attachments, failed.Attachments)
+	assert.Equal(t, []domain.Mention{{Name: "Jane", Start: 0, End: 5}}, failed.Mentions)
+	assert.NotEmpty(t, failed.Id)
+	assert.Equal(t, "deployer", chatBroadcasts[1].msg.(*domain.UserMessage).From)
+	assert.Equal(t, "[2Jcleared", chatBroadcasts[2].msg.(*domain.UserMessage).Text)
+}
+
+func TestChatService_EventSubscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	room := application.NewChatRoom("general", "general", application.NewClientRegistry())
+	room.AddModerator("Jane", "jane-token")
+	frozenTime := time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC)
+	dispatcher := &SpyDispatcher{}
+	subscriptions := application.NewEventSubscriptions(application.UUIDGen, func() string { return "secret" }, dispatcher)
+
+	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
+	spyNotifier := SpyNotifier{broadcasts: make([]Broadcast, 0)}
+	chatService := application.NewChatService(application.NewRoomRepository(room), application.NewInMemoryMessageStore(100), application.NewWebhookRegistry(application.RandomToken), subscriptions, &spyNotifier, func() time.Time { return frozenTime }, application.PresenceConfiguration{}, 8, 8, &spyLogger)
+	chatService.Start(ctx)
+
+	chatService.EnterRoom("1", "Jane", "jane-token", "", false)
+	time.Sleep(20 * time.Millisecond)
+
+	chatService.ManageEventSubscriptions("1", &domain.SubscribeEventsMessage{Url: "ftp://example.com", Events: []domain.EventKind{domain.EventUserJoined}})
+	chatService.ManageEventSubscriptions("1", &domain.SubscribeEventsMessage{
+		Url:      "https://203.0.113.10/events",
+		Events:   []domain.EventKind{domain.EventUserJoined, domain.EventUserLeft, domain.EventKeywordMentioned},
+		Keywords: []string{"deploy"},
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	chatService.EnterRoom("2", "John", "", "", false)
+	time.Sleep(20 * time.Millisecond)
+	chatService.ManageEventSubscriptions("2", &domain.ListEventSubscriptionsMessage{})
+	chatService.SendMessage("2", "", "the Deploy failed")
+	chatService.SendMessage("2", "", "deployment is done")
+	time.Sleep(20 * time.Millisecond)
+	chatService.LeaveRoom("2")
+	time.Sleep(20 * time.Millisecond)
+
+	require.Len(t, subscriptions.ForRoom("general"), 1)
+	subscription := domain.EventSubscription{
+		Id:        subscriptions.ForRoom("general")[0].Id,
+		RoomId:    "general",
+		Url:       "https://203.0.113.10/events",
+		Events:    []domain.EventKind{domain.EventUserJoined, domain.EventUserLeft, domain.EventKeywordMentioned},
+		Keywords:  []string{"deploy"},
+		Secret:    "secret",
+		CreatedBy: "Jane",
+		CreatedAt: frozenTime,
+	}
+	assert.Equal(t, []domain.EventSubscription{subscription}, subscriptions.ForRoom("general"))
+	assert.Len(t, spyLogger.Errors(), 2)
+
+	dispatched := dispatcher.Dispatched()
+	require.Len(t, dispatched, 3)
+	for _, d := range dispatched {
+		assert.Equal(t, subscription, d.subscription)
+		assert.Equal(t, "general", d.event.RoomId)
+	}
+	assert.Equal(t, domain.EventUserJoined, dispatched[0].event.Kind)
+	assert.Equal(t, "John", dispatched[0].event.Name)
+	assert.Equal(t, domain.EventKeywordMentioned, dispatched[1].event.Kind)
+	assert.Equal(t, []string{"deploy"}, dispatched[1].event.Keywords)
+	assert.Equal(t, "the Deploy failed", dispatched[1].event.Message.Text)
+	assert.NotEmpty(t, dispatched[1].event.Message.Id)
+	assert.Equal(t, domain.EventUserLeft, dispatched[2].event.Kind)
+	assert.Equal(t, "John", dispatched[2].event.Name)
+
+	chatService.ManageEventSubscriptions("1", &domain.UnsubscribeEventsMessage{Id: subscription.Id})
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, subscriptions.ForRoom("general"))
+	last := spyNotifier.Direct()[len(spyNotifier.Direct())-1]
+	assert.Equal(t, &domain.EventSubscriptionListSystemMessage{Subscriptions: []domain.EventSubscription{}}, last.msg)
+}
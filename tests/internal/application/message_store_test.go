@@ -0,0 +1,130 @@
+package application_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/application"
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func saveAll(store *application.InMemoryMessageStore, roomId string, messages ...*domain.UserMessage) {
+	for _, msg := range messages {
+		store.Save(roomId, msg)
+	}
+}
+
+func texts(page domain.SearchPage) []string {
+	texts := make([]string, 0, len(page.Results))
+	for _, msg := range page.Results {
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
+
+func TestInMemoryMessageStore_Search(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := application.NewInMemoryMessageStore(100)
+	saveAll(store, "general",
+		domain.NewUserMessage("The deploy failed again", start, "jane"),
+		domain.NewUserMessage("deploy is green now", start.Add(time.Minute), "john"),
+		domain.NewUserMessage("who broke the DEPLOY? it failed", start.Add(2*time.Minute), "john"),
+		domain.NewUserMessage("lunch?", start.Add(3*time.Minute), "jane"),
+	)
+	saveAll(store, "random", domain.NewUserMessage("deploy failed in random", start, "jane"))
+
+	tests := []struct {
+		name     string
+		query    domain.SearchQuery
+		expected []string
+	}{
+		{
+			name:     "every term has to be there, newest first",
+			query:    domain.NewSearchQuery("failed deploy", "", time.Time{}, 0, 0),
+			expected: []string{"who broke the DEPLOY? it failed", "The deploy failed again"},
+		},
+		{
+			name:     "unknown term",
+			query:    domain.NewSearchQuery("deploy rollback", "", time.Time{}, 0, 0),
+			expected: []string{},
+		},
+		{
+			name:     "from is case insensitive",
+			query:    domain.NewSearchQuery("deploy", "JOHN", time.Time{}, 0, 0),
+			expected: []string{"who broke the DEPLOY? it failed", "deploy is green now"},
+		},
+		{
+			name:     "before",
+			query:    domain.NewSearchQuery("deploy", "", start.Add(time.Minute), 0, 0),
+			expected: []string{"The deploy failed again"},
+		},
+		{
+			name:     "filters alone",
+			query:    domain.NewSearchQuery("", "jane", time.Time{}, 0, 0),
+			expected: []string{"lunch?", "The deploy failed again"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := store.Search("general", tt.query)
+
+			assert.Equal(t, tt.expected, texts(page))
+			assert.Equal(t, len(tt.expected), page.Total)
+		})
+	}
+}
+
+func TestInMemoryMessageStore_GivesIds(t *testing.T) {
+	store := application.NewInMemoryMessageStore(100)
+	first := domain.NewUserMessage("hello", time.Now(), "jane")
+	second := domain.NewUserMessage("hello", time.Now(), "jane")
+
+	saveAll(store, "general", first)
+	saveAll(store, "random", second)
+
+	assert.NotEmpty(t, first.Id)
+	assert.NotEqual(t, first.Id, second.Id)
+
+	page := store.Search("general", domain.NewSearchQuery("hello", "", time.Time{}, 0, 0))
+	require.Len(t, page.Results, 1)
+	assert.Equal(t, first.Id, page.Results[0].Id)
+}
+
+func TestInMemoryMessageStore_CountsTheMessagesOfEachRoom(t *testing.T) {
+	store := application.NewInMemoryMessageStore(2)
+
+	assert.Equal(t, uint64(1), store.Save("general", domain.NewUserMessage("one", time.Now(), "jane")))
+	assert.Equal(t, uint64(1), store.Save("random", domain.NewUserMessage("one", time.Now(), "jane")))
+	assert.Equal(t, uint64(2), store.Save("general", domain.NewUserMessage("two", time.Now(), "jane")))
+	// dropping the oldest messages does not change the count
+	assert.Equal(t, uint64(3), store.Save("general", domain.NewUserMessage("three", time.Now(), "jane")))
+}
+
+func TestInMemoryMessageStore_Pages(t *testing.T) {
+	store := application.NewInMemoryMessageStore(100)
+	for i := 0; i < 5; i++ {
+		saveAll(store, "general", domain.NewUserMessage(fmt.Sprintf("ping %d", i), time.Now(), "jane"))
+	}
+
+	page := store.Search("general", domain.NewSearchQuery("ping", "", time.Time{}, 2, 2))
+
+	assert.Equal(t, []string{"ping 2", "ping 1"}, texts(page))
+	assert.Equal(t, 5, page.Total)
+}
+
+func TestInMemoryMessageStore_DropsTheOldest(t *testing.T) {
+	store := application.NewInMemoryMessageStore(2)
+	saveAll(store, "general",
+		domain.NewUserMessage("first ping", time.Now(), "jane"),
+		domain.NewUserMessage("second ping", time.Now(), "jane"),
+		domain.NewUserMessage("third ping", time.Now(), "jane"),
+	)
+
+	assert.Equal(t, []string{"third ping", "second ping"}, texts(store.Search("general", domain.NewSearchQuery("ping", "", time.Time{}, 0, 0))))
+	assert.Empty(t, texts(store.Search("general", domain.NewSearchQuery("first", "", time.Time{}, 0, 0))))
+}
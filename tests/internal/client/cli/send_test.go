@@ -0,0 +1,49 @@
+package cli_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	client := NewFakeChatClient()
+
+	err := cli.Send(client, "build #12 passed")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build #12 passed"}, client.delivered)
+	assert.True(t, client.closed)
+}
+
+func TestSend_EmptyMessage(t *testing.T) {
+	client := NewFakeChatClient()
+
+	err := cli.Send(client, "  ")
+
+	assert.ErrorIs(t, err, cli.ErrEmptyMessage)
+	assert.Empty(t, client.delivered)
+}
+
+func TestSendLines_SkipsBlankLines(t *testing.T) {
+	client := NewFakeChatClient()
+
+	err := cli.SendLines(client, strings.NewReader("first\n\n  \nsecond\nthird"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, client.delivered)
+	assert.True(t, client.closed)
+}
+
+func TestSend_ConnectionFailure(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	client := NewFakeChatClient()
+	client.connectErr = refused
+	assert.ErrorIs(t, cli.Send(client, "hi"), refused)
+	assert.Empty(t, client.delivered)
+}
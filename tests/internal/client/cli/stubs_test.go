@@ -0,0 +1,54 @@
+package cli_test
+
+import (
+	"errors"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+)
+
+// FakeChatClient has the server send the member list first, the way a real one does
+type FakeChatClient struct {
+	connectErr error
+	inbound    chan gchad.Message
+	errors     chan error
+	delivered  []string
+	closed     bool
+}
+
+func NewFakeChatClient(inbound ...gchad.Message) *FakeChatClient {
+	client := &FakeChatClient{
+		inbound: make(chan gchad.Message, len(inbound)+1),
+		errors:  make(chan error, 1),
+	}
+	client.inbound <- gchad.MemberListMessage{Version: 1}
+	for _, msg := range inbound {
+		client.inbound <- msg
+	}
+
+	return client
+}
+
+func (c *FakeChatClient) Connect() error {
+	return c.connectErr
+}
+
+func (c *FakeChatClient) Deliver(message string) error {
+	if c.closed {
+		return errors.New("closed")
+	}
+	c.delivered = append(c.delivered, message)
+	return nil
+}
+
+func (c *FakeChatClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *FakeChatClient) InboundMessages() <-chan gchad.Message {
+	return c.inbound
+}
+
+func (c *FakeChatClient) Errors() <-chan error {
+	return c.errors
+}
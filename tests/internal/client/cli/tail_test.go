@@ -0,0 +1,86 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/client/cli"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var lost = errors.New("connection reset")
+
+func feed() []gchad.Message {
+	at := time.Date(2026, 10, 19, 9, 30, 0, 0, time.Local)
+
+	return []gchad.Message{
+		gchad.UserJoinedMessage{Timestamp: at, Name: "ci", Version: 2},
+		gchad.ChatMessage{Id: "7", From: "ci", Timestamp: at, Text: "build #12 failed\nsee the logs", Webhook: true, Attachments: []gchad.Attachment{{Title: "build #12", Url: "https://ci.example/12"}}},
+		gchad.PresenceMessage{Timestamp: at, Name: "ci", Presence: gchad.PresenceAway, Version: 3},
+		gchad.UserRenamedMessage{Timestamp: at, OldName: "ci", NewName: "ci-bot", Version: 4},
+		gchad.UserLeftMessage{Timestamp: at, Name: "ci-bot", Version: 5},
+	}
+}
+
+// tail runs until the feed is exhausted, the fake connection dropping right after it
+func tail(t *testing.T, format cli.Format) string {
+	client := NewFakeChatClient(feed()...)
+	var out bytes.Buffer
+
+	go func() {
+		for len(client.inbound) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		client.errors <- lost
+	}()
+	err := cli.Tail(context.Background(), client, &out, format)
+	require.ErrorIs(t, err, lost)
+
+	return out.String()
+}
+
+func TestTail_Text(t *testing.T) {
+	expected := "2026-10-19 09:30:00 * ci joined\n" +
+		"2026-10-19 09:30:00 ci: build #12 failed\n\tsee the logs\n\t> build #12 https://ci.example/12\n" +
+		"2026-10-19 09:30:00 * ci is now ci-bot\n" +
+		"2026-10-19 09:30:00 * ci-bot left\n"
+
+	assert.Equal(t, expected, tail(t, cli.FormatText))
+}
+
+func TestTail_JSON(t *testing.T) {
+	lines := bytes.Split(bytes.TrimSpace([]byte(tail(t, cli.FormatJSON))), []byte("\n"))
+
+	require.Len(t, lines, 4)
+	assert.Contains(t, string(lines[0]), `{"type":"user_joined","payload":{`)
+	assert.Contains(t, string(lines[1]), `"type":"chat"`)
+	assert.Contains(t, string(lines[1]), `"text":"build #12 failed\nsee the logs"`)
+	assert.Contains(t, string(lines[2]), `"type":"user_renamed"`)
+	assert.Contains(t, string(lines[3]), `"type":"user_left"`)
+}
+
+func TestTail_StopsWithTheContext(t *testing.T) {
+	client := NewFakeChatClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := cli.Tail(ctx, client, &bytes.Buffer{}, cli.FormatText)
+
+	require.NoError(t, err)
+	assert.True(t, client.closed)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := cli.ParseFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, cli.FormatJSON, format)
+
+	_, err = cli.ParseFormat("xml")
+	assert.Error(t, err)
+}
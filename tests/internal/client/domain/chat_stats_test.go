@@ -0,0 +1,31 @@
+package domain_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatStats_SnapshotWhileCounting(t *testing.T) {
+	stats := domain.NewChatStats()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			stats.IncrementReceived()
+			stats.IncrementSent()
+			stats.ResetClients(i)
+		}
+	}()
+	for range 100 {
+		snapshot := stats.Snapshot()
+		assert.LessOrEqual(t, snapshot.MessagesSent, snapshot.MessagesReceived)
+	}
+	wg.Wait()
+
+	assert.Equal(t, &domain.ChatStats{MessagesReceived: 100, MessagesSent: 100, ClientsInTheRoom: 99}, stats.Snapshot())
+}
@@ -0,0 +1,57 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearch(t *testing.T) {
+	before := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected gchad.SearchMessage
+	}{
+		{
+			name:     "terms only",
+			input:    "deploy  failed",
+			expected: gchad.SearchMessage{Terms: "deploy failed"},
+		},
+		{
+			name:     "filters among the terms",
+			input:    "from:jane deploy before:2026-03-01 failed",
+			expected: gchad.SearchMessage{Terms: "deploy failed", From: "jane", Before: &before},
+		},
+		{
+			name:     "filters alone",
+			input:    "from:jane",
+			expected: gchad.SearchMessage{From: "jane"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := domain.ParseSearch(tt.input)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, request)
+		})
+	}
+}
+
+func TestParseSearch_Errors(t *testing.T) {
+	_, err := domain.ParseSearch("  ")
+	assert.ErrorIs(t, err, domain.ErrEmptySearch)
+
+	_, err = domain.ParseSearch("?! ...")
+	assert.ErrorIs(t, err, domain.ErrEmptySearch)
+
+	_, err = domain.ParseSearch("deploy before:yesterday")
+	assert.Error(t, err)
+}
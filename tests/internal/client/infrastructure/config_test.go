@@ -0,0 +1,53 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/infrastructure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_MissingFileIsEmpty(t *testing.T) {
+	config, err := infrastructure.LoadConfig(filepath.Join(t.TempDir(), "gchad", "config.json"))
+
+	require.NoError(t, err)
+	assert.Equal(t, infrastructure.Config{}, config)
+}
+
+func TestLoadConfig_Keys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys": {"chat": {"find": ["ctrl+f"]}}}`), 0600))
+
+	config, err := infrastructure.LoadConfig(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string][]string{"chat": {"find": {"ctrl+f"}}}, config.Keys)
+}
+
+func TestLoadConfig_RejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"kyes": {}}`), 0600))
+
+	_, err := infrastructure.LoadConfig(path)
+
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_Themes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"theme": "mine",
+		"themes": {"mine": {"base": "latte", "colors": {"mauve": "#8839ef"}}}
+	}`), 0600))
+
+	config, err := infrastructure.LoadConfig(path)
+
+	require.NoError(t, err)
+	assert.Equal(t, "mine", config.Theme)
+	assert.Equal(t, map[string]infrastructure.ThemeConfig{
+		"mine": {Base: "latte", Colors: map[string]string{"mauve": "#8839ef"}},
+	}, config.Themes)
+}
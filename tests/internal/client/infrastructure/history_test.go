@@ -0,0 +1,37 @@
+package infrastructure_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/infrastructure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHistory_MissingFileIsEmpty(t *testing.T) {
+	history := infrastructure.NewFileHistory(filepath.Join(t.TempDir(), "gchad", "history"), 10)
+
+	entries, err := history.Load()
+
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFileHistory_AppendAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gchad", "history")
+	history := infrastructure.NewFileHistory(path, 2)
+
+	require.NoError(t, history.Append("first"))
+	require.NoError(t, history.Append("second\nwith a newline"))
+	require.NoError(t, history.Append("third"))
+
+	entries, err := history.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second\nwith a newline", "third"}, entries)
+
+	// the file has been trimmed down to the limit
+	entries, err = infrastructure.NewFileHistory(path, 10).Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second\nwith a newline", "third"}, entries)
+}
@@ -0,0 +1,57 @@
+package infrastructure_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/internal/client/infrastructure"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTranscript_AppendAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcripts", "localhost_8080", "general.jsonl")
+	transcript := infrastructure.NewFileTranscript(path)
+
+	entries, err := transcript.Load()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	timestamp := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	chat := domain.TranscriptEntry{
+		Timestamp: timestamp,
+		From:      "jane",
+		Text:      "hi @john\nhow are you?",
+		Mentions:  []gchad.Mention{{Name: "john", Start: 3, End: 8}},
+	}
+	joined := domain.TranscriptEntry{Timestamp: timestamp, Text: "john joined!", System: true}
+	require.NoError(t, transcript.Append(chat))
+	require.NoError(t, transcript.Append(joined))
+
+	entries, err = infrastructure.NewFileTranscript(path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, []domain.TranscriptEntry{chat, joined}, entries)
+}
+
+func TestFileTranscript_SkipsMangledLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "general.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"text\":\"kept\"}\n{\"text\":\n"), 0o600))
+
+	entries, err := infrastructure.NewFileTranscript(path).Load()
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.TranscriptEntry{{Text: "kept"}}, entries)
+}
+
+func TestDefaultTranscriptPath_PerServerAndRoom(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+
+	path, err := infrastructure.DefaultTranscriptPath("localhost:8080", "../general")
+
+	require.NoError(t, err)
+	assert.Equal(t, "/data/gchad/transcripts/localhost_8080/_general.jsonl", path)
+}
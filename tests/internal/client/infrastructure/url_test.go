@@ -0,0 +1,19 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/infrastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUrl_WithQueryParam(t *testing.T) {
+	url := infrastructure.NewUrl("ws", "localhost", "chat", 8080, infrastructure.NewQueryParam("name", ""))
+
+	withRoom := url.WithQueryParam("room", "off topic").WithQueryParam("name", "jane")
+
+	assert.Equal(t, "ws://localhost:8080/chat?name=", url.String())
+	assert.Equal(t, "ws://localhost:8080/chat?name=jane&room=off+topic", withRoom.String())
+	assert.Equal(t, "off topic", withRoom.QueryParam("room"))
+	assert.Equal(t, "", url.QueryParam("room"))
+}
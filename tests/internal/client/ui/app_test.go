@@ -0,0 +1,342 @@
+package ui_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Send is a chat message the UI is sending, which waits for the test to answer it as the server would
+type Send struct {
+	Text  string
+	reply chan sendResult
+}
+
+type sendResult struct {
+	ack gchad.AckMessage
+	err error
+}
+
+func (s Send) Ack(ack gchad.AckMessage) {
+	s.reply <- sendResult{ack: ack}
+}
+
+func (s Send) Fail(err error) {
+	s.reply <- sendResult{err: err}
+}
+
+// FakeChatClient is connected as soon as asked, hands the UI whatever the test receives and has
+// every chat message wait for the test to answer it
+type FakeChatClient struct {
+	mu      sync.Mutex
+	name    string
+	room    string
+	members []gchad.Member
+	inbound chan gchad.Message
+	errors  chan error
+	sends   chan Send
+}
+
+func NewFakeChatClient() *FakeChatClient {
+	return NewFakeRoomClient("general")
+}
+
+func NewFakeRoomClient(room string) *FakeChatClient {
+	return &FakeChatClient{
+		room:    room,
+		inbound: make(chan gchad.Message, 16),
+		errors:  make(chan error, 1),
+		sends:   make(chan Send, 16),
+	}
+}
+
+// Receive hands the message to the UI as if the server sent it
+func (f *FakeChatClient) Receive(msg gchad.Message) {
+	f.inbound <- msg
+}
+
+// NextSend is the chat message the UI sends next
+func (f *FakeChatClient) NextSend(t *testing.T) Send {
+	t.Helper()
+
+	select {
+	case send := <-f.sends:
+		return send
+	case <-time.After(time.Second):
+		t.Fatal("nothing was sent")
+		return Send{}
+	}
+}
+
+func (f *FakeChatClient) Connect() error    { return nil }
+func (f *FakeChatClient) Disconnect() error { return nil }
+
+func (f *FakeChatClient) SendMessage(ctx context.Context, message string) (gchad.AckMessage, error) {
+	send := Send{Text: message, reply: make(chan sendResult, 1)}
+	f.sends <- send
+
+	select {
+	case result := <-send.reply:
+		return result.ack, result.err
+	case <-ctx.Done():
+		return gchad.AckMessage{}, ctx.Err()
+	}
+}
+
+func (f *FakeChatClient) SetPresence(gchad.Presence, string)           {}
+func (f *FakeChatClient) ReportActivity()                              {}
+func (f *FakeChatClient) Rename(string)                                {}
+func (f *FakeChatClient) ListRooms()                                   {}
+func (f *FakeChatClient) Search(gchad.SearchMessage)                   {}
+func (f *FakeChatClient) CreateWebhook(string)                         {}
+func (f *FakeChatClient) RevokeWebhook(string)                         {}
+func (f *FakeChatClient) ListWebhooks()                                {}
+func (f *FakeChatClient) WebhookUrl(token string) string               { return token }
+func (f *FakeChatClient) SubscribeEvents(gchad.SubscribeEventsMessage) {}
+func (f *FakeChatClient) UnsubscribeEvents(string)                     {}
+func (f *FakeChatClient) ListEventSubscriptions()                      {}
+func (f *FakeChatClient) InboundMessages() <-chan gchad.Message        { return f.inbound }
+func (f *FakeChatClient) Errors() <-chan error                         { return f.errors }
+func (f *FakeChatClient) Host() string                                 { return "ws://localhost:8080/chat" }
+func (f *FakeChatClient) Room() string                                 { return f.room }
+func (f *FakeChatClient) Stats() *domain.ChatStats                     { return domain.NewChatStats() }
+
+func (f *FakeChatClient) SetName(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.name = name
+}
+
+func (f *FakeChatClient) Name() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.name
+}
+
+// SetMembers is who the client counts as being in the room from now on
+func (f *FakeChatClient) SetMembers(members []gchad.Member) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.members = members
+}
+
+func (f *FakeChatClient) Members() []gchad.Member {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.members
+}
+
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(string, string) {}
+
+// Program drives a model the way the bubbletea runtime does, running the commands it returns in
+// the background and handing their messages back to it when the test waits for something
+type Program struct {
+	t     *testing.T
+	model tea.Model
+	msgs  chan tea.Msg
+}
+
+func NewProgram(t *testing.T, model tea.Model) *Program {
+	return &Program{t: t, model: model, msgs: make(chan tea.Msg, 64)}
+}
+
+func (p *Program) Send(msg tea.Msg) {
+	model, cmd := p.model.Update(msg)
+	p.model = model
+	p.run(cmd)
+}
+
+func (p *Program) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+
+	go func() {
+		switch msg := cmd().(type) {
+		case nil:
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				p.run(cmd)
+			}
+		default:
+			p.msgs <- msg
+		}
+	}()
+}
+
+// Type enters the text and presses enter
+func (p *Program) Type(text string) {
+	p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	p.Send(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// View is what the model shows, without the styling
+func (p *Program) View() string {
+	return ansi.Strip(p.model.View())
+}
+
+// Until hands the model the messages of its commands until what it shows satisfies the condition
+func (p *Program) Until(condition func(view string) bool, why string) {
+	p.t.Helper()
+
+	timeout := time.After(time.Second)
+	for !condition(p.View()) {
+		select {
+		case msg := <-p.msgs:
+			p.Send(msg)
+		case <-timeout:
+			p.t.Fatalf("%s, the view is:\n%s", why, p.View())
+		}
+	}
+}
+
+// UntilShows waits for the text to be shown
+func (p *Program) UntilShows(text string) {
+	p.t.Helper()
+	p.Until(func(view string) bool { return strings.Contains(view, text) }, "never showed "+text)
+}
+
+// chatSetup is what the chat is made with besides the defaults
+type chatSetup struct {
+	transcript     ui.TranscriptStore
+	bannerDuration time.Duration
+	roomClients    RoomClients
+	notifier       ui.DesktopNotifier
+}
+
+// RoomClients are the clients of the chats the app opened, by room
+type RoomClients map[string]*FakeChatClient
+
+type chatOption func(*chatSetup)
+
+func withTranscript(transcript ui.TranscriptStore) chatOption {
+	return func(s *chatSetup) { s.transcript = transcript }
+}
+
+func withBannerDuration(d time.Duration) chatOption {
+	return func(s *chatSetup) { s.bannerDuration = d }
+}
+
+func withNotifier(notifier ui.DesktopNotifier) chatOption {
+	return func(s *chatSetup) { s.notifier = notifier }
+}
+
+func withRoomClients(clients RoomClients) chatOption {
+	return func(s *chatSetup) { s.roomClients = clients }
+}
+
+func newChat(keymaps ui.Keymaps, client *FakeChatClient, setup chatSetup) ui.Chat {
+	chat := ui.InitialChatModel(keymaps.Chat, client, ui.NewMessageRingBuffer(100), setup.notifier, ui.NewInputHistory(&MemoryHistoryStore{}), setup.transcript)
+	if setup.bannerDuration > 0 {
+		chat = chat.WithBannerDuration(setup.bannerDuration)
+	}
+
+	return chat
+}
+
+// loggedIn is the app logged into the room as jane, the chat screen being width by height
+func loggedIn(t *testing.T, width int, height int, options ...chatOption) (*Program, *FakeChatClient) {
+	setup := chatSetup{roomClients: RoomClients{}, notifier: NopNotifier{}}
+	for _, option := range options {
+		option(&setup)
+	}
+
+	client := NewFakeChatClient()
+	keymaps := ui.DefaultKeymaps()
+	openRoom := func(room string) ui.Chat {
+		opened := NewFakeRoomClient(room)
+		setup.roomClients[room] = opened
+		return newChat(keymaps, opened, setup)
+	}
+	app := ui.InitialAppModel(ui.InitialLoginModel("Who are you?", keymaps.Login, client), newChat(keymaps, client, setup), openRoom, keymaps.App)
+
+	program := NewProgram(t, app)
+	program.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	program.Type("jane")
+	program.UntilShows(client.Host())
+	require.Equal(t, "jane", client.Name())
+
+	return program, client
+}
+
+// openRoom picks the room from the room picker, waiting for its tab to be opened
+func openRoom(program *Program, client *FakeChatClient, room string) {
+	program.t.Helper()
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlO})
+	client.Receive(gchad.RoomListMessage{Rooms: []gchad.RoomSummary{
+		{Id: "general", Name: "general", Members: 1},
+		{Id: room, Name: room, Members: 0},
+	}})
+	program.UntilShows(room)
+	program.Send(tea.KeyMsg{Type: tea.KeyDown})
+	program.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	program.UntilShows("#" + room)
+}
+
+func TestTabs_OpenAndSwitch(t *testing.T) {
+	rooms := RoomClients{}
+	program, client := loggedIn(t, 80, 20, withRoomClients(rooms))
+
+	openRoom(program, client, "random")
+	assert.Contains(t, program.View(), "1 general")
+	assert.Contains(t, program.View(), "2 random")
+	assert.Equal(t, "jane", rooms["random"].Name())
+
+	// what arrives in the room in the background is counted on its tab
+	client.Receive(gchad.ChatMessage{From: "bob", Text: "over in general", Timestamp: time.Now()})
+	program.UntilShows("1 general (1)")
+	assert.NotContains(t, program.View(), "over in general")
+
+	program.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1"), Alt: true})
+	assert.Contains(t, program.View(), "#general")
+	assert.Contains(t, program.View(), "over in general")
+	assert.NotContains(t, program.View(), "(1)")
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlN})
+	assert.Contains(t, program.View(), "#random")
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlP})
+	assert.Contains(t, program.View(), "#general")
+}
+
+func TestTabs_OpenedUnderTheNameRenamedTo(t *testing.T) {
+	rooms := RoomClients{}
+	program, client := loggedIn(t, 80, 20, withRoomClients(rooms))
+
+	client.Receive(gchad.UserRenamedMessage{OldName: "jane", NewName: "janet", Timestamp: time.Now()})
+	program.UntilShows("janet #general")
+
+	openRoom(program, client, "random")
+	assert.Equal(t, "janet", rooms["random"].Name())
+}
+
+func TestTabs_ClosingTheLastGoesBackToLogin(t *testing.T) {
+	rooms := RoomClients{}
+	program, client := loggedIn(t, 80, 20, withRoomClients(rooms))
+	say(program, client, "said before leaving")
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlD})
+	program.UntilShows("Username")
+
+	// logging in again joins the room with a fresh chat
+	program.Type("jane")
+	program.UntilShows("jane #general")
+	require.Contains(t, rooms, "general")
+	assert.Equal(t, "jane", rooms["general"].Name())
+	assert.NotContains(t, program.View(), "said before leaving")
+
+	rooms["general"].Receive(gchad.ChatMessage{From: "bob", Text: "welcome back", Timestamp: time.Now()})
+	program.UntilShows("welcome back")
+}
@@ -0,0 +1,82 @@
+package ui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// untilGone waits for the text to be taken off the screen
+func untilGone(program *Program, text string) {
+	program.t.Helper()
+	program.Until(func(view string) bool { return !strings.Contains(view, text) }, "still showing "+text)
+}
+
+func TestBanner_ShowsWhatTheServerTurnedDown(t *testing.T) {
+	tests := []struct {
+		name      string
+		rejection gchad.ErrorMessage
+		banner    string
+	}{
+		{
+			name:      "request",
+			rejection: gchad.ErrorMessage{Code: protocol.CodeNameTaken, Message: "the name is taken", Request: protocol.Rename},
+			banner:    "the server turned down rename: the name is taken",
+		},
+		{
+			name:      "unreadable message",
+			rejection: gchad.ErrorMessage{Code: protocol.CodeMalformedMessage, Message: "malformed message"},
+			banner:    "the server turned down a message: malformed message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, client := loggedIn(t, 120, 30, withBannerDuration(50*time.Millisecond))
+
+			client.Receive(tt.rejection)
+			program.UntilShows(tt.banner)
+
+			untilGone(program, tt.banner)
+		})
+	}
+}
+
+func TestBanner_ReplacesTheOneUp(t *testing.T) {
+	const duration = 300 * time.Millisecond
+	program, client := loggedIn(t, 120, 30, withBannerDuration(duration))
+
+	client.Receive(gchad.ErrorMessage{Code: protocol.CodeBusy, Message: "first", Request: protocol.Search})
+	program.UntilShows("search: first")
+	time.Sleep(duration / 2)
+
+	replaced := time.Now()
+	client.Receive(gchad.ErrorMessage{Code: protocol.CodeBusy, Message: "second", Request: protocol.Search})
+	program.UntilShows("search: second")
+	assert.NotContains(t, program.View(), "search: first")
+
+	// the first banner expiring leaves the second one up for as long as it is due
+	untilGone(program, "search: second")
+	assert.GreaterOrEqual(t, time.Since(replaced), duration)
+}
+
+func TestBanner_TakesThePlaceOfTheNewMessagesLine(t *testing.T) {
+	program, client := loggedIn(t, 120, 20, withBannerDuration(50*time.Millisecond))
+	receiveLines(program, client, 1, 30)
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("g"))
+	client.Receive(numberedLine(31))
+	program.UntilShows("1 new message ↓")
+
+	client.Receive(gchad.ErrorMessage{Code: protocol.CodeBusy, Message: "slow down", Request: protocol.Search})
+	program.UntilShows("search: slow down")
+	assert.NotContains(t, program.View(), "new message")
+
+	untilGone(program, "search: slow down")
+	assert.Contains(t, program.View(), "1 new message ↓")
+}
@@ -0,0 +1,75 @@
+package ui_test
+
+import (
+	"testing"
+
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MemoryHistoryStore struct {
+	entries []string
+}
+
+func (s *MemoryHistoryStore) Load() ([]string, error) {
+	return s.entries, nil
+}
+
+func (s *MemoryHistoryStore) Append(entry string) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestInputHistory_PreviousNextKeepsDraft(t *testing.T) {
+	history := ui.NewInputHistory(&MemoryHistoryStore{entries: []string{"one", "two"}})
+	require.NoError(t, history.Load())
+
+	entry, ok := history.Previous("draft")
+	assert.True(t, ok)
+	assert.Equal(t, "two", entry)
+
+	entry, _ = history.Previous("two")
+	assert.Equal(t, "one", entry)
+
+	_, ok = history.Previous("one")
+	assert.False(t, ok)
+
+	entry, _ = history.Next()
+	assert.Equal(t, "two", entry)
+
+	entry, ok = history.Next()
+	assert.True(t, ok)
+	assert.Equal(t, "draft", entry)
+
+	_, ok = history.Next()
+	assert.False(t, ok)
+}
+
+func TestInputHistory_AddSkipsRepeats(t *testing.T) {
+	store := &MemoryHistoryStore{}
+	history := ui.NewInputHistory(store)
+
+	require.NoError(t, history.Add("hello"))
+	require.NoError(t, history.Add("hello"))
+	require.NoError(t, history.Add("world"))
+
+	assert.Equal(t, []string{"hello", "world"}, store.entries)
+	assert.Equal(t, 2, history.Len())
+}
+
+func TestInputHistory_Search(t *testing.T) {
+	history := ui.NewInputHistory(&MemoryHistoryStore{entries: []string{"deploy staging", "hello", "deploy prod"}})
+	require.NoError(t, history.Load())
+
+	match, position, found := history.Search("deploy", history.Len())
+	assert.True(t, found)
+	assert.Equal(t, "deploy prod", match)
+
+	match, position, found = history.Search("deploy", position)
+	assert.True(t, found)
+	assert.Equal(t, "deploy staging", match)
+
+	_, _, found = history.Search("deploy", position)
+	assert.False(t, found)
+}
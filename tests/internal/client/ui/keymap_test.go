@@ -0,0 +1,80 @@
+package ui_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeymaps_DefaultsDoNotConflict(t *testing.T) {
+	_, err := ui.DefaultKeymaps().Override(nil)
+
+	assert.NoError(t, err)
+}
+
+func TestKeymaps_Override(t *testing.T) {
+	keymaps, err := ui.DefaultKeymaps().Override(ui.KeymapOverrides{
+		"chat": {"find": {"ctrl+f", "F"}, "page_down": {"pgdown"}, "help": {}},
+		"app":  {"room_1": {"f1"}},
+	})
+	require.NoError(t, err)
+
+	ctrlF := tea.KeyMsg{Type: tea.KeyCtrlF}
+	assert.True(t, key.Matches(ctrlF, keymaps.Chat.Find))
+	assert.False(t, key.Matches(ctrlF, keymaps.Chat.PageDown))
+	assert.Equal(t, "ctrl+f/F", keymaps.Chat.Find.Help().Key)
+	assert.False(t, keymaps.Chat.Help.Enabled())
+	assert.Equal(t, []string{"f1"}, keymaps.App.Tabs[0].Keys())
+
+	// the defaults are left alone
+	assert.Equal(t, []string{"/"}, ui.DefaultChatScreenKeymap.Find.Keys())
+	assert.Equal(t, []string{"alt+1"}, ui.DefaultAppKeymap.Tabs[0].Keys())
+}
+
+func TestKeymaps_OverrideErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides ui.KeymapOverrides
+		expected  string
+	}{
+		{
+			name:      "unknown screen",
+			overrides: ui.KeymapOverrides{"lobby": {"quit": {"q"}}},
+			expected:  `unknown screen "lobby" in the key bindings`,
+		},
+		{
+			name:      "unknown binding",
+			overrides: ui.KeymapOverrides{"chat": {"teleport": {"t"}}},
+			expected:  `chat: unknown key binding "teleport"`,
+		},
+		{
+			name:      "conflict within the viewport mode",
+			overrides: ui.KeymapOverrides{"chat": {"top": {"n"}}},
+			expected:  "n is bound to both next_match and top",
+		},
+		{
+			name:      "conflict between the app and the chat",
+			overrides: ui.KeymapOverrides{"app": {"next_room": {"ctrl+t"}}},
+			expected:  "ctrl+t is bound to both toggle_members and next_room",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ui.DefaultKeymaps().Override(tt.overrides)
+
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestKeymaps_ModesMayShareKeys(t *testing.T) {
+	// k only scrolls in viewport mode, so it is free for the input mode
+	_, err := ui.DefaultKeymaps().Override(ui.KeymapOverrides{"chat": {"history_search": {"k"}}})
+
+	assert.NoError(t, err)
+}
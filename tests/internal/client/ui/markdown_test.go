@@ -0,0 +1,309 @@
+package ui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+// withColors has the styles rendered as on a 256 colour terminal for the rest of the test
+func withColors(t *testing.T) {
+	previous := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(previous) })
+}
+
+func renderText(text string, mentions []gchad.Mention, width int) string {
+	return ui.NewChatEntry(gchad.ChatMessage{
+		Timestamp: time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC),
+		From:      "alice",
+		Text:      text,
+		Mentions:  mentions,
+	}, false).Render(width)
+}
+
+// italicText is the text rendered in italics
+func italicText(rendered string) string {
+	var italic strings.Builder
+	on := false
+
+	for len(rendered) > 0 {
+		if strings.HasPrefix(rendered, "\x1b[") {
+			end := strings.IndexByte(rendered, 'm')
+			params := strings.Split(rendered[2:end], ";")
+			for i := 0; i < len(params); i++ {
+				switch params[i] {
+				case "", "0", "23":
+					on = false
+				case "3":
+					on = true
+				case "38", "48":
+					// the colour that follows is not an attribute
+					if i+1 < len(params) && params[i+1] == "5" {
+						i += 2
+					} else {
+						i += 4
+					}
+				}
+			}
+			rendered = rendered[end+1:]
+			continue
+		}
+		if on {
+			italic.WriteByte(rendered[0])
+		}
+		rendered = rendered[1:]
+	}
+
+	return italic.String()
+}
+
+// styleOf is the escape sequence text is rendered with
+func styleOf(rendered, text string) string {
+	before := rendered[:strings.Index(rendered, text)]
+	start := strings.LastIndex(before, "\x1b[")
+	if start < 0 {
+		return ""
+	}
+
+	return before[start:]
+}
+
+func TestMarkdown_Blocks(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		code  []string
+		prose []string
+	}{
+		{
+			name:  "fenced",
+			text:  "look:\n```go\nfunc main() {}\n```\nnice",
+			code:  []string{"func main() {}"},
+			prose: []string{"look:", "nice"},
+		},
+		{
+			name:  "unterminated fence runs to the end",
+			text:  "look:\n```\nfirst\nsecond",
+			code:  []string{"first", "second"},
+			prose: []string{"look:"},
+		},
+		{
+			name:  "fence opening the message",
+			text:  "```\nonly code\n```",
+			code:  []string{"only code"},
+			prose: nil,
+		},
+		{
+			name:  "fence within a line",
+			text:  "just ``` here",
+			code:  nil,
+			prose: []string{"just"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withColors(t)
+			rendered := renderText(tt.text, nil, 80)
+
+			if tt.code != nil {
+				assert.NotContains(t, ansi.Strip(rendered), "```")
+			}
+			for _, line := range strings.Split(rendered, "\n") {
+				stripped := ansi.Strip(line)
+				for _, code := range tt.code {
+					if strings.Contains(stripped, code) {
+						assert.Contains(t, line, "48;5;", "%q is not in a code block", code)
+					}
+				}
+				for _, prose := range tt.prose {
+					if strings.Contains(stripped, prose) {
+						assert.NotContains(t, line, "48;5;", "%q is in a code block", prose)
+					}
+				}
+			}
+			for _, text := range append(tt.code, tt.prose...) {
+				assert.Contains(t, ansi.Strip(rendered), text)
+			}
+		})
+	}
+}
+
+func TestMarkdown_Inline(t *testing.T) {
+	tests := []struct {
+		text   string
+		shown  string
+		italic string
+	}{
+		{"*emphasis*", "emphasis", "emphasis"},
+		{"_emphasis_", "emphasis", "emphasis"},
+		{"see _this_ and that", "see this and that", "this"},
+		{"snake_case_name", "snake_case_name", ""},
+		{"call snake_case_name now", "call snake_case_name now", ""},
+		{"a * b * c", "a * b * c", ""},
+		{"2*3*4", "2*3*4", ""},
+		{"**bold**", "bold", ""},
+		{"`*not emphasis*`", "*not emphasis*", ""},
+		{"*unclosed", "*unclosed", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			withColors(t)
+			rendered := renderText(tt.text, nil, 80)
+
+			assert.Contains(t, ansi.Strip(rendered), tt.shown)
+			assert.Equal(t, tt.italic, italicText(rendered))
+		})
+	}
+}
+
+func TestMarkdown_MentionsInCodeSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		mentions []gchad.Mention
+		// code are the mentions which are in code spans and so are to be left as code
+		code  []gchad.Mention
+		shown string
+	}{
+		{
+			name:     "mention in a code span",
+			text:     "`@jane`",
+			mentions: []gchad.Mention{{Name: "jane", Start: 1, End: 6}},
+			code:     []gchad.Mention{{Name: "jane", Start: 1, End: 6}},
+			shown:    "@jane",
+		},
+		{
+			name:     "mention in and out of a code span",
+			text:     "`ping @jane` then @jane",
+			mentions: []gchad.Mention{{Name: "jane", Start: 6, End: 11}, {Name: "jane", Start: 18, End: 23}},
+			code:     []gchad.Mention{{Name: "jane", Start: 6, End: 11}},
+			shown:    "ping @jane then @jane",
+		},
+		{
+			name:     "backticks on other lines",
+			text:     "a `\n@jane\nb`",
+			mentions: []gchad.Mention{{Name: "jane", Start: 4, End: 9}},
+			code:     nil,
+			shown:    "@jane",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withColors(t)
+			rendered := renderText(tt.text, tt.mentions, 80)
+
+			assert.Contains(t, ansi.Strip(rendered), tt.shown)
+			// a mention in a code span renders as if it was not one
+			var styled []gchad.Mention
+			for _, mention := range tt.mentions {
+				if !containsMention(tt.code, mention) {
+					styled = append(styled, mention)
+				}
+			}
+			assert.Equal(t, renderText(tt.text, styled, 80), rendered)
+			if len(tt.code) < len(tt.mentions) {
+				assert.NotEqual(t, renderText(tt.text, nil, 80), rendered)
+			}
+		})
+	}
+}
+
+func containsMention(mentions []gchad.Mention, mention gchad.Mention) bool {
+	for _, m := range mentions {
+		if m == mention {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestMarkdown_LongCodeLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		width int
+	}{
+		{"one word", strings.Repeat("x", 150), 60},
+		{"words", strings.Repeat("fmt.Println(value) ", 8), 60},
+		{"tabs", "\t\tif err != nil { return fmt.Errorf(\"failed to do the thing: %w\", err) }", 50},
+		{"wide characters", strings.Repeat("日本語", 20), 40},
+		{"fits", "short", 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withColors(t)
+			rendered := ansi.Strip(renderText("```go\n"+tt.line+"\n```", nil, tt.width))
+
+			var code strings.Builder
+			for i, line := range strings.Split(rendered, "\n") {
+				assert.LessOrEqual(t, ansi.StringWidth(line), tt.width, line)
+				if i > 0 {
+					code.WriteString(strings.TrimSpace(line))
+				}
+			}
+			assert.NotContains(t, rendered, "…")
+			// nothing of the line is lost, whitespace aside
+			want := strings.Join(strings.Fields(tt.line), "")
+			assert.Equal(t, want, strings.Join(strings.Fields(code.String()), ""))
+		})
+	}
+}
+
+func TestMarkdown_Highlighting(t *testing.T) {
+	tests := []struct {
+		language  string
+		line      string
+		same      [][2]string
+		different [][2]string
+	}{
+		{
+			language:  "go",
+			line:      `func main() { return "func" }`,
+			same:      [][2]string{{"func", "return"}},
+			different: [][2]string{{"func", "main"}, {"func", `"func"`}},
+		},
+		{
+			language:  "go",
+			line:      `x := 1 // the count`,
+			different: [][2]string{{"// the count", "x"}, {"// the count", "1"}},
+		},
+		{
+			language:  "python",
+			line:      `s = "# not a comment" # a comment`,
+			different: [][2]string{{`"# not a comment"`, "# a comment"}},
+		},
+		{
+			language:  "sql",
+			line:      `select name from users -- the users`,
+			same:      [][2]string{{"select", "from"}},
+			different: [][2]string{{"select", "name"}, {"-- the users", "select"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language+" "+tt.line, func(t *testing.T) {
+			withColors(t)
+			rendered := renderText("```"+tt.language+"\n"+tt.line+"\n```", nil, 80)
+
+			for _, pair := range tt.same {
+				assert.Equal(t, styleOf(rendered, pair[0]), styleOf(rendered, pair[1]), "%s and %s", pair[0], pair[1])
+			}
+			for _, pair := range tt.different {
+				assert.NotEqual(t, styleOf(rendered, pair[0]), styleOf(rendered, pair[1]), "%s and %s", pair[0], pair[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ui_test
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+// SpyNotifier hands the notifications over to the test as they are made
+type SpyNotifier struct {
+	notifications chan string
+}
+
+func NewSpyNotifier() *SpyNotifier {
+	return &SpyNotifier{notifications: make(chan string, 16)}
+}
+
+func (s *SpyNotifier) Notify(title string, body string) {
+	s.notifications <- title + " " + body
+}
+
+// Next is the notification made next, if any is made shortly
+func (s *SpyNotifier) Next() (string, bool) {
+	select {
+	case notification := <-s.notifications:
+		return notification, true
+	case <-time.After(100 * time.Millisecond):
+		return "", false
+	}
+}
+
+// mentioning is a message of the sender mentioning the name at the end of the text
+func mentioning(from string, text string, name string) gchad.ChatMessage {
+	start := len(text) + 1
+	return gchad.ChatMessage{
+		From:      from,
+		Text:      text + " @" + name,
+		Mentions:  []gchad.Mention{{Name: name, Start: start, End: start + 1 + len(name)}},
+		Timestamp: time.Now(),
+	}
+}
+
+func TestMentions_Highlighted(t *testing.T) {
+	withColors(t)
+	program, client := loggedIn(t, 80, 20)
+
+	client.Receive(mentioning("bob", "ping", "jane"))
+	program.UntilShows("ping @jane")
+	client.Receive(gchad.ChatMessage{From: "bob", Text: "pong @nobody", Timestamp: time.Now()})
+	program.UntilShows("pong @nobody")
+
+	rendered := program.model.View()
+	assert.NotEqual(t, styleOf(rendered, "ping"), styleOf(rendered, "@jane"))
+	// a name the server did not find in the room is rendered along with the rest of the text
+	assert.Contains(t, rendered, "pong @nobody")
+}
+
+func TestMentions_Notify(t *testing.T) {
+	tests := []struct {
+		name         string
+		members      []gchad.Member
+		msg          gchad.ChatMessage
+		notification string
+	}{
+		{
+			name:         "mention",
+			msg:          mentioning("bob", "ping", "jane"),
+			notification: "gchad bob: ping @jane",
+		},
+		{
+			name: "someone else mentioned",
+			msg:  mentioning("bob", "ping", "alice"),
+		},
+		{
+			name: "own mention",
+			msg:  mentioning("jane", "note to", "jane"),
+		},
+		{
+			name:    "do not disturb",
+			members: []gchad.Member{{Name: "jane", Presence: gchad.PresenceDoNotDisturb}},
+			msg:     mentioning("bob", "ping", "jane"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := NewSpyNotifier()
+			program, client := loggedIn(t, 80, 20, withNotifier(notifier))
+			client.SetMembers(tt.members)
+
+			client.Receive(tt.msg)
+			program.UntilShows(tt.msg.Text)
+
+			notification, notified := notifier.Next()
+			assert.Equal(t, tt.notification != "", notified)
+			assert.Equal(t, tt.notification, notification)
+		})
+	}
+}
+
+func TestMentions_CountedUntilScrolledIntoView(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+
+	// read where it arrives, a mention is seen right away
+	client.Receive(mentioning("bob", "first", "jane"))
+	program.UntilShows("first @jane")
+	assert.NotContains(t, program.View(), "@1")
+
+	receiveLines(program, client, 1, 30)
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("g"))
+
+	client.Receive(mentioning("bob", "second", "jane"))
+	program.UntilShows("@1")
+	assert.NotContains(t, program.View(), "second @jane")
+
+	program.Send(viewportKey("G"))
+	assert.Contains(t, program.View(), "second @jane")
+	assert.NotContains(t, program.View(), "@1")
+}
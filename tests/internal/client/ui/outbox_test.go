@@ -0,0 +1,75 @@
+package ui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func echo(id string, text string) gchad.ChatMessage {
+	return gchad.ChatMessage{Id: id, From: "jane", Text: text, Timestamp: time.Now()}
+}
+
+func TestOutbox_AckBeforeTheMessageArrives(t *testing.T) {
+	program, client := loggedIn(t, 100, 30)
+
+	program.Type("hello")
+	send := client.NextSend(t)
+	assert.Equal(t, "hello", send.Text)
+	program.UntilShows("sending…")
+
+	send.Ack(gchad.AckMessage{RequestId: "1", Id: "m1", Sequence: 1})
+	program.Until(func(view string) bool { return !strings.Contains(view, "sending…") }, "the message is still sending")
+	client.Receive(echo("m1", "hello"))
+
+	program.UntilShows("✓")
+	assert.Equal(t, 1, strings.Count(program.View(), "hello"))
+}
+
+func TestOutbox_MessageArrivesBeforeTheAck(t *testing.T) {
+	program, client := loggedIn(t, 100, 30)
+
+	program.Type("hello")
+	send := client.NextSend(t)
+	client.Receive(echo("m1", "hello"))
+	// the outbox still has the message, as the ack has yet to come
+	program.Until(func(view string) bool { return strings.Count(view, "hello") == 2 }, "the message never arrived")
+	assert.NotContains(t, program.View(), "✓")
+
+	send.Ack(gchad.AckMessage{RequestId: "1", Id: "m1", Sequence: 1})
+
+	program.UntilShows("✓")
+	assert.NotContains(t, program.View(), "sending…")
+	assert.Equal(t, 1, strings.Count(program.View(), "hello"))
+}
+
+func TestOutbox_TurnedDown(t *testing.T) {
+	program, client := loggedIn(t, 100, 30)
+
+	program.Type("hello")
+	send := client.NextSend(t)
+	rejection := gchad.ErrorMessage{Code: protocol.CodeBusy, Message: "slow down", Request: protocol.Chat, RequestId: "1"}
+	// the rejection also arrives as a message, which the outbox already tells the user about
+	client.Receive(rejection)
+	send.Fail(rejection)
+
+	program.UntilShows("not sent: slow down")
+	assert.NotContains(t, program.View(), "the server turned down")
+
+	// a message of the user arriving with nothing waiting for its ack is not marked
+	client.Receive(echo("m2", "from elsewhere"))
+	program.UntilShows("from elsewhere")
+	assert.NotContains(t, program.View(), "✓")
+
+	// trying again sends the same text, the ack settling it
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlY})
+	retry := client.NextSend(t)
+	assert.Equal(t, "hello", retry.Text)
+	retry.Ack(gchad.AckMessage{RequestId: "2", Id: "m3", Sequence: 3})
+	program.Until(func(view string) bool { return !strings.Contains(view, "not sent") }, "the message is still not sent")
+}
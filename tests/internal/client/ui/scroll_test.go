@@ -0,0 +1,125 @@
+package ui_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+// numberedLine is a message of bob telling its number
+func numberedLine(n int) gchad.ChatMessage {
+	return gchad.ChatMessage{Id: fmt.Sprintf("m%d", n), From: "bob", Text: fmt.Sprintf("line %02d", n), Timestamp: time.Now()}
+}
+
+// receiveLines has the lines from first to last arrive, one after the other
+func receiveLines(program *Program, client *FakeChatClient, first int, last int) {
+	for n := first; n <= last; n++ {
+		client.Receive(numberedLine(n))
+		program.UntilShows(numberedLine(n).Text)
+	}
+}
+
+func viewportKey(keys string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)}
+}
+
+func TestScroll_FollowsTheLatestMessage(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+
+	receiveLines(program, client, 1, 30)
+
+	assert.NotContains(t, program.View(), "line 01")
+	assert.NotContains(t, program.View(), "new message")
+}
+
+func TestScroll_KeepsThePositionWhileReadingBack(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+	receiveLines(program, client, 1, 30)
+
+	// to the viewport and up to the oldest message
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("g"))
+	assert.Contains(t, program.View(), "line 01")
+
+	tests := []struct {
+		line      int
+		indicator string
+	}{
+		{31, "1 new message ↓"},
+		{32, "2 new messages ↓"},
+		{33, "3 new messages ↓"},
+	}
+	for _, tt := range tests {
+		client.Receive(numberedLine(tt.line))
+		program.UntilShows(tt.indicator)
+
+		view := program.View()
+		assert.Contains(t, view, "line 01", "the viewport moved on line %d", tt.line)
+		assert.NotContains(t, view, numberedLine(tt.line).Text)
+	}
+
+	// scrolling down to the bottom counts as having caught up
+	program.Send(viewportKey("G"))
+	assert.Contains(t, program.View(), "line 33")
+	assert.NotContains(t, program.View(), "new message")
+
+	client.Receive(numberedLine(34))
+	program.UntilShows("line 34")
+	assert.NotContains(t, program.View(), "new message")
+}
+
+func TestScroll_JumpsToTheBottom(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+	receiveLines(program, client, 1, 30)
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("g"))
+	client.Receive(numberedLine(31))
+	program.UntilShows("1 new message ↓")
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlG})
+
+	view := program.View()
+	assert.Contains(t, view, "line 31")
+	assert.NotContains(t, view, "line 01")
+	assert.NotContains(t, view, "new message")
+}
+
+func TestScroll_DividerAboveWhatArrivedWhileAway(t *testing.T) {
+	program, client := loggedIn(t, 80, 30)
+	client.Receive(numberedLine(1))
+	program.UntilShows("line 01")
+
+	program.Send(tea.BlurMsg{})
+	client.Receive(numberedLine(2))
+	client.Receive(numberedLine(3))
+	program.UntilShows("line 03")
+	assert.NotContains(t, program.View(), "new since you left")
+
+	program.Send(tea.FocusMsg{})
+
+	view := program.View()
+	divider := strings.Index(view, "new since you left")
+	assert.Greater(t, divider, strings.Index(view, "line 01"))
+	assert.Less(t, divider, strings.Index(view, "line 02"))
+
+	// speaking up means the user has caught up
+	program.Type("back")
+	client.NextSend(t).Ack(gchad.AckMessage{RequestId: "1", Id: "m4", Sequence: 4})
+	assert.NotContains(t, program.View(), "new since you left")
+}
+
+func TestScroll_NoDividerWhenNothingArrived(t *testing.T) {
+	program, client := loggedIn(t, 80, 30)
+	client.Receive(numberedLine(1))
+	program.UntilShows("line 01")
+
+	program.Send(tea.BlurMsg{})
+	program.Send(tea.FocusMsg{})
+
+	assert.NotContains(t, program.View(), "new since you left")
+}
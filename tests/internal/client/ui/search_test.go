@@ -0,0 +1,137 @@
+package ui_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/iomallach/gchad/internal/client/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+type MemoryTranscript struct {
+	mu      sync.Mutex
+	entries []domain.TranscriptEntry
+}
+
+func (m *MemoryTranscript) Load() ([]domain.TranscriptEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]domain.TranscriptEntry(nil), m.entries...), nil
+}
+
+func (m *MemoryTranscript) Append(entry domain.TranscriptEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+// say has bob say the text, waiting for it to be shown
+func say(program *Program, client *FakeChatClient, text string) {
+	client.Receive(gchad.ChatMessage{From: "bob", Text: text, Timestamp: time.Now()})
+	program.UntilShows(text)
+}
+
+// find starts a / search for the query from the composer
+func find(program *Program, query string) {
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("/"))
+	program.Send(viewportKey(query))
+}
+
+func TestFind_StepsThroughTheMatches(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+	say(program, client, "needle one")
+	receiveLines(program, client, 1, 20)
+	say(program, client, "needle two")
+	receiveLines(program, client, 21, 40)
+	say(program, client, "needle three")
+
+	find(program, "needle")
+	// the newest match is the first one gone to
+	program.UntilShows("/needle  3/3")
+	assert.NotContains(t, program.View(), "n older")
+
+	program.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Contains(t, program.View(), "/needle  3/3  n older · N newer · esc close")
+
+	tests := []struct {
+		key    string
+		status string
+		shown  string
+		hidden string
+	}{
+		{"n", "2/3", "needle two", "needle one"},
+		{"n", "1/3", "needle one", "needle three"},
+		// going past the oldest match wraps around
+		{"n", "3/3", "needle three", "needle one"},
+		{"N", "1/3", "needle one", "needle three"},
+		{"N", "2/3", "needle two", "needle one"},
+	}
+	for _, tt := range tests {
+		program.Send(viewportKey(tt.key))
+
+		view := program.View()
+		assert.Contains(t, view, "/needle  "+tt.status, "after %s", tt.key)
+		assert.Contains(t, view, tt.shown, "after %s to %s", tt.key, tt.status)
+		assert.NotContains(t, view, tt.hidden, "after %s to %s", tt.key, tt.status)
+	}
+
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.NotContains(t, program.View(), "/needle")
+}
+
+func TestFind_MatchesAsTheQueryIsTyped(t *testing.T) {
+	program, client := loggedIn(t, 80, 30)
+	say(program, client, "Needle in caps")
+	say(program, client, "a needle")
+	say(program, client, "a pin")
+
+	tests := []struct {
+		key    tea.KeyMsg
+		status string
+	}{
+		{viewportKey("needl"), "/needl  2/2"},
+		{viewportKey("x"), "/needlx  no matches"},
+		{tea.KeyMsg{Type: tea.KeyBackspace}, "/needl  2/2"},
+		{viewportKey("es"), "/needles  no matches"},
+	}
+
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	program.Send(viewportKey("/"))
+	for _, tt := range tests {
+		program.Send(tt.key)
+		assert.Contains(t, program.View(), tt.status)
+	}
+
+	// an empty query closes the search
+	for range len("needles") {
+		program.Send(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	program.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.NotContains(t, program.View(), "/needl")
+}
+
+func TestFind_ReachesIntoTheTranscript(t *testing.T) {
+	transcript := &MemoryTranscript{entries: []domain.TranscriptEntry{
+		{Timestamp: time.Now().Add(-time.Hour), From: "bob", Text: "an old needle"},
+	}}
+	program, client := loggedIn(t, 80, 30, withTranscript(transcript))
+	say(program, client, "a new needle")
+	assert.NotContains(t, program.View(), "an old needle")
+
+	find(program, "needle")
+	program.UntilShows("/needle  2/2")
+	program.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	program.Send(viewportKey("n"))
+
+	assert.Contains(t, program.View(), "/needle  1/2")
+	assert.Contains(t, program.View(), "an old needle")
+
+	// closing the search puts the transcript away again
+	program.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.NotContains(t, program.View(), "an old needle")
+}
@@ -0,0 +1,64 @@
+package ui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertFits checks that no line of the view is wider than the screen
+func assertFits(t *testing.T, view string, width int) {
+	t.Helper()
+
+	for _, line := range strings.Split(view, "\n") {
+		assert.LessOrEqual(t, ansi.StringWidth(line), width, line)
+	}
+}
+
+func TestSidebar_ToggleAndResize(t *testing.T) {
+	program, client := loggedIn(t, 80, 20)
+	client.SetMembers([]gchad.Member{
+		{Name: "jane", Presence: gchad.PresenceOnline},
+		{Name: "alice", Presence: gchad.PresenceAway, StatusText: "out for lunch"},
+	})
+	// the text fits on a line of the chat unless the sidebar takes its share of an 80 column screen
+	text := strings.TrimSpace(strings.Repeat("word ", 8))
+	client.Receive(gchad.ChatMessage{From: "bob", Text: text, Timestamp: time.Now()})
+	program.UntilShows("alice")
+
+	assert.Contains(t, program.View(), "out for lunch")
+	assert.NotContains(t, program.View(), text)
+	assertFits(t, program.View(), 80)
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlT})
+	assert.NotContains(t, program.View(), "alice")
+	assert.Contains(t, program.View(), text)
+	assertFits(t, program.View(), 80)
+
+	program.Send(tea.KeyMsg{Type: tea.KeyCtrlT})
+	assert.Contains(t, program.View(), "alice")
+	assert.NotContains(t, program.View(), text)
+
+	tests := []struct {
+		width  int
+		height int
+		fits   bool
+	}{
+		{120, 30, true},
+		{60, 20, false},
+		{80, 20, false},
+	}
+	for _, tt := range tests {
+		program.Send(tea.WindowSizeMsg{Width: tt.width, Height: tt.height})
+
+		view := program.View()
+		assert.Contains(t, view, "alice", "at %dx%d", tt.width, tt.height)
+		assert.Equal(t, tt.fits, strings.Contains(view, text), "at %dx%d", tt.width, tt.height)
+		assertFits(t, view, tt.width)
+	}
+}
@@ -0,0 +1,99 @@
+package ui_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTheme_Builtin(t *testing.T) {
+	theme, err := ui.ResolveTheme("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "mocha", theme.Name)
+
+	for _, builtin := range ui.BuiltinThemes {
+		theme, err := ui.ResolveTheme(builtin.Name, nil)
+		require.NoError(t, err)
+		assert.Equal(t, builtin.Name, theme.Name)
+	}
+
+	theme, err = ui.ResolveTheme("no-color", nil)
+	require.NoError(t, err)
+	assert.True(t, theme.NoColor)
+}
+
+func TestResolveTheme_Custom(t *testing.T) {
+	custom := map[string]ui.CustomTheme{
+		"dracula-ish": {Base: "latte", Colors: map[string]string{"mauve": "#bd93f9", "base": "235"}},
+		"loud":        {Base: "dracula-ish", Nicknames: []string{"9", "#fff"}},
+		// a custom theme may take the name of the built-in one it changes
+		"mocha": {Base: "mocha", Colors: map[string]string{"text": "#ffffff"}},
+	}
+
+	theme, err := ui.ResolveTheme("loud", custom)
+	require.NoError(t, err)
+	assert.Equal(t, "loud", theme.Name)
+	assert.Equal(t, lipgloss.Color("#bd93f9"), theme.Palette.Mauve)
+	assert.Equal(t, lipgloss.Color("235"), theme.Palette.Base)
+	assert.Equal(t, ui.CatppuccinLatte.Text, theme.Palette.Text)
+	assert.Equal(t, []lipgloss.Color{"9", "#fff"}, theme.Nicknames)
+
+	theme, err = ui.ResolveTheme("mocha", custom)
+	require.NoError(t, err)
+	assert.Equal(t, lipgloss.Color("#ffffff"), theme.Palette.Text)
+	assert.Equal(t, ui.CatppuccinMocha.Mauve, theme.Palette.Mauve)
+
+	// the built-in palettes are left alone
+	assert.Equal(t, lipgloss.Color("#cdd6f4"), ui.CatppuccinMocha.Text)
+}
+
+func TestResolveTheme_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		theme    string
+		custom   map[string]ui.CustomTheme
+		expected string
+	}{
+		{
+			name:     "unknown theme",
+			theme:    "solarized",
+			expected: `unknown theme "solarized"`,
+		},
+		{
+			name:     "unknown base",
+			theme:    "mine",
+			custom:   map[string]ui.CustomTheme{"mine": {Base: "solarized"}},
+			expected: `theme mine: unknown theme "solarized"`,
+		},
+		{
+			name:     "unknown color",
+			theme:    "mine",
+			custom:   map[string]ui.CustomTheme{"mine": {Colors: map[string]string{"purple": "#800080"}}},
+			expected: `theme mine: unknown color "purple"`,
+		},
+		{
+			name:     "invalid color",
+			theme:    "mine",
+			custom:   map[string]ui.CustomTheme{"mine": {Colors: map[string]string{"mauve": "purple"}}},
+			expected: `theme mine: mauve: invalid color "purple"`,
+		},
+		{
+			name:     "ANSI color out of range",
+			theme:    "mine",
+			custom:   map[string]ui.CustomTheme{"mine": {Nicknames: []string{"256"}}},
+			expected: `theme mine: nicknames: invalid color "256"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ui.ResolveTheme(tt.theme, tt.custom)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ui_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/iomallach/gchad/internal/client/ui"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+func chatEntry(from, text string) ui.Entry {
+	return ui.NewChatEntry(gchad.ChatMessage{
+		Timestamp: time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC),
+		From:      from,
+		Text:      text,
+	}, false)
+}
+
+// column is the cell at which sub starts in line
+func column(line, sub string) int {
+	return ansi.StringWidth(line[:strings.Index(line, sub)])
+}
+
+func TestEntry_RenderWrapsWithHangingIndent(t *testing.T) {
+	entry := chatEntry("alice", strings.Repeat("lorem ipsum ", 10))
+
+	lines := strings.Split(ansi.Strip(entry.Render(50)), "\n")
+
+	assert.Greater(t, len(lines), 1)
+	assert.True(t, strings.HasPrefix(lines[0], " 12:30:00       alice: lorem"))
+	for _, line := range lines {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 50)
+	}
+	for _, line := range lines[1:] {
+		assert.True(t, strings.HasPrefix(line, strings.Repeat(" ", 23)), line)
+	}
+}
+
+func TestEntry_RenderReflowsToWidth(t *testing.T) {
+	entry := chatEntry("alice", strings.Repeat("lorem ipsum ", 10))
+
+	narrow := strings.Count(entry.Render(40), "\n")
+	wide := strings.Count(entry.Render(200), "\n")
+
+	assert.Greater(t, narrow, wide)
+	assert.Equal(t, 0, wide)
+}
+
+func TestEntry_RenderKeepsNameColumnWidth(t *testing.T) {
+	short := ansi.Strip(chatEntry("bo", "hi").Render(80))
+	long := ansi.Strip(chatEntry("averyveryverylongname", "hi").Render(80))
+
+	assert.Equal(t, 23, column(short, "hi"))
+	assert.Equal(t, 23, column(long, "hi"))
+	assert.Contains(t, long, "…:")
+}
+
+func TestEntry_RenderMeasuresWideCharacters(t *testing.T) {
+	entry := chatEntry("太郎", strings.Repeat("日本語のテキスト🙂", 8))
+
+	lines := strings.Split(ansi.Strip(entry.Render(44)), "\n")
+
+	assert.Greater(t, len(lines), 1)
+	for _, line := range lines {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 44, line)
+	}
+	assert.Equal(t, 22-ansi.StringWidth("太郎:"), column(lines[0], "太郎"))
+	assert.Equal(t, 23, column(lines[0], "日本語"))
+}
+
+func TestEntry_RenderMovesBodyBelowNameWhenNarrow(t *testing.T) {
+	entry := chatEntry("alice", "a message that does not fit next to the name")
+
+	lines := strings.Split(ansi.Strip(entry.Render(30)), "\n")
+
+	assert.Equal(t, " 12:30:00       alice: ", lines[0])
+	for _, line := range lines {
+		assert.LessOrEqual(t, ansi.StringWidth(line), 30, line)
+	}
+}
+
+func TestEntry_RenderWebhookPost(t *testing.T) {
+	entry := ui.NewChatEntry(gchad.ChatMessage{
+		Timestamp: time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC),
+		From:      "ci",
+		Text:      "the build failed",
+		Webhook:   true,
+		Attachments: []gchad.Attachment{
+			{Title: "build #12", Url: "https://ci.example/12", Text: "3 tests failed"},
+			{Url: "https://ci.example/12/logs"},
+		},
+	}, false)
+
+	lines := strings.Split(ansi.Strip(entry.Render(80)), "\n")
+
+	assert.Equal(t, []string{
+		" 12:30:00          ci: hook the build failed",
+		strings.Repeat(" ", 23) + "▸ build #12 https://ci.example/12",
+		strings.Repeat(" ", 23) + "  3 tests failed",
+		strings.Repeat(" ", 23) + "▸ https://ci.example/12/logs",
+	}, lines)
+	assert.Equal(t, entry, ui.NewEntryFromRecord(entry.Record()))
+}
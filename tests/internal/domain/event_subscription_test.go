@@ -0,0 +1,90 @@
+package domain_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSubscription_MatchKeywords(t *testing.T) {
+	subscription := domain.EventSubscription{Keywords: []string{"deploy", "On-Call", "p1"}}
+
+	assert.Equal(t, []string{"deploy"}, subscription.MatchKeywords("The DEPLOY failed!"))
+	assert.Equal(t, []string{"On-Call", "p1"}, subscription.MatchKeywords("paging on-call, this is a p1."))
+	assert.Empty(t, subscription.MatchKeywords("deployment is done, p10 next"))
+}
+
+func TestSubscribeEventsMessage_Validate(t *testing.T) {
+	events := []domain.EventKind{domain.EventMessagePosted}
+
+	tests := []struct {
+		name     string
+		msg      domain.SubscribeEventsMessage
+		expected string
+	}{
+		{"valid", domain.SubscribeEventsMessage{Url: "https://203.0.113.10/events", Events: events}, ""},
+		{"not http", domain.SubscribeEventsMessage{Url: "ftp://example.com", Events: events}, domain.ErrInvalidCallbackUrl.Error()},
+		{"relative", domain.SubscribeEventsMessage{Url: "/events", Events: events}, domain.ErrInvalidCallbackUrl.Error()},
+		{"no events", domain.SubscribeEventsMessage{Url: "https://example.com"}, domain.ErrNoEvents.Error()},
+		{"unknown event", domain.SubscribeEventsMessage{Url: "https://example.com", Events: []domain.EventKind{"typing"}}, `unknown event "typing"`},
+		{"keywords missing", domain.SubscribeEventsMessage{Url: "https://example.com", Events: []domain.EventKind{domain.EventKeywordMentioned}}, domain.ErrNoKeywords.Error()},
+		{"loopback", domain.SubscribeEventsMessage{Url: "http://127.0.0.1:8080/events", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+		{"localhost", domain.SubscribeEventsMessage{Url: "http://localhost/events", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+		{"private", domain.SubscribeEventsMessage{Url: "http://10.0.0.1/events", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+		{"cloud metadata", domain.SubscribeEventsMessage{Url: "http://169.254.169.254/latest", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+		{"ipv6 loopback", domain.SubscribeEventsMessage{Url: "http://[::1]/events", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+		{"mapped private", domain.SubscribeEventsMessage{Url: "http://[::ffff:192.168.1.1]/events", Events: events}, domain.ErrPrivateCallbackUrl.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"203.0.113.10", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.0.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, domain.IsPublicAddress(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestSignEvent(t *testing.T) {
+	signature := domain.SignEvent("secret", 1700000000, []byte(`{"id":"1"}`))
+
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, domain.SignEvent("secret", 1700000000, []byte(`{"id":"1"}`)))
+	assert.NotEqual(t, signature, domain.SignEvent("secret", 1700000001, []byte(`{"id":"1"}`)))
+	assert.NotEqual(t, signature, domain.SignEvent("other", 1700000000, []byte(`{"id":"1"}`)))
+}
@@ -0,0 +1,64 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	members := map[string]bool{"jane": true, "john.doe": true, "ünïcode": true}
+	isMember := func(name string) bool { return members[name] }
+
+	tests := []struct {
+		name     string
+		text     string
+		expected []domain.Mention
+	}{
+		{
+			name:     "no mentions",
+			text:     "hello there",
+			expected: []domain.Mention{},
+		},
+		{
+			name:     "mention at the start",
+			text:     "@jane hi",
+			expected: []domain.Mention{{Name: "jane", Start: 0, End: 5}},
+		},
+		{
+			name: "trailing punctuation is not part of the name",
+			text: "hey @jane, @john.doe.",
+			expected: []domain.Mention{
+				{Name: "jane", Start: 4, End: 9},
+				{Name: "john.doe", Start: 11, End: 20},
+			},
+		},
+		{
+			name:     "unknown names and email addresses are ignored",
+			text:     "@bob mail me at jane@example.com",
+			expected: []domain.Mention{},
+		},
+		{
+			name:     "offsets are in bytes",
+			text:     "é @ünïcode",
+			expected: []domain.Mention{{Name: "ünïcode", Start: 3, End: 13}},
+		},
+		{
+			name:     "a lone prefix",
+			text:     "@ @",
+			expected: []domain.Mention{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mentions := domain.ParseMentions(tt.text, isMember)
+
+			assert.Equal(t, tt.expected, mentions)
+			for _, mention := range mentions {
+				assert.Equal(t, "@"+mention.Name, tt.text[mention.Start:mention.End])
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package domain_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInbound(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      domain.Messager
+		expected error
+	}{
+		{"chat", &domain.UserMessage{Text: "hello"}, nil},
+		{"longest chat", &domain.UserMessage{Text: strings.Repeat("é", domain.MaxTextLength)}, nil},
+		{"empty chat", &domain.UserMessage{Text: ""}, domain.ErrEmptyMessage},
+		{"blank chat", &domain.UserMessage{Text: " \n\t"}, domain.ErrEmptyMessage},
+		{"control characters only", &domain.UserMessage{Text: "\x00\x1b"}, domain.ErrEmptyMessage},
+		{"long chat", &domain.UserMessage{Text: strings.Repeat("a", domain.MaxTextLength+1)}, domain.ErrTextTooLong},
+		{"not utf-8", &domain.UserMessage{Text: "hello \xff"}, domain.ErrInvalidUTF8},
+		{"no status", &domain.SetPresenceMessage{Presence: domain.PresenceAway}, nil},
+		{"long status", &domain.SetPresenceMessage{StatusText: strings.Repeat("a", domain.MaxStatusTextLength+1)}, domain.ErrStatusTooLong},
+		{"long search", &domain.SearchMessage{Terms: strings.Repeat("a", domain.MaxTextLength+1)}, domain.ErrTextTooLong},
+		{"empty search", &domain.SearchMessage{Terms: ""}, domain.ErrEmptySearch},
+		{"punctuation search", &domain.SearchMessage{Terms: "?! ..."}, domain.ErrEmptySearch},
+		{"search by sender", &domain.SearchMessage{Terms: "?!", From: "jane"}, nil},
+		{"no text", &domain.ActivityMessage{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, domain.ValidateInbound(tt.msg), tt.expected)
+		})
+	}
+}
+
+func TestValidateInbound_StripsControlCharacters(t *testing.T) {
+	chat := &domain.UserMessage{Text: "\x1b[31mred\x1b[0m\x00 line\nnext\tcolumn\u200b\u0085"}
+	presence := &domain.SetPresenceMessage{StatusText: "out\rto lunch\x07"}
+
+	assert.NoError(t, domain.ValidateInbound(chat))
+	assert.NoError(t, domain.ValidateInbound(presence))
+
+	assert.Equal(t, "[31mred[0m line\nnext\tcolumn\u200b", chat.Text)
+	assert.Equal(t, "outto lunch", presence.StatusText)
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected error
+	}{
+		{"Jane", nil},
+		{strings.Repeat("é", domain.MaxNameLength), nil},
+		{"", domain.ErrEmptyName},
+		{strings.Repeat("é", domain.MaxNameLength+1), domain.ErrNameTooLong},
+		{"Jane Doe", domain.ErrNameHasWhitespace},
+		{"Jane\tDoe", domain.ErrNameHasWhitespace},
+		{"\x1b[31mJane", domain.ErrNameHasControlCharacter},
+		{"Jane\x00", domain.ErrNameHasControlCharacter},
+		{"Jane\u0085", domain.ErrNameHasWhitespace},
+		{"Jane\x7f", domain.ErrNameHasControlCharacter},
+		{"Jane\xff", domain.ErrInvalidUTF8},
+	}
+
+	for _, tt := range tests {
+		assert.ErrorIs(t, domain.ValidateName(tt.name), tt.expected, "%q", tt.name)
+	}
+}
+
+func TestWebhookPayload_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  domain.WebhookPayload
+		expected error
+	}{
+		{"text", domain.WebhookPayload{Text: "deployed"}, nil},
+		{"attachment only", domain.WebhookPayload{Attachments: []domain.Attachment{{Title: "build"}}}, nil},
+		{"empty", domain.WebhookPayload{}, domain.ErrEmptyWebhookMessage},
+		{"control characters only", domain.WebhookPayload{Text: "\x1b\x07"}, domain.ErrEmptyWebhookMessage},
+		{"long text", domain.WebhookPayload{Text: strings.Repeat("a", domain.MaxWebhookTextLength+1)}, domain.ErrWebhookTextTooLong},
+		{"not utf-8", domain.WebhookPayload{Text: "deployed \xff"}, domain.ErrInvalidUTF8},
+		{"attachment not utf-8", domain.WebhookPayload{Attachments: []domain.Attachment{{Url: "https://ci/\xff"}}}, domain.ErrInvalidUTF8},
+		{"empty attachment", domain.WebhookPayload{Text: "hi", Attachments: []domain.Attachment{{}}}, domain.ErrEmptyAttachment},
+		{"attachment of control characters", domain.WebhookPayload{Text: "hi", Attachments: []domain.Attachment{{Text: "\x1b"}}}, domain.ErrEmptyAttachment},
+		{"too many attachments", domain.WebhookPayload{Text: "hi", Attachments: make([]domain.Attachment, domain.MaxAttachments+1)}, domain.ErrTooManyAttachments},
+		{"username with escape", domain.WebhookPayload{Text: "hi", Username: "\x1b[2Jci"}, domain.ErrNameHasControlCharacter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.payload.Validate(), tt.expected)
+		})
+	}
+}
+
+func TestWebhookPayload_Validate_StripsControlCharacters(t *testing.T) {
+	attachments := []domain.Attachment{{Title: "\x1b]0;owned\x07build", Url: "https://ci/1\x00", Text: "failed\nat step\t2\x1b[0m"}}
+	payload := domain.WebhookPayload{Text: "\x1b[31mred\x1b[0m alert", Attachments: attachments}
+
+	assert.NoError(t, payload.Validate())
+
+	assert.Equal(t, "[31mred[0m alert", payload.Text)
+	assert.Equal(t, []domain.Attachment{{Title: "]0;ownedbuild", Url: "https://ci/1", Text: "failed\nat step\t2[0m"}}, payload.Attachments)
+	// what the caller passed in is left as it was
+	assert.Equal(t, "https://ci/1\x00", attachments[0].Url)
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	assert.Equal(t, protocol.CodeEmptyMessage, domain.ErrorCodeOf(domain.ErrEmptyMessage))
+	assert.Equal(t, protocol.CodeEmptyMessage, domain.ErrorCodeOf(domain.ErrEmptySearch))
+	assert.Equal(t, protocol.CodeTextTooLong, domain.ErrorCodeOf(domain.ErrTextTooLong))
+	assert.Equal(t, protocol.CodeTextTooLong, domain.ErrorCodeOf(domain.ErrStatusTooLong))
+	assert.Equal(t, protocol.CodeInvalidMessage, domain.ErrorCodeOf(domain.ErrInvalidUTF8))
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/iomallach/gchad/internal/server/domain"
 	"github.com/iomallach/gchad/internal/server/infrastructure"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
 	CloseMessage = iota
 	TextMessage
 	PingMessage
+	PongMessage
+	BinaryMessage
 )
 
 type readResult struct {
@@ -38,6 +44,7 @@ type MockConnection struct {
 	writeTextError  error
 	writePingError  error
 	writeCloseError error
+	readLimit       int64
 }
 
 func NewMockConnection() *MockConnection {
@@ -61,6 +68,10 @@ func (mc *MockConnection) Close() error {
 	return nil
 }
 
+func (mc *MockConnection) Subprotocol() string {
+	return ""
+}
+
 func (mc *MockConnection) ReadMessage() (int, []byte, error) {
 	msg, ok := <-mc.readChan
 	if !ok {
@@ -74,6 +85,28 @@ func (mc *MockConnection) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+func (mc *MockConnection) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (mc *MockConnection) SetReadLimit(limit int64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.readLimit = limit
+}
+
+func (mc *MockConnection) ReadLimit() int64 {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.readLimit
+}
+
+func (mc *MockConnection) SetPongHandler(f func(string) error) {}
+
+func (mc *MockConnection) SetPingHandler(f func(string) error) {}
+
 func (mc *MockConnection) writeMessage(messageType int, data []byte) error {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
@@ -102,6 +135,14 @@ func (mc *MockConnection) WriteTextMessage(data []byte) error {
 	return mc.writeMessage(TextMessage, data)
 }
 
+func (mc *MockConnection) SetCompression(network.Compression) error {
+	return nil
+}
+
+func (mc *MockConnection) WriteBinaryMessage(data []byte) error {
+	return mc.writeMessage(BinaryMessage, data)
+}
+
 func (mc *MockConnection) WritePingMessage(data []byte) error {
 	if mc.writePingError != nil {
 		return mc.writePingError
@@ -109,6 +150,10 @@ func (mc *MockConnection) WritePingMessage(data []byte) error {
 	return mc.writeMessage(PingMessage, data)
 }
 
+func (mc *MockConnection) WritePongMessage(data []byte) error {
+	return mc.writeMessage(PongMessage, data)
+}
+
 func (mc *MockConnection) EnqueueMessage(messageType int, data []byte) {
 	mc.readChan <- readResult{messageType, data, nil}
 }
@@ -126,8 +171,10 @@ func (mc *MockConnection) GetWrites() []writeResult {
 	return result
 }
 
+var JSONCodec = domain.NewCodec(protocol.SubprotocolJSON)
+
 func mustMarshallMessage(msg domain.Messager) []byte {
-	data, err := domain.MarshallMessage(msg)
+	data, err := JSONCodec.Marshal(msg)
 	if err != nil {
 		panic(fmt.Sprintf("failed to marshal test message: %v", err))
 	}
@@ -153,7 +200,7 @@ func TestClient_ReadMessagesPumpSendsMessagesToRecv(t *testing.T) {
 	defer connection.Close()
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, nil, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
 
 	go client.ReadMessages(ctx)
 
@@ -167,8 +214,8 @@ func TestClient_ReadMessagesPumpSendsMessagesToRecv(t *testing.T) {
 
 	assert.Len(t, spyLogger.Errors(), 0)
 	receivedMsg := (<-recv).(*domain.UserMessage)
-	assert.Equal(t, userMsg.Message, receivedMsg.Message)
-	assert.Equal(t, userMsg.Timestamp.Truncate(time.Second), receivedMsg.Timestamp.Truncate(time.Second))
+	assert.Equal(t, userMsg.Text, receivedMsg.Text)
+	assert.True(t, userMsg.Timestamp.Truncate(time.Second).Equal(receivedMsg.Timestamp.Truncate(time.Second)))
 	assert.Equal(t, userMsg.From, receivedMsg.From)
 }
 
@@ -180,7 +227,7 @@ func TestClient_WriteMessagesPumpSendsMessagesToRecv(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	go client.WriteMessages(ctx)
 
@@ -215,7 +262,7 @@ func TestClient_ReadMessages_InvalidJSON(t *testing.T) {
 	defer connection.Close()
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, nil, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
 
 	go client.ReadMessages(ctx)
 
@@ -231,6 +278,150 @@ func TestClient_ReadMessages_InvalidJSON(t *testing.T) {
 	assert.Len(t, recv, 0)
 }
 
+func TestClient_ReadMessages_UnknownType(t *testing.T) {
+	ctx := t.Context()
+
+	configuration := NewTestingClientConfiguration()
+	connection := NewMockConnection()
+	defer connection.Close()
+	spyLogger := NewSpyLogger()
+	recv := make(chan domain.Messager, 3)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
+
+	go client.ReadMessages(ctx)
+
+	// a message of a later version of the protocol is skipped, what follows still arrives
+	connection.EnqueueMessage(TextMessage, []byte(`{"type":"from_the_future","payload":{}}`))
+	userMsg := domain.NewUserMessage("Hello test", time.Now(), "John Doe")
+	connection.EnqueueMessage(TextMessage, mustMarshallMessage(userMsg))
+
+	time.Sleep(time.Millisecond * 50)
+
+	assert.Len(t, spyLogger.Errors(), 0)
+	require.Len(t, recv, 1)
+	assert.Equal(t, userMsg.Text, (<-recv).(*domain.UserMessage).Text)
+}
+
+func TestClient_ReadMessages_RepliesToInvalidMessages(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		code  protocol.ErrorCode
+	}{
+		{"malformed", []byte("{invalid json"), protocol.CodeMalformedMessage},
+		{"empty", mustMarshallMessage(domain.NewUserMessage("  ", time.Now(), "John Doe")), protocol.CodeEmptyMessage},
+		{"too long", mustMarshallMessage(domain.NewUserMessage(strings.Repeat("a", domain.MaxTextLength+1), time.Now(), "John Doe")), protocol.CodeTextTooLong},
+		{"not utf-8", []byte("{\"type\":\"chat\",\"payload\":{\"text\":\"hello \xff\"}}"), protocol.CodeInvalidMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connection := NewMockConnection()
+			defer connection.Close()
+			recv := make(chan domain.Messager, 3)
+			send := make(chan domain.Messager, 3)
+			client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, NewTestingClientConfiguration(), NewSpyLogger())
+
+			go client.ReadMessages(t.Context())
+
+			connection.EnqueueMessage(TextMessage, tt.frame)
+			// the client is still heard after being turned down
+			connection.EnqueueMessage(TextMessage, mustMarshallMessage(domain.NewUserMessage("hello", time.Now(), "John Doe")))
+
+			select {
+			case msg := <-send:
+				assert.Equal(t, tt.code, msg.(*domain.ErrorSystemMessage).Code)
+			case <-time.After(100 * time.Millisecond):
+				t.Fatal("expected an error message")
+			}
+			select {
+			case msg := <-recv:
+				assert.Equal(t, "hello", msg.(*domain.UserMessage).Text)
+			case <-time.After(100 * time.Millisecond):
+				t.Fatal("expected the valid message")
+			}
+		})
+	}
+}
+
+func TestClient_ReadMessages_KeepsTheRequestId(t *testing.T) {
+	connection := NewMockConnection()
+	defer connection.Close()
+	recv := make(chan domain.Messager, 3)
+	send := make(chan domain.Messager, 3)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, NewTestingClientConfiguration(), NewSpyLogger())
+
+	go client.ReadMessages(t.Context())
+
+	empty, err := JSONCodec.MarshalRequest(domain.NewUserMessage(" ", time.Now(), "John Doe"), "7")
+	require.NoError(t, err)
+	hello, err := JSONCodec.MarshalRequest(domain.NewUserMessage("hello", time.Now(), "John Doe"), "8")
+	require.NoError(t, err)
+	connection.EnqueueMessage(TextMessage, empty)
+	connection.EnqueueMessage(TextMessage, hello)
+
+	select {
+	case msg := <-send:
+		assert.Equal(t, "7", msg.(*domain.ErrorSystemMessage).RequestId)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected an error message")
+	}
+	select {
+	case msg := <-recv:
+		assert.Equal(t, "8", msg.(*domain.UserMessage).RequestId)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected the message")
+	}
+}
+
+func TestClient_ReadMessages_StripsControlCharacters(t *testing.T) {
+	connection := NewMockConnection()
+	defer connection.Close()
+	recv := make(chan domain.Messager, 3)
+	send := make(chan domain.Messager, 3)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, NewTestingClientConfiguration(), NewSpyLogger())
+
+	go client.ReadMessages(t.Context())
+
+	connection.EnqueueMessage(TextMessage, mustMarshallMessage(domain.NewUserMessage("\x1b[2Jhello\x00", time.Now(), "John Doe")))
+
+	select {
+	case msg := <-recv:
+		assert.Equal(t, "[2Jhello", msg.(*domain.UserMessage).Text)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("expected the message")
+	}
+	assert.Len(t, send, 0)
+}
+
+func TestClient_ReadMessages_MessageTooLarge(t *testing.T) {
+	configuration := NewTestingClientConfiguration()
+	configuration.MaxMessageSize = 1024
+	connection := NewMockConnection()
+	defer connection.Close()
+	spyLogger := NewSpyLogger()
+	recv := make(chan domain.Messager, 3)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
+
+	done := make(chan struct{})
+	go func() {
+		client.ReadMessages(t.Context())
+		close(done)
+	}()
+
+	connection.EnqueueError(network.ErrMessageTooLarge)
+
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("ReadMessages should have exited")
+	}
+	assert.Equal(t, int64(1024), connection.ReadLimit())
+	assert.Len(t, spyLogger.Errors(), 0)
+	_, open := <-recv
+	assert.False(t, open)
+}
+
 func TestClient_ReadMessages_ReadError(t *testing.T) {
 	ctx := t.Context()
 
@@ -239,7 +430,7 @@ func TestClient_ReadMessages_ReadError(t *testing.T) {
 	defer connection.Close()
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, nil, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
 
 	go client.ReadMessages(ctx)
 
@@ -263,7 +454,7 @@ func TestClient_ReadMessages_RecvChannelFull(t *testing.T) {
 	defer connection.Close()
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 1) // Small buffer
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, nil, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
 
 	go client.ReadMessages(ctx)
 
@@ -297,7 +488,7 @@ func TestClient_ReadMessages_ContextCancellation(t *testing.T) {
 	defer connection.Close()
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, nil, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, nil, configuration, spyLogger)
 
 	go client.ReadMessages(ctx)
 
@@ -339,7 +530,7 @@ func TestClient_WriteMessages_ContextCancellation(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	done := make(chan bool)
 	go func() {
@@ -380,7 +571,7 @@ func TestClient_WriteMessages_SendChannelClosed(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	done := make(chan bool)
 	go func() {
@@ -432,7 +623,7 @@ func TestClient_WriteMessages_PingSentPeriodically(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	done := make(chan bool)
 	go func() {
@@ -473,7 +664,7 @@ func TestClient_WriteMessages_WriteTextMessageError(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	go client.WriteMessages(ctx)
 
@@ -498,7 +689,7 @@ func TestClient_WriteMessages_WritePingError(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	done := make(chan bool)
 	go func() {
@@ -529,7 +720,7 @@ func TestClient_WriteMessages_WriteCloseMessageError(t *testing.T) {
 	spyLogger := NewSpyLogger()
 	recv := make(chan domain.Messager, 3)
 	send := make(chan domain.Messager, 3)
-	client := infrastructure.NewClient("1", "Jane Doe", connection, recv, send, configuration, spyLogger)
+	client := infrastructure.NewClient("1", "Jane Doe", connection, JSONCodec, recv, send, configuration, spyLogger)
 
 	done := make(chan bool)
 	go func() {
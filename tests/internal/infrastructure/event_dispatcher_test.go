@@ -0,0 +1,243 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/internal/server/infrastructure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NopLogger stands in for SpyLogger where the workers of the dispatcher log concurrently
+type NopLogger struct{}
+
+func (NopLogger) Debug(string, map[string]any) {}
+func (NopLogger) Info(string, map[string]any)  {}
+func (NopLogger) Error(string, map[string]any) {}
+
+type SpyDeadLetterLog struct {
+	mu      sync.Mutex
+	letters []infrastructure.DeadLetter
+}
+
+func (l *SpyDeadLetterLog) Record(letter infrastructure.DeadLetter) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.letters = append(l.letters, letter)
+	return nil
+}
+
+func (l *SpyDeadLetterLog) Letters() []infrastructure.DeadLetter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]infrastructure.DeadLetter(nil), l.letters...)
+}
+
+// Receiver answers with the given statuses in turn, the last one over and over, and keeps what it was posted
+type Receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func NewReceiver(t *testing.T, statuses ...int) *Receiver {
+	receiver := &Receiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		status := receiver.statuses[0]
+		if len(receiver.statuses) > 1 {
+			receiver.statuses = receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+
+	return receiver
+}
+
+func (r *Receiver) Received() ([]*http.Request, [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*http.Request(nil), r.requests...), append([][]byte(nil), r.bodies...)
+}
+
+var deliveryConfig = infrastructure.DeliveryConfiguration{
+	Workers:     1,
+	QueueSize:   4,
+	Timeout:     time.Second,
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  4 * time.Millisecond,
+}
+
+func subscriptionTo(url string) domain.EventSubscription {
+	return domain.EventSubscription{Id: "sub", RoomId: "general", Url: url, Events: []domain.EventKind{domain.EventUserJoined}, Secret: "secret"}
+}
+
+var joined = domain.RoomEvent{Id: "event", Kind: domain.EventUserJoined, RoomId: "general", Timestamp: time.Date(2025, 12, 7, 0, 0, 0, 0, time.UTC), Name: "Jane"}
+
+func startDispatcher(t *testing.T, config infrastructure.DeliveryConfiguration) (*infrastructure.HTTPEventDispatcher, *SpyDeadLetterLog) {
+	return startDispatcherWith(t, &http.Client{}, config)
+}
+
+func startDispatcherWith(t *testing.T, client *http.Client, config infrastructure.DeliveryConfiguration) (*infrastructure.HTTPEventDispatcher, *SpyDeadLetterLog) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	deadLetters := &SpyDeadLetterLog{}
+	dispatcher := infrastructure.NewHTTPEventDispatcher(client, config, deadLetters, NopLogger{})
+	dispatcher.Start(ctx)
+
+	return dispatcher, deadLetters
+}
+
+func TestHTTPEventDispatcher_DeliversSigned(t *testing.T) {
+	receiver := NewReceiver(t, http.StatusNoContent)
+	dispatcher, deadLetters := startDispatcher(t, deliveryConfig)
+
+	dispatcher.Dispatch(subscriptionTo(receiver.URL), joined)
+
+	require.Eventually(t, func() bool {
+		requests, _ := receiver.Received()
+		return len(requests) == 1
+	}, time.Second, time.Millisecond)
+	requests, bodies := receiver.Received()
+
+	var event domain.RoomEvent
+	require.NoError(t, json.Unmarshal(bodies[0], &event))
+	assert.Equal(t, joined, event)
+	assert.Equal(t, "user_joined", requests[0].Header.Get(infrastructure.EventHeader))
+	assert.Equal(t, "event", requests[0].Header.Get(infrastructure.DeliveryHeader))
+	timestamp, err := strconv.ParseInt(requests[0].Header.Get(infrastructure.TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256="+domain.SignEvent("secret", timestamp, bodies[0]), requests[0].Header.Get(infrastructure.SignatureHeader))
+	assert.Empty(t, deadLetters.Letters())
+}
+
+func TestHTTPEventDispatcher_RetriesFailures(t *testing.T) {
+	receiver := NewReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	dispatcher, deadLetters := startDispatcher(t, deliveryConfig)
+
+	dispatcher.Dispatch(subscriptionTo(receiver.URL), joined)
+
+	require.Eventually(t, func() bool {
+		requests, _ := receiver.Received()
+		return len(requests) == 3
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	requests, _ := receiver.Received()
+	assert.Len(t, requests, 3)
+	for _, request := range requests {
+		assert.Equal(t, "event", request.Header.Get(infrastructure.DeliveryHeader))
+	}
+	assert.Empty(t, deadLetters.Letters())
+}
+
+func TestHTTPEventDispatcher_DeadLetters(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		expectedAttempts int
+	}{
+		{"out of attempts", []int{http.StatusInternalServerError}, 3},
+		{"rejected", []int{http.StatusBadRequest}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := NewReceiver(t, tt.statuses...)
+			dispatcher, deadLetters := startDispatcher(t, deliveryConfig)
+
+			dispatcher.Dispatch(subscriptionTo(receiver.URL), joined)
+
+			require.Eventually(t, func() bool {
+				return len(deadLetters.Letters()) == 1
+			}, time.Second, time.Millisecond)
+			letter := deadLetters.Letters()[0]
+			assert.Equal(t, "sub", letter.Subscription)
+			assert.Equal(t, receiver.URL, letter.Url)
+			assert.Equal(t, joined, letter.Event)
+			assert.Equal(t, tt.expectedAttempts, letter.Attempts)
+			assert.NotEmpty(t, letter.Error)
+			requests, _ := receiver.Received()
+			assert.Len(t, requests, tt.expectedAttempts)
+		})
+	}
+}
+
+func TestHTTPEventDispatcher_QueueFull(t *testing.T) {
+	config := deliveryConfig
+	config.Workers = 0
+	config.QueueSize = 1
+	dispatcher, deadLetters := startDispatcher(t, config)
+
+	dispatcher.Dispatch(subscriptionTo("http://localhost:1"), joined)
+	dispatcher.Dispatch(subscriptionTo("http://localhost:1"), joined)
+
+	letters := deadLetters.Letters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, 0, letters[0].Attempts)
+}
+
+func TestFileDeadLetterLog(t *testing.T) {
+	path := t.TempDir() + "/dead_letters.jsonl"
+	deadLetters := infrastructure.NewFileDeadLetterLog(path)
+
+	require.NoError(t, deadLetters.Record(infrastructure.DeadLetter{Subscription: "a", Event: joined, Attempts: 3, Error: "boom"}))
+	require.NoError(t, deadLetters.Record(infrastructure.DeadLetter{Subscription: "b", Event: joined, Attempts: 1, Error: "bang"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var letter infrastructure.DeadLetter
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &letter))
+	assert.Equal(t, "b", letter.Subscription)
+	assert.Equal(t, joined, letter.Event)
+}
+
+func TestCallbackClient_RefusesPrivateAddresses(t *testing.T) {
+	// the receiver listens on loopback, which the subscription might have resolved to only after
+	// it was validated
+	receiver := NewReceiver(t, http.StatusOK)
+	dispatcher, deadLetters := startDispatcherWith(t, infrastructure.NewCallbackClient(), deliveryConfig)
+
+	dispatcher.Dispatch(subscriptionTo(receiver.URL), joined)
+
+	require.Eventually(t, func() bool { return len(deadLetters.Letters()) == 1 }, time.Second, time.Millisecond)
+	letter := deadLetters.Letters()[0]
+	// there is no point in trying again
+	assert.Equal(t, 1, letter.Attempts)
+	assert.Contains(t, letter.Error, domain.ErrPrivateCallbackUrl.Error())
+	requests, _ := receiver.Received()
+	assert.Empty(t, requests)
+}
+
+func TestCallbackClient_RefusesRedirects(t *testing.T) {
+	client := infrastructure.NewCallbackClient()
+	request, err := http.NewRequest(http.MethodPost, "https://203.0.113.10/elsewhere", nil)
+	require.NoError(t, err)
+
+	assert.Error(t, client.CheckRedirect(request, []*http.Request{request}))
+}
@@ -0,0 +1,311 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/internal/server/application"
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/internal/server/infrastructure"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewChatServer(t *testing.T) string {
+	server := httptest.NewServer(newHandler(t))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "?name=jane"
+}
+
+// NewSSEChatServer serves the chat over server-sent events, returning the event and message endpoints
+func NewSSEChatServer(t *testing.T) (string, string) {
+	handler := newHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", handler.ServeEvents)
+	mux.HandleFunc("POST /messages", handler.ServeMessages)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server.URL + "/events?name=jane", server.URL + "/messages"
+}
+
+func newHandler(t *testing.T) *infrastructure.Handler {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	notifier := infrastructure.NewClientNotifier(NopLogger{}, make(map[string]*infrastructure.Client))
+	chatService := application.NewChatService(
+		application.NewRoomRepository(application.NewChatRoom("general", "general", application.NewClientRegistry())),
+		application.NewInMemoryMessageStore(100),
+		application.NewWebhookRegistry(application.RandomToken),
+		application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, NopDispatcher{}),
+		notifier,
+		time.Now,
+		application.PresenceConfiguration{},
+		8,
+		8,
+		NopLogger{},
+	)
+	chatService.Start(ctx)
+
+	clientConfig := infrastructure.ClientConfiguration{
+		WriteWait:       time.Second,
+		PongWait:        time.Minute,
+		PingPeriod:      time.Minute,
+		RecieveChanWait: time.Second,
+		HandshakeWait:   50 * time.Millisecond,
+		MaxMessageSize:  4096,
+		SendChannelSize: 16,
+		RecvChannelSize: 16,
+	}
+	return infrastructure.NewHandler(websocket.Upgrader{Subprotocols: protocol.Subprotocols()}, chatService, notifier, clientConfig, application.UUIDGen, NopLogger{}, ctx)
+}
+
+func dialChat(t *testing.T, address string, subprotocols ...string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: subprotocols}
+	conn, _, err := dialer.Dial(address, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func writeFrame(t *testing.T, conn *websocket.Conn, msg domain.Messager) {
+	data, err := JSONCodec.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, data))
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) protocol.Envelope {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var envelope protocol.Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+
+	return envelope
+}
+
+func TestHandler_Handshake(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	writeFrame(t, conn, protocol.HelloMessage{
+		Version:      protocol.Version + 1,
+		Capabilities: []protocol.Capability{"teleport", protocol.CapabilityWebhooks},
+	})
+
+	envelope := readFrame(t, conn)
+	require.Equal(t, protocol.Welcome, envelope.Type)
+	var welcome protocol.WelcomeMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &welcome))
+	assert.Equal(t, protocol.WelcomeMessage{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilityWebhooks}}, welcome)
+	// the room comes after the welcome
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+}
+
+func TestHandler_ClientWithoutHandshake(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+}
+
+func TestHandler_ClientWithoutHandshakeSpeaksFirst(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	// what a client predating the handshake says first is handled once it is in the room
+	writeFrame(t, conn, &domain.ListRoomsMessage{})
+
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+	envelope := readFrame(t, conn)
+	require.Equal(t, protocol.RoomList, envelope.Type)
+	var rooms domain.RoomListSystemMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &rooms))
+	require.Len(t, rooms.Rooms, 1)
+	assert.Equal(t, "general", rooms.Rooms[0].Id)
+}
+
+func TestHandler_ChatAsTheFirstFrame(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	// a client predating the handshake may chat right away, which goes to the room once it is in
+	writeFrame(t, conn, domain.NewUserMessage("first", time.Now(), "jane"))
+
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+	envelope := readFrame(t, conn)
+	require.Equal(t, protocol.Chat, envelope.Type)
+	var chat domain.UserMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &chat))
+	assert.Equal(t, "first", chat.Text)
+}
+
+func TestHandler_ChatRightAfterTheHello(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	writeFrame(t, conn, protocol.HelloMessage{Version: protocol.Version})
+	writeFrame(t, conn, domain.NewUserMessage("right away", time.Now(), "jane"))
+
+	assert.Equal(t, protocol.Welcome, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.Chat, readFrame(t, conn).Type)
+}
+
+func TestHandler_NameTaken(t *testing.T) {
+	address := NewChatServer(t)
+	first := dialChat(t, address)
+	assert.Equal(t, protocol.UserJoined, readFrame(t, first).Type)
+
+	second := dialChat(t, address)
+	writeFrame(t, second, protocol.HelloMessage{Version: protocol.Version})
+
+	assert.Equal(t, protocol.Welcome, readFrame(t, second).Type)
+	envelope := readFrame(t, second)
+	require.Equal(t, protocol.Error, envelope.Type)
+	var refusal domain.ErrorSystemMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &refusal))
+	assert.Equal(t, protocol.CodeNameTaken, refusal.Code)
+
+	require.NoError(t, second.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := second.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+}
+
+func TestHandler_NameWithEscape(t *testing.T) {
+	address := strings.Replace(NewChatServer(t), "name=jane", "name=%1B%5B2Jjane", 1)
+
+	_, resp, err := websocket.DefaultDialer.Dial(address, nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandler_UnsupportedVersion(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+
+	writeFrame(t, conn, protocol.HelloMessage{Version: protocol.MinVersion - 1})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseProtocolError), err)
+}
+
+func TestHandler_BinaryFrames(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t), protocol.SubprotocolMsgPack, protocol.SubprotocolJSON)
+	require.Equal(t, protocol.SubprotocolMsgPack, conn.Subprotocol())
+	codec := domain.NewCodec(protocol.SubprotocolMsgPack)
+
+	data, err := codec.Marshal(protocol.HelloMessage{Version: protocol.Version})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	frameType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, frameType)
+	msg, err := gchad.NewCodec(protocol.SubprotocolMsgPack).Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.WelcomeMessage{Version: protocol.Version}, msg)
+}
+
+func TestHandler_MessageTooLarge(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+
+	writeFrame(t, conn, domain.NewUserMessage(strings.Repeat("a", 4096), time.Now(), "jane"))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err)
+}
+
+func TestHandler_InvalidMessage(t *testing.T) {
+	conn := dialChat(t, NewChatServer(t))
+	assert.Equal(t, protocol.UserJoined, readFrame(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readFrame(t, conn).Type)
+
+	writeFrame(t, conn, domain.NewUserMessage("\x00 \x07", time.Now(), "jane"))
+
+	envelope := readFrame(t, conn)
+	require.Equal(t, protocol.Error, envelope.Type)
+	var reply domain.ErrorSystemMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reply))
+	assert.Equal(t, domain.ErrorSystemMessage{Code: protocol.CodeEmptyMessage, Message: domain.ErrEmptyMessage.Error(), Request: protocol.Chat}, reply)
+}
+
+func dialSSEChat(t *testing.T, events string, messages string) *network.SSEClientConnection {
+	conn, err := network.DialSSE(context.Background(), nil, events, messages, NopLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func readEvent(t *testing.T, conn network.Connection) protocol.Envelope {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var envelope protocol.Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+
+	return envelope
+}
+
+func TestHandler_ServeEvents(t *testing.T) {
+	events, messages := NewSSEChatServer(t)
+	conn := dialSSEChat(t, events, messages)
+
+	data, err := JSONCodec.Marshal(protocol.HelloMessage{Version: protocol.Version, Capabilities: protocol.Capabilities()})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteTextMessage(data))
+	assert.Equal(t, protocol.Welcome, readEvent(t, conn).Type)
+	assert.Equal(t, protocol.UserJoined, readEvent(t, conn).Type)
+	assert.Equal(t, protocol.MemberList, readEvent(t, conn).Type)
+
+	data, err = JSONCodec.MarshalRequest(domain.NewUserMessage("over the proxy", time.Now(), "jane"), "1")
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteTextMessage(data))
+	envelope := readEvent(t, conn)
+	require.Equal(t, protocol.Ack, envelope.Type)
+	var ack domain.AckSystemMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &ack))
+	assert.Equal(t, "1", ack.RequestId)
+	envelope = readEvent(t, conn)
+	require.Equal(t, protocol.Chat, envelope.Type)
+	var chat domain.UserMessage
+	require.NoError(t, json.Unmarshal(envelope.Payload, &chat))
+	assert.Equal(t, "over the proxy", chat.Text)
+}
+
+func TestHandler_ServeEvents_UnknownRoom(t *testing.T) {
+	events, messages := NewSSEChatServer(t)
+
+	_, err := network.DialSSE(context.Background(), nil, events+"&room=lobby", messages, NopLogger{})
+	assert.ErrorIs(t, err, network.ErrBadSSEHandshake)
+	assert.ErrorContains(t, err, "no such room: lobby")
+}
+
+func TestHandler_ServeMessages_UnknownSession(t *testing.T) {
+	_, messages := NewSSEChatServer(t)
+
+	resp, err := http.Post(messages+"?session=guessed", "text/plain", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
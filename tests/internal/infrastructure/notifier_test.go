@@ -1,7 +1,6 @@
 package infrastructure_test
 
 import (
-	"context"
 	"testing"
 	"time"
 
@@ -25,6 +24,7 @@ func TestClientNotifier_BroadCastToRoom(t *testing.T) {
 				client.Id(),
 				client.Name(),
 				nil,
+				JSONCodec,
 				nil,
 				make(chan domain.Messager, clientConfiguration.SendChannelSize),
 				clientConfiguration,
@@ -53,28 +53,23 @@ func TestClientNotifier_BroadCastToRoom(t *testing.T) {
 			t.Errorf("expected a user message, got %T", msgIntf)
 		}
 
-		assert.Equal(t, "Hello test", msg.Message)
+		assert.Equal(t, "Hello test", msg.Text)
 	}
 }
 
 func TestClientNotifier_RegisterUnregisterClient(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	clientConfiguration := infrastructure.ClientConfiguration{
 		SendChannelSize: 1,
 	}
 	adapterSpyLogger := SpyLogger{calls: make([]LogCall, 0)}
 	clients := []*infrastructure.Client{
-		infrastructure.NewClient("1", "Jane Doe", nil, nil, nil, clientConfiguration, &adapterSpyLogger),
-		infrastructure.NewClient("2", "John Doe", nil, nil, nil, clientConfiguration, &adapterSpyLogger),
+		infrastructure.NewClient("1", "Jane Doe", nil, JSONCodec, nil, nil, clientConfiguration, &adapterSpyLogger),
+		infrastructure.NewClient("2", "John Doe", nil, JSONCodec, nil, nil, clientConfiguration, &adapterSpyLogger),
 	}
 	spyLogger := SpyLogger{calls: make([]LogCall, 0)}
 	registry := make(map[string]*infrastructure.Client)
 	notifier := infrastructure.NewClientNotifier(&spyLogger, registry)
 
-	notifier.Start(ctx)
-
 	// first register all the clients
 	for _, client := range clients {
 		notifier.RegisterClient(client)
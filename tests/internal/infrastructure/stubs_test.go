@@ -1,12 +1,16 @@
 package infrastructure_test
 
+import "sync"
+
 type LogCall struct {
 	msg    string
 	fields map[string]any
 	level  string
 }
 
+// SpyLogger is written to from the pumps and read from the test
 type SpyLogger struct {
+	mu    sync.Mutex
 	calls []LogCall
 }
 
@@ -14,36 +18,41 @@ func NewSpyLogger() *SpyLogger {
 	return &SpyLogger{calls: make([]LogCall, 0)}
 }
 
+func (l *SpyLogger) log(level string, msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: level})
+}
+
 func (l *SpyLogger) Error(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "ERROR"})
+	l.log("ERROR", msg, fields)
 }
 func (l *SpyLogger) Info(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "INFO"})
+	l.log("INFO", msg, fields)
 }
 func (l *SpyLogger) Debug(msg string, fields map[string]any) {
-	l.calls = append(l.calls, LogCall{msg: msg, fields: fields, level: "DEBUG"})
+	l.log("DEBUG", msg, fields)
 }
 
 func (l *SpyLogger) Errors() []LogCall {
-	errors := make([]LogCall, 0)
-
-	for _, call := range l.calls {
-		if call.level == "ERROR" {
-			errors = append(errors, call)
-		}
-	}
-
-	return errors
+	return l.callsAt("ERROR")
 }
 
 func (l *SpyLogger) Debugs() []LogCall {
-	errors := make([]LogCall, 0)
+	return l.callsAt("DEBUG")
+}
+
+func (l *SpyLogger) callsAt(level string) []LogCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
+	calls := make([]LogCall, 0)
 	for _, call := range l.calls {
-		if call.level == "DEBUG" {
-			errors = append(errors, call)
+		if call.level == level {
+			calls = append(calls, call)
 		}
 	}
 
-	return errors
+	return calls
 }
@@ -0,0 +1,72 @@
+package infrastructure_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/application"
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/internal/server/infrastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+type NopNotifier struct{}
+
+func (NopNotifier) BroadcastToRoom(*application.ChatRoom, domain.Messager) {}
+func (NopNotifier) SendToClient(string, domain.Messager)                   {}
+
+type NopDispatcher struct{}
+
+func (NopDispatcher) Dispatch(domain.EventSubscription, domain.RoomEvent) {}
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{"posted", "secret", `{"text":"the build failed","attachments":[{"title":"build #12","url":"https://ci.example/12"}]}`, http.StatusAccepted},
+		{"unknown token", "guess", `{"text":"hi"}`, http.StatusNotFound},
+		{"not json", "secret", `text=hi`, http.StatusBadRequest},
+		{"nothing to say", "secret", `{"text":""}`, http.StatusBadRequest},
+		{"too large", "secret", `{"text":"` + strings.Repeat("a", 100<<10) + `"}`, http.StatusBadRequest},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	webhooks := application.NewWebhookRegistry(func() string { return "secret" })
+	webhooks.Create("general", "ci", "Jane", time.Now())
+	chatService := application.NewChatService(
+		application.NewRoomRepository(application.NewChatRoom("general", "general", application.NewClientRegistry())),
+		application.NewInMemoryMessageStore(100),
+		webhooks,
+		application.NewEventSubscriptions(application.UUIDGen, application.RandomToken, NopDispatcher{}),
+		NopNotifier{},
+		time.Now,
+		application.PresenceConfiguration{},
+		8,
+		8,
+		NopLogger{},
+	)
+	chatService.Start(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /hooks/{token}", infrastructure.NewWebhookHandler(chatService, NopLogger{}))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/hooks/"+tt.token, strings.NewReader(tt.body))
+			response := httptest.NewRecorder()
+
+			mux.ServeHTTP(response, request)
+
+			assert.Equal(t, tt.expectedStatus, response.Code, response.Body.String())
+		})
+	}
+}
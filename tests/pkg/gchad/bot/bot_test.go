@@ -0,0 +1,198 @@
+package bot_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/gchad/bot"
+	"github.com/iomallach/gchad/pkg/gchad/bottest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run starts the bot and waits for it to be in the rooms, the returned func stops it and tells
+// what Run returned
+func run(t *testing.T, b *bot.Bot, server *bottest.Server, name string, rooms ...string) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- b.Run(ctx, server.Address(), rooms...)
+	}()
+
+	joined := rooms
+	if len(joined) == 0 {
+		joined = []string{bottest.DefaultRoom}
+	}
+	for _, room := range joined {
+		server.WaitForMember(t, room, name)
+	}
+
+	stop := sync.OnceValue(func() error {
+		cancel()
+		select {
+		case err := <-result:
+			return err
+		case <-time.After(bottest.Timeout):
+			t.Error("the bot did not stop")
+			return nil
+		}
+	})
+	t.Cleanup(func() { stop() })
+
+	return stop
+}
+
+func echoBot(options ...bot.Option) *bot.Bot {
+	b := bot.New("echo", options...)
+	b.Command("echo", func(ctx *bot.Context) error {
+		return ctx.Reply(ctx.Text)
+	})
+
+	return b
+}
+
+func TestBot_Command(t *testing.T) {
+	server := bottest.NewServer(t)
+	run(t, echoBot(), server, "echo")
+
+	server.Say("general", "jane", "!echo  hello there ")
+	reply := server.Expect(t, "general")
+
+	assert.Equal(t, "echo", reply.From)
+	assert.Equal(t, "@jane hello there", reply.Text)
+}
+
+func TestBot_CommandArgs(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := bot.New("echo")
+	b.Command("count", func(ctx *bot.Context) error {
+		return ctx.Say(ctx.Args[len(ctx.Args)-1])
+	})
+	run(t, b, server, "echo")
+
+	server.Say("general", "jane", "!count one two  three")
+	assert.Equal(t, "three", server.Expect(t, "general").Text)
+
+	server.Say("general", "jane", "!counting one")
+	server.ExpectSilence(t, "general", 100*time.Millisecond)
+}
+
+func TestBot_Prefix(t *testing.T) {
+	server := bottest.NewServer(t)
+	run(t, echoBot(bot.WithPrefix("/")), server, "echo")
+
+	server.Say("general", "jane", "!echo not me")
+	server.ExpectSilence(t, "general", 100*time.Millisecond)
+
+	server.Say("general", "jane", "/echo me")
+	assert.Equal(t, "@jane me", server.Expect(t, "general").Text)
+}
+
+func TestBot_Hear(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := bot.New("tickets")
+	b.Hear(`#(\d+)`, func(ctx *bot.Context) error {
+		return ctx.Say("https://tracker.example/" + ctx.Match[1])
+	})
+	run(t, b, server, "tickets")
+
+	server.Say("general", "jane", "is #42 fixed?")
+
+	assert.Equal(t, "https://tracker.example/42", server.Expect(t, "general").Text)
+}
+
+func TestBot_Mention(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := echoBot()
+	b.Mention(func(ctx *bot.Context) error {
+		return ctx.Reply("I am " + ctx.BotName())
+	})
+	run(t, b, server, "echo")
+
+	server.Say("general", "jane", "@echo who are you?")
+	assert.Equal(t, "@jane I am echo", server.Expect(t, "general").Text)
+
+	server.Say("general", "jane", "echo who are you?")
+	server.ExpectSilence(t, "general", 100*time.Millisecond)
+}
+
+func TestBot_FirstRouteWins(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := echoBot()
+	b.Hear(`.*`, func(ctx *bot.Context) error {
+		return ctx.Say("heard")
+	})
+	run(t, b, server, "echo")
+
+	server.Say("general", "jane", "!echo hi")
+	assert.Equal(t, "@jane hi", server.Expect(t, "general").Text)
+	server.ExpectSilence(t, "general", 100*time.Millisecond)
+}
+
+func TestBot_IgnoresBots(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := bot.New("parrot")
+	b.Hear(`.*`, func(ctx *bot.Context) error {
+		return ctx.Say(ctx.Text)
+	})
+	run(t, b, server, "parrot")
+
+	other, err := gchad.Dial(context.Background(), server.Address(), "other", gchad.AsBot())
+	require.NoError(t, err)
+	defer other.Close(context.Background())
+	require.Eventually(t, func() bool {
+		for _, member := range other.Members() {
+			if member.Name == "parrot" {
+				return member.Bot
+			}
+		}
+		return false
+	}, bottest.Timeout, 10*time.Millisecond)
+
+	require.NoError(t, other.Send(context.Background(), "anyone?"))
+	assert.Equal(t, "other", server.Expect(t, "general").From)
+
+	// neither the other bot nor the echo of itself gets an answer
+	server.ExpectSilence(t, "general", 200*time.Millisecond)
+}
+
+func TestBot_MarkedAsBot(t *testing.T) {
+	server := bottest.NewServer(t)
+	run(t, echoBot(), server, "echo")
+
+	assert.True(t, server.WaitForMember(t, "general", "echo").Bot)
+}
+
+func TestBot_Rooms(t *testing.T) {
+	server := bottest.NewServer(t)
+	b := bot.New("echo")
+	b.Command("where", func(ctx *bot.Context) error {
+		return ctx.Say(ctx.Room)
+	})
+	run(t, b, server, "echo", "builds", "random")
+
+	server.Say("random", "jane", "!where")
+	assert.Equal(t, "random", server.Expect(t, "random").Text)
+	server.ExpectSilence(t, "builds", 100*time.Millisecond)
+}
+
+func TestBot_RunStopsWithTheContext(t *testing.T) {
+	server := bottest.NewServer(t)
+	stop := run(t, echoBot(), server, "echo")
+
+	assert.NoError(t, stop())
+
+	server.Say("general", "jane", "!echo still there?")
+	server.ExpectSilence(t, "general", 100*time.Millisecond)
+}
+
+func TestBot_RunFailsToJoin(t *testing.T) {
+	server := bottest.NewServer(t)
+
+	err := bot.New("").Run(context.Background(), server.Address(), "general")
+
+	assert.ErrorContains(t, err, `failed to join room "general"`)
+}
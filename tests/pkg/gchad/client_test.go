@@ -0,0 +1,416 @@
+package gchad_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FakeServer lets every client into the room and hands the connection over to the test, those with
+// the secret token as moderators. It predates the handshake unless told what to welcome clients with.
+type FakeServer struct {
+	*httptest.Server
+	conns   chan *websocket.Conn
+	version uint64
+	welcome *protocol.WelcomeMessage
+}
+
+func NewFakeServer(t *testing.T) *FakeServer {
+	server := &FakeServer{conns: make(chan *websocket.Conn, 4)}
+	upgrader := websocket.Upgrader{}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "empty name", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		messageType, _ := read(t, conn)
+		assert.Equal(t, protocol.Hello, messageType)
+		if server.welcome != nil {
+			write(t, conn, *server.welcome)
+		}
+
+		role := gchad.RoleMember
+		if r.URL.Query().Get("moderator_token") == "secret" {
+			role = gchad.RoleModerator
+		}
+		server.version++
+		write(t, conn, gchad.UserJoinedMessage{Name: name, Role: role, Version: server.version})
+		write(t, conn, gchad.MemberListMessage{
+			Version: server.version,
+			Members: []gchad.Member{{Name: "alice"}, {Name: name}},
+		})
+		server.conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *FakeServer) Address() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/chat"
+}
+
+func (s *FakeServer) Accept(t *testing.T) *websocket.Conn {
+	select {
+	case conn := <-s.conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("no client connected")
+		return nil
+	}
+}
+
+func write(t *testing.T, conn *websocket.Conn, msg gchad.Message) {
+	data, err := gchad.NewCodec(protocol.SubprotocolJSON).Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, data))
+}
+
+// read returns the type and the text of what the client wrote, if anything
+func read(t *testing.T, conn *websocket.Conn) (gchad.MessageType, string) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var envelope struct {
+		Type    gchad.MessageType `json:"type"`
+		Payload struct {
+			Text string `json:"text"`
+		} `json:"payload"`
+	}
+	require.NoError(t, json.Unmarshal(data, &envelope))
+
+	return envelope.Type, envelope.Payload.Text
+}
+
+func next(t *testing.T, subscription *gchad.Subscription) gchad.Message {
+	select {
+	case msg, ok := <-subscription.Events():
+		require.True(t, ok, "the subscription is closed")
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func dial(t *testing.T, server *FakeServer, opts ...gchad.Option) *gchad.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	client, err := gchad.Dial(ctx, server.Address(), "bot", opts...)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestDial_EntersTheRoom(t *testing.T) {
+	server := NewFakeServer(t)
+	events := gchad.NewSubscription()
+
+	client := dial(t, server, gchad.WithRoom("builds"), gchad.WithSubscription(events))
+	server.Accept(t)
+
+	assert.Equal(t, "bot", client.Name())
+	assert.Equal(t, "builds", client.Room())
+	assert.Equal(t, []gchad.Member{{Name: "alice"}, {Name: "bot"}}, client.Members())
+	// the subscription passed to Dial sees the client entering the room
+	assert.Equal(t, gchad.TypeUserJoined, next(t, events).MessageType())
+	assert.Equal(t, gchad.TypeMemberList, next(t, events).MessageType())
+}
+
+func TestDial_Handshake(t *testing.T) {
+	server := NewFakeServer(t)
+	server.welcome = &protocol.WelcomeMessage{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilitySearch}}
+	events := gchad.NewSubscription()
+
+	client := dial(t, server, gchad.WithSubscription(events))
+	server.Accept(t)
+
+	assert.Equal(t, protocol.Session{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilitySearch}}, client.Protocol())
+	assert.True(t, client.Supports(protocol.CapabilitySearch))
+	assert.False(t, client.Supports(protocol.CapabilityWebhooks))
+	// the welcome is kept from the subscriptions
+	assert.Equal(t, gchad.TypeUserJoined, next(t, events).MessageType())
+}
+
+func TestDial_ServerWithoutHandshake(t *testing.T) {
+	server := NewFakeServer(t)
+
+	client := dial(t, server)
+	server.Accept(t)
+
+	assert.Equal(t, protocol.Legacy(), client.Protocol())
+	assert.False(t, client.Supports(protocol.CapabilitySearch))
+}
+
+func TestDial_AsModerator(t *testing.T) {
+	server := NewFakeServer(t)
+	events := gchad.NewSubscription()
+
+	dial(t, server, gchad.AsModerator("secret"), gchad.WithSubscription(events))
+	server.Accept(t)
+
+	joined, ok := next(t, events).(gchad.UserJoinedMessage)
+	require.True(t, ok)
+	assert.Equal(t, gchad.RoleModerator, joined.Role)
+}
+
+func TestDial_Rejected(t *testing.T) {
+	server := NewFakeServer(t)
+	events := gchad.NewSubscription()
+
+	_, err := gchad.Dial(context.Background(), server.Address(), "", gchad.WithSubscription(events))
+
+	require.Error(t, err)
+	_, open := <-events.Events()
+	assert.False(t, open)
+}
+
+func TestDial_InvalidCompression(t *testing.T) {
+	server := NewFakeServer(t)
+
+	_, err := gchad.Dial(context.Background(), server.Address(), "bot", gchad.WithCompression(network.Compression{Level: 42}))
+
+	assert.ErrorIs(t, err, network.ErrInvalidCompressionLevel)
+}
+
+func TestClient_SendAndClose(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+
+	require.NoError(t, client.Send(context.Background(), "first"))
+	require.NoError(t, client.Send(context.Background(), "second"))
+	require.NoError(t, client.Close(context.Background()))
+
+	messageType, text := read(t, conn)
+	assert.Equal(t, gchad.TypeChat, messageType)
+	assert.Equal(t, "first", text)
+	_, text = read(t, conn)
+	assert.Equal(t, "second", text)
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNoStatusReceived), err)
+
+	assert.ErrorIs(t, client.Send(context.Background(), "too late"), gchad.ErrClosed)
+	assert.NoError(t, client.Err())
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+	chat := client.Subscribe(gchad.TypeChat)
+	everything := client.Subscribe()
+
+	write(t, conn, gchad.UserLeftMessage{Name: "alice", Version: 2})
+	write(t, conn, gchad.ChatMessage{From: "carol", Text: "hi"})
+
+	assert.Equal(t, gchad.ChatMessage{From: "carol", Text: "hi"}, next(t, chat))
+	assert.Equal(t, gchad.TypeUserLeft, next(t, everything).MessageType())
+	assert.Equal(t, gchad.TypeChat, next(t, everything).MessageType())
+	assert.Equal(t, []gchad.Member{{Name: "bot"}}, client.Members())
+
+	require.NoError(t, client.Close(context.Background()))
+	_, open := <-chat.Events()
+	assert.False(t, open)
+}
+
+func TestClient_PublishesRejections(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+	rejections := client.Subscribe(gchad.TypeError)
+
+	write(t, conn, gchad.ErrorMessage{Code: protocol.CodeNameTaken, Message: "the name is already taken", Request: protocol.Rename})
+
+	rejection := next(t, rejections).(gchad.ErrorMessage)
+	assert.Equal(t, protocol.CodeNameTaken, rejection.Code)
+	assert.EqualError(t, rejection, "rename: the name is already taken")
+}
+
+// readRequestId returns the request id of what the client wrote, if any
+func readRequestId(t *testing.T, conn *websocket.Conn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var envelope protocol.Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+
+	return envelope.RequestId
+}
+
+func withAcks(server *FakeServer) {
+	server.welcome = &protocol.WelcomeMessage{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilityAcks}}
+}
+
+func wait(t *testing.T, delivery *gchad.Delivery) (gchad.AckMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return delivery.Wait(ctx)
+}
+
+func TestClient_Post_Acknowledged(t *testing.T) {
+	server := NewFakeServer(t)
+	withAcks(server)
+	client := dial(t, server)
+	conn := server.Accept(t)
+
+	delivery, err := client.Post(context.Background(), "hello")
+	require.NoError(t, err)
+	requestId := readRequestId(t, conn)
+	assert.Equal(t, delivery.RequestId(), requestId)
+	write(t, conn, gchad.AckMessage{RequestId: requestId, Id: "42", Sequence: 7})
+
+	ack, err := wait(t, delivery)
+	require.NoError(t, err)
+	assert.Equal(t, gchad.AckMessage{RequestId: requestId, Id: "42", Sequence: 7}, ack)
+}
+
+func TestClient_Post_Rejected(t *testing.T) {
+	server := NewFakeServer(t)
+	withAcks(server)
+	client := dial(t, server)
+	conn := server.Accept(t)
+
+	first, err := client.Post(context.Background(), "first")
+	require.NoError(t, err)
+	second, err := client.Post(context.Background(), "second")
+	require.NoError(t, err)
+	readRequestId(t, conn)
+	write(t, conn, gchad.ErrorMessage{Code: protocol.CodeBusy, Message: "the server is busy", Request: protocol.Chat, RequestId: readRequestId(t, conn)})
+
+	_, err = wait(t, second)
+	var rejection gchad.ErrorMessage
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, protocol.CodeBusy, rejection.Code)
+	// the other message is still waiting for its answer
+	select {
+	case <-first.Done():
+		t.Fatal("the first message was settled by the answer to the second")
+	default:
+	}
+}
+
+func TestClient_Post_ServerWithoutAcks(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+
+	delivery, err := client.Post(context.Background(), "hello")
+	require.NoError(t, err)
+
+	assert.Empty(t, readRequestId(t, conn))
+	ack, err := wait(t, delivery)
+	assert.NoError(t, err)
+	assert.Equal(t, gchad.AckMessage{}, ack)
+}
+
+func TestClient_Post_ConnectionLost(t *testing.T) {
+	server := NewFakeServer(t)
+	withAcks(server)
+	client := dial(t, server, gchad.WithReconnect(gchad.NeverReconnect))
+	conn := server.Accept(t)
+
+	delivery, err := client.Post(context.Background(), "hello")
+	require.NoError(t, err)
+	readRequestId(t, conn)
+	conn.Close()
+
+	_, err = wait(t, delivery)
+	assert.ErrorIs(t, err, gchad.ErrNotAcknowledged)
+	<-client.Done()
+	_, err = client.Post(context.Background(), "too late")
+	assert.ErrorIs(t, err, gchad.ErrClosed)
+}
+
+func TestClient_SkipsUnknownMessages(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+	events := client.Subscribe()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"from_the_future","payload":{}}`)))
+	write(t, conn, gchad.ChatMessage{From: "carol", Text: "hi"})
+
+	assert.Equal(t, gchad.ChatMessage{From: "carol", Text: "hi"}, next(t, events))
+}
+
+func TestClient_TracksRenames(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server)
+	conn := server.Accept(t)
+	events := client.Subscribe(gchad.TypeUserRenamed)
+
+	write(t, conn, gchad.UserRenamedMessage{OldName: "bot", NewName: "ci-bot", Version: 2})
+	next(t, events)
+
+	assert.Equal(t, "ci-bot", client.Name())
+}
+
+func TestClient_Reconnects(t *testing.T) {
+	server := NewFakeServer(t)
+	events := gchad.NewSubscription(gchad.TypeDisconnected, gchad.TypeReconnected)
+	client := dial(t, server,
+		gchad.WithReconnect(gchad.ReconnectPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		gchad.WithSubscription(events),
+	)
+	server.Accept(t).Close()
+
+	disconnected := next(t, events).(gchad.Disconnected)
+	assert.True(t, disconnected.Reconnecting)
+	assert.Error(t, disconnected.Err)
+	reconnected := next(t, events).(gchad.Reconnected)
+	assert.Equal(t, 1, reconnected.Attempts)
+
+	conn := server.Accept(t)
+	require.NoError(t, client.Send(context.Background(), "back"))
+	_, text := read(t, conn)
+	assert.Equal(t, "back", text)
+}
+
+func TestClient_GivesUp(t *testing.T) {
+	server := NewFakeServer(t)
+	client := dial(t, server, gchad.WithReconnect(gchad.NeverReconnect))
+
+	server.Accept(t).Close()
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the client is still running")
+	}
+	assert.Error(t, client.Err())
+	assert.ErrorIs(t, client.Send(context.Background(), "anyone?"), gchad.ErrClosed)
+}
+
+func TestReconnectPolicy_Delay(t *testing.T) {
+	policy := gchad.ReconnectPolicy{MaxAttempts: 10, MinDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 4*time.Second, policy.Delay(3))
+	assert.Equal(t, 5*time.Second, policy.Delay(4))
+	assert.Equal(t, 5*time.Second, policy.Delay(10))
+}
@@ -0,0 +1,44 @@
+package gchad_test
+
+import (
+	"testing"
+
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembers_IgnoresDeltasUntilSnapshot(t *testing.T) {
+	members := gchad.NewMembers()
+
+	assert.NoError(t, members.Join("Jane", gchad.RoleMember, false, 1))
+	assert.Equal(t, 0, members.Len())
+
+	members.Replace([]gchad.Member{{Name: "Jane", Role: gchad.RoleMember, Presence: gchad.PresenceOnline}}, 1)
+
+	// already part of the snapshot
+	assert.NoError(t, members.Join("Jane", gchad.RoleMember, false, 1))
+	assert.NoError(t, members.Join("John", gchad.RoleModerator, false, 2))
+	assert.Equal(t, []gchad.Member{
+		{Name: "Jane", Role: gchad.RoleMember, Presence: gchad.PresenceOnline},
+		{Name: "John", Role: gchad.RoleModerator, Presence: gchad.PresenceOnline},
+	}, members.List())
+}
+
+func TestMembers_GapRequiresSnapshot(t *testing.T) {
+	members := gchad.NewMembers()
+	members.Replace([]gchad.Member{{Name: "Jane", Role: gchad.RoleMember, Presence: gchad.PresenceOnline}}, 4)
+
+	err := members.Leave("Jane", 6)
+
+	assert.ErrorIs(t, err, gchad.ErrRoomStateGap)
+	assert.Equal(t, 1, members.Len())
+
+	// nothing is applied until a fresh snapshot arrives
+	assert.NoError(t, members.Rename("Jane", "Janet", 7))
+	assert.Equal(t, "Jane", members.List()[0].Name)
+
+	members.Replace([]gchad.Member{}, 7)
+	assert.NoError(t, members.UpdatePresence("Janet", gchad.PresenceAway, "", 7))
+	assert.Equal(t, 0, members.Len())
+	assert.Equal(t, uint64(7), members.Version())
+}
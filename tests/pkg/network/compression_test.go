@@ -0,0 +1,200 @@
+package network_test
+
+import (
+	"compress/flate"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, map[string]any) {}
+func (nopLogger) Info(string, map[string]any)  {}
+func (nopLogger) Error(string, map[string]any) {}
+
+func TestCompression_Validate(t *testing.T) {
+	tests := []struct {
+		level int
+		valid bool
+	}{
+		{flate.HuffmanOnly, true},
+		{flate.BestSpeed, true},
+		{flate.BestCompression, true},
+		{flate.HuffmanOnly - 1, false},
+		{flate.BestCompression + 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.level), func(t *testing.T) {
+			err := network.Compression{Level: tt.level}.Validate()
+
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, network.ErrInvalidCompressionLevel)
+			}
+		})
+	}
+}
+
+func TestCompression_Compresses(t *testing.T) {
+	compression := network.Compression{Level: flate.BestSpeed, Threshold: 256}
+
+	assert.False(t, compression.Compresses(255))
+	assert.True(t, compression.Compresses(256))
+	assert.True(t, network.Compression{}.Compresses(0))
+}
+
+// countingListener counts the bytes the server writes to its connections
+type countingListener struct {
+	net.Listener
+	written *atomic.Int64
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	return countingConn{conn, l.written}, err
+}
+
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// wireSize has the server write the message over a connection negotiated with or without
+// permessage-deflate, returning the bytes it took on the wire
+func wireSize(t testing.TB, deflate bool, compression network.Compression, data []byte) int {
+	var written atomic.Int64
+	sizes := make(chan int64, 1)
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(sizes)
+		wsConn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn := network.NewWebsocketsConnection(wsConn, nopLogger{})
+		defer conn.Close()
+
+		if err := conn.SetCompression(compression); err != nil {
+			return
+		}
+		before := written.Load()
+		if err := conn.WriteBinaryMessage(data); err != nil {
+			return
+		}
+		sizes <- written.Load() - before
+		// the client closes once it has read the message
+		conn.ReadMessage()
+	}))
+	server.Listener = countingListener{server.Listener, &written}
+	server.Start()
+	defer server.Close()
+
+	dialer := websocket.Dialer{EnableCompression: deflate, HandshakeTimeout: time.Second}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, data, msg)
+	size, ok := <-sizes
+	require.True(t, ok, "the server failed to write the message")
+
+	return int(size)
+}
+
+// backfill is a page of history as a client scrolling back gets it
+func backfill(messages int) *domain.SearchResultsSystemMessage {
+	timestamp := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+	page := domain.SearchPage{Total: messages * 4}
+	for i := range messages {
+		msg := domain.NewUserMessage(
+			fmt.Sprintf("build #%d of main failed again on the integration tests, see the attachment", i),
+			timestamp.Add(time.Duration(i)*time.Minute),
+			[]string{"jane", "bob", "alice"}[i%3],
+		)
+		msg.Id = fmt.Sprint(1000 + i)
+		msg.Attachments = []domain.Attachment{{Title: fmt.Sprintf("build #%d", i), Url: fmt.Sprintf("https://ci.example/%d", i)}}
+		page.Results = append(page.Results, *msg)
+	}
+	request := &domain.SearchMessage{Terms: "build failed", Limit: messages}
+
+	return domain.NewSearchResultsSystemMessage(request, request.Query(), page)
+}
+
+func TestWebsocketsConnection_CompressesBackfill(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			data, err := domain.NewCodec(subprotocol).Marshal(backfill(50))
+			require.NoError(t, err)
+
+			plain := wireSize(t, false, network.DefaultCompression, data)
+			compressed := wireSize(t, true, network.DefaultCompression, data)
+
+			t.Logf("%d bytes of %s: %d on the wire as they are, %d compressed", len(data), subprotocol, plain, compressed)
+			assert.Less(t, compressed, plain/3)
+		})
+	}
+}
+
+func TestWebsocketsConnection_UnderThreshold(t *testing.T) {
+	data := []byte(strings.Repeat("a", 120))
+
+	size := wireSize(t, true, network.Compression{Level: flate.BestCompression, Threshold: 256}, data)
+
+	// the two bytes of the frame header of a message going out as it is
+	assert.Equal(t, len(data)+2, size)
+}
+
+// The benchmark reports how many bytes a page of history costs on the wire:
+//
+//	go test ./tests/pkg/network -bench Backfill
+func BenchmarkWebsocketsConnection_Backfill(b *testing.B) {
+	compressions := []struct {
+		name        string
+		deflate     bool
+		compression network.Compression
+	}{
+		{"plain", false, network.DefaultCompression},
+		{"best_speed", true, network.DefaultCompression},
+		{"best_compression", true, network.Compression{Level: flate.BestCompression, Threshold: 256}},
+	}
+
+	for _, subprotocol := range protocol.Subprotocols() {
+		data, err := domain.NewCodec(subprotocol).Marshal(backfill(50))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, c := range compressions {
+			b.Run(subprotocol+"/"+c.name, func(b *testing.B) {
+				var size int
+				for b.Loop() {
+					size = wireSize(b, c.deflate, c.compression, data)
+				}
+				b.ReportMetric(float64(size), "wire_bytes/page")
+				b.ReportMetric(float64(len(data)), "bytes/page")
+			})
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package network_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iomallach/gchad/pkg/network"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialSSE connects to a server handing the server end of every stream it opens to the channel,
+// the stream lasting until that end is closed
+func dialSSE(t *testing.T) (*network.SSEClientConnection, *network.SSEConnection) {
+	var mu sync.Mutex
+	streams := make(map[string]*network.SSEConnection)
+	opened := make(chan *network.SSEConnection, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		conn := network.NewSSEConnection(w, r, "session", nopLogger{})
+		defer conn.Close()
+		mu.Lock()
+		streams[conn.Session()] = conn
+		mu.Unlock()
+
+		if err := conn.Open(); err != nil {
+			return
+		}
+		opened <- conn
+		<-conn.Done()
+	})
+	mux.HandleFunc("POST /messages", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conn, ok := streams[r.URL.Query().Get("session")]
+		mu.Unlock()
+		if !ok {
+			http.Error(w, "no such session", http.StatusNotFound)
+			return
+		}
+
+		err := conn.Receive(r.Context(), r.URL.Query().Get("control"), r.Body)
+		switch {
+		case errors.Is(err, network.ErrMessageTooLarge):
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case err != nil:
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := network.DialSSE(context.Background(), server.Client(), server.URL+"/events", server.URL+"/messages", nopLogger{})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	select {
+	case conn := <-opened:
+		t.Cleanup(func() { conn.Close() })
+		assert.Equal(t, conn.Session(), client.Session())
+		return client, conn
+	case <-time.After(time.Second):
+		t.Fatal("the stream was never opened")
+		return nil, nil
+	}
+}
+
+func readSoon(t *testing.T, conn network.Connection) (int, []byte, error) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	return conn.ReadMessage()
+}
+
+func TestSSE_ExchangesMessages(t *testing.T) {
+	client, server := dialSSE(t)
+
+	assert.Equal(t, protocol.SubprotocolJSON, client.Subprotocol())
+	assert.Equal(t, protocol.SubprotocolJSON, server.Subprotocol())
+
+	require.NoError(t, server.WriteTextMessage([]byte("{\"type\":\"chat\",\n\"text\":\"hi\"}")))
+	kind, data, err := readSoon(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, kind)
+	assert.Equal(t, "{\"type\":\"chat\",\n\"text\":\"hi\"}", string(data))
+
+	require.NoError(t, client.WriteTextMessage([]byte("first")))
+	require.NoError(t, client.WriteTextMessage([]byte("second")))
+	for _, want := range []string{"first", "second"} {
+		kind, data, err := readSoon(t, server)
+		require.NoError(t, err)
+		assert.Equal(t, websocket.TextMessage, kind)
+		assert.Equal(t, want, string(data))
+	}
+
+	assert.ErrorIs(t, client.WriteBinaryMessage([]byte{1}), network.ErrBinaryMessage)
+	assert.ErrorIs(t, server.WriteBinaryMessage([]byte{1}), network.ErrBinaryMessage)
+}
+
+func TestSSE_PingsAndPongs(t *testing.T) {
+	client, server := dialSSE(t)
+
+	pinged := make(chan string, 1)
+	client.SetPingHandler(func(data string) error {
+		pinged <- data
+		return client.WritePongMessage([]byte(data))
+	})
+	ponged := make(chan string, 1)
+	server.SetPongHandler(func(data string) error {
+		ponged <- data
+		return nil
+	})
+
+	require.NoError(t, server.WritePingMessage([]byte("1")))
+	require.NoError(t, server.WriteTextMessage([]byte("after the ping")))
+	_, data, err := readSoon(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, "after the ping", string(data))
+	assert.Equal(t, "1", <-pinged)
+	assert.Equal(t, "1", <-ponged)
+}
+
+func TestSSE_ClosesWithTheCode(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{websocket.CloseNormalClosure, network.ErrConnectionClosedNormally},
+		{websocket.CloseProtocolError, network.ErrConnectionClosedAbnormally},
+	}
+
+	for _, tt := range tests {
+		client, server := dialSSE(t)
+
+		require.NoError(t, server.WriteCloseMessage(websocket.FormatCloseMessage(tt.code, "bye")))
+		_, _, err := readSoon(t, client)
+		assert.ErrorIs(t, err, tt.want, "code %d", tt.code)
+	}
+}
+
+func TestSSE_ClientCloses(t *testing.T) {
+	client, server := dialSSE(t)
+
+	require.NoError(t, client.WriteCloseMessage(nil))
+	_, _, err := readSoon(t, server)
+	assert.ErrorIs(t, err, network.ErrConnectionClosedNormally)
+
+	// the server is done with the stream, which ends
+	_, _, err = readSoon(t, client)
+	assert.ErrorIs(t, err, network.ErrConnectionClosedAbnormally)
+	assert.ErrorIs(t, server.WriteTextMessage([]byte("late")), network.ErrWriteAfterClose)
+	assert.ErrorIs(t, client.WriteTextMessage([]byte("late")), network.ErrWriteAfterClose)
+}
+
+func TestSSE_MessageTooLarge(t *testing.T) {
+	client, server := dialSSE(t)
+	server.SetReadLimit(8)
+
+	assert.Error(t, client.WriteTextMessage([]byte("longer than eight bytes")))
+	_, _, err := readSoon(t, server)
+	assert.ErrorIs(t, err, network.ErrMessageTooLarge)
+}
+
+func TestSSE_ReadDeadline(t *testing.T) {
+	client, server := dialSSE(t)
+
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+	_, _, err := client.ReadMessage()
+	assert.ErrorIs(t, err, network.ErrReadTimeOut)
+
+	// moving the deadline applies to the read waiting on it
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(time.Hour)))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		server.SetReadDeadline(time.Now())
+	}()
+	_, _, err = server.ReadMessage()
+	assert.ErrorIs(t, err, network.ErrReadTimeOut)
+}
+
+func TestDialSSE_BadHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such room: lobby", http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := network.DialSSE(context.Background(), server.Client(), server.URL+"/events", server.URL+"/messages", nopLogger{})
+	assert.ErrorIs(t, err, network.ErrBadSSEHandshake)
+	assert.ErrorContains(t, err, "no such room: lobby")
+}
@@ -0,0 +1,189 @@
+package protocol_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iomallach/gchad/internal/server/domain"
+	"github.com/iomallach/gchad/pkg/gchad"
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var timestamp = time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+
+func chatMessage() *domain.UserMessage {
+	msg := domain.NewUserMessage("@bob the build of main failed again, see the attachment", timestamp, "jane")
+	msg.Id = "42"
+	msg.Mentions = []domain.Mention{{Name: "bob", Start: 0, End: 4}}
+	msg.Attachments = []domain.Attachment{{Title: "build #12", Url: "https://ci.example/12"}}
+
+	return msg
+}
+
+func memberList(members int) *domain.MemberListSystemMessage {
+	state := domain.RoomState{Version: 7}
+	for i := range members {
+		state.Members = append(state.Members, domain.Member{
+			Name:     fmt.Sprintf("member-%d", i),
+			Role:     domain.RoleMember,
+			Presence: domain.PresenceOnline,
+		})
+	}
+
+	return domain.NewMemberListSystemMessage(state)
+}
+
+func TestCodecs_ServerToClient(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			data, err := domain.NewCodec(subprotocol).Marshal(chatMessage())
+			require.NoError(t, err)
+
+			msg, err := gchad.NewCodec(subprotocol).Unmarshal(data)
+			require.NoError(t, err)
+
+			chat := msg.(gchad.ChatMessage)
+			assert.True(t, timestamp.Equal(chat.Timestamp))
+			chat.Timestamp = timestamp
+			assert.Equal(t, gchad.ChatMessage{
+				Id:          "42",
+				From:        "jane",
+				Timestamp:   timestamp,
+				Text:        "@bob the build of main failed again, see the attachment",
+				Mentions:    []gchad.Mention{{Name: "bob", Start: 0, End: 4}},
+				Attachments: []gchad.Attachment{{Title: "build #12", Url: "https://ci.example/12"}},
+			}, chat)
+		})
+	}
+}
+
+func TestCodecs_Rejection(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			data, err := domain.NewCodec(subprotocol).Marshal(domain.NewErrorSystemMessage(protocol.Chat, protocol.CodeTextTooLong, domain.ErrTextTooLong.Error()))
+			require.NoError(t, err)
+
+			msg, err := gchad.NewCodec(subprotocol).Unmarshal(data)
+
+			require.NoError(t, err)
+			assert.Equal(t, gchad.ErrorMessage{
+				Code:    protocol.CodeTextTooLong,
+				Message: "the text is longer than 4000 characters",
+				Request: protocol.Chat,
+			}, msg)
+		})
+	}
+}
+
+func TestCodecs_RequestId(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			codec := gchad.NewCodec(subprotocol)
+			withId, err := codec.MarshalRequest(gchad.ChatMessage{Text: "hello"}, "17")
+			require.NoError(t, err)
+			withoutId, err := codec.Marshal(gchad.ChatMessage{Text: "hello"})
+			require.NoError(t, err)
+
+			msg, requestId, err := domain.NewCodec(subprotocol).UnmarshalRequest(withId)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", msg.(*domain.UserMessage).Text)
+			assert.Equal(t, "17", requestId)
+
+			msg, requestId, err = domain.NewCodec(subprotocol).UnmarshalRequest(withoutId)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", msg.(*domain.UserMessage).Text)
+			assert.Empty(t, requestId)
+		})
+	}
+}
+
+func TestCodecs_Ack(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			data, err := domain.NewCodec(subprotocol).Marshal(domain.NewAckSystemMessage("17", "42", 3))
+			require.NoError(t, err)
+
+			msg, err := gchad.NewCodec(subprotocol).Unmarshal(data)
+
+			require.NoError(t, err)
+			assert.Equal(t, gchad.AckMessage{RequestId: "17", Id: "42", Sequence: 3}, msg)
+		})
+	}
+}
+
+func TestCodecs_ClientToServer(t *testing.T) {
+	before := timestamp
+	request := gchad.SearchMessage{Terms: "build failed", From: "jane", Before: &before, Limit: 20}
+
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			data, err := gchad.NewCodec(subprotocol).Marshal(request)
+			require.NoError(t, err)
+
+			msg, err := domain.NewCodec(subprotocol).Unmarshal(data)
+			require.NoError(t, err)
+
+			search := msg.(*domain.SearchMessage)
+			assert.Equal(t, "build failed", search.Terms)
+			assert.Equal(t, "jane", search.From)
+			require.NotNil(t, search.Before)
+			assert.True(t, timestamp.Equal(*search.Before))
+			assert.Equal(t, 0, search.Offset)
+			assert.Equal(t, 20, search.Limit)
+		})
+	}
+}
+
+// The benchmarks report the size of a frame next to the time it takes to write and read it:
+//
+//	go test ./tests/pkg/protocol -bench Codec -benchmem
+var benchmarked = []struct {
+	name string
+	msg  domain.Messager
+}{
+	{"chat", chatMessage()},
+	{"presence", domain.NewPresenceSystemMessage("jane", domain.PresenceAway, "lunch", 12, timestamp)},
+	{"member_list", memberList(50)},
+}
+
+func BenchmarkCodec_Marshal(b *testing.B) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		codec := domain.NewCodec(subprotocol)
+		for _, bm := range benchmarked {
+			b.Run(subprotocol+"/"+bm.name, func(b *testing.B) {
+				var size int
+				for b.Loop() {
+					data, err := codec.Marshal(bm.msg)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = len(data)
+				}
+				b.ReportMetric(float64(size), "bytes/msg")
+			})
+		}
+	}
+}
+
+func BenchmarkCodec_Unmarshal(b *testing.B) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		codec := domain.NewCodec(subprotocol)
+		for _, bm := range benchmarked {
+			data, err := codec.Marshal(bm.msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(subprotocol+"/"+bm.name, func(b *testing.B) {
+				for b.Loop() {
+					if _, err := codec.Unmarshal(data); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(data)), "bytes/msg")
+			})
+		}
+	}
+}
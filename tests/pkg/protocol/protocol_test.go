@@ -0,0 +1,144 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/iomallach/gchad/pkg/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		hello    protocol.HelloMessage
+		expected protocol.Session
+	}{
+		{
+			"same version",
+			protocol.HelloMessage{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilityWebhooks, protocol.CapabilitySearch}},
+			protocol.Session{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilityWebhooks, protocol.CapabilitySearch}},
+		},
+		{
+			"later client",
+			protocol.HelloMessage{Version: protocol.Version + 1, Capabilities: []protocol.Capability{"teleport", protocol.CapabilitySearch}},
+			protocol.Session{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilitySearch}},
+		},
+		{
+			"older client",
+			protocol.HelloMessage{Version: protocol.MinVersion},
+			protocol.Session{Version: protocol.MinVersion},
+		},
+		{
+			"repeated capability",
+			protocol.HelloMessage{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilitySearch, protocol.CapabilitySearch}},
+			protocol.Session{Version: protocol.Version, Capabilities: []protocol.Capability{protocol.CapabilitySearch}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := protocol.Negotiate(tt.hello, protocol.Capabilities())
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, session)
+		})
+	}
+}
+
+func TestNegotiate_UnsupportedVersion(t *testing.T) {
+	_, err := protocol.Negotiate(protocol.HelloMessage{Version: protocol.MinVersion - 1}, protocol.Capabilities())
+
+	assert.ErrorIs(t, err, protocol.ErrUnsupportedVersion)
+}
+
+var decoders = protocol.Decoders[protocol.Message]{
+	protocol.Hello: func(payload protocol.Payload) (protocol.Message, error) {
+		var msg protocol.HelloMessage
+		err := payload.Decode(&msg)
+		return msg, err
+	},
+}
+
+func TestNewCodec(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		expected    string
+		binary      bool
+	}{
+		{"", protocol.SubprotocolJSON, false},
+		{protocol.SubprotocolJSON, protocol.SubprotocolJSON, false},
+		{protocol.SubprotocolMsgPack, protocol.SubprotocolMsgPack, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subprotocol, func(t *testing.T) {
+			codec := protocol.NewCodec(tt.subprotocol, decoders)
+
+			assert.Equal(t, tt.expected, codec.Subprotocol())
+			assert.Equal(t, tt.binary, codec.Binary())
+		})
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	hello := protocol.HelloMessage{Version: 2, Capabilities: []protocol.Capability{protocol.CapabilitySearch}}
+
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			codec := protocol.NewCodec(subprotocol, decoders)
+
+			data, err := codec.Marshal(hello)
+			require.NoError(t, err)
+			msg, err := codec.Unmarshal(data)
+
+			require.NoError(t, err)
+			assert.Equal(t, hello, msg)
+		})
+	}
+}
+
+func TestCodec_JSONFrames(t *testing.T) {
+	codec := protocol.NewCodec(protocol.SubprotocolJSON, decoders)
+
+	data, err := codec.Marshal(protocol.HelloMessage{Version: 2, Capabilities: []protocol.Capability{protocol.CapabilitySearch}})
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"hello","payload":{"version":2,"capabilities":["search"]}}`, string(data))
+}
+
+func TestCodec_UnknownType(t *testing.T) {
+	for _, subprotocol := range protocol.Subprotocols() {
+		t.Run(subprotocol, func(t *testing.T) {
+			codec := protocol.NewCodec(subprotocol, decoders)
+			data, err := codec.Marshal(protocol.WelcomeMessage{Version: 2})
+			require.NoError(t, err)
+
+			_, err = codec.Unmarshal(data)
+
+			assert.ErrorIs(t, err, protocol.ErrUnknownType)
+		})
+	}
+}
+
+func TestCodec_Invalid(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		data        []byte
+	}{
+		{protocol.SubprotocolJSON, []byte(`{"type":"hello","payload":{"version":"two"}}`)},
+		{protocol.SubprotocolJSON, []byte(`{invalid json`)},
+		{protocol.SubprotocolMsgPack, []byte(`{"type":"hello","payload":{}}`)},
+		// an array of the type alone
+		{protocol.SubprotocolMsgPack, []byte{0x91, 0xa5, 'h', 'e', 'l', 'l', 'o'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subprotocol, func(t *testing.T) {
+			_, err := protocol.NewCodec(tt.subprotocol, decoders).Unmarshal(tt.data)
+
+			assert.Error(t, err)
+			assert.NotErrorIs(t, err, protocol.ErrUnknownType)
+		})
+	}
+}